@@ -3,19 +3,32 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"iter"
 	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime/debug"
+	"slices"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/hashicorp/go-retryablehttp"
 
 	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/config"
 	"github.com/xtruder/ffbookmarks-to-markdown/internal/firefox"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/hooks"
 	"github.com/xtruder/ffbookmarks-to-markdown/internal/llm"
 	"github.com/xtruder/ffbookmarks-to-markdown/internal/markdown"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/sources"
 	"github.com/xtruder/ffbookmarks-to-markdown/internal/web"
 	"github.com/xtruder/ffbookmarks-to-markdown/internal/x"
 )
@@ -24,91 +37,881 @@ var (
 	// Command line flags
 	baseFolder    string
 	outputDir     string
-	listBookmarks bool
+	listBookmarks listMode
+	unreadDays    int
+	readStats     bool
 	verbose       bool
 	ignoreFolders string
 	screenshotAPI string
+	screenshotVer string
+	screenshotW   int
+	screenshotH   int
+	screenshotFP  bool
+	shotToken     string
+	shotBasicAuth string
+	waitShots     time.Duration
+	llmProvider   string
 	llmAPIKey     string
 	llmBaseURL    string
 	llmModel      string
+	inlineFields  string
+	hostLimit     int
+	doctor        bool
+	heal          bool
+	refresh       string
+	refreshAll    bool
+	recapture     time.Duration
+	fix           bool
+	nameCollision string
+	configFile    string
+	printConfig   bool
+	strictHooks   bool
+	llmSources    string
+	llmPromptDir  string
+	llmPromptFile string
+	llmSystemFile string
+	llmMinLength  int
+	llmPhase      string
+	llmWorkers    int
+	llmTemp       float64
+	llmMaxTokens  int
+	llmChunkSize  int
+	llmSummary    bool
+	noLLMTags     bool
+	linkSafeNames bool
+	retryAfterMax time.Duration
+	cacheTTL      time.Duration
+	maxContent    int64
+	githubToken   string
+	noTriage      bool
+	pinTag        string
+	related       relatedMode
+	noStatusNote  bool
+	prune         bool
+	archiveDel    bool
+	clearCache    bool
+	clearCacheURL string
+	renameOnTitle bool
+	renameStubs   bool
+	breakerLimit  int
+	breakerWindow time.Duration
+	breakerCool   time.Duration
+	slugs         bool
+	backfill      bool
+	inclDeleted   bool
+	overridesDir  string
+	maxPathLength int
+	minYear       int
+	maxYear       int
+	privacyReport bool
+	allowedHosts  string
+	subfolder     string
+	order         string
+	looseDir      string
+	fragmentMode  string
+	source        string
+	ffsclientPath string
+	deterministic bool
+	excerpt       bool
+	clippingsDir  string
+	rewriteClips  bool
+	ffsTimeout    time.Duration
+	dedupe        bool
+	dedupeStubs   bool
+	ffsSession    string
+	licenseMeta   bool
+	quiet         bool
+	expandLists   string
+	expandMax     int
+	folderIndexes bool
+	folderSort    string
+	mode          string
+	dryRun        dryRunMode
+	planOut       string
 )
 
+// Exit codes
+const (
+	exitOK          = 0
+	exitFatal       = 1 // configuration or setup error
+	exitPartial     = 2 // some bookmarks failed
+	exitInterrupted = 3 // stopped by a signal
+	exitRetry       = 4 // some bookmarks were deferred for reasons likely to pass on a later run
+)
+
+// Sync modes
+const (
+	modeSync   = "sync"
+	modeMirror = "mirror"
+)
+
+// dryRunMode is the -dry-run flag, which can be given without a value to
+// plan without fetching
+type dryRunMode string
+
+func (m *dryRunMode) String() string { return string(*m) }
+
+// IsBoolFlag lets -dry-run be given without a value
+func (m *dryRunMode) IsBoolFlag() bool { return true }
+
+func (m *dryRunMode) Set(value string) error {
+	switch value {
+	case "true", markdown.DryRunPlan:
+		*m = markdown.DryRunPlan
+	case "false":
+		*m = ""
+	case markdown.DryRunFetch:
+		*m = markdown.DryRunFetch
+	default:
+		return fmt.Errorf("unknown dry-run mode '%s' (plan, fetch)", value)
+	}
+	return nil
+}
+
+// Bookmark lists printed by -list
+const (
+	listAll     = "all"
+	listFolders = "folders"
+	listUnread  = "unread"
+)
+
+// listMode is the -list flag, which can be given without a value to list all
+// bookmarks
+type listMode string
+
+func (m *listMode) String() string { return string(*m) }
+
+// IsBoolFlag lets -list be given without a value
+func (m *listMode) IsBoolFlag() bool { return true }
+
+func (m *listMode) Set(value string) error {
+	switch value {
+	case "true", listAll:
+		*m = listAll
+	case "false":
+		*m = ""
+	case listFolders, listUnread:
+		*m = listMode(value)
+	default:
+		return fmt.Errorf("unknown list '%s' (all, folders, unread)", value)
+	}
+	return nil
+}
+
+// relatedMode is the -related flag, which can be given without a value to
+// link notes by URL mentions only
+type relatedMode string
+
+func (m *relatedMode) String() string { return string(*m) }
+
+// IsBoolFlag lets -related be given without a value
+func (m *relatedMode) IsBoolFlag() bool { return true }
+
+func (m *relatedMode) Set(value string) error {
+	switch value {
+	case "true", markdown.RelatedURLs:
+		*m = markdown.RelatedURLs
+	case "false":
+		*m = ""
+	case markdown.RelatedTitles:
+		*m = markdown.RelatedTitles
+	default:
+		return fmt.Errorf("unknown related mode '%s' (urls, titles)", value)
+	}
+	return nil
+}
+
+// sourceShorthands maps the deprecated flags that read bookmarks from a file
+// to the -source kind they are a shorthand for
+var sourceShorthands = map[string]string{
+	"input":          sources.KindHTML,
+	"bookmarks-file": sources.KindBackup,
+}
+
+// LLM cleaning phases
+const (
+	llmPhaseInline = "inline"
+	llmPhaseBatch  = "batch"
+)
+
+// converterURL is the markdown converter service used for generic pages
+const converterURL = "https://md.dhr.wtf"
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfigCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "cache" {
+		os.Exit(runCacheCommand(os.Args[2:]))
+	}
+
+	// apply syncs with the arguments of a plan written by -plan-out, only
+	// making its changes
+	var appliedPlan *markdown.Plan
+	if len(os.Args) > 1 && os.Args[1] == "apply" {
+		plan, err := readApplyPlan(os.Args[2:])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(exitFatal)
+		}
+		appliedPlan = plan
+		os.Args = append([]string{os.Args[0]}, plan.Args...)
+	}
+
 	// Define command line flags
-	flag.StringVar(&baseFolder, "folder", "toolbar", "Base folder name to sync from Firefox bookmarks")
-	flag.StringVar(&outputDir, "output", "bookmarks", "Output directory for markdown files")
-	flag.BoolVar(&listBookmarks, "list", false, "List all available bookmarks")
+	flag.StringVar(&baseFolder, "folder", "toolbar", "Comma-separated list of base folders to sync from Firefox bookmarks")
+	flag.StringVar(&outputDir, "output", "bookmarks", "Output directory for markdown files, or a .zip archive")
+	flag.Var(&listBookmarks, "list", "List bookmarks and exit: all lists available bookmarks, folders lists folder paths for -folder, unread lists notes older than -unread-days without read_at (-list means all)")
+	flag.IntVar(&unreadDays, "unread-days", 30, "With -list=unread, only list notes of bookmarks added at least this many days ago")
+	flag.BoolVar(&readStats, "read-stats", false, "Add read_at columns and read counts to the year and inbox indexes")
 	flag.BoolVar(&verbose, "verbose", false, "Enable verbose logging")
+	flag.BoolVar(&quiet, "quiet", false, "Only log warnings and errors")
 	flag.StringVar(&ignoreFolders, "ignore", "", "Comma-separated list of folder names to ignore")
 	flag.StringVar(&screenshotAPI, "screenshot-api", "", "Screenshot API base URL")
+	flag.StringVar(&screenshotVer, "screenshot-api-version", web.ScreenshotAPIAuto, "Screenshot API version (auto, v2, v3)")
+	flag.IntVar(&screenshotW, "screenshot-width", 0, "Screenshot viewport width (0 = server default)")
+	flag.IntVar(&screenshotH, "screenshot-height", 0, "Screenshot viewport height (0 = server default)")
+	flag.BoolVar(&screenshotFP, "screenshot-fullpage", false, "Capture full page screenshots")
+	flag.StringVar(&shotToken, "screenshot-token", "", "Bearer token for the screenshot API (default: $SCREENSHOT_API_TOKEN)")
+	flag.StringVar(&shotBasicAuth, "screenshot-basic-auth", "", "Basic auth credentials for the screenshot API as user:password")
+	flag.DurationVar(&waitShots, "wait-screenshots", 0, "Wait up to this duration for new screenshots and only embed the ones taken (0 = don't wait)")
+	flag.StringVar(&llmProvider, "llm-provider", llm.ProviderOpenAI, "LLM API provider (openai for OpenAI compatible APIs, anthropic)")
 	flag.StringVar(&llmAPIKey, "llm-key", "", "API key for LLM service")
 	flag.StringVar(&llmBaseURL, "llm-url", "https://generativelanguage.googleapis.com/v1beta/openai/", "Base URL for LLM service")
 	flag.StringVar(&llmModel, "llm-model", "gemini-2.0-flash", "Model to use for LLM service")
+	flag.StringVar(&llmPhase, "llm-phase", llmPhaseInline, "When to clean content with LLM: inline after each fetch, or batch after fetching all content")
+	flag.Float64Var(&llmTemp, "llm-temperature", llm.DefaultTemperature, "Sampling temperature of LLM requests (0 to 2, up to 1 for anthropic)")
+	flag.IntVar(&llmMaxTokens, "llm-max-tokens", 0, "Maximum number of tokens in LLM responses (0 = provider default)")
+	flag.IntVar(&llmChunkSize, "llm-chunk-size", llm.DefaultChunkSize, fmt.Sprintf("Content length in bytes above which content is cleaned with LLM in chunks (0 = never split, otherwise at least %d)", llm.MinChunkSize))
+	flag.BoolVar(&noLLMTags, "no-llm-tags", false, "Don't add LLM suggested topical tags to new notes (one extra LLM request per note)")
+	flag.BoolVar(&llmSummary, "llm-summary", false, "Write an LLM summary of new notes into the description frontmatter field (one extra LLM request per note)")
+	flag.IntVar(&llmWorkers, "llm-concurrency", 4, "Number of parallel LLM cleaning requests with -llm-phase batch")
+	flag.IntVar(&llmMinLength, "llm-min-length", 0, "Minimum content length in bytes to clean with LLM, shorter content is kept as is")
+	flag.StringVar(&llmPromptDir, "llm-prompt-dir", "", "Directory with custom LLM cleaning prompts (article.md, readme.md, discussion.md) and system message (system.md)")
+	flag.StringVar(&llmPromptFile, "llm-prompt-file", "", "File with an LLM cleaning prompt for all content, %s marks where the content goes (default: $LLM_PROMPT_FILE)")
+	flag.StringVar(&llmSystemFile, "llm-system-file", "", "File with the LLM system message of cleaning requests (default: $LLM_SYSTEM_FILE)")
+	flag.StringVar(&llmSources, "llm-sources", web.SourceGeneric, "Comma-separated list of content sources to clean with LLM (generic,github,youtube)")
+	flag.StringVar(&inlineFields, "inline-fields", "", "Comma-separated list of fields to emit as Dataview inline fields (url,path,created,tags)")
+	flag.IntVar(&hostLimit, "concurrency-per-host", 0, "Maximum number of simultaneous requests to a single host (0 = unlimited)")
+	flag.BoolVar(&doctor, "doctor", false, "Report problems with existing notes and exit")
+	flag.BoolVar(&fix, "fix", false, "With -doctor, keep the newer of each sync conflict copy and its note and move the other to _conflicts/")
+	flag.BoolVar(&noTriage, "no-triage", false, "Don't mark new notes with status: inbox or write the Inbox.md index")
+	flag.StringVar(&pinTag, "pin-tag", "", "Firefox tag marking bookmarks to pin, e.g. ★; pinned notes get pinned: true and are listed first in indexes")
+	flag.Var(&related, "related", "Link notes mentioning each other's URL with a Related footer and related frontmatter: urls, or titles to also link the only two notes sharing a rare title word (-related means urls)")
+	flag.BoolVar(&noStatusNote, "no-status-note", false, "Don't write the _status.md note summarizing the run")
+	flag.BoolVar(&heal, "heal", false, "Refetch content for notes that only contain their title")
+	flag.StringVar(&refresh, "refresh", "", "Comma-separated list of bookmark URLs or IDs whose notes are refetched and cleaned again, bypassing all caches")
+	flag.BoolVar(&refreshAll, "refresh-all", false, "Refetch and clean again the content of all existing notes, bypassing all caches")
+	flag.DurationVar(&recapture, "recapture-screenshots", 0, "Take new screenshots of notes refreshed with -refresh or -refresh-all, waiting up to this duration, and flag pages whose appearance changed (0 = off)")
+	flag.StringVar(&nameCollision, "name-collision", markdown.CollisionSuffixBookmark, "What to rename when a folder and a bookmark share a name (bookmark, folder)")
+	flag.BoolVar(&linkSafeNames, "link-safe-names", false, "Replace characters reserved by Obsidian links ([ ] # ^ |) in file and folder names")
+	flag.DurationVar(&retryAfterMax, "retry-after-max", 5*time.Minute, "Maximum time to wait when a server asks to retry later")
+	flag.IntVar(&breakerLimit, "converter-max-failures", 5, "Consecutive markdown converter failures before pausing requests (0 = never pause)")
+	flag.DurationVar(&breakerWindow, "converter-failure-window", 5*time.Minute, "Time window in which converter failures are counted")
+	flag.DurationVar(&breakerCool, "converter-cooldown", 10*time.Minute, "How long to pause converter requests after repeated failures")
+	flag.BoolVar(&slugs, "slugs", false, "Add a unique permalink slug to frontmatter of new notes")
+	flag.BoolVar(&backfill, "backfill-screenshots", false, "Add screenshots to existing notes created before their screenshot was available")
+	flag.BoolVar(&inclDeleted, "include-deleted", false, "Process bookmarks deleted in Firefox and tag them as deleted")
+	flag.StringVar(&overridesDir, "overrides-dir", "", "Directory with hand-authored content named <bookmark id>.md or <url key>.md")
+	flag.IntVar(&minYear, "min-year", markdown.DefaultMinYear, "Earliest plausible bookmark year, older dates are corrected and flagged with date_suspect")
+	flag.IntVar(&maxYear, "max-year", 0, "Latest plausible bookmark year, later dates are corrected and flagged with date_suspect (0 = next year)")
+	flag.IntVar(&maxPathLength, "max-path-length", 0, "Shorten folder and file names so note paths stay below this length (0 = no limit, 260 for Windows)")
+	flag.BoolVar(&privacyReport, "privacy-report", false, "Print all third-party hosts contacted during the run")
+	flag.StringVar(&allowedHosts, "allowed-hosts", "", "Comma-separated list of hosts requests may be sent to (default: all)")
+	flag.StringVar(&subfolder, "subfolder", "", "Only sync this folder path below -folder, e.g. \"work/project\"")
+	flag.StringVar(&order, "order", markdown.OrderFolder, "Order in which new notes are created (folder, newest, oldest)")
+	flag.StringVar(&looseDir, "loose-dir", "_inbox", "Folder for bookmarks directly in the synced folder (empty = output root)")
+	flag.StringVar(&fragmentMode, "fragment-mode", markdown.FragmentFull, "Notes for bookmarks of an already bookmarked page with a different #fragment (full, stub, section)")
+	flag.StringVar(&source, "source", "ffsclient", "Bookmarks source: ffsclient (Firefox Sync), places:/path/to/places.sqlite, html:bookmarks.html, backup:bookmarks.json, chrome:/path/to/Bookmarks, safari:/path/to/Bookmarks.plist, pocket:export.csv or raindrop:export.csv")
+	flag.StringVar(&source, "browser", "ffsclient", "Alias of -source")
+	flag.StringVar(&ffsclientPath, "ffsclient-path", firefox.DefaultFFSyncCmd, "Path to the ffsclient binary")
+	flag.StringVar(&ffsclientPath, "ffsclient", firefox.DefaultFFSyncCmd, "Deprecated: use -ffsclient-path")
+	for name, kind := range sourceShorthands {
+		flag.String(name, "", fmt.Sprintf("Deprecated: use -source %s:<path>", kind))
+	}
+	flag.BoolVar(&deterministic, "deterministic", false, "Render dates in UTC so identical bookmarks produce identical output on any machine")
+	flag.BoolVar(&excerpt, "excerpt", false, "Add a plain text excerpt of the content to frontmatter")
+	flag.StringVar(&clippingsDir, "clippings-dir", "", "Folder in the output directory with Obsidian Web Clipper notes to adopt for matching bookmarks")
+	flag.BoolVar(&rewriteClips, "rewrite-clippings", false, "Rename frontmatter fields of adopted clippings to the ones of generated notes")
+	flag.DurationVar(&ffsTimeout, "ffsclient-timeout", firefox.DefaultFFSyncTimeout, "Maximum time to wait for ffsclient to fetch bookmarks (0 = no limit)")
+	flag.BoolVar(&dedupe, "dedupe", false, "Create a single note for a URL bookmarked in several folders, ignoring host case, utm_* parameters and the fragment")
+	flag.BoolVar(&dedupeStubs, "dedupe-stubs", false, "With -dedupe, write a stub linking to the single note in the folders of duplicates instead of recording their titles as aliases")
+	flag.StringVar(&ffsSession, "ffsclient-session", "", "Path to the ffsclient session file (default: ffsclient default)")
+	flag.BoolVar(&licenseMeta, "license-metadata", false, "Record page license and robots noarchive hints in frontmatter (one extra request per page)")
+	flag.StringVar(&expandLists, "expand-lists", "", "Create notes for the links of reading list bookmarks (stub, full)")
+	flag.IntVar(&expandMax, "expand-max", 20, "Maximum number of notes created for one reading list")
+	flag.BoolVar(&folderIndexes, "folder-indexes", false, "Write an index note listing the bookmarks of each folder")
+	flag.StringVar(&folderSort, "folder-index-sort", markdown.FolderIndexSortBookmark, "Listing order of folder indexes (bookmark, title, date)")
+	flag.StringVar(&githubToken, "github-token", "", "GitHub token for fetching READMEs through the GitHub API (default: $GITHUB_TOKEN)")
+	flag.Int64Var(&maxContent, "max-content-size", web.DefaultMaxContentSize, "Maximum size of fetched content in bytes, larger pages get a link-only note")
+	flag.DurationVar(&cacheTTL, "cache-ttl", 0, "Refetch cached content older than this duration, e.g. 720h (0 never expires)")
+	flag.BoolVar(&clearCache, "clear-cache", false, "Remove all cached content and exit")
+	flag.StringVar(&clearCacheURL, "clear-cache-url", "", "Remove cached content and LLM responses of a single URL and exit")
+	flag.BoolVar(&renameOnTitle, "rename-on-title-change", false, "Rename note files to match a changed bookmark title instead of only updating the title")
+	flag.Var(&dryRun, "dry-run", "Only report the changes a sync would make: plan skips fetching, fetch fetches content without writing it (-dry-run means plan)")
+	flag.StringVar(&planOut, "plan-out", "", "With -dry-run, write the planned changes as JSON to this file, which apply -plan makes")
+	flag.StringVar(&mode, "mode", modeSync, "Sync mode: sync keeps existing notes, mirror regenerates an output directory marked with "+markdown.MirrorMarker+" from scratch")
+	flag.BoolVar(&prune, "prune", false, "Delete notes of bookmarks removed from the synced folders")
+	flag.BoolVar(&archiveDel, "archive-deleted", false, "Move notes of bookmarks removed from the synced folders into _archive")
+	flag.BoolVar(&renameStubs, "rename-stubs", false, "Leave a stub linking to the new file when a note is renamed for a changed bookmark title")
+	flag.StringVar(&configFile, "config", "", "Path to YAML configuration file")
+	flag.BoolVar(&printConfig, "print-config", false, "Print the effective configuration and exit")
+	flag.BoolVar(&strictHooks, "strict-hooks", false, "Treat failing hooks as errors instead of warnings")
 	flag.Parse()
 
-	// Get API key from environment if not provided
+	// The plan was made by a dry run, which apply repeats for real
+	if appliedPlan != nil {
+		dryRun, planOut = "", ""
+	}
+
+	if !slices.Contains(llm.Providers, llmProvider) {
+		fmt.Printf("Unknown LLM provider '%s' (%s)\n", llmProvider, strings.Join(llm.Providers, ", "))
+		os.Exit(exitFatal)
+	}
+
+	// The defaults of -llm-url and -llm-model are for the openai provider
+	if llmProvider == llm.ProviderAnthropic {
+		set := make(map[string]bool)
+		flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+		if !set["llm-url"] {
+			llmBaseURL = llm.DefaultAnthropicURL
+		}
+		if !set["llm-model"] {
+			llmModel = llm.DefaultAnthropicModel
+		}
+	}
+
+	if max := llm.MaxTemperature[llmProvider]; llmTemp < 0 || llmTemp > max {
+		fmt.Printf("-llm-temperature must be between 0 and %g for the %s provider\n", max, llmProvider)
+		os.Exit(exitFatal)
+	}
+
+	if llmMaxTokens < 0 {
+		fmt.Println("-llm-max-tokens must not be negative")
+		os.Exit(exitFatal)
+	}
+
+	// Get API key of the provider from environment if not provided
 	if llmAPIKey == "" {
-		llmAPIKey = os.Getenv("GEMINI_API_KEY")
+		switch llmProvider {
+		case llm.ProviderAnthropic:
+			llmAPIKey = os.Getenv("ANTHROPIC_API_KEY")
+		case llm.ProviderOpenAI:
+			llmAPIKey = os.Getenv("GEMINI_API_KEY")
+		}
+	}
+
+	// Parse inline fields
+	var inlineFieldsList []string
+	if inlineFields != "" {
+		for _, field := range strings.Split(inlineFields, ",") {
+			field = strings.TrimSpace(field)
+			if !slices.Contains(markdown.InlineFields, field) {
+				fmt.Printf("Unknown inline field '%s'\n", field)
+				os.Exit(exitFatal)
+			}
+			inlineFieldsList = append(inlineFieldsList, field)
+		}
+	}
+
+	// Parse LLM sources
+	llmSourcesList := []string{}
+	for _, source := range strings.Split(llmSources, ",") {
+		source = strings.TrimSpace(source)
+		if source == "" {
+			continue
+		}
+		if !slices.Contains(web.Sources, source) {
+			fmt.Printf("Unknown LLM source '%s'\n", source)
+			os.Exit(exitFatal)
+		}
+		llmSourcesList = append(llmSourcesList, source)
+	}
+
+	if backfill && screenshotAPI == "" {
+		fmt.Println("Screenshot backfill requires -screenshot-api")
+		os.Exit(exitFatal)
+	}
+
+	if recapture > 0 && (screenshotAPI == "" || (refresh == "" && !refreshAll)) {
+		fmt.Println("-recapture-screenshots requires -screenshot-api and -refresh or -refresh-all")
+		os.Exit(exitFatal)
+	}
+
+	if llmChunkSize != 0 && llmChunkSize < llm.MinChunkSize {
+		fmt.Printf("-llm-chunk-size must be 0 or at least %d\n", llm.MinChunkSize)
+		os.Exit(exitFatal)
+	}
+
+	if nameCollision != markdown.CollisionSuffixBookmark && nameCollision != markdown.CollisionSuffixFolder {
+		fmt.Printf("Unknown name collision strategy '%s'\n", nameCollision)
+		os.Exit(exitFatal)
+	}
+
+	if subfolder != "" && strings.Contains(baseFolder, ",") {
+		fmt.Println("-subfolder can only be used with a single -folder")
+		os.Exit(exitFatal)
+	}
+
+	if clippingsDir != "" && (markdown.IsZipOutput(outputDir) || filepath.IsAbs(clippingsDir)) {
+		fmt.Println("Clippings require a directory output and a -clippings-dir relative to it")
+		os.Exit(exitFatal)
+	}
+
+	sourceFlags := 0
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "ffsclient" {
+			slog.Warn("-ffsclient is deprecated, use -ffsclient-path")
+			return
+		}
+		if kind, ok := sourceShorthands[f.Name]; ok {
+			slog.Warn(fmt.Sprintf("-%s is deprecated, use -source %s:<path>", f.Name, kind))
+			source = kind + ":" + f.Value.String()
+		} else if f.Name != "source" && f.Name != "browser" {
+			return
+		}
+		sourceFlags++
+	})
+	if sourceFlags > 1 {
+		fmt.Println("Only one of -source, -browser, -input and -bookmarks-file can be used")
+		os.Exit(exitFatal)
+	}
+
+	sources.Register(sources.KindFFSClient, func(path string) firefox.BookmarksFetcher {
+		fetcher := firefox.NewFirefoxFetcher(cmp.Or(path, ffsclientPath), ffsTimeout)
+		fetcher.SessionFile = ffsSession
+		return fetcher
+	})
+	ffFetcher, err := sources.New(source)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(exitFatal)
+	}
+
+	if fragmentMode != markdown.FragmentFull && fragmentMode != markdown.FragmentStub && fragmentMode != markdown.FragmentSection {
+		fmt.Printf("Unknown fragment mode '%s'\n", fragmentMode)
+		os.Exit(exitFatal)
+	}
+
+	if expandLists != markdown.ExpandOff && expandLists != markdown.ExpandStub && expandLists != markdown.ExpandFull {
+		fmt.Printf("Unknown reading list expansion '%s'\n", expandLists)
+		os.Exit(exitFatal)
+	}
+
+	if order != markdown.OrderFolder && order != markdown.OrderNewest && order != markdown.OrderOldest {
+		fmt.Printf("Unknown order '%s'\n", order)
+		os.Exit(exitFatal)
+	}
+
+	if folderSort != markdown.FolderIndexSortBookmark && folderSort != markdown.FolderIndexSortTitle && folderSort != markdown.FolderIndexSortDate {
+		fmt.Printf("Unknown folder index sort '%s'\n", folderSort)
+		os.Exit(exitFatal)
+	}
+
+	if llmPhase != llmPhaseInline && llmPhase != llmPhaseBatch {
+		fmt.Printf("Unknown LLM phase '%s'\n", llmPhase)
+		os.Exit(exitFatal)
+	}
+
+	// Load configuration file
+	cfg := config.Default()
+	if configFile != "" {
+		var err error
+		cfg, err = config.Load(configFile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(exitFatal)
+		}
+	}
+
+	if printConfig {
+		fmt.Print(cfg)
+		os.Exit(exitOK)
+	}
+
+	trackingParams := append(slices.Clone(web.DefaultTrackingParams), cfg.TrackingParams...)
+
+	if clearCache && clearCacheURL != "" {
+		fmt.Println("Only one of -clear-cache and -clear-cache-url can be used")
+		os.Exit(exitFatal)
+	}
+
+	if githubToken == "" {
+		githubToken = os.Getenv("GITHUB_TOKEN")
+	}
+
+	if llmPromptFile == "" {
+		llmPromptFile = os.Getenv("LLM_PROMPT_FILE")
+	}
+
+	if llmSystemFile == "" {
+		llmSystemFile = os.Getenv("LLM_SYSTEM_FILE")
+	}
+
+	if llmPromptFile != "" && llmPromptDir != "" {
+		fmt.Println("Only one of -llm-prompt-dir and -llm-prompt-file can be used")
+		os.Exit(exitFatal)
+	}
+
+	if shotToken == "" {
+		shotToken = os.Getenv("SCREENSHOT_API_TOKEN")
+	}
+
+	if shotToken != "" && shotBasicAuth != "" {
+		fmt.Println("Only one of -screenshot-token and -screenshot-basic-auth can be used")
+		os.Exit(exitFatal)
+	}
+
+	if shotBasicAuth != "" && !strings.Contains(shotBasicAuth, ":") {
+		fmt.Println("-screenshot-basic-auth must be given as user:password")
+		os.Exit(exitFatal)
+	}
+
+	if mode != modeSync && mode != modeMirror {
+		fmt.Printf("Unknown mode '%s'\n", mode)
+		os.Exit(exitFatal)
+	}
+
+	if mode == modeMirror && (markdown.IsZipOutput(outputDir) || clippingsDir != "") {
+		fmt.Println("-mode mirror requires a directory output and can't adopt clippings")
+		os.Exit(exitFatal)
+	}
+
+	if mode == modeMirror {
+		// Mirrors are regenerated on every run and must not depend on the machine
+		deterministic = true
+	}
+
+	if prune && archiveDel {
+		fmt.Println("Only one of -prune and -archive-deleted can be used")
+		os.Exit(exitFatal)
+	}
+
+	if (prune || archiveDel) && markdown.IsZipOutput(outputDir) {
+		fmt.Println("-prune and -archive-deleted require a directory output")
+		os.Exit(exitFatal)
+	}
+
+	if related != "" && markdown.IsZipOutput(outputDir) {
+		fmt.Println("-related requires a directory output")
+		os.Exit(exitFatal)
+	}
+
+	if planOut != "" && dryRun == "" {
+		fmt.Println("-plan-out can only be used with -dry-run")
+		os.Exit(exitFatal)
+	}
+
+	// Mirrors and zip archives are written from scratch, not only the planned changes
+	if (planOut != "" || appliedPlan != nil) && (mode == modeMirror || markdown.IsZipOutput(outputDir)) {
+		fmt.Println("Plans require a directory output synced in sync mode")
+		os.Exit(exitFatal)
+	}
+
+	if fix && dryRun != "" {
+		fmt.Println("-fix can't be used with -dry-run")
+		os.Exit(exitFatal)
+	}
+
+	if fix && !doctor {
+		fmt.Println("-fix can only be used with -doctor")
+		os.Exit(exitFatal)
+	}
+
+	if quiet && verbose {
+		fmt.Println("Only one of -quiet and -verbose can be used")
+		os.Exit(exitFatal)
 	}
 
 	// Initialize logger
 	logLevel := slog.LevelInfo
 	if verbose {
 		logLevel = slog.LevelDebug
+	} else if quiet {
+		logLevel = slog.LevelWarn
 	}
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
 		Level: logLevel,
 	}))
 	slog.SetDefault(logger)
 
+	// Dates of the run are relative to its start
+	started := time.Now()
+
+	// An interrupted sync stops after the note being written and still
+	// writes indexes, the status note and the checkpoint. A second signal
+	// stops right away.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	context.AfterFunc(ctx, func() {
+		slog.Warn("sync interrupted, finishing the current note")
+		stop()
+	})
+
+	if len(cfg.SyncConflictPatterns) > 0 {
+		markdown.SyncConflictPatterns = cfg.SyncConflictPatterns
+	}
+
+	if doctor {
+		// Only -fix changes the vault, reporting leaves duplicates in place
+		readCache := markdown.ReadSubtreeCache
+		if fix {
+			readCache = markdown.BuildSubtreeCache
+		}
+		mdCache, err := readCache(outputDir, "")
+		if err != nil {
+			slog.Error("failed to build markdown cache", "error", err)
+			os.Exit(exitFatal)
+		}
+
+		conflicts, err := markdown.FindSyncConflicts(outputDir, mdCache)
+		if err != nil {
+			slog.Error("failed to find sync conflicts", "error", err)
+			os.Exit(exitFatal)
+		}
+
+		if fix {
+			resolved, err := markdown.ResolveSyncConflicts(outputDir, conflicts)
+			if err != nil {
+				slog.Error("failed to resolve sync conflicts", "error", err)
+				os.Exit(exitFatal)
+			}
+			slog.Info("resolved sync conflicts", "count", resolved)
+
+			if conflicts, err = markdown.FindSyncConflicts(outputDir, mdCache); err != nil {
+				slog.Error("failed to find sync conflicts", "error", err)
+				os.Exit(exitFatal)
+			}
+		}
+
+		fmt.Print(markdown.NewDoctorReport(mdCache, conflicts))
+		os.Exit(exitOK)
+	}
+
+	if listBookmarks == listUnread {
+		mdCache, err := markdown.ReadSubtreeCache(outputDir, subfolder)
+		if err != nil {
+			slog.Error("failed to build markdown cache", "error", err)
+			os.Exit(exitFatal)
+		}
+
+		fmt.Print(mdCache.Unread(started.AddDate(0, 0, -unreadDays)))
+		os.Exit(exitOK)
+	}
+
 	// Initialize HTTP client
 	client := retryablehttp.NewClient()
 	client.RetryMax = 3
 	client.Logger = nil // Disable retryable client logging
+	client.Backoff = web.RetryAfterBackoff(retryAfterMax)
+	client.HTTPClient.Transport = web.NewHostLimitTransport(client.HTTPClient.Transport, hostLimit)
 
-	homeDir, err := os.UserHomeDir()
+	// Record and restrict outbound hosts. Everything fetching over HTTP gets
+	// this client passed in, nothing uses http.DefaultClient.
+	var allowedHostsList []string
+	if allowedHosts != "" {
+		allowedHostsList = strings.Split(allowedHosts, ",")
+	}
+	hostPolicy := web.NewHostPolicyTransport(client.HTTPClient.Transport, allowedHostsList)
+	hostPolicy.SetPurpose(converterURL, "markdown converter")
+	hostPolicy.SetPurpose(screenshotAPI, "screenshot API")
+	hostPolicy.SetPurpose(llmBaseURL, "LLM endpoint")
+	hostPolicy.SetPurpose("https://raw.githubusercontent.com", "GitHub raw")
+	hostPolicy.SetPurpose("https://api.github.com", "GitHub API")
+	client.HTTPClient.Transport = hostPolicy
+
+	// Blocked hosts stay blocked, so don't retry them
+	client.CheckRetry = func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+		if web.CategoryOf(err) == web.CategoryBlocked {
+			return false, err
+		}
+		return retryablehttp.DefaultRetryPolicy(ctx, resp, err)
+	}
+
+	cacheDir, err := defaultCacheDir()
 	if err != nil {
 		slog.Error("failed to get home directory", "error", err)
-		os.Exit(1)
+		os.Exit(exitFatal)
 	}
 
-	cacheDir := filepath.Join(homeDir, ".cache", "ffbookmarks-to-markdown")
-
 	// Initialize cache
-	cache, err := x.NewFileCache(cacheDir)
+	cache, err := x.NewFileCacheWithTTL(cacheDir, cacheTTL)
 	if err != nil {
 		slog.Warn("failed to initialize cache", "error", err)
 	}
 
+	if (clearCache || clearCacheURL != "") && cache == nil {
+		os.Exit(exitFatal)
+	}
+
+	if clearCache {
+		removed, err := cache.Clear()
+		if err != nil {
+			slog.Error("failed to clear cache", "error", err)
+			os.Exit(exitFatal)
+		}
+
+		slog.Info("cleared cache", "dir", cacheDir, "removed", removed)
+		os.Exit(exitOK)
+	}
+
 	var llmClient web.ContentCleaner
+	var summarizer markdown.Summarizer
+	var tagger markdown.Tagger
+	if llmSummary && llmAPIKey == "" {
+		slog.Warn("-llm-summary needs an LLM API key, notes get no description")
+	}
 	if llmAPIKey != "" {
-		llmClient, err = llm.NewOpenAIClient(llmAPIKey, llmBaseURL, llmModel, client.StandardClient(), cache)
+		llmPromptClient, err := llm.NewClient(llmProvider, llmAPIKey, llmBaseURL, llmModel, llm.LLMOptions{Temperature: llmTemp, MaxTokens: llmMaxTokens, ChunkSize: llmChunkSize}, client.StandardClient(), cache)
 		if err != nil {
 			slog.Error("failed to initialize LLM client", "error", err)
-			os.Exit(1)
+			os.Exit(exitFatal)
+		}
+
+		// -llm-system-file overrides system.md of -llm-prompt-dir
+		promptFiles := make(map[string]string)
+		if llmPromptDir != "" {
+			if promptFiles, err = llm.PromptFiles(llmPromptDir); err != nil {
+				slog.Error("failed to load LLM prompts", "error", err)
+				os.Exit(exitFatal)
+			}
+		}
+		if llmPromptFile != "" {
+			for _, contentType := range web.ContentTypes {
+				promptFiles[contentType] = llmPromptFile
+			}
+		}
+		if llmSystemFile != "" {
+			promptFiles[llm.PromptSystem] = llmSystemFile
+		}
+		if err := llmPromptClient.LoadPrompts(promptFiles); err != nil {
+			slog.Error("failed to load LLM prompts", "error", err)
+			os.Exit(exitFatal)
+		}
+		llmClient = llmPromptClient
+		if llmSummary {
+			summarizer = llmPromptClient
+		}
+		if !noLLMTags {
+			tagger = llmPromptClient
 		}
 	}
 
+	var breaker *web.CircuitBreaker
+	if breakerLimit > 0 {
+		breaker = web.NewCircuitBreaker("markdown converter", breakerLimit, breakerWindow, breakerCool)
+	}
+
 	// Initialize services
-	ffFetcher := firefox.NewFirefoxFetcher()
-	contentService := web.NewContentService(client.StandardClient(), web.FetchOptions{
-		BaseURL:        "https://md.dhr.wtf",
+	contentService, err := web.NewContentService(client.StandardClient(), web.FetchOptions{
+		BaseURL:        converterURL,
 		ContentCleaner: llmClient,
+		CleanSources:   llmSourcesList,
+		CleanMinLength: llmMinLength,
+		MaxContentSize: maxContent,
+		GitHubToken:    githubToken,
+		TrackingParams: trackingParams,
+		BatchClean:     llmPhase == llmPhaseBatch,
 		Cache:          cache,
+		Breaker:        breaker,
 	})
+	if err != nil {
+		slog.Error("failed to initialize content service", "error", err)
+		os.Exit(exitFatal)
+	}
+
+	// Cleaned content is cached by the LLM client under keys of the content,
+	// which the content service knows
+	if clearCacheURL != "" {
+		removed, err := cache.Delete(contentService.CacheKeys(clearCacheURL)...)
+		if err != nil {
+			slog.Error("failed to clear cache", "error", err)
+			os.Exit(exitFatal)
+		}
+
+		slog.Info("cleared cache", "dir", cacheDir, "url", clearCacheURL, "removed", removed)
+		os.Exit(exitOK)
+	}
 
 	// Get Firefox bookmarkRoot
-	bookmarkRoot, err := ffFetcher.GetBookmarks()
+	bookmarkRoot, err := ffFetcher.GetBookmarks(ctx)
 	if err != nil {
 		slog.Error("failed to get Firefox bookmarks", "error", err)
-		os.Exit(1)
+		os.Exit(exitFatal)
+	}
+
+	// Notes are matched to bookmarks by ID, so every bookmark needs one
+	for _, root := range bookmarkRoot.Roots() {
+		root.FillMissingIDs()
+	}
+
+	if listBookmarks == listFolders {
+		for _, path := range bookmarkRoot.FolderPaths() {
+			fmt.Println(path)
+		}
+
+		os.Exit(exitOK)
 	}
 
-	// Find target folder
-	targetFolder := bookmarkRoot.Path(baseFolder)
-	if targetFolder == nil {
-		fmt.Printf("Folder '%s' not found in bookmarks\n", baseFolder)
-		os.Exit(1)
+	// Find target folders. A single folder maps to the output root, several
+	// folders each map to their own path in the output
+	type syncTarget struct {
+		folder *bookmarks.Bookmark
+		path   string
+	}
+	var targets []syncTarget
+	var folderNames []string
+	for _, name := range strings.Split(baseFolder, ",") {
+		if name = strings.Trim(strings.TrimSpace(name), "/"); name != "" {
+			folderNames = append(folderNames, name)
+		}
+	}
+
+	if len(folderNames) == 1 {
+		targetFolder := bookmarkRoot.Path(folderNames[0])
+		if targetFolder == nil {
+			fmt.Printf("Folder '%s' not found in bookmarks\n", baseFolder)
+			os.Exit(exitFatal)
+		}
+
+		// Narrow the sync down to a single subfolder, which maps to the same path in the output
+		subfolder = strings.Trim(subfolder, "/")
+		if subfolder != "" {
+			targetFolder = targetFolder.Path(targetFolder.Title + "/" + subfolder)
+			if targetFolder == nil {
+				fmt.Printf("Folder '%s' not found in '%s'\n", subfolder, baseFolder)
+				os.Exit(exitFatal)
+			}
+		}
+		targets = append(targets, syncTarget{folder: targetFolder, path: subfolder})
+	} else {
+		for _, name := range folderNames {
+			targetFolder := bookmarkRoot.Path(name)
+			if targetFolder == nil && (prune || archiveDel) {
+				// Notes of a missing folder would all look removed
+				fmt.Printf("Folder '%s' not found in bookmarks, refusing to prune\n", name)
+				os.Exit(exitFatal)
+			}
+			if targetFolder == nil {
+				slog.Error("folder not found in bookmarks, skipping", "folder", name)
+				continue
+			}
+			targets = append(targets, syncTarget{folder: targetFolder, path: name})
+		}
+		if len(targets) == 0 {
+			fmt.Printf("None of the folders '%s' found in bookmarks\n", baseFolder)
+			os.Exit(exitFatal)
+		}
+	}
+
+	// A plan only applies to the bookmarks it was made for
+	var targetFolders []bookmarks.Bookmark
+	for _, target := range targets {
+		targetFolders = append(targetFolders, *target.folder)
+	}
+	treeHash := bookmarks.Hash(targetFolders...)
+	if appliedPlan != nil && appliedPlan.TreeHash != treeHash {
+		fmt.Println("Bookmarks changed since the plan was made, make a new one with -dry-run -plan-out")
+		os.Exit(exitFatal)
 	}
 
 	// Parse ignored folders
@@ -117,64 +920,153 @@ func main() {
 		ignoredFoldersList = strings.Split(ignoreFolders, ",")
 	}
 
-	// Collect new URLs for screenshots
+	// The explicitly requested folders are synced even if they lie in an ignored folder
+	for _, folder := range folderNames {
+		for _, name := range strings.Split(folder, "/") {
+			if slices.ContainsFunc(ignoredFoldersList, func(ignored string) bool { return strings.TrimSpace(ignored) == name }) {
+				slog.Info("target folder is inside an ignored folder, syncing it anyway", "folder", folder, "ignored", name)
+			}
+		}
+	}
+
+	var targetBookmarks []iter.Seq2[string, *bookmarks.Bookmark]
+	for _, target := range targets {
+		targetBookmarks = append(targetBookmarks, target.folder.All())
+	}
+
+	// Collect new URLs for screenshots, using the same ignore rules as the processor
 	allBookmarks := x.Filter2(
-		targetFolder.All(),
+		x.Concat2(targetBookmarks...),
 		func(path string, v *bookmarks.Bookmark) bool {
-			for _, ignorePath := range ignoredFoldersList {
-				if strings.HasPrefix(path, ignorePath) {
-					return false
-				}
+			if markdown.IsIgnoredPath(path, ignoredFoldersList) {
+				return false
 			}
 
-			return v.Type == "bookmark" && !v.Deleted
+			// Separators, folders and queries never become notes
+			return v.Type == bookmarks.TypeBookmark && !v.IsQuery() && (!v.Deleted || inclDeleted)
 		},
 	)
 
-	if listBookmarks {
+	if listBookmarks == listAll {
 		for path := range allBookmarks {
 			fmt.Println(path)
 		}
 
-		os.Exit(0)
+		os.Exit(exitOK)
 	}
 
-	mdCache, err := markdown.BuildCache(outputDir)
-	if err != nil {
-		slog.Error("failed to build markdown cache", "error", err)
-		os.Exit(1)
+	// read_at is owned by the user, keep it for regenerated mirror notes
+	var readDates map[string]string
+	if mode == modeMirror {
+		if readDates, err = markdown.MirrorReadDates(outputDir); err != nil {
+			slog.Error("failed to read mirror notes", "error", err)
+			os.Exit(exitFatal)
+		}
+	}
+
+	if mode == modeMirror {
+		if err := markdown.CheckMirror(outputDir); err != nil {
+			slog.Error("failed to open mirror", "error", err)
+			os.Exit(exitFatal)
+		}
+	}
+
+	// Archives and mirrors are always written from scratch, so start with an
+	// empty cache. Dry runs leave duplicate notes in place.
+	mdCache := make(markdown.Cache)
+	if !markdown.IsZipOutput(outputDir) && mode != modeMirror {
+		if dryRun != "" {
+			mdCache, err = markdown.ReadSubtreeCache(outputDir, subfolder)
+		} else {
+			mdCache, err = markdown.BuildSubtreeCache(outputDir, subfolder)
+		}
+		if err != nil {
+			slog.Error("failed to build markdown cache", "error", err)
+			os.Exit(exitFatal)
+		}
+	}
+
+	var clippings map[string]markdown.Clipping
+	if clippingsDir != "" {
+		clippings, err = markdown.LoadClippings(outputDir, clippingsDir)
+		if err != nil {
+			slog.Error("failed to load clippings", "error", err)
+			os.Exit(exitFatal)
+		}
 	}
 
 	var screenshotService *web.ScreenshotService
-	var screenshots map[string]bool
+	var screenshots map[string]web.ScreenshotResult
+	var plannedShots int
 	if screenshotAPI != "" {
-		screenshotService = web.NewScreenshotService(client.StandardClient(), screenshotAPI)
+		screenshotAuth := web.ScreenshotAuth{Token: shotToken}
+		if shotBasicAuth != "" {
+			screenshotAuth.Username, screenshotAuth.Password, _ = strings.Cut(shotBasicAuth, ":")
+		}
+
+		if screenshotVer == web.ScreenshotAPIAuto {
+			screenshotVer, err = web.DetectScreenshotAPIVersion(client.StandardClient(), screenshotAPI, screenshotAuth)
+			if err != nil {
+				slog.Error("failed to detect screenshot API version", "error", err)
+				os.Exit(exitFatal)
+			}
+		}
+
+		screenshotService, err = web.NewScreenshotService(client.StandardClient(), screenshotAPI, web.ScreenshotOptions{
+			Version:        screenshotVer,
+			Width:          screenshotW,
+			Height:         screenshotH,
+			FullPage:       screenshotFP,
+			Auth:           screenshotAuth,
+			TrackingParams: trackingParams,
+		})
+		if err != nil {
+			slog.Error("failed to initialize screenshot service", "error", err)
+			os.Exit(exitFatal)
+		}
 
 		// Get existing screenshots
-		screenshots, err = screenshotService.GetExistingScreenshots()
+		screenshots, err = screenshotService.GetScreenshotResults()
 		if err != nil {
 			slog.Error("failed to get existing screenshots", "error", err)
-			os.Exit(1)
+			os.Exit(exitFatal)
 		}
 
 		newURLs := mdCache.CollectNewURLs(x.Values(allBookmarks))
+		if fragmentMode != markdown.FragmentFull {
+			// Fragment notes link to the page note and reuse its screenshot
+			newURLs = markdown.DropFragmentVariants(newURLs, mdCache)
+		}
 
-		// Filter URLs that need screenshots
+		// Filter URLs that need screenshots, taking one per normalized URL
 		var urlsToScreenshot []string
+		requested := make(map[string]bool)
 		for _, u := range newURLs {
-			if !screenshots[u] {
+			u = screenshotService.Normalize(u)
+			if _, ok := screenshots[u]; !ok && !requested[u] {
+				requested[u] = true
 				urlsToScreenshot = append(urlsToScreenshot, u)
 			}
 		}
 
 		// Submit new screenshots
-		if len(urlsToScreenshot) > 0 {
+		if len(urlsToScreenshot) > 0 && dryRun != "" {
+			slog.Info("would submit screenshots", "count", len(urlsToScreenshot))
+			plannedShots = len(urlsToScreenshot)
+		} else if len(urlsToScreenshot) > 0 {
 			slog.Info("submitting batch screenshot request",
 				"total", len(newURLs),
 				"new", len(urlsToScreenshot),
 				"cached", len(newURLs)-len(urlsToScreenshot))
 			if err := screenshotService.SubmitScreenshots(urlsToScreenshot); err != nil {
 				slog.Error("failed to submit screenshots", "error", err)
+			} else if waitShots > 0 {
+				ready, err := screenshotService.WaitForScreenshots(ctx, urlsToScreenshot, waitShots)
+				if err != nil {
+					slog.Warn("failed to wait for screenshots", "error", err)
+				} else {
+					screenshots = ready
+				}
 			}
 		} else {
 			slog.Info("no new screenshots needed",
@@ -183,25 +1075,387 @@ func main() {
 		}
 	}
 
+	// Dry runs neither run hooks nor open the output. Mirrors are built in
+	// a directory of their own and replace the output directory at the end.
+	hooksConfig := cfg.Hooks
+	var output markdown.Output
+	var mirror *markdown.MirrorOutput
+	noteDir := outputDir
+	if dryRun != "" {
+		hooksConfig = config.HooksConfig{}
+	} else if mode == modeMirror {
+		if mirror, err = markdown.NewMirrorOutput(outputDir); err != nil {
+			slog.Error("failed to open mirror", "error", err)
+			os.Exit(exitFatal)
+		}
+		output, noteDir = mirror, mirror.Dir()
+	} else if output, err = markdown.NewOutput(outputDir); err != nil {
+		slog.Error("failed to open output", "error", err)
+		os.Exit(exitFatal)
+	}
+	if markdown.IsZipOutput(outputDir) && hooksConfig.PostCreate != "" {
+		// Notes in a zip archive have no path the hook could open
+		slog.Warn("post_create hook is not run for zip output")
+		hooksConfig.PostCreate = ""
+	}
+	hookRunner := hooks.NewRunner(hooksConfig, outputDir, noteDir, strictHooks)
+
+	// Checkpoint progress so an interrupted first sync can resume, zip
+	// archives and mirrors are always written from scratch
+	var checkpoint x.Cache
+	if cache != nil && !markdown.IsZipOutput(outputDir) && mode != modeMirror && dryRun == "" {
+		checkpoint = cache
+	}
+
 	// Process bookmarks
 	mdProcessor := markdown.NewProcessor(
 		markdown.ProcessorOptions{
-			OutputDir:      outputDir,
-			IgnoredFolders: ignoredFoldersList,
+			OutputDir:            noteDir,
+			Output:               output,
+			IgnoredFolders:       ignoredFoldersList,
+			InlineFields:         inlineFieldsList,
+			NameCollision:        nameCollision,
+			LinkSafeNames:        linkSafeNames,
+			Slugs:                slugs,
+			IncludeDeleted:       inclDeleted,
+			OverridesDir:         overridesDir,
+			TagSynonyms:          cfg.Tags.Aliases(),
+			Triage:               !noTriage,
+			PinTag:               pinTag,
+			Related:              string(related),
+			TrackingParams:       trackingParams,
+			ReadStats:            readStats,
+			ReadDates:            readDates,
+			DryRun:               string(dryRun),
+			Plan:                 appliedPlan,
+			Summarizer:           summarizer,
+			Tagger:               tagger,
+			PaywallDomains:       append(slices.Clone(markdown.DefaultPaywallDomains), cfg.PaywallDomains...),
+			RenameOnTitleChange:  renameOnTitle,
+			RenameStubs:          renameStubs,
+			BatchClean:           llmClient != nil && llmPhase == llmPhaseBatch,
+			CleanConcurrency:     llmWorkers,
+			MaxPathLength:        maxPathLength,
+			MinYear:              minYear,
+			MaxYear:              maxYear,
+			Now:                  started,
+			Order:                order,
+			LooseDir:             looseDir,
+			FragmentMode:         fragmentMode,
+			Deterministic:        deterministic,
+			Excerpt:              excerpt,
+			Dedupe:               dedupe,
+			DedupeStubs:          dedupeStubs,
+			LicenseMetadata:      licenseMeta,
+			ExpandLists:          expandLists,
+			ExpandMax:            expandMax,
+			FolderIndexes:        folderIndexes,
+			FolderIndexSort:      folderSort,
+			Clippings:            clippings,
+			RewriteClippings:     rewriteClips,
+			Checkpoint:           checkpoint,
+			Screenshots:          screenshots,
+			ReadyScreenshotsOnly: waitShots > 0,
+			RecaptureScreenshots: recapture,
+			Hooks:                hookRunner,
+			Context:              ctx,
 		},
 		contentService,
 		screenshotService,
 		mdCache,
 	)
 
+	if err := hookRunner.PreRun(); err != nil {
+		slog.Error("pre-run hook failed", "error", err)
+		os.Exit(exitFatal)
+	}
+
+	if heal {
+		mdProcessor.HealDegenerateNotes()
+	}
+
+	if refresh != "" || refreshAll {
+		var refs []string
+		if refresh != "" {
+			refs = strings.Split(refresh, ",")
+		}
+		mdProcessor.RefreshNotes(refs, refreshAll)
+	}
+
+	if backfill {
+		mdProcessor.BackfillScreenshots(screenshots)
+	}
+
 	// Process bookmarks and create indexes
-	if err := mdProcessor.ProcessBookmarks(*targetFolder, ""); err != nil {
-		slog.Error("failed to process bookmarks", "error", err)
-		os.Exit(1)
+	for _, target := range targets {
+		err := mdProcessor.ProcessBookmarks(*target.folder, target.path)
+		if errors.Is(err, context.Canceled) {
+			break
+		}
+		if err != nil {
+			slog.Error("failed to process bookmarks", "error", err)
+			os.Exit(exitFatal)
+		}
+	}
+	interrupted := ctx.Err() != nil
+
+	if err := mdProcessor.CreateFolderIndexes(); err != nil {
+		slog.Error("failed to create folder indexes", "error", err)
+		os.Exit(exitFatal)
+	}
+
+	if (prune || archiveDel) && !interrupted {
+		mode := markdown.PruneDelete
+		if archiveDel {
+			mode = markdown.PruneArchive
+		}
+		// Bookmarks of ignored folders still exist, keep their notes
+		current := x.Filter2(x.Concat2(targetBookmarks...), func(_ string, v *bookmarks.Bookmark) bool {
+			return v.Type == bookmarks.TypeBookmark && (!v.Deleted || inclDeleted)
+		})
+		var paths []string
+		for _, target := range targets {
+			paths = append(paths, target.path)
+		}
+		if _, err := mdProcessor.PruneNotes(x.Values(current), paths, mode); err != nil {
+			slog.Error("failed to prune notes", "error", err)
+			os.Exit(exitFatal)
+		}
+	}
+
+	if related != "" && !interrupted {
+		if err := mdProcessor.LinkRelated(); err != nil {
+			slog.Error("failed to link related notes", "error", err)
+			os.Exit(exitFatal)
+		}
 	}
 
 	if err := mdProcessor.CreateYearIndexes(x.Values(allBookmarks)); err != nil {
 		slog.Error("failed to create year indexes", "error", err)
-		os.Exit(1)
+		os.Exit(exitFatal)
+	}
+
+	if !noTriage {
+		if err := mdProcessor.CreateInboxIndex(); err != nil {
+			slog.Error("failed to create inbox index", "error", err)
+			os.Exit(exitFatal)
+		}
+	}
+
+	if !noStatusNote {
+		// Deterministic output leaves out the time of the run
+		finished := time.Now()
+		if deterministic {
+			finished = time.Time{}
+		}
+		if err := mdProcessor.CreateStatusNote(finished); err != nil {
+			slog.Error("failed to create status note", "error", err)
+			os.Exit(exitFatal)
+		}
+	}
+
+	if mirror != nil && interrupted {
+		// Keep the previous mirror instead of an incomplete one
+		if err := mirror.Discard(); err != nil {
+			slog.Warn("failed to remove incomplete mirror", "error", err)
+		}
+	} else if output != nil {
+		if err := output.Close(); err != nil {
+			slog.Error("failed to close output", "error", err)
+			os.Exit(exitFatal)
+		}
+	}
+
+	summary := mdProcessor.Summary()
+	failures := make(map[string]int)
+	for category, count := range summary.FailureCategories() {
+		failures[string(category)] = count
+	}
+
+	if err := hookRunner.PostRun(failures); err != nil {
+		slog.Error("post-run hook failed", "error", err)
+		os.Exit(exitFatal)
+	}
+
+	if hookRunner.Warnings() > 0 {
+		slog.Warn("some hooks failed", "count", hookRunner.Warnings())
+	}
+
+	if privacyReport {
+		fmt.Print(hostPolicy.Report())
+	}
+
+	if planOut != "" {
+		plan := markdown.Plan{TreeHash: treeHash, Args: os.Args[1:], Changes: mdProcessor.Changes()}
+		if err := markdown.WritePlan(planOut, plan); err != nil {
+			slog.Error("failed to write plan", "error", err)
+			os.Exit(exitFatal)
+		}
+	}
+
+	if dryRun != "" {
+		fmt.Print(mdProcessor.Changes())
+		if screenshotService != nil {
+			fmt.Printf("Screenshots to submit: %d\n", plannedShots)
+		}
+	}
+
+	breakerTrips := 0
+	if breaker != nil {
+		breakerTrips = breaker.Trips()
+	}
+	slog.Info("sync finished",
+		"created", summary.Created,
+		"failed", summary.Failed,
+		"deferred", summary.Deferred,
+		"retitled", summary.Retitled,
+		"pruned", summary.Pruned,
+		"refreshed", summary.Refreshed,
+		"unread_reading_time", summary.UnreadReadingTime,
+		"converter_pauses", breakerTrips,
+		"failures", failures)
+
+	if code := exitCode(summary, interrupted); code != exitOK {
+		os.Exit(code)
+	}
+}
+
+// exitCode returns the exit code of a finished sync. An interrupt wins over
+// failures, which win over bookmarks deferred to the next run.
+func exitCode(summary markdown.Summary, interrupted bool) int {
+	switch {
+	case interrupted:
+		return exitInterrupted
+	case summary.Failed > 0:
+		return exitPartial
+	case summary.Deferred > 0:
+		return exitRetry
+	}
+	return exitOK
+}
+
+// readApplyPlan reads the plan of the apply command
+func readApplyPlan(args []string) (*markdown.Plan, error) {
+	flags := flag.NewFlagSet("apply", flag.ContinueOnError)
+	path := flags.String("plan", "", "Plan written by -dry-run -plan-out")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if *path == "" || flags.NArg() > 0 {
+		return nil, errors.New("usage: ffbookmarks-to-markdown apply -plan <plan.json>")
+	}
+	return markdown.ReadPlan(*path)
+}
+
+// runConfigCommand handles the config subcommands and returns the exit code
+func runConfigCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Println("Usage: ffbookmarks-to-markdown config validate <file> | config schema")
+		return 1
+	}
+
+	switch args[0] {
+	case "validate":
+		if len(args) != 2 {
+			fmt.Println("Usage: ffbookmarks-to-markdown config validate <file>")
+			return 1
+		}
+
+		if _, err := config.Load(args[1]); err != nil {
+			fmt.Println(err)
+			return 1
+		}
+
+		fmt.Printf("%s is valid\n", args[1])
+		return 0
+	case "schema":
+		schema, err := config.Schema()
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+
+		fmt.Println(string(schema))
+		return 0
+	}
+
+	fmt.Printf("Unknown config command '%s'\n", args[0])
+	return 1
+}
+
+// defaultCacheDir returns the directory of cached content and LLM responses
+func defaultCacheDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".cache", "ffbookmarks-to-markdown"), nil
+}
+
+// runCacheCommand handles the cache subcommands and returns the exit code
+func runCacheCommand(args []string) int {
+	const usage = "Usage: ffbookmarks-to-markdown cache export -o <bundle.tar.gz> | cache import <bundle.tar.gz>"
+	if len(args) == 0 {
+		fmt.Println(usage)
+		return 1
+	}
+
+	cacheDir, err := defaultCacheDir()
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	cache, err := x.NewFileCache(cacheDir)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	switch args[0] {
+	case "export":
+		fs := flag.NewFlagSet("cache export", flag.ContinueOnError)
+		output := fs.String("o", "", "Path of the bundle to write")
+		if err := fs.Parse(args[1:]); err != nil || *output == "" {
+			fmt.Println("Usage: ffbookmarks-to-markdown cache export -o <bundle.tar.gz>")
+			return 1
+		}
+
+		count, err := cache.Export(*output, toolVersion())
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+
+		fmt.Printf("Exported %d cache entries to %s\n", count, *output)
+		return 0
+	case "import":
+		if len(args) != 2 {
+			fmt.Println("Usage: ffbookmarks-to-markdown cache import <bundle.tar.gz>")
+			return 1
+		}
+
+		manifest, imported, err := cache.Import(args[1])
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+
+		if manifest.ToolVersion != toolVersion() {
+			fmt.Printf("Bundle was exported by version %s\n", manifest.ToolVersion)
+		}
+		fmt.Printf("Imported %d of %d cache entries, kept %d newer local entries\n", imported, len(manifest.Entries), len(manifest.Entries)-imported)
+		return 0
+	}
+
+	fmt.Printf("Unknown cache command '%s'\n", args[0])
+	fmt.Println(usage)
+	return 1
+}
+
+// toolVersion returns the module version the binary was built from
+func toolVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		return info.Main.Version
 	}
+	return "unknown"
 }
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/markdown"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name        string
+		summary     markdown.Summary
+		interrupted bool
+		want        int
+	}{
+		{"ok", markdown.Summary{Created: 3}, false, exitOK},
+		{"failed", markdown.Summary{Created: 3, Failed: 1}, false, exitPartial},
+		{"deferred", markdown.Summary{Created: 3, Deferred: 2}, false, exitRetry},
+		{"failed and deferred", markdown.Summary{Failed: 1, Deferred: 2}, false, exitPartial},
+		{"interrupted", markdown.Summary{Failed: 1, Deferred: 2}, true, exitInterrupted},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := exitCode(test.summary, test.interrupted); got != test.want {
+				t.Errorf("got exit code %d, want %d", got, test.want)
+			}
+		})
+	}
+}
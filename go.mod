@@ -2,19 +2,32 @@ module github.com/xtruder/ffbookmarks-to-markdown
 
 go 1.23.4
 
-require github.com/adrg/frontmatter v0.2.0
+require (
+	github.com/adrg/frontmatter v0.2.0
+	golang.org/x/text v0.21.0
+	modernc.org/sqlite v1.34.5
+)
 
 require (
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/google/uuid v1.6.0 // indirect
 	github.com/hashicorp/go-cleanhttp v0.5.2 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/ncruces/go-strftime v0.1.9 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
 	github.com/tidwall/gjson v1.14.4 // indirect
 	github.com/tidwall/match v1.1.1 // indirect
 	github.com/tidwall/pretty v1.2.1 // indirect
 	github.com/tidwall/sjson v1.2.5 // indirect
+	golang.org/x/sys v0.22.0 // indirect
+	modernc.org/libc v1.55.3 // indirect
+	modernc.org/mathutil v1.6.0 // indirect
+	modernc.org/memory v1.8.0 // indirect
 )
 
 require (
 	github.com/BurntSushi/toml v0.3.1 // indirect
 	github.com/hashicorp/go-retryablehttp v0.7.7
 	github.com/openai/openai-go v0.1.0-alpha.56
-	gopkg.in/yaml.v2 v2.3.0 // indirect
+	gopkg.in/yaml.v2 v2.3.0
 )
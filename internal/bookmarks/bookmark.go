@@ -1,11 +1,25 @@
 package bookmarks
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"iter"
 	"slices"
 	"strings"
 )
 
+// Bookmark types
+const (
+	TypeBookmark  = "bookmark"
+	TypeFolder    = "folder"
+	TypeSeparator = "separator"
+	// TypeQuery is a saved search or smart folder with a place: URI
+	TypeQuery = "query"
+	// TypeLivemark is a legacy RSS feed folder
+	TypeLivemark = "livemark"
+)
+
 // Bookmark represents a Firefox bookmark
 type Bookmark struct {
 	Added     string     `json:"added"`
@@ -15,9 +29,28 @@ type Bookmark struct {
 	Title     string     `json:"title"`
 	Type      string     `json:"type"`
 	URI       string     `json:"uri,omitempty"`
+	Tags      []string   `json:"tags,omitempty"`
+	Keyword   string     `json:"keyword,omitempty"`
 	Children  []Bookmark `json:"children,omitempty"`
 }
 
+// IsQuery reports whether the bookmark is a Firefox place: query rather than a web page
+func (b Bookmark) IsQuery() bool {
+	return b.Type == TypeQuery || strings.HasPrefix(b.URI, "place:")
+}
+
+// Hash returns a hash of folders and everything below them, which changes
+// with any of their bookmarks
+func Hash(folders ...Bookmark) string {
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	for _, folder := range folders {
+		// Encoding bookmarks into a hash can't fail
+		_ = enc.Encode(folder)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 func (folder Bookmark) All() iter.Seq2[string, *Bookmark] {
 	return func(yield func(string, *Bookmark) bool) {
 		var collect func(b Bookmark, path string)
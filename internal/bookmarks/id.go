@@ -0,0 +1,37 @@
+package bookmarks
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"net/url"
+	"strings"
+)
+
+// derivedIDPrefix marks IDs derived from the URL, as opposed to Firefox GUIDs
+const derivedIDPrefix = "url-"
+
+// DeriveID returns a stable ID for a bookmark URL, used for bookmarks that
+// come without an ID. The scheme and host are lowercased before hashing.
+func DeriveID(uri string) string {
+	uri = strings.TrimSpace(uri)
+	if u, err := url.Parse(uri); err == nil {
+		u.Scheme = strings.ToLower(u.Scheme)
+		u.Host = strings.ToLower(u.Host)
+		uri = u.String()
+	}
+
+	hash := sha256.Sum256([]byte(uri))
+	encoded := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(hash[:])
+	return derivedIDPrefix + strings.ToLower(encoded[:16])
+}
+
+// FillMissingIDs sets derived IDs on bookmarks below folder that have none
+func (folder *Bookmark) FillMissingIDs() {
+	for i := range folder.Children {
+		child := &folder.Children[i]
+		if child.Type == TypeBookmark && child.ID == "" && child.URI != "" {
+			child.ID = DeriveID(child.URI)
+		}
+		child.FillMissingIDs()
+	}
+}
@@ -0,0 +1,114 @@
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/firefox"
+)
+
+// Kind is the -source kind reading Chrome bookmarks
+const Kind = "chrome"
+
+// chromeEpochOffset is the number of seconds between 1601-01-01, the epoch
+// of Chrome timestamps, and the Unix epoch
+const chromeEpochOffset = 11644473600
+
+// node is an entry of a Chrome Bookmarks file
+type node struct {
+	GUID      string `json:"guid"`
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	URL       string `json:"url"`
+	DateAdded string `json:"date_added"`
+	Children  []node `json:"children"`
+}
+
+// Fetcher reads bookmarks from the Bookmarks JSON file of a Chrome or
+// Chromium profile
+type Fetcher struct {
+	Path string
+}
+
+// NewFetcher creates a fetcher reading the Chrome Bookmarks file at path
+func NewFetcher(path string) *Fetcher {
+	return &Fetcher{Path: path}
+}
+
+// GetBookmarks reads all bookmarks from the Bookmarks file. The bookmarks
+// bar maps to the toolbar root, other bookmarks to unfiled and mobile
+// bookmarks to mobile, like the Firefox roots.
+func (f *Fetcher) GetBookmarks(ctx context.Context) (*firefox.BookmarksRoot, error) {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Chrome bookmarks: %w", err)
+	}
+
+	var file struct {
+		Roots struct {
+			BookmarkBar node `json:"bookmark_bar"`
+			Other       node `json:"other"`
+			Synced      node `json:"synced"`
+		} `json:"roots"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	var root firefox.BookmarksRoot
+	root.Bookmarks.Toolbar = rootFolder("toolbar", file.Roots.BookmarkBar)
+	root.Bookmarks.Unfiled = rootFolder("unfiled", file.Roots.Other)
+	root.Bookmarks.Mobile = rootFolder("mobile", file.Roots.Synced)
+	root.Bookmarks.Menu = bookmarks.Bookmark{ID: "menu", Title: "menu", Type: bookmarks.TypeFolder}
+	return &root, nil
+}
+
+// rootFolder converts a Chrome root, named and identified like in ffsclient output
+func rootFolder(name string, n node) bookmarks.Bookmark {
+	folder := n.bookmark()
+	folder.ID = name
+	folder.Title = name
+	folder.Type = bookmarks.TypeFolder
+	return folder
+}
+
+// bookmark converts a node and its children
+func (n node) bookmark() bookmarks.Bookmark {
+	bookmark := bookmarks.Bookmark{
+		ID:    n.GUID,
+		Title: n.Name,
+		URI:   n.URL,
+	}
+	if bookmark.ID == "" {
+		bookmark.ID = n.ID
+	}
+
+	// date_added is in microseconds since 1601-01-01
+	if micros, err := strconv.ParseInt(n.DateAdded, 10, 64); err == nil && micros > 0 {
+		added := time.Unix(micros/1e6-chromeEpochOffset, 0)
+		bookmark.AddedUnix = added.Unix()
+		bookmark.Added = added.Format(time.RFC3339)
+	}
+
+	switch n.Type {
+	case "url":
+		bookmark.Type = bookmarks.TypeBookmark
+		if bookmark.Title == "" {
+			bookmark.Title = n.URL
+		}
+	case "folder":
+		bookmark.Type = bookmarks.TypeFolder
+		for _, child := range n.Children {
+			bookmark.Children = append(bookmark.Children, child.bookmark())
+		}
+	default:
+		bookmark.Type = n.Type
+	}
+	return bookmark
+}
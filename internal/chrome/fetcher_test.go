@@ -0,0 +1,65 @@
+package chrome
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+)
+
+func TestFetcher(t *testing.T) {
+	root, err := NewFetcher("testdata/Bookmarks").GetBookmarks(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Roots are named like in ffsclient output
+	for name, folder := range map[string]bookmarks.Bookmark{
+		"toolbar": root.Bookmarks.Toolbar,
+		"unfiled": root.Bookmarks.Unfiled,
+		"mobile":  root.Bookmarks.Mobile,
+		"menu":    root.Bookmarks.Menu,
+	} {
+		if folder.ID != name || folder.Title != name || folder.Type != bookmarks.TypeFolder {
+			t.Errorf("got %s root %+v", name, folder)
+		}
+	}
+
+	page := root.Path("toolbar/Reading/Go")
+	if page == nil {
+		t.Fatal("nested bookmark not found")
+	}
+	want := bookmarks.Bookmark{
+		ID:        "5b8e0c1a-7f3d-4e2a-9c6b-1d0e2f3a4b5c",
+		Title:     "Go",
+		Type:      bookmarks.TypeBookmark,
+		URI:       "https://go.dev/",
+		AddedUnix: 1709294400,
+	}
+	if page.ID != want.ID || page.Title != want.Title || page.Type != want.Type || page.URI != want.URI || page.AddedUnix != want.AddedUnix {
+		t.Errorf("got bookmark %+v, want %+v", *page, want)
+	}
+
+	// Untitled bookmarks without a GUID fall back to the URL and the numeric ID
+	untitled := root.Bookmarks.Toolbar.Children[1]
+	if untitled.ID != "7" || untitled.Title != "https://example.com/untitled" || untitled.AddedUnix != 0 {
+		t.Errorf("got untitled bookmark %+v", untitled)
+	}
+
+	if later := root.Path("unfiled/Later"); later == nil || later.URI != "https://example.com/later" {
+		t.Errorf("got other bookmark %+v", later)
+	}
+	if len(root.Bookmarks.Mobile.Children) != 0 {
+		t.Errorf("got mobile bookmarks %+v, want none", root.Bookmarks.Mobile.Children)
+	}
+}
+
+func TestFetcherErrors(t *testing.T) {
+	if _, err := NewFetcher(filepath.Join(t.TempDir(), "Bookmarks")).GetBookmarks(context.Background()); err == nil {
+		t.Error("got no error for a missing file")
+	}
+	if _, err := NewFetcher("fetcher_test.go").GetBookmarks(context.Background()); err == nil {
+		t.Error("got no error for a file that isn't JSON")
+	}
+}
@@ -0,0 +1,125 @@
+// Configuration file loading
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Config contains settings loaded from the configuration file
+type Config struct {
+	Hooks HooksConfig `yaml:"hooks"`
+	Tags  TagsConfig  `yaml:"tags,omitempty"`
+	// PaywallDomains are added to the built-in list of paywalled news sites
+	PaywallDomains []string `yaml:"paywall_domains,omitempty"`
+	// TrackingParams are added to the built-in list of query parameters
+	// stripped from URLs for cache keys and screenshots
+	TrackingParams []string `yaml:"tracking_params,omitempty"`
+	// SyncConflictPatterns replace the built-in file name patterns of sync
+	// tool conflict copies
+	SyncConflictPatterns []string `yaml:"sync_conflict_patterns,omitempty"`
+}
+
+// TagsConfig contains tag normalization settings
+type TagsConfig struct {
+	// Synonyms maps canonical tags to aliases replaced by them
+	Synonyms map[string][]string `yaml:"synonyms,omitempty"`
+}
+
+// Aliases returns the canonical tags keyed by alias
+func (t TagsConfig) Aliases() map[string]string {
+	aliases := make(map[string]string)
+	for canonical, synonyms := range t.Synonyms {
+		for _, alias := range synonyms {
+			aliases[alias] = canonical
+		}
+	}
+	return aliases
+}
+
+// HooksConfig contains shell commands run around a sync
+type HooksConfig struct {
+	// PreRun is run before bookmarks are processed
+	PreRun string `yaml:"pre_run,omitempty"`
+	// PostRun is run after all bookmarks have been processed
+	PostRun string `yaml:"post_run,omitempty"`
+	// PostCreate is run after each new note is written
+	PostCreate string `yaml:"post_create,omitempty"`
+	// Timeout limits how long a single hook may run
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+// DefaultHookTimeout is used when no hook timeout is configured
+const DefaultHookTimeout = 30 * time.Second
+
+// Load reads the configuration file at path
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s:\n%w", path, describeDecodeError(err))
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s:\n%w", path, err)
+	}
+
+	if cfg.Hooks.Timeout == 0 {
+		cfg.Hooks.Timeout = DefaultHookTimeout
+	}
+
+	return &cfg, nil
+}
+
+// Default returns the configuration used when no file is given
+func Default() *Config {
+	return &Config{
+		Hooks: HooksConfig{Timeout: DefaultHookTimeout},
+	}
+}
+
+const configDoc = `# Hooks are run with "sh -c". Non-zero exits are logged as warnings,
+# or fail the run (pre_run/post_run) or bookmark (post_create) with -strict-hooks.
+#
+# post_create receives the note as positional arguments and environment:
+#   $1 / FFBM_NOTE_PATH  path of the written note
+#   $2 / FFBM_URL        bookmark URL
+#   $3 / FFBM_TITLE      bookmark title
+#   $4 / FFBM_ID         bookmark ID
+# With -mode mirror notes are written to a build directory that replaces the
+# output at the end of the run, and post_create is not run for zip output.
+# pre_run and post_run receive FFBM_OUTPUT with the output directory.
+# post_run also receives the failed bookmarks:
+#   FFBM_FAILED          number of failed bookmarks
+#   FFBM_FAILURES        counts by category, e.g. fetch-not-found=2,fetch-timeout=1
+#
+# tags.synonyms maps a canonical tag to aliases written as the canonical tag,
+# e.g. {go: [golang, go-lang]}. Tags are lowercased and slugified first.
+#
+# Short pages of paywall_domains, and short pages with a subscription
+# prompt, are tagged paywalled.
+#
+# tracking_params are query parameters ignored when caching content and taking
+# screenshots, in addition to utm_*, fbclid, gclid and similar. A trailing *
+# matches any parameter with that prefix.
+#
+# sync_conflict_patterns are file name globs of conflict copies made by sync
+# tools, e.g. "*conflicted copy*" (Dropbox) or "*.sync-conflict-*" (Syncthing).
+`
+
+// String renders the configuration as documented YAML
+func (c *Config) String() string {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Sprintf("# failed to render config: %v\n", err)
+	}
+	return configDoc + string(data)
+}
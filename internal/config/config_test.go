@@ -0,0 +1,110 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	cfg, err := Load(writeConfig(t, `hooks:
+  pre_run: echo start
+  post_create: ./tag.sh "$1"
+  timeout: 5s
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := HooksConfig{PreRun: "echo start", PostCreate: `./tag.sh "$1"`, Timeout: 5 * time.Second}
+	if cfg.Hooks != want {
+		t.Errorf("got hooks %+v, want %+v", cfg.Hooks, want)
+	}
+}
+
+func TestLoadDefaultTimeout(t *testing.T) {
+	cfg, err := Load(writeConfig(t, "hooks:\n  post_run: echo done\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Hooks.Timeout != DefaultHookTimeout {
+		t.Errorf("got timeout %s, want the default", cfg.Hooks.Timeout)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	if _, err := Load(writeConfig(t, "hooks: [not, a, map]\n")); err == nil {
+		t.Error("got no error for an invalid config")
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("got no error for a missing config")
+	}
+}
+
+func TestString(t *testing.T) {
+	cfg := Default()
+	cfg.Hooks.PostCreate = "echo created"
+	out := cfg.String()
+
+	// The hook contract is documented in -print-config output
+	for _, part := range []string{"$1 / FFBM_NOTE_PATH", "FFBM_OUTPUT", "post_create: echo created", "timeout: 30s"} {
+		if !strings.Contains(out, part) {
+			t.Errorf("printed config is missing %q:\n%s", part, out)
+		}
+	}
+}
+
+func TestLoadUnknownKey(t *testing.T) {
+	_, err := Load(writeConfig(t, "hooks:\n  post_craete: echo created\n"))
+	if err == nil {
+		t.Fatal("got no error for an unknown key")
+	}
+	if want := `line 2: unknown key "post_craete", did you mean "post_create"?`; !strings.Contains(err.Error(), want) {
+		t.Errorf("got error %q, want it to contain %q", err, want)
+	}
+
+	_, err = Load(writeConfig(t, "notifications:\n  url: x\n"))
+	if err == nil || strings.Contains(err.Error(), "did you mean") {
+		t.Errorf("got error %v, want an unknown key without a suggestion", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	_, err := Load(writeConfig(t, "hooks:\n  timeout: -5s\n"))
+	if err == nil || !strings.Contains(err.Error(), "hooks.timeout must not be negative") {
+		t.Errorf("got error %v, want a negative timeout rejected", err)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	schema, err := Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The shipped schema is regenerated with make schema
+	shipped, err := os.ReadFile("../../docs/config.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(shipped)) != string(schema) {
+		t.Errorf("docs/config.schema.json is out of date, run make schema:\n%s", schema)
+	}
+
+	for _, part := range []string{`"post_create"`, `"additionalProperties": false`, `"pattern"`} {
+		if !strings.Contains(string(schema), part) {
+			t.Errorf("schema is missing %s", part)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// unknownFieldRe matches yaml.v2 strict decoding errors for unknown keys
+var unknownFieldRe = regexp.MustCompile(`^line (\d+): field (\S+) not found in type (\S+)$`)
+
+// describeDecodeError rewrites yaml decoding errors with suggestions for misspelled keys
+func describeDecodeError(err error) error {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return err
+	}
+
+	keys := knownKeys(reflect.TypeOf(Config{}))
+
+	var msgs []string
+	for _, msg := range typeErr.Errors {
+		m := unknownFieldRe.FindStringSubmatch(msg)
+		if m == nil {
+			msgs = append(msgs, msg)
+			continue
+		}
+
+		msg = fmt.Sprintf("line %s: unknown key %q", m[1], m[2])
+		if suggestion := closestKey(m[2], keys[m[3]]); suggestion != "" {
+			msg += fmt.Sprintf(", did you mean %q?", suggestion)
+		}
+		msgs = append(msgs, msg)
+	}
+
+	return errors.New(strings.Join(msgs, "\n"))
+}
+
+// knownKeys collects yaml keys of all structs reachable from t, keyed by type name
+func knownKeys(t reflect.Type) map[string][]string {
+	keys := make(map[string][]string)
+
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct || t == reflect.TypeOf(time.Time{}) {
+			return
+		}
+		if _, ok := keys[t.String()]; ok {
+			return
+		}
+
+		keys[t.String()] = nil
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if name := yamlName(field); name != "" {
+				keys[t.String()] = append(keys[t.String()], name)
+				walk(field.Type)
+			}
+		}
+	}
+	walk(t)
+
+	return keys
+}
+
+// yamlName returns the yaml key of a struct field, or "" if it isn't serialized
+func yamlName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return strings.ToLower(field.Name)
+	}
+	return name
+}
+
+// closestKey returns the known key most similar to key, if any is close enough
+func closestKey(key string, known []string) string {
+	best, bestDist := "", len(key)/2+1
+	for _, k := range known {
+		if d := levenshtein(key, k); d < bestDist {
+			best, bestDist = k, d
+		}
+	}
+	return best
+}
+
+// levenshtein computes the edit distance between two strings
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
+
+// Validate checks the configuration for values that can't work
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Hooks.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("hooks.timeout must not be negative"))
+	}
+
+	canonicals := make(map[string]string)
+	for _, canonical := range slices.Sorted(maps.Keys(c.Tags.Synonyms)) {
+		for _, alias := range c.Tags.Synonyms[canonical] {
+			if other, ok := canonicals[alias]; ok && other != canonical {
+				errs = append(errs, fmt.Errorf("tags.synonyms: alias %q is listed for both %q and %q", alias, other, canonical))
+			}
+			canonicals[alias] = canonical
+		}
+	}
+
+	return errors.Join(errs...)
+}
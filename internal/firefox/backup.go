@@ -0,0 +1,108 @@
+package firefox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+)
+
+// KindBackup is the -source kind reading a JSON backup
+const KindBackup = "backup"
+
+// backupItem is an entry of a Firefox bookmarks JSON backup
+type backupItem struct {
+	GUID      string       `json:"guid"`
+	Title     string       `json:"title"`
+	DateAdded int64        `json:"dateAdded"`
+	TypeCode  int          `json:"typeCode"`
+	URI       string       `json:"uri"`
+	Tags      string       `json:"tags"`
+	Keyword   string       `json:"keyword"`
+	Children  []backupItem `json:"children"`
+}
+
+// BackupFetcher reads bookmarks from a JSON backup, as written by
+// "Backup..." in the Firefox bookmarks library
+type BackupFetcher struct {
+	Path string
+}
+
+// NewBackupFetcher creates a fetcher reading the JSON backup at path
+func NewBackupFetcher(path string) *BackupFetcher {
+	return &BackupFetcher{Path: path}
+}
+
+// GetBookmarks reads all bookmarks from the backup
+func (f *BackupFetcher) GetBookmarks(ctx context.Context) (*BookmarksRoot, error) {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bookmarks backup: %w", err)
+	}
+
+	var backup backupItem
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	var root BookmarksRoot
+	for _, item := range backup.Children {
+		name, ok := placesRoots[item.GUID]
+		if !ok {
+			continue
+		}
+
+		// Roots are named and identified like in ffsclient output
+		folder := item.bookmark()
+		folder.ID = name
+		folder.Title = name
+
+		switch name {
+		case "menu":
+			root.Bookmarks.Menu = folder
+		case "mobile":
+			root.Bookmarks.Mobile = folder
+		case "toolbar":
+			root.Bookmarks.Toolbar = folder
+		case "unfiled":
+			root.Bookmarks.Unfiled = folder
+		}
+	}
+
+	return &root, nil
+}
+
+// bookmark converts a backup entry and its children to a bookmark tree.
+// Type codes match the item types of the places database.
+func (item backupItem) bookmark() bookmarks.Bookmark {
+	// Backups store dateAdded in microseconds
+	added := time.UnixMicro(item.DateAdded)
+	bookmark := bookmarks.Bookmark{
+		Added:     added.Format(time.RFC3339),
+		AddedUnix: added.Unix(),
+		ID:        item.GUID,
+		Title:     item.Title,
+		URI:       item.URI,
+		Keyword:   item.Keyword,
+	}
+	if item.Tags != "" {
+		bookmark.Tags = strings.Split(item.Tags, ",")
+	}
+
+	switch item.TypeCode {
+	case placesTypeBookmark:
+		bookmark.Type = bookmarks.TypeBookmark
+	case placesTypeSeparator:
+		bookmark.Type = bookmarks.TypeSeparator
+	case placesTypeFolder:
+		bookmark.Type = bookmarks.TypeFolder
+		for _, child := range item.Children {
+			bookmark.Children = append(bookmark.Children, child.bookmark())
+		}
+	}
+	return bookmark
+}
@@ -0,0 +1,48 @@
+package firefox
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+)
+
+func TestBackupFetcher(t *testing.T) {
+	root, err := NewBackupFetcher("testdata/backup.json").GetBookmarks(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Roots are named like in ffsclient output, whatever their title
+	toolbar := root.Path("toolbar")
+	if toolbar == nil || toolbar.ID != "toolbar" {
+		t.Fatalf("got toolbar %+v, want the toolbar root", toolbar)
+	}
+	if root.Bookmarks.Unfiled.Title != "unfiled" || root.Bookmarks.Mobile.Title != "" {
+		t.Errorf("got unfiled %q and mobile %q roots", root.Bookmarks.Unfiled.Title, root.Bookmarks.Mobile.Title)
+	}
+
+	page := root.Path("toolbar/Reading/Go")
+	if page == nil {
+		t.Fatal("nested bookmark not found")
+	}
+	if page.Type != bookmarks.TypeBookmark || page.URI != "https://go.dev/" || page.AddedUnix != 1709294400 {
+		t.Errorf("got bookmark %+v", page)
+	}
+	if folder := root.Path("toolbar/Reading"); folder == nil || folder.Type != bookmarks.TypeFolder {
+		t.Errorf("got folder %+v", folder)
+	}
+	if menu := root.Bookmarks.Menu; len(menu.Children) != 1 || menu.Children[0].Type != bookmarks.TypeSeparator {
+		t.Errorf("got menu children %+v, want a separator", menu.Children)
+	}
+}
+
+func TestBackupFetcherErrors(t *testing.T) {
+	if _, err := NewBackupFetcher(filepath.Join(t.TempDir(), "missing.json")).GetBookmarks(context.Background()); err == nil {
+		t.Error("got no error for a missing backup")
+	}
+	if _, err := NewBackupFetcher("backup_test.go").GetBookmarks(context.Background()); err == nil {
+		t.Error("got no error for a file that isn't JSON")
+	}
+}
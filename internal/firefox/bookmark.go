@@ -1,6 +1,8 @@
 package firefox
 
 import (
+	"fmt"
+	"log/slog"
 	"strings"
 
 	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
@@ -18,6 +20,44 @@ type BookmarksRoot struct {
 	Unreferenced []string `json:"unreferenced"`
 }
 
+// RootKeys are the structural keys of the root folders, in the order of
+// Roots. Unlike root titles, they don't depend on the Firefox locale.
+var RootKeys = []string{"menu", "mobile", "toolbar", "unfiled"}
+
+// Roots returns all root folders
+func (root *BookmarksRoot) Roots() []*bookmarks.Bookmark {
+	return []*bookmarks.Bookmark{
+		&root.Bookmarks.Menu,
+		&root.Bookmarks.Mobile,
+		&root.Bookmarks.Toolbar,
+		&root.Bookmarks.Unfiled,
+	}
+}
+
+// missingRoot reports whether a root folder is absent from the source, like
+// the mobile root of profiles that never synced a phone
+func missingRoot(folder *bookmarks.Bookmark) bool {
+	return folder.Title == "" && len(folder.Children) == 0
+}
+
+// Root returns the root folder with a structural key or title, nil if there
+// is none
+func (root *BookmarksRoot) Root(name string) *bookmarks.Bookmark {
+	for i, folder := range root.Roots() {
+		if missingRoot(folder) {
+			continue
+		}
+		if strings.EqualFold(name, RootKeys[i]) || (folder.Title != "" && folder.Title == name) {
+			return folder
+		}
+	}
+	return nil
+}
+
+// Path returns the folder at a slash separated path starting with a root key
+// or title, like "toolbar/news". Folders below the root are matched by
+// title. A path starting with another folder name is resolved against the
+// first folder of that name found in any root.
 func (root *BookmarksRoot) Path(path string) *bookmarks.Bookmark {
 	parts := strings.Split(path, "/")
 
@@ -25,14 +65,71 @@ func (root *BookmarksRoot) Path(path string) *bookmarks.Bookmark {
 		return nil
 	}
 
-	switch parts[0] {
-	case "menu":
-		return root.Bookmarks.Menu.Path(path)
-	case "mobile":
-		return root.Bookmarks.Mobile.Path(path)
-	case "toolbar":
-		return root.Bookmarks.Toolbar.Path(path)
+	if folder := root.Root(parts[0]); folder != nil {
+		parts[0] = folder.Title
+		return folder.Path(strings.Join(parts, "/"))
 	}
 
-	return nil
+	var matches []*bookmarks.Bookmark
+	var matchPaths []string
+	for _, folder := range root.Roots() {
+		findFolders(folder, parts[0], folder.Title, &matches, &matchPaths)
+	}
+
+	if len(matches) == 0 {
+		return nil
+	}
+
+	if len(matches) > 1 {
+		slog.Warn("folder name is ambiguous, using the first match", "folder", parts[0], "matches", matchPaths)
+	}
+
+	return matches[0].Path(path)
+}
+
+// findFolders collects folders with the given title below a folder
+func findFolders(folder *bookmarks.Bookmark, title string, path string, matches *[]*bookmarks.Bookmark, paths *[]string) {
+	for i := range folder.Children {
+		child := &folder.Children[i]
+		if child.Type != bookmarks.TypeFolder {
+			continue
+		}
+
+		childPath := path + "/" + child.Title
+		if child.Title == title {
+			*matches = append(*matches, child)
+			*paths = append(*paths, childPath)
+		}
+		findFolders(child, title, childPath, matches, paths)
+	}
+}
+
+// FolderPaths returns the paths of all folders for display, starting with the
+// structural key of their root, which -folder accepts like the root title.
+// Roots with a localized title show it next to their key.
+func (root *BookmarksRoot) FolderPaths() []string {
+	var paths []string
+	for i, folder := range root.Roots() {
+		if missingRoot(folder) {
+			continue
+		}
+
+		key := RootKeys[i]
+		if folder.Title != "" && folder.Title != key {
+			paths = append(paths, fmt.Sprintf("%s (%s)", key, folder.Title))
+		} else {
+			paths = append(paths, key)
+		}
+
+		prefix := folder.Title + "/"
+		if folder.Title == "" {
+			prefix = ""
+		}
+		for path, bookmark := range folder.All() {
+			if rel, ok := strings.CutPrefix(path, prefix); ok && rel != "" && bookmark.Type == bookmarks.TypeFolder {
+				paths = append(paths, key+"/"+rel)
+			}
+		}
+	}
+	return paths
 }
@@ -0,0 +1,176 @@
+package firefox
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+)
+
+func testFolder(title string, children ...bookmarks.Bookmark) bookmarks.Bookmark {
+	return bookmarks.Bookmark{ID: title, Title: title, Type: bookmarks.TypeFolder, Children: children}
+}
+
+// testRoot has folders in the toolbar, menu and unfiled roots and no mobile root
+func testRoot() *BookmarksRoot {
+	root := &BookmarksRoot{}
+	root.Bookmarks.Menu = testFolder("menu", testFolder("Recipes"))
+	root.Bookmarks.Toolbar = testFolder("toolbar",
+		testFolder("News", testFolder("Go")),
+		bookmarks.Bookmark{ID: "page", Title: "Page", Type: bookmarks.TypeBookmark, URI: "https://example.com/"},
+	)
+	root.Bookmarks.Unfiled = testFolder("unfiled", testFolder("Work", testFolder("Project")))
+	return root
+}
+
+// localizedRoot has roots titled like a German Firefox, an untitled unfiled
+// root and no mobile root
+func localizedRoot() *BookmarksRoot {
+	root := &BookmarksRoot{}
+	root.Bookmarks.Menu = testFolder("Lesezeichen-Menü", testFolder("Work"))
+	root.Bookmarks.Toolbar = testFolder("Lesezeichen-Symbolleiste",
+		testFolder("News", testFolder("Go")),
+		bookmarks.Bookmark{ID: "page", Title: "Page", Type: bookmarks.TypeBookmark, URI: "https://example.com/"},
+	)
+	root.Bookmarks.Unfiled = testFolder("", testFolder("Later"))
+	return root
+}
+
+func TestRootPath(t *testing.T) {
+	root := testRoot()
+
+	for path, want := range map[string]string{
+		"toolbar":              "toolbar",
+		"toolbar/News/Go":      "Go",
+		"menu/Recipes":         "Recipes",
+		"unfiled":              "unfiled",
+		"unfiled/Work":         "Work",
+		"unfiled/Work/Project": "Project",
+		// Bare folder names are looked up in all roots
+		"Work":         "Work",
+		"News/Go":      "Go",
+		"Work/Project": "Project",
+	} {
+		folder := root.Path(path)
+		if folder == nil {
+			t.Errorf("%s: found no folder", path)
+		} else if folder.Title != want {
+			t.Errorf("%s: got folder %q, want %q", path, folder.Title, want)
+		}
+	}
+
+	for _, path := range []string{"mobile", "toolbar/Work", "unfiled/Missing", "Missing", "Work/Missing", ""} {
+		if folder := root.Path(path); folder != nil {
+			t.Errorf("%q: got folder %q, want none", path, folder.Title)
+		}
+	}
+
+	// Every folder is found again by its path
+	for _, folder := range root.Roots() {
+		if folder.Title == "" {
+			continue
+		}
+		for path, b := range folder.All() {
+			if b.Type != bookmarks.TypeFolder {
+				continue
+			}
+			if got := root.Path(path); got == nil || got.ID != b.ID {
+				t.Errorf("%s: did not resolve back to folder %q", path, b.Title)
+			}
+		}
+	}
+}
+
+func TestRootPathAmbiguous(t *testing.T) {
+	root := &BookmarksRoot{}
+	root.Bookmarks.Menu = testFolder("menu", testFolder("Projects", testFolder("Menu only")))
+	root.Bookmarks.Toolbar = testFolder("toolbar", testFolder("Archive", testFolder("Projects", testFolder("Toolbar only"))))
+	root.Bookmarks.Unfiled = testFolder("unfiled", testFolder("Projects"))
+
+	// The first match in root order wins
+	if folder := root.Path("Projects/Menu only"); folder == nil {
+		t.Error("got no folder for the first match")
+	}
+	if folder := root.Path("Projects/Toolbar only"); folder != nil {
+		t.Errorf("got folder %q below a later match, want none", folder.Title)
+	}
+
+	// Qualifying the path with a root selects a specific folder
+	if folder := root.Path("toolbar/Archive/Projects/Toolbar only"); folder == nil {
+		t.Error("got no folder for a qualified path")
+	}
+	if folder := root.Path("unfiled/Projects"); folder == nil || folder != &root.Bookmarks.Unfiled.Children[0] {
+		t.Errorf("got folder %+v, want the unfiled one", folder)
+	}
+}
+
+func TestRootPathLocalized(t *testing.T) {
+	root := localizedRoot()
+
+	for path, want := range map[string]string{
+		"toolbar":                       "Lesezeichen-Symbolleiste",
+		"Toolbar/News":                  "News",
+		"toolbar/News/Go":               "Go",
+		"Lesezeichen-Symbolleiste/News": "News",
+		"menu/Work":                     "Work",
+		"unfiled/Later":                 "Later",
+		// Resolved against the first folder of that name in any root
+		"News/Go": "Go",
+	} {
+		folder := root.Path(path)
+		if folder == nil {
+			t.Errorf("%s: found no folder", path)
+		} else if folder.Title != want {
+			t.Errorf("%s: got folder %q, want %q", path, folder.Title, want)
+		}
+	}
+
+	for _, path := range []string{"mobile", "mobile/News", "toolbar/Missing", "Missing"} {
+		if folder := root.Path(path); folder != nil {
+			t.Errorf("%s: got folder %q, want none", path, folder.Title)
+		}
+	}
+}
+
+func TestFolderPaths(t *testing.T) {
+	for name, tt := range map[string]struct {
+		root *BookmarksRoot
+		want []string
+	}{
+		"english": {testRoot(), []string{
+			"menu",
+			"menu/Recipes",
+			"toolbar",
+			"toolbar/News",
+			"toolbar/News/Go",
+			"unfiled",
+			"unfiled/Work",
+			"unfiled/Work/Project",
+		}},
+		"localized": {localizedRoot(), []string{
+			"menu (Lesezeichen-Menü)",
+			"menu/Work",
+			"toolbar (Lesezeichen-Symbolleiste)",
+			"toolbar/News",
+			"toolbar/News/Go",
+			"unfiled",
+			"unfiled/Later",
+		}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got := tt.root.FolderPaths()
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got folder paths %q, want %q", got, tt.want)
+			}
+
+			// Every listed path is accepted by -folder
+			for _, path := range got {
+				path, _, _ = strings.Cut(path, " (")
+				if tt.root.Path(path) == nil {
+					t.Errorf("%s: listed path does not resolve to a folder", path)
+				}
+			}
+		})
+	}
+}
@@ -4,31 +4,82 @@
 package firefox
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"os/exec"
+	"strings"
+	"time"
 )
 
+// BookmarksFetcher fetches the Firefox bookmark tree
+type BookmarksFetcher interface {
+	GetBookmarks(ctx context.Context) (*BookmarksRoot, error)
+}
+
 // FirefoxFetcher handles fetching bookmarks from Firefox
 type FirefoxFetcher struct {
 	FFSyncCmd string
+	// SessionFile is passed as --sessionfile, empty uses the ffsclient default
+	SessionFile string
+	// ExtraArgs are appended to the ffsclient command line
+	ExtraArgs []string
+	// Timeout limits how long ffsclient may run, zero means no limit
+	Timeout time.Duration
 }
 
-// NewFirefoxFetcher creates a new Firefox bookmarks fetcher
-func NewFirefoxFetcher() *FirefoxFetcher {
-	return &FirefoxFetcher{FFSyncCmd: "ffsclient"}
+// KindFFSClient is the -source kind reading bookmarks with ffsclient
+const KindFFSClient = "ffsclient"
+
+// DefaultFFSyncCmd is the ffsclient command looked up in PATH
+const DefaultFFSyncCmd = "ffsclient"
+
+// DefaultFFSyncTimeout is the default time limit for an ffsclient run
+const DefaultFFSyncTimeout = 60 * time.Second
+
+// NewFirefoxFetcher creates a new Firefox bookmarks fetcher running the
+// ffsclient binary at cmd, or the default one from PATH if cmd is empty
+func NewFirefoxFetcher(cmd string, timeout time.Duration) *FirefoxFetcher {
+	if cmd == "" {
+		cmd = DefaultFFSyncCmd
+	}
+	return &FirefoxFetcher{FFSyncCmd: cmd, Timeout: timeout}
 }
 
 // GetBookmarks fetches all bookmarks from Firefox
-func (f *FirefoxFetcher) GetBookmarks() (*BookmarksRoot, error) {
-	cmd := exec.Command(f.FFSyncCmd, "bookmarks", "list", "--format=json")
+func (f *FirefoxFetcher) GetBookmarks(ctx context.Context) (*BookmarksRoot, error) {
+	if _, err := exec.LookPath(f.FFSyncCmd); err != nil {
+		return nil, fmt.Errorf("ffsclient not found at %q, install it or set -ffsclient-path: %w", f.FFSyncCmd, err)
+	}
+
+	if f.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.Timeout)
+		defer cancel()
+	}
+
+	args := []string{"bookmarks", "list", "--format=json"}
+	if f.SessionFile != "" {
+		args = append(args, "--sessionfile", f.SessionFile)
+	}
+	args = append(args, f.ExtraArgs...)
+
+	cmd := exec.CommandContext(ctx, f.FFSyncCmd, args...)
+	// Don't wait for output of processes left behind by a killed ffsclient
+	cmd.WaitDelay = time.Second
 	output, err := cmd.Output()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("ffsclient did not finish within %s, raise -ffsclient-timeout: %w", f.Timeout, ctx.Err())
+	}
 	if err != nil {
 		var exitErr *exec.ExitError
 		if errors.As(err, &exitErr) {
 			slog.Error("failed to execute ffsclient", "stderr", string(exitErr.Stderr))
+			if isAuthError(string(exitErr.Stderr)) {
+				return nil, fmt.Errorf("ffsclient is not logged in, run 'ffsclient login' first: %w", err)
+			}
 			return nil, err
 		}
 
@@ -42,3 +93,14 @@ func (f *FirefoxFetcher) GetBookmarks() (*BookmarksRoot, error) {
 
 	return &root, nil
 }
+
+// isAuthError checks whether ffsclient failed because of a missing or expired session
+func isAuthError(stderr string) bool {
+	stderr = strings.ToLower(stderr)
+	for _, hint := range []string{"login", "session", "unauthorized", "401", "auth"} {
+		if strings.Contains(stderr, hint) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package firefox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeFFSClient returns the path of the testdata/ffsclient.sh fixture and
+// the file it records its arguments to
+func fakeFFSClient(t *testing.T) (string, string) {
+	t.Helper()
+
+	script, err := filepath.Abs("testdata/ffsclient.sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := filepath.Join(t.TempDir(), "args")
+	t.Setenv("FFSCLIENT_ARGS", args)
+	return script, args
+}
+
+func TestFirefoxFetcher(t *testing.T) {
+	script, args := fakeFFSClient(t)
+
+	fetcher := NewFirefoxFetcher(script, time.Minute)
+	fetcher.SessionFile = "/home/user/.ffsclient"
+	root, err := fetcher.GetBookmarks(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.TrimSpace(string(data)), "bookmarks list --format=json --sessionfile /home/user/.ffsclient"; got != want {
+		t.Errorf("got arguments %q, want %q", got, want)
+	}
+
+	page := root.Path("toolbar/Reading/Go")
+	if page == nil {
+		t.Fatal("nested bookmark not found")
+	}
+	if page.URI != "https://go.dev/" || len(page.Tags) != 1 || page.Tags[0] != "golang" {
+		t.Errorf("got bookmark %+v", page)
+	}
+}
+
+func TestFirefoxFetcherErrors(t *testing.T) {
+	script, _ := fakeFFSClient(t)
+
+	_, err := NewFirefoxFetcher(filepath.Join(t.TempDir(), "ffsclient"), 0).GetBookmarks(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "-ffsclient-path") {
+		t.Errorf("got %v for a missing binary, want a hint at -ffsclient-path", err)
+	}
+
+	t.Setenv("FFSCLIENT_STDERR", "no session found")
+	_, err = NewFirefoxFetcher(script, 0).GetBookmarks(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "ffsclient login") {
+		t.Errorf("got %v without a session, want a hint at ffsclient login", err)
+	}
+}
@@ -0,0 +1,199 @@
+package firefox
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+)
+
+// KindHTML is the -source kind reading an HTML bookmark export
+const KindHTML = "html"
+
+var (
+	htmlTagRegex  = regexp.MustCompile(`(?i)<(/?dl|h3|a|hr)\b([^>]*)>`)
+	htmlAttrRegex = regexp.MustCompile(`([A-Za-z_][\w-]*)\s*=\s*"([^"]*)"`)
+)
+
+// HTMLFetcher reads bookmarks from a Netscape bookmark file, as written by
+// Firefox's "Export Bookmarks to HTML"
+type HTMLFetcher struct {
+	Path string
+}
+
+// NewHTMLFetcher creates a fetcher reading the bookmark export at path
+func NewHTMLFetcher(path string) *HTMLFetcher {
+	return &HTMLFetcher{Path: path}
+}
+
+// GetBookmarks parses the bookmark export. Bookmarks directly in the export
+// form the menu root, while the folders flagged as toolbar and unfiled
+// folders become the toolbar and unfiled roots.
+func (f *HTMLFetcher) GetBookmarks(ctx context.Context) (*BookmarksRoot, error) {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bookmarks file: %w", err)
+	}
+
+	return parseBookmarksHTML(string(data))
+}
+
+// htmlNode is a bookmark with its children still being collected
+type htmlNode struct {
+	bookmark bookmarks.Bookmark
+	children []*htmlNode
+}
+
+// tree converts the node and its children to a bookmark tree
+func (n *htmlNode) tree() bookmarks.Bookmark {
+	bookmark := n.bookmark
+	for _, child := range n.children {
+		bookmark.Children = append(bookmark.Children, child.tree())
+	}
+	return bookmark
+}
+
+// parseBookmarksHTML builds the bookmark tree from Netscape bookmark HTML
+func parseBookmarksHTML(content string) (*BookmarksRoot, error) {
+	menu := &htmlNode{bookmark: bookmarks.Bookmark{ID: "menu", Title: "menu", Type: bookmarks.TypeFolder}}
+	var toolbar, unfiled *htmlNode
+
+	// Folders being filled, innermost last
+	stack := []*htmlNode{menu}
+	// Folder whose heading was seen, waiting for its <DL>
+	var pending *htmlNode
+	opened := false
+
+	for _, match := range htmlTagRegex.FindAllStringSubmatchIndex(content, -1) {
+		tag := strings.ToLower(content[match[2]:match[3]])
+		attrs := parseHTMLAttrs(content[match[4]:match[5]])
+		parent := stack[len(stack)-1]
+
+		switch tag {
+		case "dl":
+			// The outermost list is the export itself
+			if !opened {
+				opened = true
+				continue
+			}
+			if pending == nil {
+				// List without a heading, keep filling the current folder
+				pending = parent
+			}
+			stack = append(stack, pending)
+			pending = nil
+		case "/dl":
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+			pending = nil
+		case "h3":
+			title := htmlElementText(content, match[1], "</h3>")
+			folder := &htmlNode{bookmark: bookmarks.Bookmark{
+				Title: title,
+				Type:  bookmarks.TypeFolder,
+			}}
+			setAdded(&folder.bookmark, attrs["add_date"])
+
+			switch {
+			case attrs["personal_toolbar_folder"] == "true" && toolbar == nil:
+				folder.bookmark.ID, folder.bookmark.Title = "toolbar", "toolbar"
+				toolbar = folder
+			case attrs["unfiled_bookmarks_folder"] == "true" && unfiled == nil:
+				folder.bookmark.ID, folder.bookmark.Title = "unfiled", "unfiled"
+				unfiled = folder
+			default:
+				folder.bookmark.ID = htmlID(folderPath(stack) + "/" + title)
+				parent.children = append(parent.children, folder)
+			}
+			pending = folder
+		case "a":
+			href := attrs["href"]
+			bookmark := bookmarks.Bookmark{
+				ID:    htmlID(href + "\n" + attrs["add_date"]),
+				Title: htmlElementText(content, match[1], "</a>"),
+				Type:  bookmarks.TypeBookmark,
+				URI:   href,
+				// Keywords are exported as SHORTCUTURL
+				Keyword: attrs["shortcuturl"],
+			}
+			if attrs["tags"] != "" {
+				bookmark.Tags = strings.Split(attrs["tags"], ",")
+			}
+			setAdded(&bookmark, attrs["add_date"])
+			if bookmark.Title == "" {
+				bookmark.Title = href
+			}
+			parent.children = append(parent.children, &htmlNode{bookmark: bookmark})
+			pending = nil
+		case "hr":
+			parent.children = append(parent.children, &htmlNode{bookmark: bookmarks.Bookmark{
+				ID:   htmlID(fmt.Sprintf("%s/separator-%d", folderPath(stack), len(parent.children))),
+				Type: bookmarks.TypeSeparator,
+			}})
+			pending = nil
+		}
+	}
+
+	root := &BookmarksRoot{}
+	root.Bookmarks.Menu = menu.tree()
+	if toolbar != nil {
+		root.Bookmarks.Toolbar = toolbar.tree()
+	}
+	if unfiled != nil {
+		root.Bookmarks.Unfiled = unfiled.tree()
+	}
+	return root, nil
+}
+
+// parseHTMLAttrs returns the attributes of a tag, with lowercase names
+func parseHTMLAttrs(s string) map[string]string {
+	attrs := make(map[string]string)
+	for _, attr := range htmlAttrRegex.FindAllStringSubmatch(s, -1) {
+		attrs[strings.ToLower(attr[1])] = html.UnescapeString(attr[2])
+	}
+	return attrs
+}
+
+// htmlElementText returns the unescaped text from start up to the closing tag
+func htmlElementText(content string, start int, closing string) string {
+	end := strings.Index(strings.ToLower(content[start:]), closing)
+	if end == -1 {
+		return ""
+	}
+	return strings.TrimSpace(html.UnescapeString(content[start : start+end]))
+}
+
+// setAdded sets the added time from an ADD_DATE attribute in Unix seconds
+func setAdded(bookmark *bookmarks.Bookmark, addDate string) {
+	added, err := strconv.ParseInt(addDate, 10, 64)
+	if err != nil {
+		return
+	}
+	bookmark.AddedUnix = added
+	bookmark.Added = time.Unix(added, 0).Format(time.RFC3339)
+}
+
+// folderPath returns the titles of the open folders
+func folderPath(stack []*htmlNode) string {
+	titles := make([]string, len(stack))
+	for i, folder := range stack {
+		titles[i] = folder.bookmark.Title
+	}
+	return strings.Join(titles, "/")
+}
+
+// htmlID derives a stable 12 character ID, like Firefox GUIDs, as the
+// export does not contain any
+func htmlID(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return base64.RawURLEncoding.EncodeToString(hash[:])[:12]
+}
@@ -0,0 +1,196 @@
+package firefox
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+)
+
+// Import formats of other bookmarking services, which are also their -source
+// kinds
+const (
+	ImportPocket   = "pocket"
+	ImportRaindrop = "raindrop"
+)
+
+// importedItem is a bookmark read from an export of another service
+type importedItem struct {
+	id      string
+	title   string
+	url     string
+	folder  string
+	tags    []string
+	created time.Time
+}
+
+// ImportFetcher reads bookmarks from a CSV export of another bookmarking
+// service. Items are placed in the toolbar root, with collections as folders.
+type ImportFetcher struct {
+	Format string
+	Path   string
+}
+
+// NewImportFetcher creates a fetcher reading an export in format at path
+func NewImportFetcher(format string, path string) *ImportFetcher {
+	return &ImportFetcher{Format: format, Path: path}
+}
+
+// GetBookmarks reads all bookmarks from the export
+func (f *ImportFetcher) GetBookmarks(ctx context.Context) (*BookmarksRoot, error) {
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s export: %w", f.Format, err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s export: %w", f.Format, err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	var items []importedItem
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s export: %w", f.Format, err)
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		var item importedItem
+		switch f.Format {
+		case ImportPocket:
+			item = pocketItem(field)
+		case ImportRaindrop:
+			item = raindropItem(field)
+		default:
+			return nil, fmt.Errorf("unsupported import format: %s", f.Format)
+		}
+		if item.url != "" {
+			items = append(items, item)
+		}
+	}
+
+	var root BookmarksRoot
+	root.Bookmarks.Toolbar = importedTree(items)
+	root.Bookmarks.Menu = bookmarks.Bookmark{ID: "menu", Title: "menu", Type: bookmarks.TypeFolder}
+	root.Bookmarks.Mobile = bookmarks.Bookmark{ID: "mobile", Title: "mobile", Type: bookmarks.TypeFolder}
+	root.Bookmarks.Unfiled = bookmarks.Bookmark{ID: "unfiled", Title: "unfiled", Type: bookmarks.TypeFolder}
+	return &root, nil
+}
+
+// pocketItem maps a row of a Pocket CSV export (title, url, time_added,
+// tags, status), placing archived items in an Archive folder
+func pocketItem(field func(string) string) importedItem {
+	item := importedItem{
+		title: field("title"),
+		url:   field("url"),
+	}
+	if added, err := strconv.ParseInt(field("time_added"), 10, 64); err == nil {
+		item.created = time.Unix(added, 0)
+	}
+	if tags := field("tags"); tags != "" {
+		item.tags = strings.Split(tags, "|")
+	}
+	if field("status") == "archive" {
+		item.folder = "Archive"
+	}
+	item.id = htmlID("pocket\n" + item.url + "\n" + field("time_added"))
+	return item
+}
+
+// raindropItem maps a row of a raindrop.io CSV export (id, title, url,
+// folder, tags, created), leaving unsorted items outside of folders
+func raindropItem(field func(string) string) importedItem {
+	item := importedItem{
+		title:  field("title"),
+		url:    field("url"),
+		folder: strings.Trim(field("folder"), "/"),
+	}
+	if item.folder == "Unsorted" {
+		item.folder = ""
+	}
+	if created, err := time.Parse(time.RFC3339, field("created")); err == nil {
+		item.created = created
+	}
+	for _, tag := range strings.Split(field("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			item.tags = append(item.tags, tag)
+		}
+	}
+	item.id = htmlID("raindrop\n" + field("id") + "\n" + item.url)
+	return item
+}
+
+// importedTree builds the toolbar root from imported items, creating a
+// folder for each slash separated collection path
+func importedTree(items []importedItem) bookmarks.Bookmark {
+	toolbar := &htmlNode{bookmark: bookmarks.Bookmark{ID: "toolbar", Title: "toolbar", Type: bookmarks.TypeFolder}}
+	folders := map[string]*htmlNode{"": toolbar}
+
+	var folderNode func(path string) *htmlNode
+	folderNode = func(path string) *htmlNode {
+		if node, ok := folders[path]; ok {
+			return node
+		}
+
+		parentPath, title := "", path
+		if i := strings.LastIndex(path, "/"); i != -1 {
+			parentPath, title = path[:i], path[i+1:]
+		}
+		parent := folderNode(parentPath)
+
+		node := &htmlNode{bookmark: bookmarks.Bookmark{
+			ID:    htmlID("folder\n" + path),
+			Title: title,
+			Type:  bookmarks.TypeFolder,
+		}}
+		parent.children = append(parent.children, node)
+		folders[path] = node
+		return node
+	}
+
+	for _, item := range items {
+		bookmark := bookmarks.Bookmark{
+			ID:    item.id,
+			Title: item.title,
+			Type:  bookmarks.TypeBookmark,
+			URI:   item.url,
+			Tags:  item.tags,
+		}
+		if bookmark.Title == "" {
+			bookmark.Title = item.url
+		}
+		if !item.created.IsZero() {
+			bookmark.AddedUnix = item.created.Unix()
+			bookmark.Added = item.created.Format(time.RFC3339)
+		}
+
+		parent := folderNode(item.folder)
+		parent.children = append(parent.children, &htmlNode{bookmark: bookmark})
+	}
+
+	return toolbar.tree()
+}
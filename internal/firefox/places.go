@@ -0,0 +1,177 @@
+package firefox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+
+	_ "modernc.org/sqlite"
+)
+
+// KindPlaces is the -source kind reading a places.sqlite database
+const KindPlaces = "places"
+
+// Places bookmark item types
+const (
+	placesTypeBookmark  = 1
+	placesTypeFolder    = 2
+	placesTypeSeparator = 3
+)
+
+// placesRoots maps Places root folder GUIDs to the root names used by ffsclient
+var placesRoots = map[string]string{
+	"menu________": "menu",
+	"mobile______": "mobile",
+	"toolbar_____": "toolbar",
+	"unfiled_____": "unfiled",
+}
+
+// PlacesFetcher reads bookmarks from a local Firefox places.sqlite database
+type PlacesFetcher struct {
+	Path string
+}
+
+// NewPlacesFetcher creates a fetcher reading the places.sqlite database at path
+func NewPlacesFetcher(path string) *PlacesFetcher {
+	return &PlacesFetcher{Path: path}
+}
+
+// placesItem is a row of the moz_bookmarks table joined with its URL
+type placesItem struct {
+	id        int64
+	itemType  int
+	parent    int64
+	title     string
+	dateAdded int64
+	guid      string
+	url       string
+}
+
+// GetBookmarks reads all bookmarks from the places database
+func (f *PlacesFetcher) GetBookmarks(ctx context.Context) (*BookmarksRoot, error) {
+	// A running Firefox keeps the database locked, so read from a copy
+	dir, err := os.MkdirTemp("", "ffbookmarks-places-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	dbPath := filepath.Join(dir, "places.sqlite")
+	if err := copyFile(f.Path, dbPath); err != nil {
+		return nil, fmt.Errorf("failed to copy places database: %w", err)
+	}
+	// Recent changes may still be in the write-ahead log
+	if err := copyFile(f.Path+"-wal", dbPath+"-wal"); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to copy places write-ahead log: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open places database: %w", err)
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT b.id, b.type, b.parent, COALESCE(b.title, ''), COALESCE(b.dateAdded, 0), b.guid, COALESCE(p.url, '')
+		FROM moz_bookmarks b
+		LEFT JOIN moz_places p ON b.fk = p.id
+		ORDER BY b.parent, b.position`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query bookmarks: %w", err)
+	}
+	defer rows.Close()
+
+	items := make(map[int64]placesItem)
+	children := make(map[int64][]int64)
+	for rows.Next() {
+		var item placesItem
+		if err := rows.Scan(&item.id, &item.itemType, &item.parent, &item.title, &item.dateAdded, &item.guid, &item.url); err != nil {
+			return nil, fmt.Errorf("failed to read bookmark: %w", err)
+		}
+		items[item.id] = item
+		children[item.parent] = append(children[item.parent], item.id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read bookmarks: %w", err)
+	}
+
+	var build func(id int64) bookmarks.Bookmark
+	build = func(id int64) bookmarks.Bookmark {
+		item := items[id]
+
+		// Places stores dateAdded in microseconds
+		added := time.UnixMicro(item.dateAdded)
+		bookmark := bookmarks.Bookmark{
+			Added:     added.Format(time.RFC3339),
+			AddedUnix: added.Unix(),
+			ID:        item.guid,
+			Title:     item.title,
+			URI:       item.url,
+		}
+
+		switch item.itemType {
+		case placesTypeBookmark:
+			bookmark.Type = bookmarks.TypeBookmark
+		case placesTypeSeparator:
+			bookmark.Type = bookmarks.TypeSeparator
+		case placesTypeFolder:
+			bookmark.Type = bookmarks.TypeFolder
+			for _, childID := range children[id] {
+				bookmark.Children = append(bookmark.Children, build(childID))
+			}
+		}
+		return bookmark
+	}
+
+	var root BookmarksRoot
+	for id, item := range items {
+		name, ok := placesRoots[item.guid]
+		if !ok {
+			continue
+		}
+
+		// Roots are named and identified like in ffsclient output
+		folder := build(id)
+		folder.ID = name
+		folder.Title = name
+
+		switch name {
+		case "menu":
+			root.Bookmarks.Menu = folder
+		case "mobile":
+			root.Bookmarks.Mobile = folder
+		case "toolbar":
+			root.Bookmarks.Toolbar = folder
+		case "unfiled":
+			root.Bookmarks.Unfiled = folder
+		}
+	}
+
+	return &root, nil
+}
+
+// copyFile copies the file at src to dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,9 @@
+#!/bin/sh
+# Fake ffsclient printing testdata/ffsclient.json, recording its arguments
+# to $FFSCLIENT_ARGS
+echo "$*" >"$FFSCLIENT_ARGS"
+if [ -n "$FFSCLIENT_STDERR" ]; then
+	echo "$FFSCLIENT_STDERR" >&2
+	exit 1
+fi
+cat "$(dirname "$0")/ffsclient.json"
@@ -0,0 +1,130 @@
+// User-defined shell hooks
+
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/config"
+)
+
+// Runner executes configured hooks
+type Runner struct {
+	cfg       config.HooksConfig
+	outputDir string
+	noteDir   string
+	strict    bool
+	warnings  int
+}
+
+// NewRunner creates a hook runner. Notes are written to noteDir, which differs
+// from outputDir while a mirror is built. With strict set, failing hooks return
+// errors instead of being counted as warnings.
+func NewRunner(cfg config.HooksConfig, outputDir, noteDir string, strict bool) *Runner {
+	return &Runner{
+		cfg:       cfg,
+		outputDir: outputDir,
+		noteDir:   noteDir,
+		strict:    strict,
+	}
+}
+
+// Warnings returns the number of hooks that failed without failing the run
+func (r *Runner) Warnings() int {
+	return r.warnings
+}
+
+// PreRun runs the pre-run hook
+func (r *Runner) PreRun() error {
+	return r.run("pre_run", r.cfg.PreRun, nil, "FFBM_OUTPUT="+r.outputDir)
+}
+
+// PostRun runs the post-run hook with the number of failed bookmarks by
+// category
+func (r *Runner) PostRun(failures map[string]int) error {
+	total := 0
+	var counts []string
+	for _, category := range slices.Sorted(maps.Keys(failures)) {
+		total += failures[category]
+		counts = append(counts, fmt.Sprintf("%s=%d", category, failures[category]))
+	}
+	return r.run("post_run", r.cfg.PostRun, nil,
+		"FFBM_OUTPUT="+r.outputDir,
+		"FFBM_FAILED="+strconv.Itoa(total),
+		"FFBM_FAILURES="+strings.Join(counts, ","),
+	)
+}
+
+// PostCreate runs the post-create hook for a newly written note
+func (r *Runner) PostCreate(path string, bookmark bookmarks.Bookmark) error {
+	notePath := filepath.Join(r.noteDir, path)
+	return r.run("post_create", r.cfg.PostCreate,
+		[]string{notePath, bookmark.URI, bookmark.Title, bookmark.ID},
+		"FFBM_OUTPUT="+r.noteDir,
+		"FFBM_NOTE_PATH="+notePath,
+		"FFBM_URL="+bookmark.URI,
+		"FFBM_TITLE="+bookmark.Title,
+		"FFBM_ID="+bookmark.ID,
+	)
+}
+
+func (r *Runner) run(name, command string, args []string, env ...string) error {
+	if command == "" {
+		return nil
+	}
+
+	timeout := r.cfg.Timeout
+	if timeout == 0 {
+		timeout = config.DefaultHookTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// The first argument after the command becomes $0
+	cmd := exec.CommandContext(ctx, "sh", append([]string{"-c", command, name}, args...)...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.WaitDelay = time.Second
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	start := time.Now()
+	err := cmd.Run()
+	slog.Debug("hook finished",
+		"hook", name,
+		"duration", time.Since(start),
+		"stdout", stdout.String(),
+		"stderr", stderr.String())
+
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+	err = fmt.Errorf("hook %s failed: %w", name, err)
+
+	if r.strict {
+		return err
+	}
+
+	slog.Warn("hook failed", "hook", name, "error", err, "stderr", stderr.String())
+	r.warnings++
+	return nil
+}
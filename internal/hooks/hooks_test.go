@@ -0,0 +1,154 @@
+package hooks
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/config"
+)
+
+// recordHook returns a hook command running the testdata/record.sh fixture
+// and the log it writes the arguments and environment of each call to
+func recordHook(t *testing.T) (string, string) {
+	t.Helper()
+
+	script, err := filepath.Abs("testdata/record.sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := filepath.Join(t.TempDir(), "hook.log")
+	t.Setenv("HOOK_LOG", log)
+	return script + ` "$@"`, log
+}
+
+func readLog(t *testing.T, log string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestPostCreate(t *testing.T) {
+	command, log := recordHook(t)
+	runner := NewRunner(config.HooksConfig{PostCreate: command}, "/vault", "/vault", false)
+
+	bookmark := bookmarks.Bookmark{ID: "abc", Title: "A title with spaces", URI: "https://example.com/page"}
+	if err := runner.PostCreate("Reading/example.com - A title with spaces.md", bookmark); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `args=/vault/Reading/example.com - A title with spaces.md https://example.com/page A title with spaces abc
+FFBM_OUTPUT=/vault
+FFBM_NOTE_PATH=/vault/Reading/example.com - A title with spaces.md
+FFBM_URL=https://example.com/page
+FFBM_TITLE=A title with spaces
+FFBM_ID=abc
+FFBM_FAILED=
+FFBM_FAILURES=
+`
+	if got := readLog(t, log); got != want {
+		t.Errorf("got hook call:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPostCreateMirror(t *testing.T) {
+	command, log := recordHook(t)
+	runner := NewRunner(config.HooksConfig{PostCreate: command, PostRun: command}, "/vault", "/.vault-mirror-1", false)
+
+	bookmark := bookmarks.Bookmark{ID: "abc", Title: "Title", URI: "https://example.com/"}
+	if err := runner.PostCreate("note.md", bookmark); err != nil {
+		t.Fatal(err)
+	}
+	if err := runner.PostRun(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Notes are in the build directory, the run hooks get the final output
+	got := readLog(t, log)
+	if !strings.Contains(got, "args=/.vault-mirror-1/note.md ") || !strings.Contains(got, "FFBM_NOTE_PATH=/.vault-mirror-1/note.md\n") {
+		t.Errorf("post-create hook got no build directory path:\n%s", got)
+	}
+	if !strings.Contains(got, "FFBM_OUTPUT=/.vault-mirror-1\n") || !strings.Contains(got, "args=\nFFBM_OUTPUT=/vault\n") {
+		t.Errorf("got wrong output directories:\n%s", got)
+	}
+}
+
+func TestRunHooks(t *testing.T) {
+	command, log := recordHook(t)
+	runner := NewRunner(config.HooksConfig{PreRun: command, PostRun: command}, "/vault", "/vault", false)
+
+	if err := runner.PreRun(); err != nil {
+		t.Fatal(err)
+	}
+	if err := runner.PostRun(map[string]int{"fetch-timeout": 2, "fetch-not-found": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := readLog(t, log)
+	if n := strings.Count(got, "FFBM_OUTPUT=/vault\n"); n != 2 {
+		t.Errorf("got %d calls with the output directory, want 2:\n%s", n, got)
+	}
+	if strings.Contains(got, "FFBM_NOTE_PATH=/") {
+		t.Errorf("run hooks got a note path:\n%s", got)
+	}
+
+	// Only the post-run hook gets the failures by category
+	if !strings.Contains(got, "FFBM_FAILED=\nFFBM_FAILURES=\n") {
+		t.Errorf("pre-run hook got failures:\n%s", got)
+	}
+	if !strings.Contains(got, "FFBM_FAILED=3\nFFBM_FAILURES=fetch-not-found=1,fetch-timeout=2\n") {
+		t.Errorf("post-run hook is missing the failures:\n%s", got)
+	}
+}
+
+func TestFailingHook(t *testing.T) {
+	command, _ := recordHook(t)
+	t.Setenv("HOOK_EXIT", "3")
+	bookmark := bookmarks.Bookmark{ID: "abc", Title: "Title", URI: "https://example.com/"}
+
+	runner := NewRunner(config.HooksConfig{PostCreate: command}, "/vault", "/vault", false)
+	if err := runner.PostCreate("note.md", bookmark); err != nil {
+		t.Errorf("got error %v, want a warning", err)
+	}
+	if runner.Warnings() != 1 {
+		t.Errorf("got %d warnings, want 1", runner.Warnings())
+	}
+
+	strict := NewRunner(config.HooksConfig{PostCreate: command}, "/vault", "/vault", true)
+	if err := strict.PostCreate("note.md", bookmark); err == nil || !strings.Contains(err.Error(), "exit status 3") {
+		t.Errorf("got error %v, want the exit status", err)
+	}
+	if strict.Warnings() != 0 {
+		t.Errorf("got %d warnings with strict hooks, want 0", strict.Warnings())
+	}
+}
+
+func TestHookTimeout(t *testing.T) {
+	runner := NewRunner(config.HooksConfig{PreRun: "sleep 10", Timeout: 100 * time.Millisecond}, "/vault", "/vault", true)
+
+	start := time.Now()
+	err := runner.PreRun()
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("got error %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("hook ran for %s despite the timeout", elapsed)
+	}
+}
+
+func TestNoHooks(t *testing.T) {
+	runner := NewRunner(config.HooksConfig{}, "/vault", "/vault", true)
+	if err := runner.PreRun(); err != nil {
+		t.Fatal(err)
+	}
+	if err := runner.PostCreate("note.md", bookmarks.Bookmark{}); err != nil {
+		t.Fatal(err)
+	}
+}
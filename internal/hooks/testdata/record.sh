@@ -0,0 +1,13 @@
+#!/bin/sh
+# Appends the arguments and hook environment to $HOOK_LOG
+{
+	echo "args=$*"
+	echo "FFBM_OUTPUT=$FFBM_OUTPUT"
+	echo "FFBM_NOTE_PATH=$FFBM_NOTE_PATH"
+	echo "FFBM_URL=$FFBM_URL"
+	echo "FFBM_TITLE=$FFBM_TITLE"
+	echo "FFBM_ID=$FFBM_ID"
+	echo "FFBM_FAILED=$FFBM_FAILED"
+	echo "FFBM_FAILURES=$FFBM_FAILURES"
+} >>"$HOOK_LOG"
+exit "${HOOK_EXIT:-0}"
@@ -0,0 +1,146 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/web"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/x"
+)
+
+// Defaults of the Anthropic Messages API
+const (
+	DefaultAnthropicURL   = "https://api.anthropic.com/v1"
+	DefaultAnthropicModel = "claude-3-5-haiku-latest"
+	anthropicVersion      = "2023-06-01"
+	// anthropicMaxTokens is sent when no token limit is set, since the
+	// Messages API requires one
+	anthropicMaxTokens = 8192
+)
+
+// statusOverloaded is returned by the Anthropic API when it is overloaded
+const statusOverloaded = 529
+
+// anthropicProvider sends prompts to the Anthropic Messages API
+type anthropicProvider struct {
+	client  *http.Client
+	baseURL string
+	apiKey  string
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature float64            `json:"temperature"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason string `json:"stop_reason"`
+}
+
+type anthropicError struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// NewAnthropicClient creates a client for the Anthropic Messages API
+func NewAnthropicClient(apiKey, baseURL, model string, opts LLMOptions, httpClient *http.Client, cache x.Cache) (*PromptClient, error) {
+	baseURL, err := x.NormalizeBaseURL(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("LLM API: %w", err)
+	}
+	if httpClient == nil {
+		return nil, fmt.Errorf("LLM API: no HTTP client")
+	}
+
+	provider := &anthropicProvider{client: httpClient, baseURL: baseURL, apiKey: apiKey}
+	return newPromptClient(provider, model, opts, cache), nil
+}
+
+func (p *anthropicProvider) complete(ctx context.Context, model string, system string, prompt string, opts LLMOptions) (completion, error) {
+	maxTokens := opts.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = anthropicMaxTokens
+	}
+
+	jsonData, err := json.Marshal(anthropicRequest{
+		Model:       model,
+		System:      system,
+		Messages:    []anthropicMessage{{Role: "user", Content: prompt}},
+		MaxTokens:   maxTokens,
+		Temperature: opts.Temperature,
+	})
+	if err != nil {
+		return completion{}, fmt.Errorf("error marshaling request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/messages", bytes.NewReader(jsonData))
+	if err != nil {
+		return completion{}, fmt.Errorf("LLM request failed: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Api-Key", p.apiKey)
+	req.Header.Set("Anthropic-Version", anthropicVersion)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return completion{}, fmt.Errorf("LLM request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return completion{}, fmt.Errorf("LLM request failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr anthropicError
+		message := strings.TrimSpace(string(body))
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error.Message != "" {
+			message = apiErr.Error.Type + ": " + apiErr.Error.Message
+		}
+		err := fmt.Errorf("LLM request failed with status %d: %s", resp.StatusCode, message)
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == statusOverloaded {
+			return completion{}, fmt.Errorf("%w: %w", web.CategoryLLMRateLimited, err)
+		}
+		return completion{}, err
+	}
+
+	var response anthropicResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return completion{}, fmt.Errorf("invalid LLM response: %w", err)
+	}
+	if response.StopReason == "refusal" {
+		return completion{}, fmt.Errorf("%w: LLM refused to clean the content", web.CategoryLLMRefused)
+	}
+
+	var text strings.Builder
+	for _, block := range response.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return completion{}, fmt.Errorf("%w: LLM returned no text", web.CategoryLLMRefused)
+	}
+
+	return completion{text: text.String(), truncated: response.StopReason == "max_tokens"}, nil
+}
@@ -0,0 +1,137 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/web"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/x"
+)
+
+// newAnthropicServer answers Messages API requests with status and body,
+// counting the requests
+func newAnthropicServer(t *testing.T, status int, body string, requests *int) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		if r.URL.Path != "/v1/messages" || r.Header.Get("X-Api-Key") != "test-key" || r.Header.Get("Anthropic-Version") == "" {
+			t.Errorf("unexpected request %s with headers %v", r.URL.Path, r.Header)
+		}
+
+		var request anthropicRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || len(request.Messages) != 1 || request.MaxTokens <= 0 {
+			t.Errorf("invalid request %+v: %v", request, err)
+		}
+
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAnthropicClient(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		want     string
+		category web.Category
+		err      bool
+	}{
+		{"answer", http.StatusOK, `{"content":[{"type":"text","text":"Cleaned"}],"stop_reason":"end_turn"}`, "Cleaned", "", false},
+		{"rate limited", http.StatusTooManyRequests, `{"error":{"type":"rate_limit_error","message":"slow down"}}`, "", web.CategoryLLMRateLimited, true},
+		{"overloaded", statusOverloaded, `{"error":{"type":"overloaded_error","message":"busy"}}`, "", web.CategoryLLMRateLimited, true},
+		{"refusal", http.StatusOK, `{"content":[],"stop_reason":"refusal"}`, "", web.CategoryLLMRefused, true},
+		{"truncated", http.StatusOK, `{"content":[{"type":"text","text":"Clea"}],"stop_reason":"max_tokens"}`, "", web.CategoryOther, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requests := 0
+			server := newAnthropicServer(t, test.status, test.body, &requests)
+			cache, err := x.NewFileCache(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			client, err := NewAnthropicClient("test-key", server.URL+"/v1", DefaultAnthropicModel, LLMOptions{}, server.Client(), cache)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := client.SummarizeMarkdown("Some content")
+			if test.err {
+				if err == nil {
+					t.Fatalf("got %q, want an error", got)
+				}
+				if category := web.CategoryOf(err); category != test.category {
+					t.Errorf("got category %s, want %s", category, test.category)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAnthropicClientRequiresHTTPClient(t *testing.T) {
+	if _, err := NewAnthropicClient("test-key", DefaultAnthropicURL, DefaultAnthropicModel, LLMOptions{}, nil, nil); err == nil {
+		t.Error("created a client without HTTP client")
+	}
+}
+
+func TestProvidersShareCache(t *testing.T) {
+	cache, err := x.NewFileCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A response cached through one provider answers the other
+	echo := newPromptClient(&echoProvider{}, DefaultAnthropicModel, LLMOptions{Temperature: DefaultTemperature}, cache)
+	want, err := echo.CleanMarkdown("Some content", web.ContentArticle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := 0
+	server := newAnthropicServer(t, http.StatusOK, `{"content":[{"type":"text","text":"Other"}],"stop_reason":"end_turn"}`, &requests)
+	client, err := NewAnthropicClient("test-key", server.URL+"/v1", DefaultAnthropicModel, LLMOptions{Temperature: DefaultTemperature}, server.Client(), cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := client.CleanMarkdown("Some content", web.ContentArticle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want || requests != 0 {
+		t.Errorf("got %q after %d requests, want cached %q", got, requests, want)
+	}
+}
+
+func TestFakeClient(t *testing.T) {
+	client := &FakeClient{Summary: "A summary", Tags: []string{"go"}}
+
+	if got, _ := client.CleanMarkdown("Content", web.ContentArticle); got != "Content" {
+		t.Errorf("got cleaned %q, want the content", got)
+	}
+	if got, _ := client.SummarizeMarkdown("Content"); got != "A summary" {
+		t.Errorf("got summary %q", got)
+	}
+	if client.Calls("CleanMarkdown") != 1 || client.Calls("SuggestTags") != 0 {
+		t.Error("calls were not counted")
+	}
+
+	client.Err = web.CategoryLLMRateLimited
+	if _, err := client.SuggestTags("Content"); web.CategoryOf(err) != web.CategoryLLMRateLimited {
+		t.Errorf("got error %v, want %s", err, web.CategoryLLMRateLimited)
+	}
+}
@@ -0,0 +1,187 @@
+package llm
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultChunkSize is the content length in bytes above which content is
+// cleaned in chunks, well below the context window of common models
+const DefaultChunkSize = 60000
+
+// MinChunkSize is the smallest chunk size, smaller chunks leave the LLM too
+// little content to work with
+const MinChunkSize = 1000
+
+// chunkOverlapRatio is the part of the chunk size that is repeated from the
+// end of the previous chunk as context
+const chunkOverlapRatio = 10
+
+// Markers around the end of the previous chunk repeated as context
+const (
+	overlapStart = "<previous>"
+	overlapEnd   = "</previous>"
+)
+
+// chunkRule asks the LLM to leave the repeated context out of its answer
+const chunkRule = "The content is one part of a longer page. Text between " + overlapStart + " and " + overlapEnd +
+	" is the end of the previous part, only use it as context and leave it out of your answer.\n\n"
+
+// chunk is a part of content cleaned in one request
+type chunk struct {
+	// overlap is the end of the previous chunk, sent as context only
+	overlap string
+	text    string
+}
+
+// content returns the chunk as sent to the LLM, with the overlap marked
+func (c chunk) content() string {
+	if c.overlap == "" {
+		return c.text
+	}
+	return overlapStart + "\n" + c.overlap + "\n" + overlapEnd + "\n\n" + c.text
+}
+
+// splitChunks splits markdown content into chunks of about size bytes at
+// blank lines outside code blocks. Chunks starting below a heading of the
+// previous chunk repeat it, so the LLM keeps the heading hierarchy, and
+// carry the end of the previous chunk as overlap.
+func splitChunks(content string, size int) []chunk {
+	var chunks []chunk
+	var current strings.Builder
+	var heading string
+	overlapSize := size / chunkOverlapRatio
+
+	flush := func() {
+		if current.Len() > 0 {
+			next := chunk{text: current.String()}
+			if len(chunks) > 0 {
+				next.overlap = overlapTail(chunks[len(chunks)-1].text, overlapSize)
+			}
+			chunks = append(chunks, next)
+			current.Reset()
+		}
+	}
+	add := func(block string) {
+		if current.Len() > 0 && current.Len()+len(block) > size {
+			flush()
+			if heading != "" && !isHeading(block) {
+				current.WriteString(heading + "\n\n")
+			}
+		}
+		current.WriteString(block)
+	}
+
+	for _, block := range splitBlocks(content) {
+		for len(block) > size {
+			// Blocks larger than a chunk, e.g. huge code blocks, are cut,
+			// always after at least one character
+			cut := runeCut(block, size)
+			add(block[:cut])
+			block = block[cut:]
+		}
+		add(block)
+		if isHeading(block) {
+			heading = strings.TrimSpace(strings.SplitN(block, "\n", 2)[0])
+		}
+	}
+	flush()
+
+	return chunks
+}
+
+// runeCut returns the largest index of at most n that doesn't cut a
+// character of s in half, but at least the end of the first character
+func runeCut(s string, n int) int {
+	cut := min(n, len(s))
+	for cut > 0 && cut < len(s) && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	if cut == 0 && len(s) > 0 {
+		_, cut = utf8.DecodeRuneInString(s)
+	}
+	return cut
+}
+
+// overlapTail returns the last blocks of text that fit into size bytes, or
+// the end of the last block if it is longer
+func overlapTail(text string, size int) string {
+	if size <= 0 {
+		return ""
+	}
+
+	blocks := splitBlocks(text)
+	tail := ""
+	for i := len(blocks) - 1; i >= 0 && len(tail)+len(blocks[i]) <= size; i-- {
+		tail = blocks[i] + tail
+	}
+	if tail == "" {
+		last := blocks[len(blocks)-1]
+		start := max(len(last)-size, 0)
+		for start < len(last) && !utf8.RuneStart(last[start]) {
+			start++
+		}
+		tail = last[start:]
+	}
+	return strings.TrimSpace(tail)
+}
+
+// splitBlocks splits markdown into blocks ending after a blank line, keeping
+// fenced code blocks together
+func splitBlocks(content string) []string {
+	var blocks []string
+	var block strings.Builder
+	fenced := false
+
+	for _, line := range strings.SplitAfter(content, "\n") {
+		block.WriteString(line)
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fenced = !fenced
+		}
+		if !fenced && strings.TrimSpace(line) == "" {
+			blocks = append(blocks, block.String())
+			block.Reset()
+		}
+	}
+	if block.Len() > 0 {
+		blocks = append(blocks, block.String())
+	}
+
+	return blocks
+}
+
+// joinChunks reassembles cleaned chunks, dropping headings repeated at the
+// start of a chunk and overlap the LLM echoed back
+func joinChunks(chunks []string) string {
+	var parts []string
+	var heading string
+
+	for _, chunk := range chunks {
+		chunk = strings.TrimSpace(chunk)
+		if strings.HasPrefix(chunk, overlapStart) {
+			if _, rest, ok := strings.Cut(chunk, overlapEnd); ok {
+				chunk = strings.TrimSpace(rest)
+			}
+		}
+		if first, rest, _ := strings.Cut(chunk, "\n"); heading != "" && strings.TrimSpace(first) == heading {
+			chunk = strings.TrimSpace(rest)
+		}
+		if chunk == "" {
+			continue
+		}
+		parts = append(parts, chunk)
+
+		for _, line := range strings.Split(chunk, "\n") {
+			if isHeading(line) {
+				heading = strings.TrimSpace(line)
+			}
+		}
+	}
+
+	return strings.Join(parts, "\n\n")
+}
+
+// isHeading reports whether a block starts with a markdown heading
+func isHeading(block string) bool {
+	return strings.HasPrefix(strings.TrimLeft(block, " "), "#")
+}
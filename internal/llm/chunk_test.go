@@ -0,0 +1,174 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/x"
+)
+
+// echoProvider answers with the content it was asked to clean, leaving out
+// the overlap repeated from the previous chunk
+type echoProvider struct {
+	mu      sync.Mutex
+	prompts []string
+}
+
+func (p *echoProvider) complete(ctx context.Context, model, system, prompt string, opts LLMOptions) (completion, error) {
+	p.mu.Lock()
+	p.prompts = append(p.prompts, prompt)
+	p.mu.Unlock()
+
+	_, content, _ := strings.Cut(prompt, "Content to clean:\n")
+	if _, rest, ok := strings.Cut(content, "\n"+overlapEnd+"\n"); ok {
+		content = rest
+	}
+	return completion{text: content}, nil
+}
+
+func newTestClient(t *testing.T, provider provider, opts LLMOptions) *PromptClient {
+	t.Helper()
+	cache, err := x.NewFileCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newPromptClient(provider, "test-model", opts, cache)
+}
+
+func TestCleanMarkdownChunks(t *testing.T) {
+	// A few megabytes of paragraphs
+	var paragraphs []string
+	for i := range 12000 {
+		paragraphs = append(paragraphs, fmt.Sprintf("Paragraph %d:%s", i, strings.Repeat(" lorem ipsum", 20)))
+	}
+	content := strings.Join(paragraphs, "\n\n")
+
+	provider := &echoProvider{}
+	client := newTestClient(t, provider, LLMOptions{ChunkSize: DefaultChunkSize})
+
+	cleaned, err := client.CleanMarkdown(content, "article")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if min := len(content) / DefaultChunkSize; len(provider.prompts) < min {
+		t.Errorf("got %d LLM calls, want at least %d", len(provider.prompts), min)
+	}
+	if want := strings.TrimSpace(content); cleaned != want {
+		t.Error("cleaned chunks are not the content in order")
+	}
+
+	// Every chunk after the first carries the end of the previous one
+	for i, prompt := range provider.prompts[1:] {
+		if !strings.Contains(prompt, overlapStart) || !strings.Contains(prompt, chunkRule) {
+			t.Errorf("chunk %d has no overlap", i+2)
+		}
+	}
+
+	// A second run is answered from the cache
+	calls := len(provider.prompts)
+	if _, err := client.CleanMarkdown(content, "article"); err != nil {
+		t.Fatal(err)
+	}
+	if len(provider.prompts) != calls {
+		t.Errorf("got %d LLM calls for cached content, want none", len(provider.prompts)-calls)
+	}
+}
+
+func TestCleanMarkdownChunkCacheKey(t *testing.T) {
+	var content string
+	for i := range 200 {
+		content += fmt.Sprintf("Paragraph %d of text.\n\n", i)
+	}
+
+	provider := &echoProvider{}
+	cache, err := x.NewFileCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int{1000, 2000} {
+		client := newPromptClient(provider, "test-model", LLMOptions{ChunkSize: size}, cache)
+		if _, err := client.CleanMarkdown(content, "article"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Chunks of 1000 bytes don't answer requests for chunks of 2000 bytes
+	small := len(splitChunks(content, 1000))
+	large := len(splitChunks(content, 2000))
+	if len(provider.prompts) != small+large {
+		t.Errorf("got %d LLM calls, want %d", len(provider.prompts), small+large)
+	}
+}
+
+func TestSplitChunksMultiByte(t *testing.T) {
+	content := strings.Repeat("ü", 10) + "\n\n" + strings.Repeat("日本語", 5)
+
+	for size := 1; size <= 4; size++ {
+		chunks := splitChunks(content, size)
+		var text strings.Builder
+		for _, chunk := range chunks {
+			text.WriteString(chunk.text)
+		}
+		if text.String() != content {
+			t.Errorf("size %d: chunks don't add up to the content", size)
+		}
+	}
+}
+
+func TestSplitChunksOverlap(t *testing.T) {
+	content := strings.Repeat("A paragraph of about fifty bytes of plain text.\n\n", 100)
+
+	chunks := splitChunks(content, 1000)
+	if len(chunks) < 2 {
+		t.Fatalf("got %d chunks, want several", len(chunks))
+	}
+	if chunks[0].overlap != "" {
+		t.Errorf("first chunk has overlap %q", chunks[0].overlap)
+	}
+	for i, chunk := range chunks[1:] {
+		if chunk.overlap == "" || len(chunk.overlap) > 1000/chunkOverlapRatio {
+			t.Errorf("chunk %d has overlap of %d bytes", i+2, len(chunk.overlap))
+		}
+		if !strings.HasSuffix(strings.TrimSpace(chunks[i].text), chunk.overlap) {
+			t.Errorf("overlap of chunk %d isn't the end of the previous chunk", i+2)
+		}
+	}
+}
+
+func TestJoinChunksDropsEchoedOverlap(t *testing.T) {
+	got := joinChunks([]string{"First part.", overlapStart + "\nFirst part.\n" + overlapEnd + "\n\nSecond part."})
+	if want := "First part.\n\nSecond part."; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCleanMarkdownCacheKeys(t *testing.T) {
+	var content string
+	for i := range 200 {
+		content += fmt.Sprintf("Paragraph %d of text.\n\n", i)
+	}
+
+	for _, size := range []int{0, 1000} {
+		client := newTestClient(t, &echoProvider{}, LLMOptions{ChunkSize: size})
+		keys := client.CacheKeys(content, "article")
+		want := 1
+		if size > 0 {
+			want = len(splitChunks(content, size))
+		}
+		if len(keys) != want {
+			t.Errorf("got %d keys with chunk size %d, want %d", len(keys), size, want)
+		}
+
+		if _, err := client.CleanMarkdown(content, "article"); err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range keys {
+			if _, ok := client.cache.Get(key); !ok {
+				t.Errorf("cleaned content is not cached under key %s with chunk size %d", key, size)
+			}
+		}
+	}
+}
@@ -2,11 +2,22 @@ package llm
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/web"
 )
 
-const cleanMarkdownPrompt = `Clean and enhance this markdown content following these strict rules:
+// promptVersion is bumped whenever the built-in prompts change, so cached
+// responses of older prompts are not reused
+const promptVersion = "v2"
+
+const articlePrompt = `Clean and enhance this markdown content following these strict rules:
 
 CONTENT RULES:
 1. Keep only information directly related to the main topic
@@ -36,11 +47,182 @@ CLEANUP RULES:
 4. Remove HTML comments and metadata
 5. Remove social media embeds unless they're the main content
 
-Content to clean:
-%s
 `
 
-func (c *OpenAIClient) CleanMarkdown(content string) (string, error) {
-	slog.Info("cleaning markdown", "model", c.model, "length", len(content))
-	return c.callLLM(context.Background(), fmt.Sprintf("%s%s", cleanMarkdownPrompt, content))
+const readmePrompt = `Clean this repository README following these strict rules:
+
+STRUCTURE RULES:
+1. Keep the heading hierarchy and section order of the original
+2. Keep installation, usage and configuration instructions complete
+3. Keep code blocks and command examples verbatim
+4. Keep tables of options, flags and compatibility
+
+BADGE AND LINK RULES:
+1. Keep status badges at the top of the document
+2. Keep links to documentation, releases and license
+3. Remove broken or relative links that cannot be resolved
+
+CLEANUP RULES:
+1. Remove contributor lists, sponsor logos and star history charts
+2. Remove HTML comments and leftover HTML layout elements
+3. Fix list formatting and indentation
+4. Remove redundant line breaks and spaces
+
+`
+
+const discussionPrompt = `Clean this discussion thread following these strict rules:
+
+THREAD RULES:
+1. Keep every comment together with its author
+2. Preserve reply nesting, using nested blockquotes or lists
+3. Keep the order of comments as in the original
+4. Keep quotes, code blocks and links posted in comments
+
+CLEANUP RULES:
+1. Remove voting controls, karma, flags and reply/share links
+2. Remove navigation elements, footers and sidebars
+3. Remove promotional or unrelated content
+4. Remove redundant line breaks and spaces
+
+`
+
+// defaultPrompts maps content types to built-in cleaning prompts
+var defaultPrompts = map[string]string{
+	web.ContentArticle:    articlePrompt,
+	web.ContentReadme:     readmePrompt,
+	web.ContentDiscussion: discussionPrompt,
+}
+
+// PromptSystem is the key of the system message of cleaning requests among
+// prompt files, next to the content types
+const PromptSystem = "system"
+
+// PromptFiles returns the prompt files in dir, <content type>.md for cleaning
+// prompts and system.md for the system message, leaving out missing files
+func PromptFiles(dir string) (map[string]string, error) {
+	files := make(map[string]string)
+	for _, key := range append(slices.Clone(web.ContentTypes), PromptSystem) {
+		path := filepath.Join(dir, key+".md")
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read %s prompt: %w", key, err)
+		}
+		files[key] = path
+	}
+	return files, nil
+}
+
+// LoadPrompts overrides built-in prompts with the files keyed by content type
+// or PromptSystem. A cleaning prompt gets the content in place of %s, which
+// it may contain once, or appended. Prompts without a file are kept.
+func (c *PromptClient) LoadPrompts(files map[string]string) error {
+	for key, path := range files {
+		if key != PromptSystem && !slices.Contains(web.ContentTypes, key) {
+			return fmt.Errorf("unknown prompt %s", key)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s prompt: %w", key, err)
+		}
+		prompt := strings.TrimSpace(string(data))
+		if prompt == "" {
+			return fmt.Errorf("%s prompt %s is empty", key, path)
+		}
+
+		slog.Debug("loaded custom prompt", "prompt", key, "path", path)
+		if key == PromptSystem {
+			c.system = prompt
+			continue
+		}
+		if n := strings.Count(prompt, "%s"); n > 1 {
+			return fmt.Errorf("%s prompt %s must contain %%s at most once for the content, found %d", key, path, n)
+		}
+		c.prompts[key] = prompt + "\n\n"
+	}
+	return nil
+}
+
+// CleanMarkdown cleans markdown content with the prompt for its content type
+func (c *PromptClient) CleanMarkdown(content string, contentType string) (string, error) {
+	return c.cleanMarkdown(content, contentType, true)
+}
+
+// RecleanMarkdown cleans markdown content like CleanMarkdown, but asks the
+// LLM again instead of using a cached response
+func (c *PromptClient) RecleanMarkdown(content string, contentType string) (string, error) {
+	return c.cleanMarkdown(content, contentType, false)
+}
+
+func (c *PromptClient) cleanMarkdown(content string, contentType string, useCache bool) (string, error) {
+	contentType, requests := c.cleanRequests(content, contentType)
+	slog.Info("cleaning markdown", "model", c.model, "type", contentType, "length", len(content))
+	if len(requests) == 1 {
+		return c.callLLM(context.Background(), requests[0].namespace, c.system, requests[0].prompt, useCache)
+	}
+
+	// Clean content too long for one request in chunks
+	slog.Info("cleaning markdown in chunks", "chunks", len(requests), "chunk_size", c.opts.ChunkSize)
+	cleaned := make([]string, len(requests))
+	for i, request := range requests {
+		response, err := c.callLLM(context.Background(), request.namespace, c.system, request.prompt, useCache)
+		if err != nil {
+			return "", fmt.Errorf("chunk %d of %d: %w", i+1, len(requests), err)
+		}
+		cleaned[i] = response
+	}
+	return joinChunks(cleaned), nil
+}
+
+var _ web.CachingCleaner = (*PromptClient)(nil)
+
+// CacheKeys returns the cache keys of the responses cleaning content of a
+// content type is cached under, one per chunk
+func (c *PromptClient) CacheKeys(content string, contentType string) []string {
+	_, requests := c.cleanRequests(content, contentType)
+	keys := make([]string, len(requests))
+	for i, request := range requests {
+		keys[i] = c.getCacheKey(c.model, request.namespace, c.system, request.prompt)
+	}
+	return keys
+}
+
+// cleanRequest is a prompt cleaning content, or a chunk of it
+type cleanRequest struct {
+	namespace string
+	prompt    string
+}
+
+// cleanRequests returns the prompts cleaning content takes, with the content
+// type whose prompt they use
+func (c *PromptClient) cleanRequests(content string, contentType string) (string, []cleanRequest) {
+	prompt, ok := c.prompts[contentType]
+	if !ok {
+		contentType, prompt = web.ContentArticle, c.prompts[web.ContentArticle]
+	}
+
+	namespace := fmt.Sprintf("clean-%s-%s", contentType, promptVersion)
+	request := func(namespace string, content string) cleanRequest {
+		if strings.Contains(prompt, "%s") {
+			return cleanRequest{namespace, strings.Replace(prompt, "%s", content, 1)}
+		}
+		return cleanRequest{namespace, fmt.Sprintf("%sContent to clean:\n%s\n", prompt, content)}
+	}
+
+	if c.opts.ChunkSize <= 0 || len(content) <= c.opts.ChunkSize {
+		return contentType, []cleanRequest{request(namespace, content)}
+	}
+
+	chunks := splitChunks(content, c.opts.ChunkSize)
+	chunkNamespace := fmt.Sprintf("%s-chunk%d-overlap%d", namespace, c.opts.ChunkSize, chunkOverlapRatio)
+	requests := make([]cleanRequest, len(chunks))
+	for i, chunk := range chunks {
+		content := chunk.content()
+		if chunk.overlap != "" {
+			content = chunkRule + content
+		}
+		requests[i] = request(chunkNamespace, content)
+	}
+	return contentType, requests
 }
@@ -4,57 +4,134 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"strings"
 
-	"github.com/openai/openai-go"
-	"github.com/openai/openai-go/option"
 	"github.com/xtruder/ffbookmarks-to-markdown/internal/x"
 )
 
-type OpenAIClient struct {
-	client *openai.Client
-	cache  x.Cache
-	model  string
+// defaultSystemPrompt is the built-in system message of all LLM requests,
+// only cleaning requests use a custom one
+const defaultSystemPrompt = "You are a markdown content curator. Your task is to clean and restructure markdown content while preserving its essential information and improving its readability. Be thorough and strict in following the cleaning rules."
+
+// DefaultTemperature is the sampling temperature of LLM requests, low to keep
+// cleaned content close to the original
+const DefaultTemperature = 0.1
+
+// LLM API providers
+const (
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
+)
+
+// Providers lists all LLM API providers
+var Providers = []string{ProviderOpenAI, ProviderAnthropic}
+
+// MaxTemperature is the highest sampling temperature each provider accepts
+var MaxTemperature = map[string]float64{
+	ProviderOpenAI:    2,
+	ProviderAnthropic: 1,
+}
+
+// ErrTruncated is returned for answers cut at the token limit, which are
+// not cached
+var ErrTruncated = errors.New("LLM response truncated")
+
+// LLMOptions tunes the completion requests
+type LLMOptions struct {
+	Temperature float64
+	// MaxTokens caps the length of responses, 0 leaves it to the provider
+	MaxTokens int
+	// ChunkSize is the content length in bytes above which content is
+	// cleaned in chunks, 0 sends content in one request
+	ChunkSize int
+}
+
+// Client cleans, summarizes and tags markdown content with an LLM
+type Client interface {
+	CleanMarkdown(content string, contentType string) (string, error)
+	RecleanMarkdown(content string, contentType string) (string, error)
+	SummarizeMarkdown(content string) (string, error)
+	SuggestTags(content string) ([]string, error)
+}
+
+// completion is the answer of an LLM to a prompt
+type completion struct {
+	text string
+	// truncated is set when the answer was cut at the token limit
+	truncated bool
+}
+
+// provider sends a single prompt to the API of an LLM provider. Errors are
+// categorized with the web.CategoryLLM* categories where possible.
+type provider interface {
+	complete(ctx context.Context, model string, system string, prompt string, opts LLMOptions) (completion, error)
 }
 
-func NewOpenAIClient(apiKey, baseURL, model string, httpClient *http.Client, cache x.Cache) (*OpenAIClient, error) {
-	client := openai.NewClient(
-		option.WithAPIKey(apiKey),
-		option.WithBaseURL(baseURL),
-		option.WithHTTPClient(httpClient),
-	)
-
-	return &OpenAIClient{
-		client: client,
-		cache:  cache,
-		model:  model,
-	}, nil
+// PromptClient implements Client for any provider, caching its responses in
+// an x.Cache under keys that don't depend on the provider
+type PromptClient struct {
+	provider provider
+	cache    x.Cache
+	model    string
+	opts     LLMOptions
+	// prompts maps content types to cleaning prompts
+	prompts map[string]string
+	// system is the system message of cleaning requests
+	system string
 }
 
-func (c *OpenAIClient) callLLM(ctx context.Context, prompt string) (string, error) {
+var _ Client = (*PromptClient)(nil)
+
+// NewClient creates a client for the API of provider, one of Providers
+func NewClient(provider, apiKey, baseURL, model string, opts LLMOptions, httpClient *http.Client, cache x.Cache) (*PromptClient, error) {
+	switch provider {
+	case ProviderOpenAI:
+		return NewOpenAIClient(apiKey, baseURL, model, opts, httpClient, cache)
+	case ProviderAnthropic:
+		return NewAnthropicClient(apiKey, baseURL, model, opts, httpClient, cache)
+	default:
+		return nil, fmt.Errorf("unknown LLM provider '%s' (%s)", provider, strings.Join(Providers, ", "))
+	}
+}
+
+func newPromptClient(provider provider, model string, opts LLMOptions, cache x.Cache) *PromptClient {
+	prompts := make(map[string]string, len(defaultPrompts))
+	for contentType, prompt := range defaultPrompts {
+		prompts[contentType] = prompt
+	}
+
+	return &PromptClient{
+		provider: provider,
+		cache:    cache,
+		model:    model,
+		opts:     opts,
+		prompts:  prompts,
+		system:   defaultSystemPrompt,
+	}
+}
+
+func (c *PromptClient) callLLM(ctx context.Context, namespace, system, prompt string, useCache bool) (string, error) {
 	// Try cache first
-	key := c.getCacheKey(c.model, prompt)
-	if cached, ok := c.cache.Get(key); ok {
+	key := c.getCacheKey(c.model, namespace, system, prompt)
+	if cached, ok := c.cache.Get(key); ok && useCache {
 		slog.Debug("using cached LLM response")
 		return cached, nil
 	}
 
-	chatCompletion, err := c.client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
-		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
-			openai.SystemMessage("You are a markdown content curator. Your task is to clean and restructure markdown content while preserving its essential information and improving its readability. Be thorough and strict in following the cleaning rules."),
-			openai.UserMessage(prompt),
-		}),
-		Model:       openai.F(c.model),
-		Temperature: openai.F(0.1),
-	})
+	answer, err := c.provider.complete(ctx, c.model, system, prompt, c.opts)
 	if err != nil {
-		return "", fmt.Errorf("LLM request failed: %w", err)
+		return "", err
+	}
+
+	if answer.truncated {
+		return "", fmt.Errorf("%w, raise -llm-max-tokens", ErrTruncated)
 	}
 
-	response := strings.TrimSpace(chatCompletion.Choices[0].Message.Content)
+	response := strings.TrimSpace(answer.text)
 	response = strings.TrimPrefix(response, "```markdown\n")
 	response = strings.TrimPrefix(response, "```\n")
 	response = strings.TrimSuffix(response, "\n```")
@@ -67,8 +144,16 @@ func (c *OpenAIClient) callLLM(ctx context.Context, prompt string) (string, erro
 	return response, nil
 }
 
-func (c *OpenAIClient) getCacheKey(model, prompt string) string {
-	data := fmt.Sprintf("%s\n---\n%s", model, prompt)
+func (c *PromptClient) getCacheKey(model, namespace, system, prompt string) string {
+	data := fmt.Sprintf("%s\n---\n%s\n---\n%s", model, namespace, prompt)
+	if system != defaultSystemPrompt {
+		// Keep keys of the built-in system message, so existing responses stay cached
+		data += "\n---\n" + system
+	}
+	if c.opts.Temperature != DefaultTemperature || c.opts.MaxTokens != 0 {
+		// Same for the default request options
+		data += fmt.Sprintf("\n---\n%g %d", c.opts.Temperature, c.opts.MaxTokens)
+	}
 	hash := sha256.Sum256([]byte(data))
 	return base64.URLEncoding.EncodeToString(hash[:])
 }
@@ -0,0 +1,64 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/x"
+)
+
+// truncatingProvider cuts its first answer at the token limit
+type truncatingProvider struct {
+	calls int
+}
+
+func (p *truncatingProvider) complete(ctx context.Context, model, system, prompt string, opts LLMOptions) (completion, error) {
+	p.calls++
+	if p.calls == 1 {
+		return completion{text: "Half an ans", truncated: true}, nil
+	}
+	return completion{text: "Full answer"}, nil
+}
+
+func TestTruncatedAnswerIsNotCached(t *testing.T) {
+	provider := &truncatingProvider{}
+	client := newTestClient(t, provider, LLMOptions{Temperature: DefaultTemperature, MaxTokens: 10})
+
+	if _, err := client.CleanMarkdown("Some content", "article"); !errors.Is(err, ErrTruncated) {
+		t.Fatalf("got error %v, want %v", err, ErrTruncated)
+	}
+
+	got, err := client.CleanMarkdown("Some content", "article")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Full answer" || provider.calls != 2 {
+		t.Errorf("got %q after %d calls, want the full answer asked again", got, provider.calls)
+	}
+}
+
+func TestCacheKeyOptions(t *testing.T) {
+	cache, err := x.NewFileCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider := &echoProvider{}
+	for _, opts := range []LLMOptions{
+		{Temperature: DefaultTemperature},
+		{Temperature: 0.7},
+		{Temperature: DefaultTemperature, MaxTokens: 1000},
+		{Temperature: DefaultTemperature},
+	} {
+		client := newPromptClient(provider, "test-model", opts, cache)
+		if _, err := client.CleanMarkdown("Some content", "article"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Changed options ask again, the repeated default options are cached
+	if len(provider.prompts) != 3 {
+		t.Errorf("got %d LLM calls, want 3", len(provider.prompts))
+	}
+}
@@ -0,0 +1,64 @@
+package llm
+
+import "sync"
+
+// FakeClient is a Client for tests that answers without calling an LLM.
+// Cleaning returns the content unchanged.
+type FakeClient struct {
+	// Summary is the answer of SummarizeMarkdown
+	Summary string
+	// Tags is the answer of SuggestTags
+	Tags []string
+	// Err fails every call if set
+	Err error
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+var _ Client = (*FakeClient)(nil)
+
+// Calls returns how often method was called
+func (c *FakeClient) Calls(method string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls[method]
+}
+
+func (c *FakeClient) record(method string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.calls == nil {
+		c.calls = make(map[string]int)
+	}
+	c.calls[method]++
+	return c.Err
+}
+
+func (c *FakeClient) CleanMarkdown(content string, contentType string) (string, error) {
+	if err := c.record("CleanMarkdown"); err != nil {
+		return "", err
+	}
+	return content, nil
+}
+
+func (c *FakeClient) RecleanMarkdown(content string, contentType string) (string, error) {
+	if err := c.record("RecleanMarkdown"); err != nil {
+		return "", err
+	}
+	return content, nil
+}
+
+func (c *FakeClient) SummarizeMarkdown(content string) (string, error) {
+	if err := c.record("SummarizeMarkdown"); err != nil {
+		return "", err
+	}
+	return c.Summary, nil
+}
+
+func (c *FakeClient) SuggestTags(content string) ([]string, error) {
+	if err := c.record("SuggestTags"); err != nil {
+		return nil, err
+	}
+	return c.Tags, nil
+}
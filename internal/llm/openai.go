@@ -0,0 +1,69 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/web"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/x"
+)
+
+// openAIProvider sends prompts to OpenAI compatible chat completion APIs
+type openAIProvider struct {
+	client *openai.Client
+}
+
+// NewOpenAIClient creates a client for OpenAI compatible chat completion APIs
+func NewOpenAIClient(apiKey, baseURL, model string, opts LLMOptions, httpClient *http.Client, cache x.Cache) (*PromptClient, error) {
+	baseURL, err := x.NormalizeBaseURL(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("LLM API: %w", err)
+	}
+	if httpClient == nil {
+		return nil, fmt.Errorf("LLM API: no HTTP client")
+	}
+
+	client := openai.NewClient(
+		option.WithAPIKey(apiKey),
+		// API paths are resolved relative to the base URL, which needs a trailing slash
+		option.WithBaseURL(baseURL+"/"),
+		option.WithHTTPClient(httpClient),
+	)
+
+	return newPromptClient(&openAIProvider{client: client}, model, opts, cache), nil
+}
+
+func (p *openAIProvider) complete(ctx context.Context, model string, system string, prompt string, opts LLMOptions) (completion, error) {
+	params := openai.ChatCompletionNewParams{
+		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(system),
+			openai.UserMessage(prompt),
+		}),
+		Model:       openai.F(model),
+		Temperature: openai.F(opts.Temperature),
+	}
+	if opts.MaxTokens > 0 {
+		params.MaxTokens = openai.F(int64(opts.MaxTokens))
+	}
+
+	chatCompletion, err := p.client.Chat.Completions.New(ctx, params)
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests {
+		return completion{}, fmt.Errorf("%w: LLM request failed: %w", web.CategoryLLMRateLimited, err)
+	}
+	if err != nil {
+		return completion{}, fmt.Errorf("LLM request failed: %w", err)
+	}
+	if len(chatCompletion.Choices) == 0 || chatCompletion.Choices[0].FinishReason == openai.ChatCompletionChoicesFinishReasonContentFilter {
+		return completion{}, fmt.Errorf("%w: LLM refused to clean the content", web.CategoryLLMRefused)
+	}
+
+	return completion{
+		text:      chatCompletion.Choices[0].Message.Content,
+		truncated: chatCompletion.Choices[0].FinishReason == openai.ChatCompletionChoicesFinishReasonLength,
+	}, nil
+}
@@ -0,0 +1,91 @@
+package llm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// systemProvider records the system message and prompt of every call
+type systemProvider struct {
+	systems []string
+	prompts []string
+}
+
+func (p *systemProvider) complete(ctx context.Context, model, system, prompt string, opts LLMOptions) (completion, error) {
+	p.systems = append(p.systems, system)
+	p.prompts = append(p.prompts, prompt)
+	return completion{text: "Answer"}, nil
+}
+
+func writePrompt(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPromptsDir(t *testing.T) {
+	dir := t.TempDir()
+	writePrompt(t, dir, "article.md", "Keep it German:\n%s\nThanks\n")
+	writePrompt(t, dir, "system.md", "You clean German pages.\n")
+
+	files, err := PromptFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files["article"] == "" || files[PromptSystem] == "" {
+		t.Fatalf("got prompt files %v, want article and system", files)
+	}
+
+	provider := &systemProvider{}
+	client := newTestClient(t, provider, LLMOptions{})
+	if err := client.LoadPrompts(files); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, call := range []func() error{
+		func() error { _, err := client.CleanMarkdown("Some content", "article"); return err },
+		func() error { _, err := client.CleanMarkdown("Some content", "readme"); return err },
+		func() error { _, err := client.SummarizeMarkdown("Some content"); return err },
+		func() error { _, err := client.SuggestTags("Some content"); return err },
+	} {
+		if err := call(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only cleaning requests get the custom system message
+	want := []string{"You clean German pages.", "You clean German pages.", defaultSystemPrompt, defaultSystemPrompt}
+	for i, system := range provider.systems {
+		if system != want[i] {
+			t.Errorf("call %d got system %q, want %q", i, system, want[i])
+		}
+	}
+
+	if got := provider.prompts[0]; got != "Keep it German:\nSome content\nThanks\n\n" {
+		t.Errorf("got article prompt %q, want the content in place of %%s", got)
+	}
+	if got := provider.prompts[1]; !strings.HasPrefix(got, readmePrompt) {
+		t.Errorf("got readme prompt %q, want the built-in prompt", got)
+	}
+}
+
+func TestLoadPromptsInvalid(t *testing.T) {
+	dir := t.TempDir()
+	for name, files := range map[string]map[string]string{
+		"two placeholders": {"article": writePrompt(t, dir, "twice.md", "%s and %s")},
+		"empty system":     {PromptSystem: writePrompt(t, dir, "empty.md", "\n")},
+		"unknown type":     {"video": writePrompt(t, dir, "video.md", "Clean it")},
+		"missing file":     {"article": filepath.Join(dir, "missing.md")},
+	} {
+		client := newTestClient(t, &systemProvider{}, LLMOptions{})
+		if err := client.LoadPrompts(files); err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+}
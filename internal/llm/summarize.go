@@ -0,0 +1,37 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+const summaryPrompt = `Summarize this markdown content in one or two plain sentences for a bookmark description:
+1. Say what the page is about and why it is useful
+2. Don't start with "This page" or "This article"
+3. Don't use markdown, links or line breaks
+4. Answer in the language of the content
+
+`
+
+// SummarizeMarkdown returns a one to two sentence summary of markdown content
+func (c *PromptClient) SummarizeMarkdown(content string) (string, error) {
+	// The beginning is enough for a summary and keeps long pages in context
+	content = c.head(content)
+
+	slog.Info("summarizing markdown", "model", c.model, "length", len(content))
+	summary, err := c.callLLM(context.Background(), "summary-v1", defaultSystemPrompt, fmt.Sprintf("%sContent to summarize:\n%s\n", summaryPrompt, content), true)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(strings.Fields(summary), " "), nil
+}
+
+// head returns the beginning of content fitting into a single chunk
+func (c *PromptClient) head(content string) string {
+	if c.opts.ChunkSize <= 0 || len(content) <= c.opts.ChunkSize {
+		return content
+	}
+	return content[:runeCut(content, c.opts.ChunkSize)]
+}
@@ -0,0 +1,87 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/x"
+)
+
+// newCompletionClient returns a client of an OpenAI compatible server that
+// answers every chat completion with answer, recording the user prompts
+func newCompletionClient(t *testing.T, answer string, opts LLMOptions, prompts *[]string) *PromptClient {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Messages []struct {
+				Role    string          `json:"role"`
+				Content json.RawMessage `json:"content"`
+			} `json:"messages"`
+		}
+		if r.URL.Path != "/v1/chat/completions" || json.NewDecoder(r.Body).Decode(&request) != nil {
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+		for _, message := range request.Messages {
+			if message.Role != "user" {
+				continue
+			}
+			// Content is either a string or a list of text parts
+			var parts []struct {
+				Text string `json:"text"`
+			}
+			var prompt string
+			if json.Unmarshal(message.Content, &prompt) != nil && json.Unmarshal(message.Content, &parts) == nil {
+				for _, part := range parts {
+					prompt += part.Text
+				}
+			}
+			*prompts = append(*prompts, prompt)
+		}
+
+		content, _ := json.Marshal(answer)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":"test","object":"chat.completion","model":"test","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":%s}}]}`, content)
+	}))
+	t.Cleanup(server.Close)
+
+	cache, err := x.NewFileCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewOpenAIClient("test-key", server.URL+"/v1", "test", opts, server.Client(), cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestSummarizeMarkdown(t *testing.T) {
+	var prompts []string
+	client := newCompletionClient(t, "Explains Go\n  generics with   examples.\n", LLMOptions{}, &prompts)
+
+	got, err := client.SummarizeMarkdown("Some content")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Explains Go generics with examples."; got != want {
+		t.Errorf("got summary %q, want %q", got, want)
+	}
+}
+
+func TestSummarizeMarkdownHead(t *testing.T) {
+	var prompts []string
+	client := newCompletionClient(t, "Summary", LLMOptions{ChunkSize: 20}, &prompts)
+
+	if _, err := client.SummarizeMarkdown(strings.Repeat("first ", 4) + strings.Repeat("later ", 100)); err != nil {
+		t.Fatal(err)
+	}
+	// Only the beginning of long content is summarized
+	if len(prompts) != 1 || !strings.Contains(prompts[0], "first first") || strings.Contains(prompts[0], "later later") {
+		t.Errorf("got prompts %q, want a single one with the beginning of the content", prompts)
+	}
+}
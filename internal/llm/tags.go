@@ -0,0 +1,69 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// MaxTags caps the number of tags SuggestTags returns
+const MaxTags = 7
+
+// tagMarkerRegex matches list markers and hashes LLMs put before tags
+var tagMarkerRegex = regexp.MustCompile(`^\s*(?:\d+[.)]\s+|[-*]\s+)?#?`)
+
+const tagsPrompt = `Propose 3 to 7 topical tags for this markdown content, for organizing bookmarks:
+1. Use lowercase words joined with hyphens, e.g. machine-learning
+2. Prefer general topics over words from the title
+3. Answer with the tags only, separated by commas, in English
+
+`
+
+// SuggestTags returns up to MaxTags topical tags for markdown content,
+// lowercase and hyphenated
+func (c *PromptClient) SuggestTags(content string) ([]string, error) {
+	content = c.head(content)
+
+	slog.Info("suggesting tags", "model", c.model, "length", len(content))
+	response, err := c.callLLM(context.Background(), "tags-v1", defaultSystemPrompt, fmt.Sprintf("%sContent to tag:\n%s\n", tagsPrompt, content), true)
+	if err != nil {
+		return nil, err
+	}
+	return parseTags(response), nil
+}
+
+// parseTags turns a comma or line separated list of tags into distinct tags
+// of lowercase letters, digits and hyphens
+func parseTags(response string) []string {
+	var tags []string
+	fields := strings.FieldsFunc(response, func(r rune) bool { return r == ',' || r == '\n' })
+	for _, field := range fields {
+		field = tagMarkerRegex.ReplaceAllString(field, "")
+
+		var sb strings.Builder
+		dash := false
+		for _, r := range strings.ToLower(field) {
+			switch {
+			case r >= 'a' && r <= 'z' || r >= '0' && r <= '9':
+				sb.WriteRune(r)
+				dash = false
+			case !dash && sb.Len() > 0 && (r == ' ' || r == '-' || r == '_'):
+				sb.WriteRune('-')
+				dash = true
+			}
+		}
+
+		tag := strings.TrimSuffix(sb.String(), "-")
+		if tag == "" || tag == "bookmark" || slices.Contains(tags, tag) {
+			continue
+		}
+		tags = append(tags, tag)
+		if len(tags) == MaxTags {
+			break
+		}
+	}
+	return tags
+}
@@ -0,0 +1,61 @@
+package llm
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/x"
+)
+
+// answerProvider answers every prompt with the same text, counting calls
+type answerProvider struct {
+	answer string
+	calls  int
+}
+
+func (p *answerProvider) complete(ctx context.Context, model, system, prompt string, opts LLMOptions) (completion, error) {
+	p.calls++
+	return completion{text: p.answer}, nil
+}
+
+func TestParseTags(t *testing.T) {
+	got := parseTags("1. Machine Learning\n- #go, web_dev,  Go , bookmark, C++, a, b, c, d, e, f")
+	want := []string{"machine-learning", "go", "web-dev", "c", "a", "b", "d"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSuggestTagsCache(t *testing.T) {
+	cache, err := x.NewFileCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := &answerProvider{answer: "go, testing"}
+
+	client := newPromptClient(provider, "model-a", LLMOptions{}, cache)
+	for range 2 {
+		tags, err := client.SuggestTags("Some content")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(tags, []string{"go", "testing"}) {
+			t.Errorf("got tags %q", tags)
+		}
+	}
+	if provider.calls != 1 {
+		t.Errorf("got %d LLM calls for the same content, want 1", provider.calls)
+	}
+
+	// Other content or another model asks again
+	if _, err := client.SuggestTags("Other content"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newPromptClient(provider, "model-b", LLMOptions{}, cache).SuggestTags("Some content"); err != nil {
+		t.Fatal(err)
+	}
+	if provider.calls != 3 {
+		t.Errorf("got %d LLM calls, want 3", provider.calls)
+	}
+}
@@ -0,0 +1,69 @@
+package markdown
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/web"
+)
+
+// BackfillScreenshots adds screenshot embeds to existing notes that were
+// created before their screenshot was available
+func (p *Processor) BackfillScreenshots(screenshots map[string]web.ScreenshotResult) {
+	if p.screenshotService == nil {
+		return
+	}
+
+	var backfilled int
+	for _, entry := range p.cache.sorted() {
+		if entry.HasScreenshot || entry.File == "" {
+			continue
+		}
+		if _, ok := screenshots[p.screenshotService.Normalize(entry.URI)]; !ok {
+			continue
+		}
+
+		if err := p.backfillScreenshot(entry); err != nil {
+			slog.Warn("failed to backfill screenshot", "file", entry.File, "error", err)
+			continue
+		}
+
+		entry.HasScreenshot = true
+		p.cache[entry.ID] = entry
+		backfilled++
+	}
+
+	slog.Info("backfilled screenshots", "count", backfilled)
+}
+
+func (p *Processor) backfillScreenshot(entry CacheEntry) error {
+	data, err := os.ReadFile(filepath.Join(p.outputDir, entry.File))
+	if err != nil {
+		return fmt.Errorf("failed to read note: %w", err)
+	}
+
+	rawMatter, body, err := splitNote(string(data))
+	if err != nil {
+		return err
+	}
+
+	// Keep the embed below any inline fields, like new notes have it
+	lines := strings.SplitAfter(body, "\n")
+	i := 0
+	for i < len(lines) && strings.Contains(lines[i], ":: ") {
+		i++
+	}
+
+	embed := fmt.Sprintf("![Screenshot](%s)\n", p.screenshotService.GetScreenshotURL(entry.URI))
+	lines = append(lines[:i], append([]string{embed}, lines[i:]...)...)
+
+	note := rawMatter + "\n" + strings.Join(lines, "")
+	if err := p.output.WriteFile(entry.File, []byte(note)); err != nil {
+		return fmt.Errorf("failed to write note: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package markdown
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/web"
+)
+
+// newTestScreenshotService returns a gowitness v3 service that is never called
+func newTestScreenshotService(t *testing.T) *web.ScreenshotService {
+	t.Helper()
+
+	service, err := web.NewScreenshotService(http.DefaultClient, "http://screenshots", web.ScreenshotOptions{Version: web.ScreenshotAPIV3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service
+}
+
+func TestBackfillScreenshots(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "example.com - Pending.md", Frontmatter{Title: "Pending", URL: "https://example.com/pending", ID: "pending"}, "status:: unread\nContent")
+	writeNote(t, dir, "example.com - Missing.md", Frontmatter{Title: "Missing", URL: "https://example.com/missing", ID: "missing"}, "Content")
+	writeNote(t, dir, "example.com - Done.md", Frontmatter{Title: "Done", URL: "https://example.com/done", ID: "done"}, "![Screenshot](http://screenshots/screenshots/old.jpeg)\nContent")
+
+	cache, err := BuildCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewProcessor(ProcessorOptions{OutputDir: dir}, newTestContentService(t), newTestScreenshotService(t), cache)
+
+	screenshots := map[string]web.ScreenshotResult{
+		"https://example.com/pending": {URL: "https://example.com/pending"},
+		"https://example.com/done":    {URL: "https://example.com/done"},
+	}
+	for range 2 {
+		p.BackfillScreenshots(screenshots)
+	}
+
+	// The embed goes below the inline fields, once
+	pending := readFile(t, dir, "example.com - Pending.md")
+	if want := "status:: unread\n![Screenshot](http://screenshots/screenshots/https---example.com-pending.jpeg)\nContent"; !strings.Contains(pending, want) {
+		t.Errorf("got note:\n%s\nwant it to contain:\n%s", pending, want)
+	}
+	if n := strings.Count(pending, "![Screenshot]"); n != 1 {
+		t.Errorf("got %d screenshot embeds, want 1", n)
+	}
+
+	// Notes without a taken screenshot or with one already are kept
+	if note := readFile(t, dir, "example.com - Missing.md"); strings.Contains(note, "![Screenshot]") {
+		t.Errorf("note without a screenshot got an embed:\n%s", note)
+	}
+	if note := readFile(t, dir, "example.com - Done.md"); strings.Count(note, "![Screenshot]") != 1 {
+		t.Errorf("note with a screenshot got another embed:\n%s", note)
+	}
+}
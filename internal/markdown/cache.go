@@ -1,55 +1,115 @@
 package markdown
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
+	"io"
 	"iter"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/adrg/frontmatter"
 	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
 )
 
-// Cache maps bookmark IDs to bookmarks
-type Cache map[string]bookmarks.Bookmark
+// minContentLength is the content length below which a note is considered degenerate
+const minContentLength = 80
+
+// CacheEntry is a bookmark known from an existing markdown file
+type CacheEntry struct {
+	bookmarks.Bookmark
+	// File is the note path relative to the output directory
+	File string
+	// Degenerate is set for notes that have no real content, e.g. just the title
+	Degenerate bool
+	// Slug is the permalink slug of the note, if any
+	Slug string
+	// HasScreenshot is set for notes that embed a screenshot
+	HasScreenshot bool
+	// DerivedID is set for notes without an id, matched by their URL
+	DerivedID bool
+	// ReadAt is the read_at date added by the user, empty for unread notes
+	ReadAt string
+	// ReadingTime is the estimated time to read the note
+	ReadingTime time.Duration
+	// DuplicateOf is the file of the note a duplicate stub links to
+	DuplicateOf string
+	// Pinned is set for notes with pinned: true
+	Pinned bool
+}
+
+// Cache maps bookmark IDs to cache entries
+type Cache map[string]CacheEntry
 
 // BuildCache builds the cache from markdown files in the output directory
 func BuildCache(outputDir string) (Cache, error) {
-	slog.Info("building markdown cache", "dir", outputDir)
-	cache := make(Cache)
+	return BuildSubtreeCache(outputDir, "")
+}
+
+// BuildSubtreeCache builds the cache from markdown files in a subdirectory of
+// the output directory only, keeping note paths relative to the output directory
+func BuildSubtreeCache(outputDir string, subPath string) (Cache, error) {
+	return buildCache(outputDir, subPath, true)
+}
+
+// ReadSubtreeCache builds the cache like BuildSubtreeCache, but leaves
+// duplicate notes in place, for dry runs
+func ReadSubtreeCache(outputDir string, subPath string) (Cache, error) {
+	return buildCache(outputDir, subPath, false)
+}
+
+// cacheWorkers is the number of notes read in parallel while building the
+// cache. Reading dominates, so this pays off most on network filesystems.
+const cacheWorkers = 16
+
+// noteHeadSize is how much of a note is read for the cache. Frontmatter is
+// at the top, and what the cache needs from the body is close to it.
+const noteHeadSize = 16 << 10
 
-	err := filepath.Walk(outputDir, func(path string, info os.FileInfo, err error) error {
+// cacheFile is a note file found while building the cache
+type cacheFile struct {
+	path string
+	info os.FileInfo
+}
+
+// parsedNote is a note parsed for the cache, with ok unset for notes that
+// are not bookmark notes
+type parsedNote struct {
+	entry CacheEntry
+	ok    bool
+}
+
+// buildCache builds the cache from markdown files below subPath, moving
+// duplicate notes into the conflicts folder if quarantine is set. Files are
+// found first and then parsed in parallel.
+func buildCache(outputDir string, subPath string, quarantine bool) (Cache, error) {
+	root := filepath.Join(outputDir, subPath)
+	slog.Info("building markdown cache", "dir", root)
+
+	var files []cacheFile
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			slog.Warn("failed to access file", "path", path, "error", err)
 			return nil
 		}
 
-		if !info.IsDir() && strings.HasSuffix(info.Name(), ".md") {
-			slog.Debug("processing cache file", "path", path)
-			content, err := os.ReadFile(path)
-			if err != nil {
-				return nil
-			}
+		if info.IsDir() && (path == filepath.Join(outputDir, conflictsDir) || path == filepath.Join(outputDir, archiveDir)) {
+			return filepath.SkipDir
+		}
 
-			var matter Frontmatter
-			_, err = frontmatter.Parse(strings.NewReader(string(content)), &matter)
-			if err != nil {
-				slog.Warn("failed to parse frontmatter", "path", path, "error", err)
+		if !info.IsDir() && strings.HasSuffix(info.Name(), ".md") {
+			if isSyncConflict(info.Name()) {
+				slog.Debug("skipping sync conflict copy", "path", path)
 				return nil
 			}
-
-			if matter.ID != "" {
-				cache[matter.ID] = bookmarks.Bookmark{
-					ID:        matter.ID,
-					Title:     matter.Title,
-					URI:       matter.URL,
-					AddedUnix: parseCreatedAt(matter.CreatedAt),
-					Type:      "bookmark",
-				}
-			}
+			files = append(files, cacheFile{path: path, info: info})
 		}
 		return nil
 	})
@@ -58,21 +118,224 @@ func BuildCache(outputDir string) (Cache, error) {
 		return nil, fmt.Errorf("error building cache: %w", err)
 	}
 
+	notes := make([]parsedNote, len(files))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, cacheWorkers)
+	for i, file := range files {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			notes[i].entry, notes[i].ok = parseCacheFile(outputDir, file)
+		}()
+	}
+	wg.Wait()
+
+	// Merge in walk order, so duplicates are resolved as by a serial walk
+	cache := make(Cache)
+	modTimes := make(map[string]time.Time)
+	var duplicates []duplicateNote
+	for i, note := range notes {
+		if !note.ok {
+			continue
+		}
+		id, modTime := note.entry.ID, files[i].info.ModTime()
+
+		// Notes matched by their URL are never quarantined, they only match
+		// the bookmark if no note has its real ID
+		if existing, ok := cache[id]; ok && (existing.DerivedID || note.entry.DerivedID) {
+			if existing.DerivedID && !note.entry.DerivedID {
+				modTimes[id] = modTime
+				cache[id] = note.entry
+			}
+			continue
+		}
+
+		// Keep the older of two notes with the same ID
+		if existing, ok := cache[id]; ok {
+			if !modTime.Before(modTimes[id]) {
+				duplicates = append(duplicates, duplicateNote{id: id, kept: existing.File, file: note.entry.File})
+				continue
+			}
+			duplicates = append(duplicates, duplicateNote{id: id, kept: note.entry.File, file: existing.File})
+		}
+		modTimes[id] = modTime
+		cache[id] = note.entry
+	}
+
+	if quarantine {
+		if err := quarantineDuplicates(outputDir, duplicates); err != nil {
+			return nil, err
+		}
+	}
+
 	slog.Info("markdown cache built", "entries", len(cache))
 	return cache, nil
 }
 
-// CollectNewURLs returns URLs that don't exist in the cache
+// parseCacheFile reads the cache entry of a note, returning false for files
+// that are not bookmark notes
+func parseCacheFile(outputDir string, file cacheFile) (CacheEntry, bool) {
+	slog.Debug("processing cache file", "path", file.path)
+	content, truncated, err := readNoteHead(file.path, file.info.Size())
+	if err != nil {
+		return CacheEntry{}, false
+	}
+
+	var matter Frontmatter
+	body, err := frontmatter.Parse(strings.NewReader(content), &matter)
+	if truncated && (err != nil || !frontmatterClosed(content)) {
+		// Frontmatter longer than the head, read the whole note. The parser
+		// returns empty fields without an error when the closing fence is
+		// missing, so look for the fence itself.
+		data, readErr := os.ReadFile(file.path)
+		if readErr != nil {
+			return CacheEntry{}, false
+		}
+		content, truncated = string(data), false
+		body, err = frontmatter.Parse(strings.NewReader(content), &matter)
+	}
+	if err != nil {
+		slog.Warn("failed to parse frontmatter", "path", file.path, "error", err)
+		return CacheEntry{}, false
+	}
+
+	// Notes of bookmarks imported without an ID are matched by URL
+	derivedID := false
+	if matter.ID == "" && matter.URL != "" {
+		matter.ID = bookmarks.DeriveID(matter.URL)
+		derivedID = true
+	}
+	if matter.ID == "" {
+		return CacheEntry{}, false
+	}
+
+	relPath, err := filepath.Rel(outputDir, file.path)
+	if err != nil {
+		return CacheEntry{}, false
+	}
+
+	var tags []string
+	for _, tag := range matter.Tags {
+		if !isGeneratedTag(tag) {
+			tags = append(tags, tag)
+		}
+	}
+
+	// The reading time of notes longer than the head is extrapolated
+	words := len(strings.Fields(string(body)))
+	if truncated {
+		words = int(int64(words) * file.info.Size() / int64(len(content)))
+	}
+
+	return CacheEntry{
+		Bookmark: bookmarks.Bookmark{
+			ID:        matter.ID,
+			Title:     matter.Title,
+			URI:       matter.URL,
+			AddedUnix: parseCreatedAt(matter.CreatedAt),
+			Type:      bookmarks.TypeBookmark,
+			Tags:      tags,
+			Keyword:   matter.Keyword,
+		},
+		File: relPath,
+		// Fragment and duplicate stubs are short on purpose. Notes whose
+		// generated content doesn't end in the head are never degenerate.
+		Degenerate:    matter.Fragment == "" && matter.DuplicateOf == "" && isDegenerateBody(string(body), matter.Title),
+		Slug:          matter.Slug,
+		HasScreenshot: strings.Contains(string(body), "![Screenshot]("),
+		DerivedID:     derivedID,
+		ReadAt:        matter.ReadAt,
+		ReadingTime:   wordsReadingTime(words),
+		DuplicateOf:   linkTarget(matter.DuplicateOf),
+		Pinned:        matter.Pinned,
+	}, true
+}
+
+// frontmatterClosed reports whether content has no frontmatter or its
+// frontmatter ends within content
+func frontmatterClosed(content string) bool {
+	if !strings.HasPrefix(content, "---") {
+		return true
+	}
+	_, rest, ok := strings.Cut(content, "\n")
+	return ok && (strings.HasPrefix(rest, "---") || strings.Contains(rest, "\n---"))
+}
+
+// readNoteHead reads up to noteHeadSize bytes of a note of size bytes,
+// reporting whether the note is longer
+func readNoteHead(path string, size int64) (string, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+
+	// One byte more tells if the note is longer than the head
+	buf := make([]byte, min(size, noteHeadSize)+1)
+	n, err := io.ReadFull(f, buf)
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return string(buf[:n]), false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if n <= noteHeadSize {
+		// The note grew since it was found, read the rest
+		rest, err := io.ReadAll(f)
+		if err != nil {
+			return "", false, err
+		}
+		return string(buf) + string(rest), false, nil
+	}
+
+	// Don't cut a character in half
+	head := buf[:noteHeadSize]
+	for len(head) > 0 && !utf8.RuneStart(buf[len(head)]) {
+		head = head[:len(head)-1]
+	}
+	return string(head), true, nil
+}
+
+// CollectNewURLs returns unique URLs of bookmarks that don't exist in the cache
 func (c Cache) CollectNewURLs(bookmarks iter.Seq[*bookmarks.Bookmark]) []string {
 	var urls []string
+	seen := make(map[string]bool)
 	for bookmark := range bookmarks {
-		if _, exists := c[bookmark.ID]; !exists {
+		if _, exists := c[bookmark.ID]; !exists && !seen[bookmark.URI] {
+			seen[bookmark.URI] = true
 			urls = append(urls, bookmark.URI)
 		}
 	}
 	return urls
 }
 
+// Degenerate returns cache entries for notes without real content
+func (c Cache) Degenerate() []CacheEntry {
+	var entries []CacheEntry
+	for _, entry := range c.sorted() {
+		if entry.Degenerate {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// sorted returns all cache entries sorted by file, so that output derived
+// from the cache does not depend on map iteration order
+func (c Cache) sorted() []CacheEntry {
+	entries := make([]CacheEntry, 0, len(c))
+	for _, entry := range c {
+		entries = append(entries, entry)
+	}
+
+	slices.SortFunc(entries, func(a, b CacheEntry) int {
+		return cmp.Or(strings.Compare(a.File, b.File), strings.Compare(a.ID, b.ID))
+	})
+	return entries
+}
+
 // parseCreatedAt parses a date string into Unix timestamp
 func parseCreatedAt(date string) int64 {
 	t, err := time.Parse("2006-01-02", date)
@@ -0,0 +1,147 @@
+package markdown
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestBuildSubtreeCache(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "example.com - Top.md", Frontmatter{Title: "Top", URL: "https://example.com/top", ID: "top"}, "Content")
+	writeNote(t, dir, "work/project/example.com - Spec.md", Frontmatter{Title: "Spec", URL: "https://example.com/spec", ID: "spec"}, "Content")
+	writeNote(t, dir, "work/other/example.com - Other.md", Frontmatter{Title: "Other", URL: "https://example.com/other", ID: "other"}, "Content")
+
+	cache, err := BuildSubtreeCache(dir, "work/project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cache) != 1 {
+		t.Fatalf("got %d cached notes, want only the subfolder note", len(cache))
+	}
+	// Paths stay relative to the output directory, not the subfolder
+	if got := cache["spec"].File; got != "work/project/example.com - Spec.md" {
+		t.Errorf("got file %q, want the path relative to the output directory", got)
+	}
+
+	full, err := BuildCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(full) != 3 {
+		t.Errorf("got %d cached notes for the full output, want 3", len(full))
+	}
+}
+
+func TestSubfolderSync(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "work/project/example.com - Spec.md", Frontmatter{Title: "Spec", URL: "https://example.com/spec", ID: "spec"}, "Kept")
+
+	// Only the subfolder is passed in, and notes land at its path in the output
+	project := testFolder("project",
+		testBookmark("spec", "Spec", "https://example.com/spec"),
+		testBookmark("new", "New", "https://example.com/new"),
+	)
+	cache, err := BuildSubtreeCache(dir, "work/project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewProcessor(ProcessorOptions{OutputDir: dir}, newTestContentService(t), nil, cache)
+	if err := p.ProcessBookmarks(project, "work/project"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !exists(dir, "work/project/example.com - New.md") {
+		t.Error("new bookmark not written into the subfolder")
+	}
+	if got := readFile(t, dir, "work/project/example.com - Spec.md"); !strings.Contains(got, "Kept") {
+		t.Errorf("existing note was rewritten:\n%s", got)
+	}
+}
+
+func TestBuildCacheLongFrontmatter(t *testing.T) {
+	dir := t.TempDir()
+	// Aliases push the end of the frontmatter past the head read for the cache
+	var aliases []string
+	for i := 0; len(strings.Join(aliases, "")) < 2*noteHeadSize; i++ {
+		aliases = append(aliases, fmt.Sprintf("Alias number %d of a bookmark merged from many folders", i))
+	}
+	writeNote(t, dir, "Long.md", Frontmatter{Title: "Long", URL: "https://example.com/long", ID: "long-id", Aliases: aliases}, "content")
+
+	cache, err := BuildCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, ok := cache["long-id"]
+	if !ok {
+		t.Fatalf("note with long frontmatter is missing from the cache: %v", cache)
+	}
+	if entry.Title != "Long" || entry.URI != "https://example.com/long" || entry.File != "Long.md" {
+		t.Errorf("unexpected cache entry %+v", entry)
+	}
+}
+
+func TestBuildCacheLongBody(t *testing.T) {
+	dir := t.TempDir()
+	body := strings.Repeat("word ", 2*noteHeadSize)
+	writeNote(t, dir, "Long.md", Frontmatter{Title: "Long", URL: "https://example.com/long", ID: "long-id"}, body)
+
+	cache, err := BuildCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := cache["long-id"]
+	if entry.Degenerate {
+		t.Error("long note is degenerate")
+	}
+	// Extrapolated from the head, so only roughly the real reading time
+	if want := wordsReadingTime(2 * noteHeadSize); entry.ReadingTime < want*9/10 || entry.ReadingTime > want*11/10 {
+		t.Errorf("reading time %v, want about %v", entry.ReadingTime, want)
+	}
+}
+
+func TestFrontmatterClosed(t *testing.T) {
+	tests := []struct {
+		content string
+		want    bool
+	}{
+		{"no frontmatter", true},
+		{"---\ntitle: a\n---\nbody", true},
+		{"---\n---\nbody", true},
+		{"---\ntitle: a\nurl: b", false},
+		{"---", false},
+	}
+	for _, test := range tests {
+		if got := frontmatterClosed(test.content); got != test.want {
+			t.Errorf("frontmatterClosed(%q) = %v, want %v", test.content, got, test.want)
+		}
+	}
+}
+
+// BenchmarkBuildCache builds the cache of a vault with 10000 notes
+func BenchmarkBuildCache(b *testing.B) {
+	dir := b.TempDir()
+	body := strings.Repeat("Some content of a bookmarked page. ", 200)
+	for i := range 10000 {
+		matter := Frontmatter{
+			Title:     fmt.Sprintf("Bookmark %d", i),
+			URL:       fmt.Sprintf("https://example.com/%d", i),
+			Path:      fmt.Sprintf("folder-%d", i%50),
+			CreatedAt: "2024-03-01",
+			ID:        fmt.Sprintf("id-%d", i),
+			Tags:      []string{"bookmark", "go"},
+		}
+		writeNote(b, dir, fmt.Sprintf("folder-%d/Bookmark %d.md", i%50, i), matter, body)
+	}
+	b.ResetTimer()
+
+	for range b.N {
+		cache, err := ReadSubtreeCache(dir, "")
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(cache) != 10000 {
+			b.Fatalf("cache has %d entries", len(cache))
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package markdown
+
+import (
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/web"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/x"
+)
+
+// checkpointKey returns the cache key holding IDs of notes created by an
+// unfinished run into outputDir
+func checkpointKey(outputDir string) string {
+	if abs, err := filepath.Abs(outputDir); err == nil {
+		outputDir = abs
+	}
+	return "checkpoint-" + web.URLKey(outputDir)
+}
+
+// checkpointInterval is the number of created notes between checkpoint writes
+const checkpointInterval = 10
+
+// loadCheckpoint reads IDs processed by a previous, interrupted run
+func loadCheckpoint(cache x.Cache, key string) map[string]bool {
+	ids := make(map[string]bool)
+	if cache == nil {
+		return ids
+	}
+
+	content, ok := cache.Get(key)
+	if !ok {
+		return ids
+	}
+
+	for _, id := range strings.Fields(content) {
+		ids[id] = true
+	}
+
+	if len(ids) > 0 {
+		slog.Info("resuming interrupted run", "processed", len(ids))
+	}
+	return ids
+}
+
+// checkpoint records a processed ID, periodically persisting the set
+func (p *Processor) checkpoint(id string) {
+	if p.checkpointCache == nil {
+		return
+	}
+
+	p.checkpointed[id] = true
+	if len(p.checkpointed)%checkpointInterval == 0 {
+		p.saveCheckpoint()
+	}
+}
+
+// saveCheckpoint persists the processed IDs
+func (p *Processor) saveCheckpoint() {
+	if p.checkpointCache == nil {
+		return
+	}
+
+	ids := make([]string, 0, len(p.checkpointed))
+	for id := range p.checkpointed {
+		ids = append(ids, id)
+	}
+
+	if err := p.checkpointCache.Set(p.checkpointKey, strings.Join(ids, "\n")); err != nil {
+		slog.Warn("failed to write checkpoint", "error", err)
+	}
+}
+
+// clearCheckpoint removes the checkpoint after a completed run
+func (p *Processor) clearCheckpoint() {
+	if p.checkpointCache == nil {
+		return
+	}
+
+	if err := p.checkpointCache.Set(p.checkpointKey, ""); err != nil {
+		slog.Warn("failed to clear checkpoint", "error", err)
+	}
+	clear(p.checkpointed)
+}
@@ -0,0 +1,92 @@
+package markdown
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/x"
+)
+
+// memCache is an in-memory x.Cache recording every write
+type memCache struct {
+	entries map[string]string
+	writes  []string
+}
+
+func (c *memCache) Get(key string) (string, bool) {
+	content, ok := c.entries[key]
+	return content, ok
+}
+
+func (c *memCache) Set(key string, content string) error {
+	c.entries[key] = content
+	c.writes = append(c.writes, content)
+	return nil
+}
+
+var _ x.Cache = (*memCache)(nil)
+
+func TestOrder(t *testing.T) {
+	older := testBookmark("older", "Older", "https://example.com/older")
+	older.AddedUnix -= 86400
+	newer := testBookmark("newer", "Newer", "https://example.com/newer")
+	newer.AddedUnix += 86400
+	tree := testFolder("toolbar",
+		testBookmark("middle", "Middle", "https://example.com/middle"),
+		testFolder("Reading", newer),
+		older,
+	)
+
+	for order, want := range map[string][]string{
+		OrderFolder: {"example.com - Middle.md", "Reading/example.com - Newer.md", "example.com - Older.md"},
+		OrderNewest: {"Reading/example.com - Newer.md", "example.com - Middle.md", "example.com - Older.md"},
+		OrderOldest: {"example.com - Older.md", "example.com - Middle.md", "Reading/example.com - Newer.md"},
+	} {
+		hooks := &recordingHooks{}
+		p := newTestProcessor(t, t.TempDir(), ProcessorOptions{Order: order, Hooks: hooks})
+		if err := p.ProcessBookmarks(tree, ""); err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(hooks.paths, want) {
+			t.Errorf("%s: got notes created in order %q, want %q", order, hooks.paths, want)
+		}
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	var children []bookmarks.Bookmark
+	for i := range checkpointInterval + 2 {
+		children = append(children, testBookmark(fmt.Sprint("id", i), fmt.Sprint("Note ", i), fmt.Sprint("https://example.com/", i)))
+	}
+	tree := testFolder("toolbar", children...)
+
+	// An interrupted run already created the first note, whose file is gone
+	cache := &memCache{entries: map[string]string{checkpointKey(dir): "id0"}}
+	p := newTestProcessor(t, dir, ProcessorOptions{Checkpoint: cache})
+	if err := p.ProcessBookmarks(tree, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if exists(dir, "example.com - Note 0.md") {
+		t.Error("note from the interrupted run was created again")
+	}
+	if !exists(dir, "example.com - Note 1.md") {
+		t.Error("remaining notes were not created")
+	}
+
+	// Progress was saved once checkpointInterval notes were processed, and
+	// cleared when the run completed
+	if len(cache.writes) != 2 {
+		t.Fatalf("got checkpoint writes %q, want one save and one clear", cache.writes)
+	}
+	if saved := strings.Fields(cache.writes[0]); len(saved) != checkpointInterval || !slices.Contains(saved, "id0") {
+		t.Errorf("got saved checkpoint %q", saved)
+	}
+	if got := cache.entries[checkpointKey(dir)]; got != "" {
+		t.Errorf("got checkpoint %q after a completed run, want it cleared", got)
+	}
+}
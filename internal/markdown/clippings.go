@@ -0,0 +1,157 @@
+package markdown
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+	"gopkg.in/yaml.v2"
+)
+
+// Clipping is a note created by the Obsidian Web Clipper
+type Clipping struct {
+	// File is the note path relative to the output directory
+	File  string
+	URL   string
+	Title string
+}
+
+// clippingFields maps Web Clipper frontmatter fields to the fields used by
+// generated notes
+var clippingFields = map[string]string{
+	"source":  "url",
+	"created": "created_at",
+}
+
+// LoadClippings reads Web Clipper notes from dir, relative to the output
+// directory, keyed by their source URL
+func LoadClippings(outputDir string, dir string) (map[string]Clipping, error) {
+	clippings := make(map[string]Clipping)
+
+	err := filepath.WalkDir(filepath.Join(outputDir, dir), func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".md") || isSyncConflict(d.Name()) {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		matter, _, err := splitNote(string(data))
+		if err != nil {
+			return nil
+		}
+
+		var fields struct {
+			Title  string `yaml:"title"`
+			Source string `yaml:"source"`
+			URL    string `yaml:"url"`
+		}
+		if err := yaml.Unmarshal([]byte(strings.Trim(matter, "-\n")), &fields); err != nil {
+			slog.Warn("failed to parse clipping frontmatter", "path", path, "error", err)
+			return nil
+		}
+
+		// Rewritten clippings already use the url field
+		url := cmp.Or(fields.Source, fields.URL)
+		if url == "" {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(outputDir, path)
+		if err != nil {
+			return err
+		}
+		clippings[clippingKey(url)] = Clipping{File: relPath, URL: url, Title: fields.Title}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read clippings: %w", err)
+	}
+
+	slog.Info("loaded clippings", "count", len(clippings))
+	return clippings, nil
+}
+
+// clippingKey normalizes a URL for matching bookmarks with clippings
+func clippingKey(url string) string {
+	return strings.TrimSuffix(url, "/")
+}
+
+// adoptClipping registers a clipping as the note of a bookmark with the same URL
+func (p *Processor) adoptClipping(bookmark bookmarks.Bookmark) bool {
+	clipping, ok := p.clippings[clippingKey(bookmark.URI)]
+	if !ok {
+		return false
+	}
+
+	slog.Info("adopting clipping", "title", bookmark.Title, "file", clipping.File)
+	if p.rewriteClippings {
+		if err := p.rewriteClipping(clipping, bookmark); err != nil {
+			slog.Warn("failed to rewrite clipping frontmatter", "file", clipping.File, "error", err)
+		}
+	}
+
+	p.cache[bookmark.ID] = CacheEntry{
+		Bookmark: bookmark,
+		File:     clipping.File,
+	}
+	return true
+}
+
+// rewriteClipping renames clipping frontmatter fields to the ones of
+// generated notes and adds the bookmark ID, so the clipping is recognized as
+// the note of the bookmark by later runs
+func (p *Processor) rewriteClipping(clipping Clipping, bookmark bookmarks.Bookmark) error {
+	path := filepath.Join(p.outputDir, clipping.File)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	matter, body, err := splitNote(string(data))
+	if err != nil {
+		return err
+	}
+
+	var fields yaml.MapSlice
+	if err := yaml.Unmarshal([]byte(strings.Trim(matter, "-\n")), &fields); err != nil {
+		return fmt.Errorf("failed to parse frontmatter: %w", err)
+	}
+
+	hasTag := false
+	for i, field := range fields {
+		key, _ := field.Key.(string)
+		if renamed, ok := clippingFields[key]; ok {
+			fields[i].Key = renamed
+		}
+		if key == "tags" {
+			// Year indexes list notes tagged as bookmarks
+			if tags, ok := field.Value.([]interface{}); ok && !slices.Contains(tags, interface{}("bookmark")) {
+				fields[i].Value = append(tags, "bookmark")
+			}
+			hasTag = true
+		}
+	}
+	fields = append(fields, yaml.MapItem{Key: "id", Value: bookmark.ID})
+	if !hasTag {
+		fields = append(fields, yaml.MapItem{Key: "tags", Value: []string{"bookmark"}})
+	}
+
+	out, err := yaml.Marshal(fields)
+	if err != nil {
+		return err
+	}
+
+	note := "---\n" + string(out) + "---\n" + body
+	return p.output.WriteFile(clipping.File, []byte(note))
+}
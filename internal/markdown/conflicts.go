@@ -0,0 +1,141 @@
+package markdown
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/adrg/frontmatter"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+)
+
+// conflictsDir is the folder below the output directory that duplicate notes
+// are moved into
+const conflictsDir = "_conflicts"
+
+// conflictsReport lists the quarantined notes
+const conflictsReport = "report.md"
+
+// duplicateNote is a note sharing its bookmark ID with an older note
+type duplicateNote struct {
+	id   string
+	kept string
+	file string
+}
+
+// quarantineDuplicates moves duplicate notes, which appear when several
+// machines sync into the same vault, into the conflicts folder and appends
+// them to the conflicts report. The older note is always the one kept, so
+// every machine resolves a conflict the same way.
+func quarantineDuplicates(outputDir string, duplicates []duplicateNote) error {
+	if len(duplicates) == 0 {
+		return nil
+	}
+
+	var report strings.Builder
+	for _, dup := range duplicates {
+		// Date entries by the duplicate itself, so the report only depends on the vault
+		info, err := os.Stat(filepath.Join(outputDir, dup.file))
+		if err != nil {
+			return fmt.Errorf("failed to quarantine %s: %w", dup.file, err)
+		}
+
+		dest := filepath.Join(outputDir, conflictsDir, dup.file)
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return fmt.Errorf("failed to create conflicts directory: %w", err)
+		}
+		if err := os.Rename(filepath.Join(outputDir, dup.file), dest); err != nil {
+			return fmt.Errorf("failed to quarantine %s: %w", dup.file, err)
+		}
+
+		slog.Warn("quarantined duplicate note",
+			"id", dup.id,
+			"kept", dup.kept,
+			"file", filepath.Join(conflictsDir, dup.file))
+		report.WriteString(fmt.Sprintf("- %s: id %s, kept %s, moved %s\n",
+			info.ModTime().UTC().Format(time.RFC3339), dup.id, wikilink(dup.kept, ""), wikilink(filepath.Join(conflictsDir, dup.file), "")))
+	}
+
+	return appendConflictsReport(outputDir, report.String())
+}
+
+// appendConflictsReport appends lines to the conflicts report
+func appendConflictsReport(outputDir string, lines string) error {
+	f, err := os.OpenFile(filepath.Join(outputDir, conflictsDir, conflictsReport), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open conflicts report: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(lines); err != nil {
+		return fmt.Errorf("failed to write conflicts report: %w", err)
+	}
+	return nil
+}
+
+// existingNoteID returns the bookmark ID of a file already in the output
+// directory, e.g. a note synced from another machine since the cache was
+// built, and whether the file exists at all. Files without an id, like notes
+// written by hand, have an empty ID.
+func (p *Processor) existingNoteID(file string) (string, bool) {
+	data, err := os.ReadFile(filepath.Join(p.outputDir, file))
+	if err != nil {
+		return "", false
+	}
+
+	var matter Frontmatter
+	if _, err := frontmatter.Parse(strings.NewReader(string(data)), &matter); err != nil {
+		return "", true
+	}
+	return matter.ID, true
+}
+
+// shortIDLength is the length of the bookmark ID prefix that tells apart notes
+// of bookmarks with the same title
+const shortIDLength = 6
+
+// noteFileName returns filename for the note of a bookmark in currentPath,
+// unless a note of another bookmark already has the name. Then the start of
+// the bookmark ID, or the whole ID if that is taken too, is appended, so
+// bookmarks with the same title don't overwrite each other.
+func (p *Processor) noteFileName(bookmark bookmarks.Bookmark, currentPath string, filename string) string {
+	if !p.fileTaken(filepath.Join(currentPath, filename), bookmark.ID) {
+		return filename
+	}
+
+	stem := strings.TrimSuffix(filename, ".md")
+	candidates := []string{bookmark.ID}
+	if len(bookmark.ID) > shortIDLength {
+		candidates = []string{bookmark.ID[:shortIDLength], bookmark.ID}
+	}
+
+	var name string
+	for _, suffix := range candidates {
+		name = p.fitFileName(currentPath, fmt.Sprintf("%s (%s).md", stem, suffix))
+		if !p.fileTaken(filepath.Join(currentPath, name), bookmark.ID) {
+			break
+		}
+	}
+
+	slog.Warn("note file name taken by another bookmark",
+		"title", bookmark.Title,
+		"file", filepath.Join(currentPath, filename),
+		"renamed", name)
+	return name
+}
+
+// fileTaken checks whether a note of a bookmark other than id was written to
+// file in this run, or any file not owned by id exists in the output
+// directory. Names written in this run are compared case-insensitively, like
+// case-insensitive filesystems do.
+func (p *Processor) fileTaken(file string, id string) bool {
+	if owner, ok := p.noteFiles[strings.ToLower(file)]; ok {
+		return owner != id
+	}
+	owner, exists := p.existingNoteID(file)
+	return exists && owner != id
+}
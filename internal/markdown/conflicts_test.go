@@ -0,0 +1,75 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSameTitleBookmarks(t *testing.T) {
+	dir := t.TempDir()
+	p := newTestProcessor(t, dir, ProcessorOptions{})
+
+	folder := testFolder("toolbar",
+		testBookmark("first-bookmark", "Release notes", "https://example.com/a"),
+		testBookmark("second-bookmark", "Release notes", "https://example.com/b"),
+	)
+	if err := p.ProcessBookmarks(folder, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	first := readFile(t, dir, "example.com - Release notes.md")
+	second := readFile(t, dir, "example.com - Release notes (second).md")
+	if !strings.Contains(first, "id: first-bookmark") || !strings.Contains(first, "Content of https://example.com/a") {
+		t.Errorf("first note was overwritten:\n%s", first)
+	}
+	if !strings.Contains(second, "id: second-bookmark") || !strings.Contains(second, "Content of https://example.com/b") {
+		t.Errorf("second note has the wrong content:\n%s", second)
+	}
+}
+
+func TestHandWrittenNoteIsNotOverwritten(t *testing.T) {
+	dir := t.TempDir()
+	for file, content := range map[string]string{
+		"example.com - Notes.md":   "My own notes\n",
+		"example.com - Reading.md": "---\nurl: https://example.com/reading\n---\nWritten by hand\n",
+	} {
+		writeFile(t, dir, file, content)
+	}
+	p := newTestProcessor(t, dir, ProcessorOptions{})
+
+	folder := testFolder("toolbar",
+		testBookmark("notes-id", "Notes", "https://example.com/notes"),
+		testBookmark("reading-id", "Reading", "https://example.com/reading-list"),
+	)
+	if err := p.ProcessBookmarks(folder, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readFile(t, dir, "example.com - Notes.md"); got != "My own notes\n" {
+		t.Errorf("note without frontmatter was overwritten:\n%s", got)
+	}
+	if got := readFile(t, dir, "example.com - Reading.md"); !strings.Contains(got, "Written by hand") {
+		t.Errorf("note without id was overwritten:\n%s", got)
+	}
+	if !exists(dir, "example.com - Notes (notes-).md") || !exists(dir, "example.com - Reading (readin).md") {
+		t.Error("bookmark notes were not written under suffixed names")
+	}
+}
+
+func TestRetitleKeepsNameTakenByHandWrittenNote(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "example.com - Old.md", Frontmatter{Title: "Old", URL: "https://example.com/", ID: "id"}, "content")
+	writeFile(t, dir, "example.com - New.md", "My own notes\n")
+	p := newTestProcessor(t, dir, ProcessorOptions{RenameOnTitleChange: true})
+
+	if err := p.ProcessBookmarks(testFolder("toolbar", testBookmark("id", "New", "https://example.com/")), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readFile(t, dir, "example.com - New.md"); got != "My own notes\n" {
+		t.Errorf("hand-written note was overwritten:\n%s", got)
+	}
+	if got := readFile(t, dir, "example.com - Old.md"); !strings.Contains(got, "title: New") {
+		t.Errorf("title was not updated in place:\n%s", got)
+	}
+}
@@ -0,0 +1,52 @@
+package markdown
+
+import (
+	"time"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+)
+
+// DefaultMinYear is the earliest year accepted for bookmark dates, older
+// dates come from broken imports
+const DefaultMinYear = 1990
+
+// unknownYearIndex lists notes whose bookmark date had to be corrected
+const unknownYearIndex = "unknown"
+
+// dateRange returns the range of plausible bookmark dates. A zero maxYear
+// allows dates up to the end of the year after the run at now.
+func dateRange(minYear int, maxYear int, now time.Time) (time.Time, time.Time) {
+	if minYear == 0 {
+		minYear = DefaultMinYear
+	}
+	if maxYear == 0 {
+		maxYear = now.Year() + 1
+	}
+	return time.Date(minYear, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(maxYear+1, 1, 1, 0, 0, 0, 0, time.UTC).Add(-time.Second)
+}
+
+// addedTime returns when a bookmark was added and whether its timestamp was
+// implausible and had to be corrected
+func (p *Processor) addedTime(bookmark bookmarks.Bookmark) (time.Time, bool) {
+	t, suspect := resolveAdded(bookmark.AddedUnix, p.minDate, p.maxDate)
+	return t.In(p.location), suspect
+}
+
+// resolveAdded turns a bookmark timestamp into a date between minDate and
+// maxDate. Timestamps too far in the future are taken as milli- or
+// microseconds, as written by some exporters, and anything still out of
+// range is clamped.
+func resolveAdded(added int64, minDate time.Time, maxDate time.Time) (time.Time, bool) {
+	suspect := false
+	for added > maxDate.Unix() {
+		added /= 1000
+		suspect = true
+	}
+
+	t := time.Unix(added, 0)
+	if t.Before(minDate) {
+		return minDate, true
+	}
+	return t, suspect
+}
@@ -0,0 +1,72 @@
+package markdown
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDateRange(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	minDate, maxDate := dateRange(0, 0, now)
+	if want := time.Date(DefaultMinYear, 1, 1, 0, 0, 0, 0, time.UTC); !minDate.Equal(want) {
+		t.Errorf("got min date %s, want %s", minDate, want)
+	}
+	if want := time.Date(2025, 12, 31, 23, 59, 59, 0, time.UTC); !maxDate.Equal(want) {
+		t.Errorf("got max date %s, want the end of the year after the run %s", maxDate, want)
+	}
+
+	_, maxDate = dateRange(0, 2030, now)
+	if maxDate.Year() != 2030 {
+		t.Errorf("got max date %s, want the end of 2030", maxDate)
+	}
+}
+
+func TestResolveAdded(t *testing.T) {
+	minDate, maxDate := dateRange(0, 0, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+	added := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		added   int64
+		want    time.Time
+		suspect bool
+	}{
+		{"seconds", added.Unix(), added, false},
+		{"milliseconds", added.UnixMilli(), added, true},
+		{"microseconds", added.UnixMicro(), added, true},
+		{"next year", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).Unix(), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), false},
+		{"zero", 0, minDate, true},
+		{"negative", -86400, minDate, true},
+		{"before min year", time.Date(1985, 1, 1, 0, 0, 0, 0, time.UTC).Unix(), minDate, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, suspect := resolveAdded(test.added, minDate, maxDate)
+			if !got.Equal(test.want) || suspect != test.suspect {
+				t.Errorf("got %s (suspect %v), want %s (suspect %v)", got.UTC(), suspect, test.want, test.suspect)
+			}
+		})
+	}
+}
+
+func TestAddedTimeUsesRunClock(t *testing.T) {
+	added := time.Date(2031, 5, 1, 0, 0, 0, 0, time.UTC)
+	bookmark := testBookmark("future-id", "Future", "https://example.com/future")
+	bookmark.AddedUnix = added.Unix()
+
+	// A date plausible for a run in 2030 is suspect for one in 2024
+	for _, test := range []struct {
+		now     time.Time
+		suspect bool
+	}{
+		{time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC), false},
+		{time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), true},
+	} {
+		p := newTestProcessor(t, t.TempDir(), ProcessorOptions{Deterministic: true, Now: test.now})
+		if _, suspect := p.addedTime(bookmark); suspect != test.suspect {
+			t.Errorf("run in %d: got suspect %v, want %v", test.now.Year(), suspect, test.suspect)
+		}
+	}
+}
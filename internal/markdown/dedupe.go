@@ -0,0 +1,169 @@
+package markdown
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/web"
+)
+
+// dedupePlanned merges planned notes sharing a normalized URL into a single
+// note that records the folder paths of all of them. Bookmarks of a URL that
+// already has a note are mapped to the existing note.
+func (p *Processor) dedupePlanned(planned []plannedNote) []plannedNote {
+	existing := make(map[string]CacheEntry)
+	for _, entry := range p.cache.sorted() {
+		key := p.dedupeKey(entry.URI)
+		if _, ok := existing[key]; !ok && entry.File != "" && entry.DuplicateOf == "" {
+			existing[key] = entry
+		}
+	}
+
+	var deduped []plannedNote
+	byURL := make(map[string]int)
+	for _, note := range planned {
+		bookmark := note.bookmark
+		key := p.dedupeKey(bookmark.URI)
+
+		if entry, ok := existing[key]; ok {
+			slog.Info("skipping duplicate bookmark", "title", bookmark.Title, "file", entry.File)
+			p.cache[bookmark.ID] = CacheEntry{Bookmark: bookmark, File: entry.File}
+			if p.dedupeStubs {
+				p.createDuplicateStub(bookmark, note.path, note.filename, entry)
+			}
+			continue
+		}
+
+		if i, ok := byURL[key]; ok {
+			slog.Info("merging duplicate bookmark", "title", bookmark.Title, "path", note.path)
+			if !slices.Contains(deduped[i].paths, note.path) {
+				deduped[i].paths = append(deduped[i].paths, note.path)
+			}
+			deduped[i].duplicates = append(deduped[i].duplicates, plannedNote{bookmark: bookmark, path: note.path, filename: note.filename})
+			continue
+		}
+
+		note.paths = []string{note.path}
+		byURL[key] = len(deduped)
+		deduped = append(deduped, note)
+	}
+
+	return deduped
+}
+
+// duplicateTag tags duplicate stubs, which stand for no bookmark of their own
+const duplicateTag = "bookmark-duplicate"
+
+// dedupeKey normalizes a URL for finding duplicates like web.NormalizeURL.
+// The fragment is kept when fragment bookmarks get their own notes linking
+// to the page.
+func (p *Processor) dedupeKey(u string) string {
+	key := web.NormalizeURL(u, p.trackingParams)
+	if _, fragment := splitFragment(u); fragment != "" && p.fragmentMode != "" && p.fragmentMode != FragmentFull {
+		key += "#" + fragment
+	}
+	return key
+}
+
+// aliases returns the titles of duplicates that differ from the title of the
+// note, so the note can be found by any of them
+func (note plannedNote) aliases() []string {
+	var aliases []string
+	for _, duplicate := range note.duplicates {
+		alias := duplicate.bookmark.Title
+		if alias != "" && alias != note.bookmark.Title && !slices.Contains(aliases, alias) {
+			aliases = append(aliases, alias)
+		}
+	}
+	return aliases
+}
+
+// createDuplicateStub writes a short note for a duplicate bookmark, linking
+// to the note of the canonical bookmark
+func (p *Processor) createDuplicateStub(bookmark bookmarks.Bookmark, currentPath string, filename string, canonical CacheEntry) {
+	added, suspect := p.addedTime(bookmark)
+	frontmatter := Frontmatter{
+		CreatedAt:   added.Format("2006-01-02"),
+		DateSuspect: suspect,
+		Path:        currentPath,
+		URL:         bookmark.URI,
+		ID:          bookmark.ID,
+		Title:       bookmark.Title,
+		DuplicateOf: wikilink(canonical.File, ""),
+		Tags:        []string{duplicateTag},
+	}
+	content := fmt.Sprintf("Duplicate of %s", wikilink(canonical.File, canonical.Title))
+
+	filePath := filepath.Join(currentPath, p.noteFileName(bookmark, currentPath, filename))
+	if err := p.output.WriteFile(filePath, []byte(frontmatter.String()+"\n"+p.renderBody(frontmatter, content))); err != nil {
+		slog.Warn("failed to write duplicate stub", "file", filePath, "error", err)
+		return
+	}
+	slog.Info("wrote duplicate stub", "file", filePath, "canonical", canonical.File)
+	p.noteFiles[strings.ToLower(filePath)] = bookmark.ID
+
+	p.cache[bookmark.ID] = CacheEntry{Bookmark: bookmark, File: filePath, DuplicateOf: canonical.File}
+}
+
+// linkTarget returns the note file of a wikilink written by wikilink
+func linkTarget(link string) string {
+	target, _, _ := strings.Cut(strings.TrimSuffix(strings.TrimPrefix(link, "[["), "]]"), "|")
+	if target == "" {
+		return ""
+	}
+	return filepath.FromSlash(target) + ".md"
+}
+
+// promoteDuplicate hands the note of a removed bookmark over to a remaining
+// duplicate of it, returning false if there is none. The duplicate's stub is
+// removed, since the note now stands for it. With dryRun set, the promotion
+// is only reported.
+func (p *Processor) promoteDuplicate(entry CacheEntry, present map[string]bool) (bool, error) {
+	var successor CacheEntry
+	for _, candidate := range p.cache.sorted() {
+		if present[candidate.ID] && candidate.ID != entry.ID && (candidate.File == entry.File || candidate.DuplicateOf == entry.File) {
+			successor = candidate
+			break
+		}
+	}
+	if successor.ID == "" {
+		return false, nil
+	}
+
+	if p.dryRun != "" {
+		// The note is kept for the successor bookmark
+		change := noteChange(ChangeUpdate, successor, ReasonDuplicate)
+		change.File = entry.File
+		p.planChange(change)
+		return true, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(p.outputDir, entry.File))
+	if err != nil {
+		return false, fmt.Errorf("failed to read note: %w", err)
+	}
+	rawMatter, body, err := splitNote(string(data))
+	if err != nil {
+		return false, err
+	}
+	if err := p.output.WriteFile(entry.File, []byte(setFrontmatterLine(rawMatter, "id", successor.ID)+"\n"+body)); err != nil {
+		return false, fmt.Errorf("failed to write note: %w", err)
+	}
+	if successor.DuplicateOf != "" {
+		if err := p.output.Remove(successor.File); err != nil {
+			return false, fmt.Errorf("failed to remove duplicate stub: %w", err)
+		}
+	}
+	slog.Info("promoted duplicate to canonical bookmark", "file", entry.File, "id", successor.ID, "previous", entry.ID)
+
+	delete(p.cache, entry.ID)
+	entry.ID = successor.ID
+	entry.DerivedID = false
+	p.cache[successor.ID] = entry
+	return true, nil
+}
@@ -0,0 +1,113 @@
+package markdown
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDedupe(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "example.com - Known.md", Frontmatter{Title: "Known", URL: "https://example.com/known", ID: "known"}, "Content")
+
+	tree := testFolder("toolbar",
+		testFolder("News", testBookmark("a", "Shared", "https://example.com/shared")),
+		testFolder("Reading",
+			testBookmark("b", "Shared again", "https://example.com/shared"),
+			testBookmark("c", "Known elsewhere", "https://example.com/known"),
+		),
+		testFolder("Work", testBookmark("d", "Shared", "https://example.com/shared")),
+		testBookmark("e", "Single", "https://example.com/single"),
+	)
+
+	p := newTestProcessor(t, dir, ProcessorOptions{Dedupe: true})
+	if err := p.ProcessBookmarks(tree, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if matter := parseNote(t, dir, "News/example.com - Shared.md"); matter.Path != "News" || !slices.Equal(matter.Paths, []string{"News", "Reading", "Work"}) {
+		t.Errorf("got path %q and paths %q for the merged note", matter.Path, matter.Paths)
+	}
+	for _, file := range []string{"Reading/example.com - Shared again.md", "Work/example.com - Shared.md", "Reading/example.com - Known elsewhere.md"} {
+		if exists(dir, file) {
+			t.Errorf("duplicate note %s was written", file)
+		}
+	}
+
+	// Every bookmark of a URL maps to its single note
+	for id, want := range map[string]string{
+		"a": "News/example.com - Shared.md",
+		"b": "News/example.com - Shared.md",
+		"d": "News/example.com - Shared.md",
+		"c": "example.com - Known.md",
+	} {
+		if got := p.cache[id].File; got != want {
+			t.Errorf("bookmark %s: got file %q, want %q", id, got, want)
+		}
+	}
+
+	if single := readFile(t, dir, "example.com - Single.md"); strings.Contains(single, "paths:") {
+		t.Errorf("note in a single folder lists paths:\n%s", single)
+	}
+}
+
+func TestInlinePaths(t *testing.T) {
+	matter := Frontmatter{Path: "News", Paths: []string{"News", "Reading"}}
+	if got := matter.InlineString([]string{"path"}); !strings.Contains(got, "path:: News, Reading") {
+		t.Errorf("got inline fields %q, want all paths", got)
+	}
+}
+
+func TestDedupeKey(t *testing.T) {
+	p := newTestProcessor(t, t.TempDir(), ProcessorOptions{})
+	want := p.dedupeKey("https://example.com/page?id=1")
+	for _, u := range []string{
+		"https://EXAMPLE.com/page?id=1",
+		"https://example.com:443/page/?id=1",
+		"https://example.com/page?id=1&utm_source=feed&fbclid=abc",
+		"https://example.com/page?id=1#section",
+	} {
+		if got := p.dedupeKey(u); got != want {
+			t.Errorf("%s: got key %q, want %q", u, got, want)
+		}
+	}
+
+	// Fragment bookmarks get their own notes in the stub mode
+	p = newTestProcessor(t, t.TempDir(), ProcessorOptions{FragmentMode: FragmentStub})
+	if p.dedupeKey("https://example.com/page#section") == p.dedupeKey("https://example.com/page") {
+		t.Error("fragment was dropped from the key")
+	}
+}
+
+func TestDuplicateStubs(t *testing.T) {
+	dir := t.TempDir()
+	folder := testFolder("toolbar",
+		testFolder("work", testBookmark("first-id", "Page", "https://example.com/page")),
+		testFolder("later", testBookmark("second-id", "Page again", "https://example.com/page/?utm_source=feed")),
+	)
+	p := newTestProcessor(t, dir, ProcessorOptions{Dedupe: true, DedupeStubs: true})
+	if err := p.ProcessBookmarks(folder, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	note, stub := p.cache["first-id"], p.cache["second-id"]
+	if stub.DuplicateOf != note.File {
+		t.Fatalf("got duplicate of %q, want %q", stub.DuplicateOf, note.File)
+	}
+	matter := parseNote(t, dir, stub.File)
+	if !slices.Equal(matter.Tags, []string{duplicateTag}) {
+		t.Errorf("got stub tags %q, want only %q", matter.Tags, duplicateTag)
+	}
+
+	// Removing the first bookmark hands its note over to the duplicate
+	p = newTestProcessor(t, dir, ProcessorOptions{})
+	if _, err := p.PruneNotes(bookmarkSeq("second-id"), []string{""}, PruneDelete); err != nil {
+		t.Fatal(err)
+	}
+	if exists(dir, stub.File) {
+		t.Error("stub of the promoted duplicate was kept")
+	}
+	if matter := parseNote(t, dir, note.File); matter.ID != "second-id" {
+		t.Errorf("got note id %q, want the duplicate's", matter.ID)
+	}
+}
@@ -0,0 +1,94 @@
+package markdown
+
+import (
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/x"
+)
+
+// snapshot reads all files below dir, keyed by their relative path
+func snapshot(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files[rel] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// compareSnapshots reports files that differ between two snapshots
+func compareSnapshots(t *testing.T, got, want map[string]string) {
+	t.Helper()
+
+	if gotNames, wantNames := slices.Sorted(maps.Keys(got)), slices.Sorted(maps.Keys(want)); !slices.Equal(gotNames, wantNames) {
+		t.Fatalf("got files %q, want %q", gotNames, wantNames)
+	}
+	for name, content := range want {
+		if got[name] != content {
+			t.Errorf("%s differs:\ngot:\n%s\nwant:\n%s", name, got[name], content)
+		}
+	}
+}
+
+func TestDeterministicExport(t *testing.T) {
+	// Added at 23:30 UTC on 2023-12-31, which is already 2024 east of UTC
+	late := testBookmark("late", "Late", "https://example.com/late")
+	late.AddedUnix = time.Date(2023, 12, 31, 23, 30, 0, 0, time.UTC).Unix()
+	tree := testFolder("toolbar",
+		testBookmark("a", "Page", "https://example.com/page"),
+		testBookmark("b", "Section", "https://example.com/page#section"),
+		testFolder("Reading", late, testBookmark("c", "Other", "https://example.com/other")),
+	)
+
+	export := func(dir string, zone *time.Location) {
+		t.Helper()
+
+		local := time.Local
+		time.Local = zone
+		defer func() { time.Local = local }()
+
+		p := newTestProcessor(t, dir, ProcessorOptions{Deterministic: true, FragmentMode: FragmentStub})
+		if err := p.ProcessBookmarks(tree, ""); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.CreateYearIndexes(x.Filter(x.Values(tree.All()), func(b *bookmarks.Bookmark) bool { return b.Type == bookmarks.TypeBookmark })); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The same bookmarks exported on machines in different time zones
+	root := t.TempDir()
+	first, second := filepath.Join(root, "first"), filepath.Join(root, "second")
+	export(first, time.FixedZone("UTC+9", 9*3600))
+	export(second, time.FixedZone("UTC-5", -5*3600))
+	want := snapshot(t, first)
+	compareSnapshots(t, snapshot(t, second), want)
+
+	if _, ok := want["2023.md"]; !ok {
+		t.Errorf("got no 2023 index for a bookmark added on 2023-12-31 UTC")
+	}
+
+	// Exporting again into the same directory changes nothing
+	export(first, time.UTC)
+	compareSnapshots(t, snapshot(t, first), want)
+}
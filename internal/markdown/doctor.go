@@ -0,0 +1,41 @@
+package markdown
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DoctorReport describes problems found in an existing vault
+type DoctorReport struct {
+	DegenerateNotes []CacheEntry
+	SyncConflicts   []SyncConflict
+}
+
+// NewDoctorReport inspects the markdown cache and sync conflicts for problems
+func NewDoctorReport(cache Cache, conflicts []SyncConflict) DoctorReport {
+	return DoctorReport{
+		DegenerateNotes: cache.Degenerate(),
+		SyncConflicts:   conflicts,
+	}
+}
+
+// String renders the report for terminal output
+func (r DoctorReport) String() string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("Degenerate notes (no content, fix with -heal): %d\n", len(r.DegenerateNotes)))
+	for _, entry := range r.DegenerateNotes {
+		sb.WriteString(fmt.Sprintf("  %s (%s)\n", entry.File, entry.URI))
+	}
+
+	sb.WriteString(fmt.Sprintf("Sync conflict copies (ignored, fix with -doctor -fix): %d\n", len(r.SyncConflicts)))
+	for _, conflict := range r.SyncConflicts {
+		if conflict.Original == "" {
+			sb.WriteString(fmt.Sprintf("  %s (no original note)\n", conflict.File))
+		} else {
+			sb.WriteString(fmt.Sprintf("  %s (copy of %s)\n", conflict.File, conflict.Original))
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,165 @@
+package markdown
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/web"
+)
+
+// Dry-run modes
+const (
+	// DryRunPlan neither fetches content nor writes notes
+	DryRunPlan = "plan"
+	// DryRunFetch fetches and cleans content, but doesn't write notes
+	DryRunFetch = "fetch"
+)
+
+// Actions of changes planned in a dry run
+const (
+	ChangeCreate  = "create"
+	ChangeUpdate  = "update"
+	ChangeRename  = "rename"
+	ChangeDelete  = "delete"
+	ChangeArchive = "archive"
+)
+
+// Reasons of changes planned in a dry run
+const (
+	ReasonNew        = "new bookmark"
+	ReasonRetitled   = "title changed"
+	ReasonPin        = "pin tag changed"
+	ReasonDegenerate = "degenerate content"
+	ReasonRefresh    = "refresh requested"
+	ReasonRemoved    = "bookmark removed"
+	ReasonDuplicate  = "duplicate promoted"
+)
+
+// charsPerToken estimates LLM tokens from content lengths
+const charsPerToken = 4
+
+// Change is a change to the output planned in a dry run
+type Change struct {
+	Action string `json:"action"`
+	File   string `json:"file"`
+	// To is the new file of a renamed note
+	To string `json:"to,omitempty"`
+	// Reason says why a note of a bookmark changes, empty for indexes
+	Reason     string `json:"reason,omitempty"`
+	BookmarkID string `json:"bookmark_id,omitempty"`
+	URL        string `json:"url,omitempty"`
+	// Fetch is set if the page content is fetched
+	Fetch bool `json:"fetch,omitempty"`
+	// LLM is set if content is sent to the LLM
+	LLM bool `json:"llm,omitempty"`
+	// EstimatedTokens estimates the LLM tokens of the content from its cached
+	// size, 0 if nothing is cached
+	EstimatedTokens int `json:"estimated_tokens,omitempty"`
+}
+
+// Changes are the changes planned in a dry run
+type Changes []Change
+
+// String renders the changes as a table for terminal output
+func (c Changes) String() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Planned changes: %d\n", len(c)))
+
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	for _, change := range c {
+		file := change.File
+		if change.To != "" {
+			file += " -> " + change.To
+		}
+		fmt.Fprintf(w, "  %s\t%s\n", change.Action, file)
+	}
+	w.Flush()
+
+	return sb.String()
+}
+
+// dryRunOutput records writes as planned changes instead of writing files
+type dryRunOutput struct {
+	dir string
+	p   *Processor
+}
+
+// MkdirAll is a no-op, folders are implied by the files created in them
+func (o *dryRunOutput) MkdirAll(dir string) error {
+	return nil
+}
+
+// WriteFile records the creation or update of a file
+func (o *dryRunOutput) WriteFile(name string, data []byte) error {
+	action := ChangeCreate
+	if _, err := os.Stat(filepath.Join(o.dir, name)); err == nil {
+		action = ChangeUpdate
+	}
+	o.p.planChange(Change{Action: action, File: name})
+	return nil
+}
+
+// Remove records the deletion of a file
+func (o *dryRunOutput) Remove(name string) error {
+	o.p.planChange(Change{Action: ChangeDelete, File: name})
+	return nil
+}
+
+// Close is a no-op, nothing was written
+func (o *dryRunOutput) Close() error {
+	return nil
+}
+
+// planChange records a change that a dry run leaves out
+func (p *Processor) planChange(change Change) {
+	slog.Info("would "+change.Action, "file", change.File)
+	p.changes = append(p.changes, change)
+}
+
+// noteChange returns a change of the note of a cache entry
+func noteChange(action string, entry CacheEntry, reason string) Change {
+	return Change{Action: action, File: entry.File, Reason: reason, BookmarkID: entry.ID, URL: entry.URI}
+}
+
+// describeChanges adds the bookmark and reason to the changes planned since
+// from, which writes through the output record with the file only
+func (p *Processor) describeChanges(from int, bookmark bookmarks.Bookmark, reason string) {
+	for i := from; i < len(p.changes); i++ {
+		if p.changes[i].BookmarkID == "" {
+			p.changes[i].Reason, p.changes[i].BookmarkID, p.changes[i].URL = reason, bookmark.ID, bookmark.URI
+		}
+	}
+}
+
+// planFetch adds what fetching the content of a change involves. refetch is
+// set for changes that bypass cached content.
+func (p *Processor) planFetch(change *Change, refetch bool) {
+	plan := p.contentService.PlanFetch(change.URL)
+	change.Fetch = plan.Fetch || refetch
+
+	// Cleaning, summary and tags each send the content once
+	requests := 0
+	if plan.Clean && (!plan.Cached || refetch) {
+		requests++
+	}
+	if !refetch && web.SourceOf(change.URL) != web.SourceYouTube {
+		if p.summarizer != nil {
+			requests++
+		}
+		if p.tagger != nil {
+			requests++
+		}
+	}
+	change.LLM = requests > 0
+	change.EstimatedTokens = requests * plan.Length / charsPerToken
+}
+
+// Changes returns the changes planned in a dry run
+func (p *Processor) Changes() Changes {
+	return p.changes
+}
@@ -0,0 +1,64 @@
+package markdown
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDryRunPlan(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "example.com - Kept.md", Frontmatter{ID: "kept-id", Title: "Kept", URL: "https://example.com/kept"}, "Content")
+	writeNote(t, dir, "example.com - Removed.md", Frontmatter{ID: "removed-id", Title: "Removed", URL: "https://example.com/removed"}, "Content")
+
+	p := newTestProcessor(t, dir, ProcessorOptions{DryRun: DryRunPlan})
+	folder := testFolder("toolbar",
+		testBookmark("kept-id", "Kept", "https://example.com/kept"),
+		testBookmark("new-id", "New", "https://example.com/new"),
+	)
+	if err := p.ProcessBookmarks(folder, ""); err != nil {
+		t.Fatal(err)
+	}
+	pruned, err := p.PruneNotes(bookmarkSeq("kept-id", "new-id"), []string{""}, PruneDelete)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(pruned, []string{"example.com - Removed.md"}) {
+		t.Errorf("planned to prune %v", pruned)
+	}
+	if exists(dir, "example.com - New.md") || !exists(dir, "example.com - Removed.md") {
+		t.Error("dry run changed the output")
+	}
+	for _, want := range []Change{
+		{Action: ChangeCreate, File: "example.com - New.md", Reason: ReasonNew, BookmarkID: "new-id", URL: "https://example.com/new", Fetch: true},
+		{Action: ChangeDelete, File: "example.com - Removed.md", Reason: ReasonRemoved, BookmarkID: "removed-id", URL: "https://example.com/removed"},
+	} {
+		if !slices.Contains(p.Changes(), want) {
+			t.Errorf("changes %v lack %v", p.Changes(), want)
+		}
+	}
+	if summary := p.Summary(); summary.Pruned != 0 {
+		t.Errorf("dry run counted %d pruned notes", summary.Pruned)
+	}
+}
+
+func TestReadSubtreeCacheLeavesDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "a.md", Frontmatter{ID: "same-id", Title: "A", URL: "https://example.com/a"}, "Content")
+	writeNote(t, dir, "b.md", Frontmatter{ID: "same-id", Title: "B", URL: "https://example.com/a"}, "Content")
+	older := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "b.md"), older, older); err != nil {
+		t.Fatal(err)
+	}
+
+	// -doctor without -fix and dry runs only read the vault
+	if _, err := ReadSubtreeCache(dir, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !exists(dir, "a.md") || !exists(dir, "b.md") || exists(dir, conflictsDir) {
+		t.Error("reading the cache quarantined a duplicate")
+	}
+}
@@ -0,0 +1,92 @@
+package markdown
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+)
+
+func TestQuarantineDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	matter := Frontmatter{Title: "Page", URL: "https://example.com/page", ID: "page"}
+	writeNote(t, dir, "example.com - Page.md", matter, "Original")
+	writeNote(t, dir, "Reading/example.com - Page.md", matter, "Copy from another machine")
+
+	// The note in Reading is the newer one, even though it is walked later
+	older := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "example.com - Page.md"), older, older); err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err := BuildCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cache["page"].File; got != "example.com - Page.md" {
+		t.Errorf("got cached file %q, want the older note kept", got)
+	}
+	if exists(dir, "Reading/example.com - Page.md") {
+		t.Error("newer duplicate was left in place")
+	}
+	if got := readFile(t, dir, "_conflicts/Reading/example.com - Page.md"); !strings.Contains(got, "Copy from another machine") {
+		t.Errorf("got quarantined note:\n%s", got)
+	}
+
+	report := readFile(t, dir, "_conflicts/report.md")
+	if !strings.Contains(report, "id page, kept [[example.com - Page]], moved [[_conflicts/Reading/example.com - Page]]") {
+		t.Errorf("got report:\n%s", report)
+	}
+
+	// Quarantined notes are not picked up again
+	cache, err = BuildCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cache) != 1 {
+		t.Errorf("got %d cached notes after quarantine, want 1", len(cache))
+	}
+}
+
+func TestNoteCreatedElsewhere(t *testing.T) {
+	dir := t.TempDir()
+	p := newTestProcessor(t, dir, ProcessorOptions{})
+
+	// Another machine wrote the note after the cache was built
+	writeNote(t, dir, "example.com - Page.md", Frontmatter{Title: "Page", URL: "https://example.com/page", ID: "page"}, "From elsewhere")
+
+	if err := p.ProcessBookmarks(testFolder("toolbar", testBookmark("page", "Page", "https://example.com/page")), ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, dir, "example.com - Page.md"); !strings.Contains(got, "From elsewhere") {
+		t.Errorf("note from another machine was overwritten:\n%s", got)
+	}
+	if got := p.cache["page"].File; got != "example.com - Page.md" {
+		t.Errorf("got cached file %q", got)
+	}
+}
+
+func TestDerivedIDDuplicatesAreNotQuarantined(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "imported.md", "---\nurl: https://example.com/a\n---\nImported by hand\n")
+	writeFile(t, dir, "copy.md", "---\nurl: https://example.com/a\n---\nAnother copy\n")
+	writeNote(t, dir, "note.md", Frontmatter{ID: bookmarks.DeriveID("https://example.com/a"), Title: "A", URL: "https://example.com/a"}, "Content")
+
+	cache, err := BuildCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry := cache[bookmarks.DeriveID("https://example.com/a")]
+	if entry.File != "note.md" || entry.DerivedID {
+		t.Errorf("matched %s, want the note with the real id", entry.File)
+	}
+	for _, file := range []string{"imported.md", "copy.md", "note.md"} {
+		if !exists(dir, file) {
+			t.Errorf("%s was quarantined", file)
+		}
+	}
+}
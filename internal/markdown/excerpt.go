@@ -0,0 +1,45 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// excerptLength is the maximum length of an excerpt in characters
+const excerptLength = 200
+
+var (
+	excerptFenceRegex    = regexp.MustCompile("(?s)```.*?(```|$)")
+	excerptHeadingRegex  = regexp.MustCompile(`(?m)^\s*#{1,6}\s.*$`)
+	excerptImageRegex    = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)`)
+	excerptLinkRegex     = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	excerptHTMLRegex     = regexp.MustCompile(`<[^>]+>`)
+	excerptPrefixRegex   = regexp.MustCompile(`(?m)^\s*(>+|[-*+]|\d+\.)\s+`)
+	excerptEmphasisRegex = regexp.MustCompile("[*_`~]+")
+)
+
+// excerpt builds a plain text preview of markdown content, without headings,
+// images, code blocks and markdown syntax
+func excerpt(content string) string {
+	text := excerptFenceRegex.ReplaceAllString(content, " ")
+	text = excerptHeadingRegex.ReplaceAllString(text, " ")
+	text = excerptImageRegex.ReplaceAllString(text, " ")
+	text = excerptLinkRegex.ReplaceAllString(text, "$1")
+	text = excerptHTMLRegex.ReplaceAllString(text, " ")
+	text = excerptPrefixRegex.ReplaceAllString(text, "")
+	text = excerptEmphasisRegex.ReplaceAllString(text, "")
+	text = strings.Join(strings.Fields(text), " ")
+
+	if utf8.RuneCountInString(text) <= excerptLength {
+		return text
+	}
+
+	// Cut at the last word boundary that fits
+	runes := []rune(text)
+	cut := string(runes[:excerptLength])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " .,;:") + "…"
+}
@@ -0,0 +1,33 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestExcerpt(t *testing.T) {
+	content := "# Title\n\n![logo](logo.png)\n\n> A **quoted** [link text](https://example.com) with `code`.\n\n" +
+		"```go\nfunc main() {}\n```\n\n- <b>First</b> item\n- Second item\n"
+	if got, want := excerpt(content), "A quoted link text with code. First item Second item"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	long := strings.Repeat("word ", 100)
+	got := excerpt(long)
+	if utf8.RuneCountInString(got) > excerptLength+1 || !strings.HasSuffix(got, "word…") {
+		t.Errorf("got %q, want it cut at a word boundary within %d characters", got, excerptLength)
+	}
+}
+
+func TestExcerptFrontmatter(t *testing.T) {
+	dir := t.TempDir()
+	p := newTestProcessor(t, dir, ProcessorOptions{Excerpt: true})
+	if err := p.ProcessBookmarks(testFolder("toolbar", testBookmark("a", "Page", "https://example.com/page")), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := parseNote(t, dir, "example.com - Page.md").Excerpt; got != "Content of https://example.com/page" {
+		t.Errorf("got excerpt %q", got)
+	}
+}
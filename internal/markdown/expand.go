@@ -0,0 +1,161 @@
+package markdown
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/web"
+)
+
+// Modes for expanding reading list bookmarks into notes of the linked pages
+const (
+	ExpandOff  = ""
+	ExpandStub = "stub"
+	ExpandFull = "full"
+)
+
+// readingListRatio is the share of lines that must be single-link list items
+// for content to be treated as a reading list
+const readingListRatio = 0.7
+
+// minReadingListLinks is the least number of links a reading list has
+const minReadingListLinks = 3
+
+var (
+	listItemRegex = regexp.MustCompile(`^\s*(?:[-*+]|\d+\.)\s`)
+	listLinkRegex = regexp.MustCompile(`\[([^\]]+)\]\((https?://[^)\s]+)[^)]*\)`)
+)
+
+// listLink is a link extracted from a reading list
+type listLink struct {
+	title string
+	url   string
+}
+
+// readingListLinks returns the external links of content that is dominated by
+// list items with a single link, or nil for regular content
+func readingListLinks(content string, pageURL string) []listLink {
+	pageHost := ""
+	if u, err := url.Parse(pageURL); err == nil {
+		pageHost = u.Host
+	}
+
+	var lines, items int
+	var links []listLink
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		lines++
+
+		if !listItemRegex.MatchString(line) {
+			continue
+		}
+		matches := listLinkRegex.FindAllStringSubmatch(line, -1)
+		if len(matches) != 1 {
+			continue
+		}
+		items++
+
+		link := listLink{title: strings.TrimSpace(matches[0][1]), url: matches[0][2]}
+		u, err := url.Parse(link.url)
+		if err != nil || u.Host == pageHost || seen[link.url] {
+			continue
+		}
+		seen[link.url] = true
+		links = append(links, link)
+	}
+
+	if lines == 0 || float64(items)/float64(lines) <= readingListRatio || len(links) < minReadingListLinks {
+		return nil
+	}
+	return links
+}
+
+// expandReadingList creates notes for the links of a reading list bookmark in
+// a folder next to its note, and returns an index linking to them
+func (p *Processor) expandReadingList(bookmark bookmarks.Bookmark, currentPath string, filename string, links []listLink) string {
+	// The suffix keeps the folder from sharing a name with the note
+	dir := filepath.Join(currentPath, strings.TrimSuffix(filename, ".md")+" (links)")
+	if err := p.output.MkdirAll(dir); err != nil {
+		slog.Warn("failed to create reading list folder", "path", dir, "error", err)
+		return ""
+	}
+
+	existing := make(map[string]bool)
+	for _, entry := range p.cache {
+		existing[entry.URI] = true
+	}
+
+	var index strings.Builder
+	index.WriteString("\n## Links\n\n")
+
+	var created int
+	for _, link := range links {
+		if created >= p.expandMax {
+			slog.Info("reading list truncated", "title", bookmark.Title, "max", p.expandMax)
+			break
+		}
+		// Links that are bookmarked themselves get their own note anyway
+		if existing[link.url] || p.plannedURLs[link.url] {
+			continue
+		}
+
+		file, err := p.createListLinkFile(bookmark, filepath.Join(currentPath, filename), dir, link)
+		if err != nil {
+			slog.Warn("failed to create reading list note", "url", link.url, "error", err)
+			continue
+		}
+		existing[link.url] = true
+		created++
+		index.WriteString(fmt.Sprintf("- %s\n", wikilink(file, link.title)))
+	}
+
+	if created == 0 {
+		return ""
+	}
+	slog.Info("expanded reading list", "title", bookmark.Title, "notes", created)
+	return index.String()
+}
+
+// createListLinkFile creates the note for a link of a reading list. Linked
+// pages are never expanded themselves.
+func (p *Processor) createListLinkFile(parent bookmarks.Bookmark, parentFile string, dir string, link listLink) (string, error) {
+	content := fmt.Sprintf("[%s](%s)", link.title, link.url)
+	if p.expandLists == ExpandFull {
+		fetched, err := p.contentService.FetchContent(link.url)
+		if err != nil {
+			return "", fmt.Errorf("failed to fetch content: %w", err)
+		}
+		content = fetched
+	}
+
+	filename := p.fitFileName(dir, sanitizeFilename(link.title, link.url, p.linkSafeNames))
+	filePath := filepath.Join(dir, filename)
+
+	added, suspect := p.addedTime(parent)
+	frontmatter := Frontmatter{
+		CreatedAt:   added.Format("2006-01-02"),
+		DateSuspect: suspect,
+		Path:        dir,
+		URL:         link.url,
+		ID:          listLinkID(parent.ID, link.url),
+		Title:       link.title,
+		Status:      p.newNoteStatus(),
+		Tags:        []string{"bookmark", "reading-list"},
+	}
+
+	body := content + "\n\nFrom " + wikilink(parentFile, parent.Title) + "\n"
+	note := frontmatter.String() + "\n" + body + generatedEndMarker + "\n"
+	if err := p.output.WriteFile(filePath, []byte(note)); err != nil {
+		return "", fmt.Errorf("%w: failed to write file: %w", web.CategoryWriteFailed, err)
+	}
+	return filePath, nil
+}
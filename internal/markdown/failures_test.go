@@ -0,0 +1,52 @@
+package markdown
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/llm"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/web"
+)
+
+func TestFailureCategories(t *testing.T) {
+	tests := []struct {
+		category web.Category
+		deferred bool
+	}{
+		{web.CategoryLLMRateLimited, true},
+		{web.CategoryLLMRefused, false},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.category), func(t *testing.T) {
+			dir := t.TempDir()
+			cache, err := BuildCache(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			service := newTestContentServiceWith(t, web.FetchOptions{
+				ContentCleaner: &llm.FakeClient{Err: fmt.Errorf("%w: test", test.category)},
+			})
+			p := NewProcessor(ProcessorOptions{OutputDir: dir}, service, nil, cache)
+
+			folder := testFolder("toolbar", testBookmark("bookmark-id", "Article", "https://example.com/article"))
+			if err := p.ProcessBookmarks(folder, ""); err != nil {
+				t.Fatal(err)
+			}
+
+			summary := p.Summary()
+			if got := summary.FailureCategories()[test.category]; got != 1 {
+				t.Errorf("got %d failures of %s, want 1", got, test.category)
+			}
+			if test.deferred && (summary.Deferred != 1 || summary.Failed != 0) {
+				t.Errorf("got %d deferred and %d failed, want the bookmark deferred", summary.Deferred, summary.Failed)
+			}
+			if !test.deferred && (summary.Failed != 1 || summary.Deferred != 0) {
+				t.Errorf("got %d deferred and %d failed, want the bookmark failed", summary.Deferred, summary.Failed)
+			}
+			if exists(dir, "example.com - Article.md") {
+				t.Error("note was written without content")
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package markdown
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+)
+
+// Listing orders of folder indexes
+const (
+	FolderIndexSortBookmark = "bookmark"
+	FolderIndexSortTitle    = "title"
+	FolderIndexSortDate     = "date"
+)
+
+// folderIndexFile is the name of the index note written into each folder
+const folderIndexFile = "_index.md"
+
+// indexedFolder is a processed folder that gets an index note
+type indexedFolder struct {
+	path   string
+	folder bookmarks.Bookmark
+}
+
+// indexItem is a bookmark listed in a folder index
+type indexItem struct {
+	bookmark bookmarks.Bookmark
+	file     string
+	pinned   bool
+}
+
+// CreateFolderIndexes writes an index note into every processed folder,
+// listing the notes of the bookmarks directly in it
+func (p *Processor) CreateFolderIndexes() error {
+	if !p.folderIndexes {
+		return nil
+	}
+
+	slog.Info("creating folder indexes")
+
+	for _, indexed := range p.indexedFolders {
+		var items []indexItem
+		for _, child := range indexed.folder.Children {
+			if child.Type != bookmarks.TypeBookmark {
+				continue
+			}
+			if entry, ok := p.cache[child.ID]; ok && entry.File != "" {
+				items = append(items, indexItem{bookmark: child, file: entry.File, pinned: p.isPinned(child.Tags)})
+			}
+		}
+		sortIndexItems(items, p.folderIndexSort)
+
+		var sb strings.Builder
+		sb.WriteString("---\ncssclasses: [\"line3\"]\n---\n")
+		for _, item := range items {
+			sb.WriteString("- " + wikilink(item.file, item.bookmark.Title) + "\n")
+		}
+
+		indexPath := filepath.Join(indexed.path, folderIndexFile)
+		if err := p.output.WriteFile(indexPath, []byte(sb.String())); err != nil {
+			return fmt.Errorf("failed to write folder index %s: %w", indexPath, err)
+		}
+		slog.Debug("wrote folder index", "path", indexed.path)
+	}
+
+	return nil
+}
+
+// sortIndexItems orders folder index items, pinned items first, keeping the
+// bookmark order for items that compare equal
+func sortIndexItems(items []indexItem, order string) {
+	switch order {
+	case FolderIndexSortTitle:
+		slices.SortStableFunc(items, func(a, b indexItem) int {
+			return strings.Compare(strings.ToLower(a.bookmark.Title), strings.ToLower(b.bookmark.Title))
+		})
+	case FolderIndexSortDate:
+		// Newest first, like year indexes
+		slices.SortStableFunc(items, func(a, b indexItem) int {
+			return cmp.Compare(b.bookmark.AddedUnix, a.bookmark.AddedUnix)
+		})
+	}
+	// Pinned items go first in any order
+	slices.SortStableFunc(items, func(a, b indexItem) int {
+		switch {
+		case a.pinned == b.pinned:
+			return 0
+		case a.pinned:
+			return -1
+		default:
+			return 1
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/x/testutil"
+)
+
+// readingList is a folder whose bookmark order differs from both the title
+// and the date order
+func readingList() bookmarks.Bookmark {
+	bookmark := func(id, title string, daysAgo int64) bookmarks.Bookmark {
+		b := testBookmark(id, title, "https://example.com/"+id)
+		b.AddedUnix -= daysAgo * 86400
+		return b
+	}
+	return testFolder("toolbar",
+		testFolder("Reading",
+			bookmark("b", "beta", 2),
+			bookmark("c", "Gamma", 0),
+			testFolder("Nested", bookmark("d", "Delta", 1)),
+			bookmark("a", "Alpha", 1),
+		),
+	)
+}
+
+func TestFolderIndexSort(t *testing.T) {
+	for _, order := range []string{FolderIndexSortBookmark, FolderIndexSortTitle, FolderIndexSortDate} {
+		t.Run(order, func(t *testing.T) {
+			dir := t.TempDir()
+			p := newTestProcessor(t, dir, ProcessorOptions{FolderIndexes: true, FolderIndexSort: order})
+			if err := p.ProcessBookmarks(readingList(), ""); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.CreateFolderIndexes(); err != nil {
+				t.Fatal(err)
+			}
+
+			testutil.Golden(t, "folder-index/"+order, []byte(readFile(t, dir, "Reading/_index.md")))
+			if !exists(dir, "Reading/Nested/_index.md") {
+				t.Error("nested folder has no index")
+			}
+		})
+	}
+}
+
+func TestNoFolderIndexes(t *testing.T) {
+	dir := t.TempDir()
+	p := newTestProcessor(t, dir, ProcessorOptions{})
+	if err := p.ProcessBookmarks(readingList(), ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.CreateFolderIndexes(); err != nil {
+		t.Fatal(err)
+	}
+	if exists(dir, "Reading/_index.md") {
+		t.Error("folder index written without -folder-indexes")
+	}
+}
@@ -0,0 +1,207 @@
+package markdown
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/web"
+)
+
+// Modes for bookmarks that only differ from another bookmark by their #fragment
+const (
+	// FragmentFull creates a full note for every bookmark
+	FragmentFull = "full"
+	// FragmentStub creates a short note linking to the page note
+	FragmentStub = "stub"
+	// FragmentSection creates a stub with the section the fragment points to
+	FragmentSection = "section"
+)
+
+var headingRegex = regexp.MustCompile(`^(#{1,6})\s+(.+?)\s*#*\s*$`)
+
+// splitFragment splits a URL into the page URL and its fragment
+func splitFragment(u string) (string, string) {
+	page, fragment, _ := strings.Cut(u, "#")
+	return page, fragment
+}
+
+// DropFragmentVariants removes URLs whose page, without the fragment, is
+// already in urls or in the cache, so the same page is not captured twice
+func DropFragmentVariants(urls []string, cache Cache) []string {
+	pages := make(map[string]bool)
+	for _, entry := range cache {
+		page, _ := splitFragment(entry.URI)
+		pages[page] = true
+	}
+	for _, u := range urls {
+		if page, fragment := splitFragment(u); fragment == "" {
+			pages[page] = true
+		}
+	}
+
+	var filtered []string
+	for _, u := range urls {
+		if page, fragment := splitFragment(u); fragment != "" && pages[page] {
+			continue
+		}
+		filtered = append(filtered, u)
+	}
+	return filtered
+}
+
+// pageNotes indexes notes by page URL, preferring notes without a fragment
+func pageNotes(cache Cache) map[string]CacheEntry {
+	pages := make(map[string]CacheEntry)
+	for _, entry := range cache.sorted() {
+		if entry.File != "" {
+			addPageNote(pages, entry)
+		}
+	}
+	return pages
+}
+
+// addPageNote registers a note as the note for its page
+func addPageNote(pages map[string]CacheEntry, entry CacheEntry) {
+	page, fragment := splitFragment(entry.URI)
+	if _, exists := pages[page]; !exists || fragment == "" {
+		pages[page] = entry
+	}
+}
+
+// fragmentPage returns the note of the page a fragment bookmark points into
+func (p *Processor) fragmentPage(bookmark bookmarks.Bookmark) (CacheEntry, bool) {
+	if p.fragmentMode == "" || p.fragmentMode == FragmentFull {
+		return CacheEntry{}, false
+	}
+
+	page, fragment := splitFragment(bookmark.URI)
+	if fragment == "" {
+		return CacheEntry{}, false
+	}
+
+	entry, ok := p.pages[page]
+	if !ok || entry.ID == bookmark.ID {
+		return CacheEntry{}, false
+	}
+	return entry, true
+}
+
+// createFragmentFile creates a stub note for a fragment bookmark, linking to
+// the note of its page instead of fetching the page again
+func (p *Processor) createFragmentFile(bookmark bookmarks.Bookmark, page CacheEntry, currentPath string, filename string) (string, error) {
+	slog.Info("creating fragment note",
+		"title", bookmark.Title,
+		"url", bookmark.URI,
+		"page", page.File)
+
+	tags := []string{"bookmark", "fragment"}
+	if bookmark.Deleted {
+		tags = append(tags, "deleted")
+	}
+
+	_, fragment := splitFragment(bookmark.URI)
+	content := fmt.Sprintf("[%s](%s)\n\nSection of %s", bookmark.Title, bookmark.URI, wikilink(page.File, page.Title))
+
+	if p.fragmentMode == FragmentSection && p.dryRun != DryRunPlan {
+		// Page content is normally served from the content cache
+		pageContent, err := p.contentService.FetchContent(page.URI)
+		if err != nil {
+			slog.Warn("failed to get page content for fragment", "url", page.URI, "error", err)
+		} else if section, ok := extractSection(pageContent, fragment); ok {
+			content += "\n\n" + section
+		} else {
+			slog.Debug("fragment section not found", "url", bookmark.URI)
+		}
+	}
+
+	added, suspect := p.addedTime(bookmark)
+	frontmatter := Frontmatter{
+		CreatedAt:   added.Format("2006-01-02"),
+		DateSuspect: suspect,
+		Path:        currentPath,
+		URL:         bookmark.URI,
+		ID:          bookmark.ID,
+		Title:       bookmark.Title,
+		Fragment:    fragment,
+		Status:      p.newNoteStatus(),
+		Pinned:      p.isPinned(bookmark.Tags),
+		ReadAt:      p.readDates[bookmark.ID],
+		Tags:        tags,
+	}
+	if p.slugs != nil {
+		frontmatter.Slug = uniqueSlug(bookmark.Title, p.slugs)
+	}
+
+	markdownContent := frontmatter.String() + "\n" + p.renderBody(frontmatter, content)
+
+	filePath := filepath.Join(currentPath, filename)
+	if err := p.output.WriteFile(filePath, []byte(markdownContent)); err != nil {
+		return "", fmt.Errorf("%w: failed to write file: %w", web.CategoryWriteFailed, err)
+	}
+
+	return filePath, nil
+}
+
+// extractSection returns the markdown section whose heading matches a URL
+// fragment, up to the next heading of the same or a higher level
+func extractSection(content string, fragment string) (string, bool) {
+	if decoded, err := url.PathUnescape(fragment); err == nil {
+		fragment = decoded
+	}
+	fragment = strings.ToLower(fragment)
+
+	lines := strings.Split(content, "\n")
+	start, level := -1, 0
+	inFence := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+
+		match := headingRegex.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		if start >= 0 {
+			if len(match[1]) <= level {
+				return strings.TrimSpace(strings.Join(lines[start:i], "\n")), true
+			}
+			continue
+		}
+
+		if headingAnchor(match[2]) == fragment {
+			start, level = i, len(match[1])
+		}
+	}
+
+	if start < 0 {
+		return "", false
+	}
+	return strings.TrimSpace(strings.Join(lines[start:], "\n")), true
+}
+
+// headingAnchor computes the anchor of a heading the way GitHub and most
+// documentation generators do
+func headingAnchor(heading string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(heading) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_':
+			sb.WriteRune(r)
+		case r == ' ':
+			sb.WriteRune('-')
+		}
+	}
+	return sb.String()
+}
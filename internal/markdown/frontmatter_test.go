@@ -0,0 +1,92 @@
+package markdown
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/adrg/frontmatter"
+)
+
+// trickyFrontmatter has values the YAML encoder has to quote or write as
+// block scalars
+var trickyFrontmatter = Frontmatter{
+	Title:       `# "Quoted": title — with ünïcode`,
+	URL:         "https://example.com/?a=1&b=2#frag",
+	Path:        "work/read later",
+	CreatedAt:   "2024-03-01",
+	ID:          "id",
+	Description: "First paragraph: with a colon.\n\nSecond paragraph after a blank line.",
+	Excerpt:     "- looks like a list",
+	Aliases:     []string{"yes", "null", "@mention"},
+	Tags:        []string{"bookmark", "go"},
+}
+
+// parseMatter parses raw frontmatter like a note's
+func parseMatter(t *testing.T, rawMatter string) Frontmatter {
+	t.Helper()
+	var matter Frontmatter
+	if _, err := frontmatter.Parse(strings.NewReader(rawMatter+"\nBody\n"), &matter); err != nil {
+		t.Fatalf("failed to parse:\n%s\n%v", rawMatter, err)
+	}
+	return matter
+}
+
+func TestFrontmatterRoundTrip(t *testing.T) {
+	rawMatter := strings.TrimSuffix(trickyFrontmatter.String(), "\n")
+	if got := parseMatter(t, rawMatter); !reflect.DeepEqual(got, trickyFrontmatter) {
+		t.Errorf("got %+v\nwant %+v", got, trickyFrontmatter)
+	}
+}
+
+func TestSetFrontmatterLineRoundTrip(t *testing.T) {
+	rawMatter := strings.TrimSuffix(trickyFrontmatter.String(), "\n")
+	want := trickyFrontmatter
+
+	rawMatter = setTitleLine(rawMatter, "New: title")
+	want.Title = "New: title"
+	rawMatter = setFrontmatterField(rawMatter, "previous_titles", []string{trickyFrontmatter.Title})
+	want.PreviousTitles = []string{trickyFrontmatter.Title}
+	rawMatter = setFrontmatterLine(rawMatter, "pinned", "true")
+	want.Pinned = true
+	if got := parseMatter(t, rawMatter); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v\nfrom:\n%s", got, want, rawMatter)
+	}
+
+	// Replacing the description drops all of its lines
+	rawMatter = setFrontmatterLine(rawMatter, "description", yamlScalar("Short"))
+	want.Description = "Short"
+	if got := parseMatter(t, rawMatter); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v\nfrom:\n%s", got, want, rawMatter)
+	}
+}
+
+func TestRemoveFrontmatterLineRoundTrip(t *testing.T) {
+	rawMatter := strings.TrimSuffix(trickyFrontmatter.String(), "\n")
+	want := trickyFrontmatter
+
+	for _, key := range []string{"description", "aliases"} {
+		rawMatter = removeFrontmatterLine(rawMatter, key)
+	}
+	want.Description, want.Aliases = "", nil
+	if got := parseMatter(t, rawMatter); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v\nfrom:\n%s", got, want, rawMatter)
+	}
+}
+
+func TestFieldEnd(t *testing.T) {
+	lines := strings.Split("---\ndescription: |-\n  one\n\n  two\n\nid: id\ntags:\n- a\n- b\n---", "\n")
+	tests := []struct {
+		start int
+		want  int
+	}{
+		{1, 5}, // the blank line within the block scalar belongs to it
+		{6, 7},
+		{7, 10},
+	}
+	for _, test := range tests {
+		if got := fieldEnd(lines, test.start); got != test.want {
+			t.Errorf("field at line %d: got end %d, want %d", test.start, got, test.want)
+		}
+	}
+}
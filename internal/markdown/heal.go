@@ -0,0 +1,90 @@
+package markdown
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/adrg/frontmatter"
+)
+
+// HealDegenerateNotes refetches content for notes that have no real content
+// and splices it into their body, keeping frontmatter and user additions
+// intact. Cleaning is only paid for again when the fetched content changed.
+func (p *Processor) HealDegenerateNotes() {
+	entries := p.cache.Degenerate()
+	slog.Info("healing degenerate notes", "count", len(entries))
+
+	var healed, failed int
+	for _, entry := range entries {
+		if p.ctx.Err() != nil {
+			break
+		}
+		if p.dryRun == DryRunPlan {
+			change := noteChange(ChangeUpdate, entry, ReasonDegenerate)
+			p.planFetch(&change, true)
+			p.planChange(change)
+			continue
+		}
+
+		planned := len(p.changes)
+		ok, err := p.refetchNote(entry, p.contentService.RefetchContent)
+		p.describeChanges(planned, entry.Bookmark, ReasonDegenerate)
+		if err != nil {
+			slog.Warn("failed to heal note", "file", entry.File, "error", err)
+			failed++
+			continue
+		}
+		if !ok {
+			slog.Warn("note content still degenerate", "file", entry.File)
+			failed++
+			continue
+		}
+
+		entry.Degenerate = false
+		p.cache[entry.ID] = entry
+		healed++
+	}
+
+	slog.Info("healed degenerate notes", "healed", healed, "failed", failed)
+}
+
+// refetchNote refetches content for a single note with fetch and replaces
+// the generated part of its body, returning whether it now has real content.
+// Notes that would lose their content are left unchanged.
+func (p *Processor) refetchNote(entry CacheEntry, fetch func(u string) (string, error)) (bool, error) {
+	data, err := os.ReadFile(filepath.Join(p.outputDir, entry.File))
+	if err != nil {
+		return false, fmt.Errorf("failed to read note: %w", err)
+	}
+
+	rawMatter, body, err := splitNote(string(data))
+	if err != nil {
+		return false, err
+	}
+
+	var matter Frontmatter
+	if _, err := frontmatter.Parse(strings.NewReader(string(data)), &matter); err != nil {
+		return false, fmt.Errorf("failed to parse frontmatter: %w", err)
+	}
+
+	content, err := fetch(entry.URI)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch content: %w", err)
+	}
+
+	newBody := p.renderBody(matter, content)
+	if isDegenerateBody(newBody, matter.Title) {
+		return false, nil
+	}
+
+	_, userContent := splitBody(body)
+	note := rawMatter + "\n" + newBody + userContent
+	if err := p.output.WriteFile(entry.File, []byte(note)); err != nil {
+		return false, fmt.Errorf("failed to write note: %w", err)
+	}
+
+	return true, nil
+}
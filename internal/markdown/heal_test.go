@@ -0,0 +1,37 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/llm"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/web"
+)
+
+func TestHealReusesCleaning(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "example.com - Page.md", Frontmatter{Title: "Page", URL: "https://example.com/page", ID: "id"}, "# Page\n")
+
+	cache, err := BuildCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cache["id"].Degenerate {
+		t.Fatal("note is not degenerate")
+	}
+
+	cleaner := &llm.FakeClient{}
+	service := newTestContentServiceWith(t, web.FetchOptions{ContentCleaner: cleaner})
+	p := NewProcessor(ProcessorOptions{OutputDir: dir}, service, nil, cache)
+
+	p.HealDegenerateNotes()
+	if cleaner.Calls("CleanMarkdown") != 1 || cleaner.Calls("RecleanMarkdown") != 0 {
+		t.Errorf("healing cleaned %d times and recleaned %d times, want cached cleaning only",
+			cleaner.Calls("CleanMarkdown"), cleaner.Calls("RecleanMarkdown"))
+	}
+
+	// Refreshing asks for the content to be cleaned again
+	p.RefreshNotes(nil, true)
+	if cleaner.Calls("RecleanMarkdown") != 1 {
+		t.Errorf("refreshing recleaned %d times, want once", cleaner.Calls("RecleanMarkdown"))
+	}
+}
@@ -0,0 +1,103 @@
+package markdown
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/web"
+)
+
+// writeNote writes a generated note with frontmatter and body into dir
+func writeNote(t testing.TB, dir string, file string, matter Frontmatter, body string) {
+	t.Helper()
+
+	path := filepath.Join(dir, file)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	note := matter.String() + "\n" + body + "\n" + generatedEndMarker + "\n"
+	if err := os.WriteFile(path, []byte(note), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeFile writes a file with content below dir
+func writeFile(t *testing.T, dir string, file string, content string) {
+	t.Helper()
+
+	path := filepath.Join(dir, file)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readFile returns the content of a file below dir
+func readFile(t *testing.T, dir string, file string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// exists checks whether a file below dir exists
+func exists(dir string, file string) bool {
+	_, err := os.Stat(filepath.Join(dir, file))
+	return err == nil
+}
+
+// newTestContentService serves "Content of <url>" for every page from a
+// fake markdown converter
+func newTestContentService(t *testing.T) *web.ContentService {
+	return newTestContentServiceWith(t, web.FetchOptions{})
+}
+
+// newTestContentServiceWith is newTestContentService with fetch options
+func newTestContentServiceWith(t *testing.T, opts web.FetchOptions) *web.ContentService {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "Content of %s", r.URL.Query().Get("url"))
+	}))
+	t.Cleanup(server.Close)
+
+	opts.BaseURL = server.URL
+	service, err := web.NewContentService(server.Client(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service
+}
+
+// newTestProcessor builds the cache of dir and a processor writing into it,
+// fetching content from newTestContentService
+func newTestProcessor(t *testing.T, dir string, opts ProcessorOptions) *Processor {
+	t.Helper()
+
+	cache, err := BuildCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.OutputDir = dir
+	return NewProcessor(opts, newTestContentService(t), nil, cache)
+}
+
+// testFolder returns a folder bookmark holding children
+func testFolder(title string, children ...bookmarks.Bookmark) bookmarks.Bookmark {
+	return bookmarks.Bookmark{ID: "folder-" + title, Title: title, Type: "folder", Children: children}
+}
+
+// testBookmark returns a bookmark added on 2024-03-01
+func testBookmark(id string, title string, uri string) bookmarks.Bookmark {
+	return bookmarks.Bookmark{ID: id, Title: title, Type: "bookmark", URI: uri, AddedUnix: 1709294400}
+}
@@ -0,0 +1,50 @@
+package markdown
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+)
+
+// recordingHooks records the notes passed to PostCreate
+type recordingHooks struct {
+	paths []string
+	err   error
+}
+
+func (h *recordingHooks) PostCreate(path string, bookmark bookmarks.Bookmark) error {
+	h.paths = append(h.paths, path)
+	return h.err
+}
+
+func TestPostCreateHook(t *testing.T) {
+	dir := t.TempDir()
+	hooks := &recordingHooks{err: errors.New("hook failed")}
+	p := newTestProcessor(t, dir, ProcessorOptions{Hooks: hooks})
+
+	tree := testFolder("toolbar",
+		testBookmark("a", "First", "https://example.com/first"),
+		testFolder("Reading", testBookmark("b", "Second", "https://example.com/second")),
+	)
+	if err := p.ProcessBookmarks(tree, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failing hook doesn't stop the next notes
+	want := []string{"example.com - First.md", "Reading/example.com - Second.md"}
+	if !slices.Equal(hooks.paths, want) {
+		t.Errorf("got hook calls for %q, want %q", hooks.paths, want)
+	}
+
+	// Existing notes are not created again
+	hooks.paths = nil
+	p = newTestProcessor(t, dir, ProcessorOptions{Hooks: hooks})
+	if err := p.ProcessBookmarks(tree, ""); err != nil {
+		t.Fatal(err)
+	}
+	if len(hooks.paths) != 0 {
+		t.Errorf("got hook calls for existing notes %q", hooks.paths)
+	}
+}
@@ -0,0 +1,58 @@
+package markdown
+
+import (
+	"testing"
+)
+
+func TestIgnoredFolders(t *testing.T) {
+	ignored := []string{"Archive", " Old Stuff"}
+	// The target is itself named like an ignored folder and always synced
+	tree := testFolder("Archive",
+		testBookmark("a", "Top", "https://example.com/top"),
+		testFolder("Old Stuff", testBookmark("b", "Old", "https://example.com/old")),
+		testFolder("Reading",
+			testBookmark("c", "Read", "https://example.com/read"),
+			testFolder("Archive", testBookmark("d", "Archived", "https://example.com/archived")),
+		),
+	)
+
+	dir := t.TempDir()
+	p := newTestProcessor(t, dir, ProcessorOptions{IgnoredFolders: ignored})
+	if err := p.ProcessBookmarks(tree, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// The processor and the screenshot URL collection agree on what is synced
+	for path, bookmark := range tree.All() {
+		if bookmark.Type != "bookmark" {
+			continue
+		}
+		written := exists(dir, p.cache[bookmark.ID].File) && p.cache[bookmark.ID].File != ""
+		if want := !IsIgnoredPath(path, ignored); written != want {
+			t.Errorf("%s: got note written %v, want %v", path, written, want)
+		}
+	}
+
+	for _, file := range []string{"example.com - Top.md", "Reading/example.com - Read.md"} {
+		if !exists(dir, file) {
+			t.Errorf("%s was not written", file)
+		}
+	}
+}
+
+func TestIsIgnoredPath(t *testing.T) {
+	ignored := []string{"Archive"}
+	for path, want := range map[string]bool{
+		"Archive":                      false,
+		"Archive/Bookmark":             false,
+		"toolbar/Archive/Bookmark":     true,
+		"toolbar/Reading/Archive/Deep": true,
+		"toolbar/Archived/Bookmark":    false,
+		"toolbar/Reading/Archive":      false,
+		"toolbar/Reading/Sub/Bookmark": false,
+	} {
+		if got := IsIgnoredPath(path, ignored); got != want {
+			t.Errorf("IsIgnoredPath(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
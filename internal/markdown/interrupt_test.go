@@ -0,0 +1,53 @@
+package markdown
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/x"
+)
+
+// cancelHook cancels the run once a note was created
+type cancelHook struct {
+	cancel context.CancelFunc
+}
+
+func (h cancelHook) PostCreate(path string, bookmark bookmarks.Bookmark) error {
+	h.cancel()
+	return nil
+}
+
+func TestInterruptedRunKeepsCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	checkpoint, err := x.NewFileCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	folder := testFolder("toolbar",
+		testBookmark("first-id", "First", "https://example.com/first"),
+		testBookmark("second-id", "Second", "https://example.com/second"),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := newTestProcessor(t, dir, ProcessorOptions{Context: ctx, Checkpoint: checkpoint, Hooks: cancelHook{cancel: cancel}})
+	if err := p.ProcessBookmarks(folder, ""); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want %v", err, context.Canceled)
+	}
+	if !exists(dir, "example.com - First.md") || exists(dir, "example.com - Second.md") {
+		t.Fatal("run did not stop after the first note")
+	}
+
+	// The next run resumes with the checkpoint of the interrupted one
+	p = newTestProcessor(t, dir, ProcessorOptions{Checkpoint: checkpoint})
+	if !p.checkpointed["first-id"] {
+		t.Error("checkpoint of the interrupted run was not saved")
+	}
+	if err := p.ProcessBookmarks(folder, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !exists(dir, "example.com - Second.md") {
+		t.Error("resumed run did not create the remaining note")
+	}
+}
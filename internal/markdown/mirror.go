@@ -0,0 +1,114 @@
+package markdown
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// MirrorMarker is the file marking an output directory as fully managed by
+// mirror mode
+const MirrorMarker = ".ffbookmarks-mirror"
+
+// MirrorReadDates returns the read_at dates of notes in a mirror directory,
+// which are lost with the replaced notes otherwise. Directories without
+// the marker have none.
+func MirrorReadDates(outputDir string) (map[string]string, error) {
+	if _, err := os.Stat(filepath.Join(outputDir, MirrorMarker)); err != nil {
+		return nil, nil
+	}
+
+	cache, err := ReadSubtreeCache(outputDir, "")
+	if err != nil {
+		return nil, err
+	}
+	return cache.ReadDates(), nil
+}
+
+// CheckMirror checks that an output directory may be replaced by mirror
+// mode. Directories that don't exist or are empty may, others need the
+// marker.
+func CheckMirror(outputDir string) error {
+	entries, err := os.ReadDir(outputDir)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && len(entries) == 0) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read mirror directory: %w", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(outputDir, MirrorMarker)); err != nil {
+		return fmt.Errorf("%s is not a mirror directory, create %s in it to let mirror mode replace its content", outputDir, MirrorMarker)
+	}
+	return nil
+}
+
+// MirrorOutput builds a mirror in a temporary directory next to the output
+// directory and replaces the output directory with it on Close, so a failed
+// run leaves the previous mirror intact
+type MirrorOutput struct {
+	*DirOutput
+	dest string
+}
+
+// NewMirrorOutput creates the build directory of a mirror of dest, removing
+// build directories left by failed runs
+func NewMirrorOutput(dest string) (*MirrorOutput, error) {
+	if err := CheckMirror(dest); err != nil {
+		return nil, err
+	}
+
+	dest = filepath.Clean(dest)
+	parent, prefix := filepath.Dir(dest), "."+filepath.Base(dest)+"-mirror-"
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create mirror directory: %w", err)
+	}
+	stale, _ := filepath.Glob(filepath.Join(parent, prefix+"*"))
+	for _, dir := range stale {
+		slog.Info("removing mirror left by a failed run", "dir", dir)
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, fmt.Errorf("failed to remove stale mirror: %w", err)
+		}
+	}
+
+	dir, err := os.MkdirTemp(parent, prefix+"*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mirror directory: %w", err)
+	}
+	if err := os.Chmod(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create mirror directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, MirrorMarker), nil, 0644); err != nil {
+		return nil, fmt.Errorf("failed to create mirror directory: %w", err)
+	}
+
+	slog.Info("building mirror", "dir", dest, "build_dir", dir)
+	return &MirrorOutput{DirOutput: NewDirOutput(dir), dest: dest}, nil
+}
+
+// Dir returns the directory the mirror is built in
+func (o *MirrorOutput) Dir() string {
+	return o.dir
+}
+
+// Close replaces the output directory with the built mirror
+func (o *MirrorOutput) Close() error {
+	old := o.dir + "-old"
+	if err := os.Rename(o.dest, old); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to replace mirror: %w", err)
+	}
+	if err := os.Rename(o.dir, o.dest); err != nil {
+		if restoreErr := os.Rename(old, o.dest); restoreErr != nil && !errors.Is(restoreErr, os.ErrNotExist) {
+			return fmt.Errorf("failed to replace mirror: %w, previous mirror left in %s", err, old)
+		}
+		return fmt.Errorf("failed to replace mirror: %w", err)
+	}
+	return os.RemoveAll(old)
+}
+
+// Discard removes the built mirror, keeping the output directory
+func (o *MirrorOutput) Discard() error {
+	return os.RemoveAll(o.dir)
+}
@@ -0,0 +1,89 @@
+package markdown
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMirrorOutput(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "mirror")
+	writeFile(t, dest, MirrorMarker, "")
+	writeFile(t, dest, "old.md", "Old note\n")
+
+	mirror, err := NewMirrorOutput(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mirror.WriteFile("new.md", []byte("New note\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The previous mirror stays in place while the new one is built
+	if !exists(dest, "old.md") || exists(dest, "new.md") {
+		t.Fatal("output directory changed before the mirror was finished")
+	}
+
+	if err := mirror.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if exists(dest, "old.md") || readFile(t, dest, "new.md") != "New note\n" || !exists(dest, MirrorMarker) {
+		t.Error("output directory was not replaced by the mirror")
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(filepath.Dir(dest), ".mirror-*")); len(leftovers) > 0 {
+		t.Errorf("build directories left behind: %v", leftovers)
+	}
+}
+
+func TestMirrorOutputFailedRun(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "mirror")
+	writeFile(t, dest, MirrorMarker, "")
+	writeFile(t, dest, "old.md", "Old note\n")
+
+	// A run failing without Close leaves its build directory behind
+	failed, err := NewMirrorOutput(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := failed.WriteFile("partial.md", []byte("Partial\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	mirror, err := NewMirrorOutput(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(failed.Dir()); err == nil {
+		t.Error("build directory of the failed run was not removed")
+	}
+	if err := mirror.Discard(); err != nil {
+		t.Fatal(err)
+	}
+	if readFile(t, dest, "old.md") != "Old note\n" {
+		t.Error("discarded mirror replaced the previous one")
+	}
+}
+
+func TestMirrorOutputRefusesUnmarkedDirectory(t *testing.T) {
+	dest := t.TempDir()
+	writeFile(t, dest, "notes.md", "Hand-written\n")
+
+	if _, err := NewMirrorOutput(dest); err == nil {
+		t.Error("mirror replaces a directory without marker")
+	}
+}
+
+func TestMirrorOutputNewDirectory(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "mirror")
+
+	mirror, err := NewMirrorOutput(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mirror.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !exists(dest, MirrorMarker) {
+		t.Error("new mirror has no marker")
+	}
+}
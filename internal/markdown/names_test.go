@@ -0,0 +1,92 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+)
+
+func TestFolderAndBookmarkSharingName(t *testing.T) {
+	tree := func() bookmarks.Bookmark {
+		return testFolder("toolbar",
+			testBookmark("a", "example.com", "https://example.com/"),
+			testFolder("example.com", testBookmark("b", "Nested", "https://example.com/nested")),
+		)
+	}
+
+	for _, tt := range []struct {
+		strategy string
+		note     string
+		folder   string
+	}{
+		{"", "example.com (bookmark).md", "example.com"},
+		{CollisionSuffixBookmark, "example.com (bookmark).md", "example.com"},
+		{CollisionSuffixFolder, "example.com.md", "example.com (folder)"},
+	} {
+		dir := t.TempDir()
+		p := newTestProcessor(t, dir, ProcessorOptions{NameCollision: tt.strategy})
+		if err := p.ProcessBookmarks(tree(), ""); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, file := range []string{tt.note, tt.folder + "/example.com - Nested.md"} {
+			if !exists(dir, file) {
+				t.Errorf("strategy %q: %s was not written", tt.strategy, file)
+			}
+		}
+	}
+}
+
+func TestResolveNamesWithoutCollision(t *testing.T) {
+	p := newTestProcessor(t, t.TempDir(), ProcessorOptions{})
+	names := p.resolveNames([]bookmarks.Bookmark{
+		testBookmark("a", "Go", "https://go.dev/"),
+		testFolder("Go"),
+		testFolder("Go.md"),
+	})
+
+	want := []string{"go.dev - Go.md", "Go", "Go.md"}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("got name %q, want %q", names[i], want[i])
+		}
+	}
+}
+
+func TestLinkSafeNames(t *testing.T) {
+	tree := testFolder("toolbar",
+		testFolder("C# [notes]", testBookmark("a", "Issue #12 | fix [WIP] ^", "https://example.com/issue")),
+	)
+
+	dir := t.TempDir()
+	p := newTestProcessor(t, dir, ProcessorOptions{LinkSafeNames: true})
+	if err := p.ProcessBookmarks(tree, ""); err != nil {
+		t.Fatal(err)
+	}
+	if file := "C notes/example.com - Issue 12 fix WIP.md"; !exists(dir, file) {
+		t.Errorf("%s was not written", file)
+	}
+
+	// Reserved characters are kept by default
+	dir = t.TempDir()
+	p = newTestProcessor(t, dir, ProcessorOptions{})
+	if err := p.ProcessBookmarks(tree, ""); err != nil {
+		t.Fatal(err)
+	}
+	if file := "C# [notes]/example.com - Issue #12 fix [WIP] ^.md"; !exists(dir, file) {
+		t.Errorf("%s was not written", file)
+	}
+}
+
+func TestWikilink(t *testing.T) {
+	for _, tt := range []struct {
+		file, alias, want string
+	}{
+		{"Reading/example.com - Go.md", "", "[[Reading/example.com - Go]]"},
+		{"example.com - Go.md", "Go | the [best] language", `[[example.com - Go|Go \| the (best) language]]`},
+	} {
+		if got := wikilink(tt.file, tt.alias); got != tt.want {
+			t.Errorf("wikilink(%q, %q) = %q, want %q", tt.file, tt.alias, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package markdown
+
+import (
+	"fmt"
+	"strings"
+)
+
+// splitNote splits note content into raw frontmatter (including fences) and body
+func splitNote(content string) (string, string, error) {
+	if !strings.HasPrefix(content, "---\n") {
+		return "", "", fmt.Errorf("note has no frontmatter")
+	}
+
+	end := strings.Index(content[4:], "\n---")
+	if end == -1 {
+		return "", "", fmt.Errorf("note frontmatter is not terminated")
+	}
+	end += 4 + len("\n---")
+
+	return content[:end], strings.TrimPrefix(content[end:], "\n"), nil
+}
+
+// splitBody splits a note body into generated content and user additions below the marker
+func splitBody(body string) (string, string) {
+	generated, user, found := strings.Cut(body, generatedEndMarker)
+	if !found {
+		return body, ""
+	}
+	return generated, strings.TrimPrefix(user, "\n")
+}
+
+// isDegenerateBody checks whether the generated part of a note body has no real content
+func isDegenerateBody(body string, title string) bool {
+	generated, _ := splitBody(body)
+	generated, _, _ = strings.Cut(generated, relatedMarker)
+
+	var lines []string
+	for _, line := range strings.Split(generated, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "![Screenshot](") || strings.Contains(line, ":: ") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	text := strings.Join(lines, "\n")
+	if strings.TrimLeft(text, "# ") == strings.TrimSpace(title) {
+		return true
+	}
+	return len(text) < minContentLength
+}
@@ -0,0 +1,137 @@
+package markdown
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Output is a destination for generated notes, with paths relative to its root
+type Output interface {
+	MkdirAll(dir string) error
+	WriteFile(name string, data []byte) error
+	Remove(name string) error
+	Close() error
+}
+
+// NewOutput creates an output for a directory or, for paths ending in .zip, a zip archive
+func NewOutput(dest string) (Output, error) {
+	if IsZipOutput(dest) {
+		return NewZipOutput(dest)
+	}
+	return NewDirOutput(dest), nil
+}
+
+// IsZipOutput checks whether the destination is a zip archive
+func IsZipOutput(dest string) bool {
+	return strings.EqualFold(filepath.Ext(dest), ".zip")
+}
+
+// DirOutput writes notes into a directory
+type DirOutput struct {
+	dir string
+}
+
+// NewDirOutput creates an output writing into dir
+func NewDirOutput(dir string) *DirOutput {
+	return &DirOutput{dir: dir}
+}
+
+// MkdirAll creates a directory and its parents
+func (o *DirOutput) MkdirAll(dir string) error {
+	return os.MkdirAll(filepath.Join(o.dir, dir), 0755)
+}
+
+// WriteFile writes a file into the directory
+func (o *DirOutput) WriteFile(name string, data []byte) error {
+	return os.WriteFile(filepath.Join(o.dir, name), data, 0644)
+}
+
+// Remove deletes a file from the directory
+func (o *DirOutput) Remove(name string) error {
+	return os.Remove(filepath.Join(o.dir, name))
+}
+
+// Close is a no-op for directories
+func (o *DirOutput) Close() error {
+	return nil
+}
+
+// ZipOutput writes notes into a zip archive
+type ZipOutput struct {
+	dest string
+	file *os.File
+	w    *zip.Writer
+	dirs map[string]bool
+}
+
+// NewZipOutput creates an output writing into a zip archive at dest.
+// The archive is written to a temporary file and moved into place on Close.
+func NewZipOutput(dest string) (*ZipOutput, error) {
+	file, err := os.CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+"-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zip archive: %w", err)
+	}
+
+	return &ZipOutput{
+		dest: dest,
+		file: file,
+		w:    zip.NewWriter(file),
+		dirs: make(map[string]bool),
+	}, nil
+}
+
+// MkdirAll adds directory entries for dir and its parents
+func (o *ZipOutput) MkdirAll(dir string) error {
+	dir = filepath.ToSlash(dir)
+	if dir == "" || dir == "." || o.dirs[dir] {
+		return nil
+	}
+
+	if err := o.MkdirAll(path.Dir(dir)); err != nil {
+		return err
+	}
+
+	if _, err := o.w.Create(dir + "/"); err != nil {
+		return err
+	}
+	o.dirs[dir] = true
+	return nil
+}
+
+// WriteFile adds a file entry to the archive
+func (o *ZipOutput) WriteFile(name string, data []byte) error {
+	w, err := o.w.Create(filepath.ToSlash(name))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Remove fails, entries can't be taken out of an archive being written
+func (o *ZipOutput) Remove(name string) error {
+	return fmt.Errorf("cannot remove %s from a zip archive", name)
+}
+
+// Close finishes the archive and moves it to its destination
+func (o *ZipOutput) Close() error {
+	if err := o.w.Close(); err != nil {
+		o.file.Close()
+		os.Remove(o.file.Name())
+		return fmt.Errorf("failed to finish zip archive: %w", err)
+	}
+	if err := o.file.Chmod(0644); err != nil {
+		o.file.Close()
+		os.Remove(o.file.Name())
+		return fmt.Errorf("failed to set zip archive permissions: %w", err)
+	}
+	if err := o.file.Close(); err != nil {
+		os.Remove(o.file.Name())
+		return fmt.Errorf("failed to close zip archive: %w", err)
+	}
+	return os.Rename(o.file.Name(), o.dest)
+}
@@ -0,0 +1,111 @@
+package markdown
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+)
+
+// readZip returns the entries of a zip archive by name
+func readZip(t *testing.T, path string) map[string]string {
+	t.Helper()
+
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	entries := make(map[string]string)
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[f.Name] = string(data)
+	}
+	return entries
+}
+
+func TestZipOutput(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "vault.zip")
+	if !IsZipOutput(dest) || IsZipOutput(filepath.Dir(dest)) {
+		t.Fatal("zip destination not told apart from a directory")
+	}
+
+	output, err := NewOutput(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := make(Cache)
+	p := NewProcessor(ProcessorOptions{Output: output}, newTestContentService(t), nil, cache)
+
+	tree := testFolder("toolbar",
+		testBookmark("a", "Top", "https://example.com/top"),
+		testFolder("Reading",
+			testFolder("Go", testBookmark("b", "Nested", "https://example.com/nested")),
+		),
+	)
+	if err := p.ProcessBookmarks(tree, ""); err != nil {
+		t.Fatal(err)
+	}
+	top, nested := tree.Children[0], tree.Children[1].Children[0].Children[0]
+	if err := p.CreateYearIndexes(slices.Values([]*bookmarks.Bookmark{&top, &nested})); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing is at the destination until the archive is finished
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Fatalf("got %v for the unfinished archive, want it not to exist", err)
+	}
+	if err := output.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := readZip(t, dest)
+	var names []string
+	for name := range entries {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	want := []string{
+		"2024.md",
+		"Reading/",
+		"Reading/Go/",
+		"Reading/Go/example.com - Nested.md",
+		"example.com - Top.md",
+	}
+	if !slices.Equal(names, want) {
+		t.Fatalf("got entries %q, want %q", names, want)
+	}
+
+	note := entries["Reading/Go/example.com - Nested.md"]
+	for _, part := range []string{"title: Nested", "path: Reading/Go", "Content of https://example.com/nested", generatedEndMarker} {
+		if !strings.Contains(note, part) {
+			t.Errorf("note is missing %q:\n%s", part, note)
+		}
+	}
+	if !strings.Contains(entries["2024.md"], `dateformat(created_at, "yyyy") = "2024"`) {
+		t.Errorf("got year index %q", entries["2024.md"])
+	}
+
+	// No temporary files are left next to the archive
+	files, err := os.ReadDir(filepath.Dir(dest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("got %d files next to the archive, want only the archive", len(files))
+	}
+}
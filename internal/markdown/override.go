@@ -0,0 +1,39 @@
+package markdown
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/web"
+)
+
+// readOverride reads hand-authored content for a bookmark from the overrides
+// directory. Override files are named <bookmark ID>.md or <URL key>.md, where
+// the URL key is the same key used by the content cache.
+func (p *Processor) readOverride(bookmark bookmarks.Bookmark) (string, bool, error) {
+	if p.overridesDir == "" {
+		return "", false, nil
+	}
+
+	for _, name := range []string{bookmark.ID, web.URLKey(bookmark.URI)} {
+		if name == "" {
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(p.overridesDir, name+".md"))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", false, fmt.Errorf("failed to read override: %w", err)
+		}
+
+		return string(content), true, nil
+	}
+
+	return "", false, nil
+}
@@ -0,0 +1,39 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/web"
+)
+
+func TestContentOverrides(t *testing.T) {
+	overrides := t.TempDir()
+	writeFile(t, overrides, "by-id.md", "Hand-written by ID")
+	writeFile(t, overrides, web.URLKey("https://example.com/by-url")+".md", "Hand-written by URL")
+
+	dir := t.TempDir()
+	p := newTestProcessor(t, dir, ProcessorOptions{OverridesDir: overrides})
+	tree := testFolder("toolbar",
+		testBookmark("by-id", "By ID", "https://example.com/by-id"),
+		testBookmark("other", "By URL", "https://example.com/by-url"),
+		testBookmark("fetched", "Fetched", "https://example.com/fetched"),
+	)
+	if err := p.ProcessBookmarks(tree, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	for file, want := range map[string]string{
+		"example.com - By ID.md":   "Hand-written by ID",
+		"example.com - By URL.md":  "Hand-written by URL",
+		"example.com - Fetched.md": "Content of https://example.com/fetched",
+	} {
+		note := readFile(t, dir, file)
+		if !strings.Contains(note, want) {
+			t.Errorf("%s is missing %q:\n%s", file, want, note)
+		}
+		if want != "Content of https://example.com/fetched" && strings.Contains(note, "Content of") {
+			t.Errorf("%s was fetched despite its override:\n%s", file, note)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package markdown
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/web"
+)
+
+func TestOversizedContent(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := BuildCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// "Content of https://example.com/large" is longer than the limit
+	service := newTestContentServiceWith(t, web.FetchOptions{MaxContentSize: 10})
+	p := NewProcessor(ProcessorOptions{OutputDir: dir}, service, nil, cache)
+
+	if err := p.ProcessBookmarks(testFolder("toolbar", testBookmark("large", "Large", "https://example.com/large")), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if tags := parseNote(t, dir, "example.com - Large.md").Tags; !slices.Equal(tags, []string{"bookmark", "oversized"}) {
+		t.Errorf("got tags %q, want the oversized tag", tags)
+	}
+	note := readFile(t, dir, "example.com - Large.md")
+	if !strings.Contains(note, "[Large](https://example.com/large)") {
+		t.Errorf("note is missing the link:\n%s", note)
+	}
+	if strings.Contains(note, "Content of") {
+		t.Errorf("got content in a link-only note:\n%s", note)
+	}
+}
@@ -0,0 +1,79 @@
+package markdown
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxNameLength is the maximum length of a single file or folder name on common filesystems
+const maxNameLength = 255
+
+// minFileNameRoom is the path length reserved for note file names when shortening folder names
+const minFileNameRoom = 64
+
+// minShortNameLength is the shortest a name is cut to, keeping some of the original readable
+const minShortNameLength = 16
+
+// shortenName cuts a name to at most limit bytes, keeping the extension and
+// appending a short hash of the full name so shortened names stay unique
+func shortenName(name string, limit int) string {
+	if len(name) <= limit {
+		return name
+	}
+
+	hash := sha256.Sum256([]byte(name))
+	suffix := "~" + hex.EncodeToString(hash[:3]) + filepath.Ext(name)
+
+	keep := max(limit-len(suffix), 1)
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+	if keep > len(stem) {
+		keep = len(stem)
+	}
+	for keep > 0 && !utf8.RuneStart(stem[keep]) {
+		keep--
+	}
+
+	return strings.TrimSpace(stem[:keep]) + suffix
+}
+
+// fitFolderName shortens a folder name so that notes inside it stay within the path length limit
+func (p *Processor) fitFolderName(currentPath string, name string) string {
+	fitted := shortenName(name, maxNameLength)
+
+	if p.maxPathLength > 0 {
+		parent := len(filepath.Join(p.outputDir, currentPath)) + 1
+		room := max(p.maxPathLength-parent-minFileNameRoom-1, minShortNameLength)
+		fitted = shortenName(fitted, room)
+	}
+
+	if fitted != name {
+		slog.Warn("shortening folder name to fit path length limit",
+			"path", currentPath,
+			"folder", name,
+			"shortened", fitted)
+	}
+	return fitted
+}
+
+// fitFileName shortens a note file name so that it stays within the path length limit
+func (p *Processor) fitFileName(currentPath string, name string) string {
+	fitted := shortenName(name, maxNameLength)
+
+	if p.maxPathLength > 0 {
+		parent := len(filepath.Join(p.outputDir, currentPath)) + 1
+		room := max(p.maxPathLength-parent, minShortNameLength)
+		fitted = shortenName(fitted, room)
+	}
+
+	if fitted != name {
+		slog.Warn("shortening file name to fit path length limit",
+			"path", currentPath,
+			"file", name,
+			"shortened", fitted)
+	}
+	return fitted
+}
@@ -0,0 +1,65 @@
+package markdown
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestShortenName(t *testing.T) {
+	long := strings.Repeat("a", 300) + ".md"
+	short := shortenName(long, maxNameLength)
+	if len(short) > maxNameLength || !strings.HasSuffix(short, ".md") {
+		t.Errorf("got %q (%d bytes), want at most %d bytes ending in .md", short, len(short), maxNameLength)
+	}
+	if other := shortenName(strings.Repeat("a", 301)+".md", maxNameLength); other == short {
+		t.Errorf("names with the same prefix shortened to the same %q", short)
+	}
+	if got := shortenName("short.md", maxNameLength); got != "short.md" {
+		t.Errorf("got %q, want names within the limit unchanged", got)
+	}
+
+	// Multi-byte characters are never cut in half
+	multi := shortenName(strings.Repeat("ž", 40)+".md", 32)
+	if len(multi) > 32 || !utf8.ValidString(multi) {
+		t.Errorf("got %q (%d bytes), want valid UTF-8 within 32 bytes", multi, len(multi))
+	}
+}
+
+func TestMaxPathLength(t *testing.T) {
+	const limit = 160
+	dir := t.TempDir()
+	longFolder := strings.Repeat("Folder ", 30)
+	tree := testFolder("toolbar",
+		testFolder(longFolder,
+			testBookmark("a", strings.Repeat("Title ", 40), "https://example.com/long"),
+		),
+	)
+
+	p := newTestProcessor(t, dir, ProcessorOptions{MaxPathLength: limit})
+	if err := p.ProcessBookmarks(tree, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	var notes int
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if len(path) > limit {
+			t.Errorf("got path of %d bytes, want at most %d: %s", len(path), limit, path)
+		}
+		if strings.HasSuffix(path, ".md") {
+			notes++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if notes != 1 {
+		t.Errorf("got %d notes, want 1", notes)
+	}
+}
@@ -0,0 +1,60 @@
+package markdown
+
+import (
+	"slices"
+	"strings"
+)
+
+// DefaultPaywallDomains are news sites known to serve teasers to anonymous readers
+var DefaultPaywallDomains = []string{
+	"wsj.com",
+	"nytimes.com",
+	"ft.com",
+	"economist.com",
+	"washingtonpost.com",
+	"bloomberg.com",
+	"theatlantic.com",
+	"newyorker.com",
+	"wired.com",
+	"medium.com",
+}
+
+// paywallMarkers are phrases of login walls and subscription prompts
+var paywallMarkers = []string{
+	"subscribe to continue reading",
+	"subscribe to read",
+	"to continue reading, subscribe",
+	"this article is for subscribers",
+	"this content is for subscribers",
+	"available to subscribers only",
+	"you have reached your limit of free articles",
+	"you've reached your free article limit",
+	"create a free account to continue",
+	"sign in to continue reading",
+	"log in to continue reading",
+	"member-only story",
+}
+
+const (
+	// paywallMarkerLength is the content length below which a paywall marker
+	// means the page is a teaser rather than a full article with a footer
+	paywallMarkerLength = 3000
+	// paywallDomainLength is the content length below which a page of a
+	// paywalled domain is considered a teaser
+	paywallDomainLength = 1500
+)
+
+// isPaywalled reports whether content looks like the teaser of a paywalled page
+func isPaywalled(url string, content string, domains []string) bool {
+	lower := strings.ToLower(content)
+	if len(content) < paywallMarkerLength && slices.ContainsFunc(paywallMarkers, func(marker string) bool {
+		return strings.Contains(lower, marker)
+	}) {
+		return true
+	}
+
+	domain := extractDomain(url)
+	return len(content) < paywallDomainLength && slices.ContainsFunc(domains, func(d string) bool {
+		return domain == d || strings.HasSuffix(domain, "."+d)
+	})
+}
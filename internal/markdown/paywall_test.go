@@ -0,0 +1,57 @@
+package markdown
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestIsPaywalled(t *testing.T) {
+	long := strings.Repeat("Full article text. ", 200)
+	tests := []struct {
+		url     string
+		content string
+		want    bool
+	}{
+		{"https://example.com/a", "Short teaser. Subscribe to continue reading.", true},
+		{"https://example.com/a", "Short teaser. SIGN IN TO CONTINUE READING", true},
+		{"https://example.com/a", long + "Subscribe to read more from us.", false},
+		{"https://example.com/a", "A short page without a prompt.", false},
+		{"https://www.nytimes.com/2024/a", "A short teaser.", true},
+		{"https://www.nytimes.com/2024/a", long, false},
+		{"https://notnytimes.com/a", "A short teaser.", false},
+		{"https://news.example.org/a", "A short teaser.", true},
+	}
+
+	domains := slices.Concat(DefaultPaywallDomains, []string{"example.org"})
+	for _, tt := range tests {
+		if got := isPaywalled(tt.url, tt.content, domains); got != tt.want {
+			t.Errorf("isPaywalled(%s, %.30q) = %v, want %v", tt.url, tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestPaywalledTag(t *testing.T) {
+	dir := t.TempDir()
+	p := newTestProcessor(t, dir, ProcessorOptions{PaywallDomains: DefaultPaywallDomains})
+
+	tree := testFolder("toolbar",
+		testBookmark("news", "News", "https://www.nytimes.com/2024/story"),
+		testBookmark("blog", "Blog", "https://example.com/post"),
+	)
+	if err := p.ProcessBookmarks(tree, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if tags := parseNote(t, dir, "nytimes.com - News.md").Tags; !slices.Equal(tags, []string{"bookmark", "paywalled"}) {
+		t.Errorf("got tags %q for a paywalled note", tags)
+	}
+	if note := readFile(t, dir, "example.com - Blog.md"); strings.Contains(note, "paywalled") {
+		t.Errorf("got a paywalled tag on a regular page:\n%s", note)
+	}
+
+	// The tag is generated, so it is not read back as a bookmark tag
+	if !isGeneratedTag("paywalled") {
+		t.Error("paywalled is not a generated tag")
+	}
+}
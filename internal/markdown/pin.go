@@ -0,0 +1,54 @@
+package markdown
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// isPinned reports whether a bookmark carries the pin tag. Tags are compared
+// as imported, since symbol tags like ★ don't survive slugification.
+func (p *Processor) isPinned(bookmarkTags []string) bool {
+	if p.pinTag == "" {
+		return false
+	}
+	for _, tag := range bookmarkTags {
+		if strings.EqualFold(strings.TrimSpace(tag), p.pinTag) {
+			return true
+		}
+	}
+	return false
+}
+
+// updatePin sets or removes pinned: true in an existing note when the pin tag
+// was added to or removed from its bookmark. The rest of the note is kept.
+func (p *Processor) updatePin(entry CacheEntry, pinned bool) error {
+	if entry.Pinned == pinned || entry.DuplicateOf != "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(p.outputDir, entry.File))
+	if err != nil {
+		return fmt.Errorf("failed to read note: %w", err)
+	}
+	rawMatter, body, err := splitNote(string(data))
+	if err != nil {
+		return err
+	}
+
+	if pinned {
+		rawMatter = setFrontmatterLine(rawMatter, "pinned", "true")
+	} else {
+		rawMatter = removeFrontmatterLine(rawMatter, "pinned")
+	}
+	if err := p.output.WriteFile(entry.File, []byte(rawMatter+"\n"+body)); err != nil {
+		return fmt.Errorf("failed to write note: %w", err)
+	}
+	slog.Info("updated note pin", "file", entry.File, "pinned", pinned)
+
+	entry.Pinned = pinned
+	p.cache[entry.ID] = entry
+	return nil
+}
@@ -0,0 +1,88 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/x/testutil"
+)
+
+func TestPinnedIndexesSort(t *testing.T) {
+	dir := t.TempDir()
+	bookmark := testBookmark("id", "Page", "https://example.com/page")
+	p := newTestProcessor(t, dir, ProcessorOptions{Triage: true, PinTag: "★"})
+	if err := p.CreateYearIndexes(func(yield func(*bookmarks.Bookmark) bool) { yield(&bookmark) }); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.CreateInboxIndex(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, index := range []string{"2024.md", inboxIndexFile} {
+		if got := readFile(t, dir, index); !strings.Contains(got, "\nSORT pinned DESC, created_at DESC\n") {
+			t.Errorf("%s does not list pinned notes first:\n%s", index, got)
+		}
+	}
+}
+
+func TestPinnedFolderIndex(t *testing.T) {
+	for _, order := range []string{FolderIndexSortBookmark, FolderIndexSortTitle, FolderIndexSortDate} {
+		t.Run(order, func(t *testing.T) {
+			folder := readingList()
+			// Gamma is neither the first bookmark nor the first title
+			folder.Children[0].Children[1].Tags = []string{"★"}
+
+			dir := t.TempDir()
+			p := newTestProcessor(t, dir, ProcessorOptions{FolderIndexes: true, FolderIndexSort: order, PinTag: "★"})
+			if err := p.ProcessBookmarks(folder, ""); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.CreateFolderIndexes(); err != nil {
+				t.Fatal(err)
+			}
+			testutil.Golden(t, "folder-index/pinned-"+order, []byte(readFile(t, dir, "Reading/_index.md")))
+		})
+	}
+}
+
+func TestPinExistingNote(t *testing.T) {
+	dir := t.TempDir()
+	file := "example.com - Page.md"
+	writeNote(t, dir, file, Frontmatter{Title: "Page", URL: "https://example.com/page", ID: "id", CreatedAt: "2024-03-01", Tags: []string{"bookmark", "go"}}, "Page content")
+	unpinned := readFile(t, dir, file)
+
+	run := func(tags ...string) {
+		t.Helper()
+		bookmark := testBookmark("id", "Page", "https://example.com/page")
+		bookmark.Tags = tags
+		p := newTestProcessor(t, dir, ProcessorOptions{PinTag: "★"})
+		if err := p.ProcessBookmarks(testFolder("toolbar", bookmark), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run("go", "★")
+	testutil.Golden(t, "pinned", []byte(readFile(t, dir, file)))
+
+	// Removing the tag unpins the note again
+	run("go")
+	if got := readFile(t, dir, file); got != unpinned {
+		t.Errorf("unpinned note differs from the original:\n%s", got)
+	}
+}
+
+func TestPinIgnoredWithoutPinTag(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "Page.md", Frontmatter{Title: "Page", URL: "https://example.com/page", ID: "id", Pinned: true}, "Page content")
+	pinned := readFile(t, dir, "Page.md")
+
+	bookmark := testBookmark("id", "Page", "https://example.com/page")
+	p := newTestProcessor(t, dir, ProcessorOptions{})
+	if err := p.ProcessBookmarks(testFolder("toolbar", bookmark), ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, dir, "Page.md"); got != pinned {
+		t.Errorf("note pinned by hand was changed:\n%s", got)
+	}
+}
@@ -0,0 +1,100 @@
+package markdown
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// PlanVersion is the version of plan documents, bumped when their fields
+// change incompatibly
+const PlanVersion = 1
+
+// ErrUnplanned is returned for writes to files a plan doesn't change
+var ErrUnplanned = errors.New("change is not in the plan")
+
+// Plan is the machine-readable document of the changes planned in a dry run,
+// which a later run applies
+type Plan struct {
+	Version int `json:"version"`
+	// TreeHash identifies the synced bookmarks the plan was made for
+	TreeHash string `json:"tree_hash"`
+	// Args are the command line arguments of the dry run
+	Args    []string `json:"args"`
+	Changes Changes  `json:"changes"`
+}
+
+// WritePlan writes a plan document to path
+func WritePlan(path string, plan Plan) error {
+	plan.Version = PlanVersion
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode plan: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write plan: %w", err)
+	}
+	return nil
+}
+
+// ReadPlan reads a plan document written by WritePlan
+func ReadPlan(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan: %w", err)
+	}
+
+	var plan Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("invalid plan %s: %w", path, err)
+	}
+	if plan.Version != PlanVersion {
+		return nil, fmt.Errorf("plan %s has version %d, expected %d", path, plan.Version, PlanVersion)
+	}
+	return &plan, nil
+}
+
+// files returns the files changed by a plan, old and new files of renames
+func (plan *Plan) files() map[string]bool {
+	files := make(map[string]bool, len(plan.Changes))
+	for _, change := range plan.Changes {
+		files[change.File] = true
+		if change.To != "" {
+			files[change.To] = true
+		}
+	}
+	return files
+}
+
+// removes checks whether a plan deletes or archives a file
+func (plan *Plan) removes(action string, file string) bool {
+	for _, change := range plan.Changes {
+		if change.Action == action && change.File == file {
+			return true
+		}
+	}
+	return false
+}
+
+// planOutput only writes and removes files that a plan changes
+type planOutput struct {
+	Output
+	files map[string]bool
+}
+
+// WriteFile writes a file the plan changes
+func (o *planOutput) WriteFile(name string, data []byte) error {
+	if !o.files[name] {
+		return fmt.Errorf("%w: %s", ErrUnplanned, name)
+	}
+	return o.Output.WriteFile(name, data)
+}
+
+// Remove removes a file the plan changes
+func (o *planOutput) Remove(name string) error {
+	if !o.files[name] {
+		return fmt.Errorf("%w: %s", ErrUnplanned, name)
+	}
+	return o.Output.Remove(name)
+}
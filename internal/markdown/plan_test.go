@@ -0,0 +1,112 @@
+package markdown
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/llm"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/web"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/x"
+)
+
+func TestApplyPlan(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "example.com - Kept.md", Frontmatter{ID: "kept-id", Title: "Kept", URL: "https://example.com/kept"}, "Content")
+	writeNote(t, dir, "example.com - Removed.md", Frontmatter{ID: "removed-id", Title: "Removed", URL: "https://example.com/removed"}, "Content")
+
+	p := newTestProcessor(t, dir, ProcessorOptions{DryRun: DryRunPlan})
+	if err := p.ProcessBookmarks(testFolder("toolbar", testBookmark("kept-id", "Kept", "https://example.com/kept"), testBookmark("new-id", "New", "https://example.com/new")), ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.PruneNotes(bookmarkSeq("kept-id", "new-id"), []string{""}, PruneDelete); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "plan.json")
+	if err := WritePlan(path, Plan{TreeHash: "hash", Args: []string{"-dry-run", "-prune"}, Changes: p.Changes()}); err != nil {
+		t.Fatal(err)
+	}
+	plan, err := ReadPlan(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(plan.Changes, p.Changes()) || plan.TreeHash != "hash" {
+		t.Fatalf("read plan %+v, want the written one", plan)
+	}
+
+	// Notes the plan doesn't create or prune are left alone
+	writeNote(t, dir, "example.com - Other.md", Frontmatter{ID: "other-id", Title: "Other", URL: "https://example.com/other"}, "Content")
+	p = newTestProcessor(t, dir, ProcessorOptions{Plan: plan})
+	folder := testFolder("toolbar",
+		testBookmark("kept-id", "Kept", "https://example.com/kept"),
+		testBookmark("new-id", "New", "https://example.com/new"),
+		testBookmark("unplanned-id", "Unplanned", "https://example.com/unplanned"),
+	)
+	if err := p.ProcessBookmarks(folder, ""); err != nil {
+		t.Fatal(err)
+	}
+	pruned, err := p.PruneNotes(bookmarkSeq("kept-id", "new-id", "unplanned-id"), []string{""}, PruneDelete)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !exists(dir, "example.com - New.md") || exists(dir, "example.com - Unplanned.md") {
+		t.Error("got other notes created than the planned one")
+	}
+	if !slices.Equal(pruned, []string{"example.com - Removed.md"}) || !exists(dir, "example.com - Other.md") {
+		t.Errorf("pruned %v, want only the planned note", pruned)
+	}
+	problems := p.Summary().Problems
+	if len(problems) != 1 || problems[0].Bookmark.ID != "unplanned-id" || !strings.Contains(problems[0].Reason, ErrUnplanned.Error()) {
+		t.Errorf("got problems %+v, want the unplanned note", problems)
+	}
+}
+
+func TestPlanFetch(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := x.NewFileCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached := strings.Repeat("Cached content. ", 100)
+	if err := cache.Set(web.URLKey("https://example.com/cached"), cached); err != nil {
+		t.Fatal(err)
+	}
+
+	service := newTestContentServiceWith(t, web.FetchOptions{Cache: cache, ContentCleaner: &llm.FakeClient{}})
+	mdCache, err := BuildCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewProcessor(ProcessorOptions{OutputDir: dir, DryRun: DryRunPlan, Tagger: &llm.FakeClient{}}, service, nil, mdCache)
+	folder := testFolder("toolbar",
+		testBookmark("cached-id", "Cached", "https://example.com/cached"),
+		testBookmark("new-id", "New", "https://example.com/new"),
+	)
+	if err := p.ProcessBookmarks(folder, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	want := Changes{
+		// Cleaned content is cached, only tags are suggested
+		{Action: ChangeCreate, File: "example.com - Cached.md", Reason: ReasonNew, BookmarkID: "cached-id", URL: "https://example.com/cached", LLM: true, EstimatedTokens: len(cached) / charsPerToken},
+		{Action: ChangeCreate, File: "example.com - New.md", Reason: ReasonNew, BookmarkID: "new-id", URL: "https://example.com/new", Fetch: true, LLM: true},
+	}
+	if got := p.Changes(); !slices.Equal(got, want) {
+		t.Errorf("got changes %+v, want %+v", got, want)
+	}
+}
+
+func TestReadPlanVersion(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "plan.json", `{"version": 2, "changes": []}`)
+	if _, err := ReadPlan(filepath.Join(dir, "plan.json")); err == nil {
+		t.Error("got no error for a plan of another version")
+	}
+	writeFile(t, dir, "broken.json", `{"version": 1`)
+	if _, err := ReadPlan(filepath.Join(dir, "broken.json")); err == nil {
+		t.Error("got no error for a broken plan")
+	}
+}
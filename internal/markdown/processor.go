@@ -1,111 +1,649 @@
 package markdown
 
 import (
+	"cmp"
+	"context"
+	"errors"
 	"fmt"
 	"iter"
 	"log/slog"
-	"os"
+	"maps"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
 	"github.com/xtruder/ffbookmarks-to-markdown/internal/web"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/x"
+	"gopkg.in/yaml.v2"
 )
 
 // ProcessorOptions contains configuration for markdown processing
 type ProcessorOptions struct {
 	OutputDir      string
+	Output         Output
 	IgnoredFolders []string
+	InlineFields   []string
+	NameCollision  string
+	LinkSafeNames  bool
+	Slugs          bool
+	IncludeDeleted bool
+	OverridesDir   string
+	MaxPathLength  int
+	Order          string
+	// LooseDir is the folder for bookmarks directly in the sync root, empty
+	// keeps them in the output root
+	LooseDir string
+	// FragmentMode controls notes for bookmarks that only differ from another
+	// bookmark by their #fragment (full, stub, section)
+	FragmentMode string
+	// Deterministic renders dates in UTC instead of the local time zone, so
+	// the same bookmarks produce identical notes on every machine
+	Deterministic bool
+	// Excerpt adds a plain text preview of the content to frontmatter
+	Excerpt bool
+	// Dedupe creates a single note for a URL bookmarked in several folders
+	Dedupe bool
+	// DedupeStubs writes a stub linking to the single note in the folders of
+	// duplicates, instead of recording their titles as aliases
+	DedupeStubs bool
+	// LicenseMetadata records license and robots hints of pages in frontmatter
+	LicenseMetadata bool
+	// ExpandLists creates notes for the links of reading list bookmarks (stub, full)
+	ExpandLists string
+	// ExpandMax limits the number of notes created for one reading list
+	ExpandMax int
+	// FolderIndexes writes an index note listing the bookmarks of each folder
+	FolderIndexes bool
+	// FolderIndexSort is the listing order of folder indexes (bookmark, title, date)
+	FolderIndexSort string
+	// Clippings are Web Clipper notes adopted by bookmarks with the same URL
+	Clippings map[string]Clipping
+	// RewriteClippings renames adopted clipping frontmatter to the note fields
+	RewriteClippings bool
+	// Checkpoint stores IDs of created notes, so an interrupted run can resume
+	Checkpoint x.Cache
+	// Screenshots are existing screenshot results keyed by normalized URL,
+	// used to tag notes with the detected tech stack and HTTP status
+	Screenshots map[string]web.ScreenshotResult
+	// ReadyScreenshotsOnly embeds only screenshots listed in Screenshots,
+	// instead of predicting the location of screenshots not taken yet
+	ReadyScreenshotsOnly bool
+	// RecaptureScreenshots takes new screenshots of refreshed notes, waiting
+	// up to this duration, and records how much their page's appearance
+	// changed. Zero keeps the existing screenshots.
+	RecaptureScreenshots time.Duration
+	// TagSynonyms maps tag aliases to their canonical tag
+	TagSynonyms map[string]string
+	// BatchClean fetches the content of all notes first and cleans it with
+	// CleanConcurrency parallel LLM calls before writing notes
+	BatchClean       bool
+	CleanConcurrency int
+	// PaywallDomains lists domains whose short pages are tagged as paywalled
+	PaywallDomains []string
+	// RenameOnTitleChange renames note files to match a changed bookmark
+	// title, otherwise only the title in the note is updated
+	RenameOnTitleChange bool
+	// RenameStubs leaves a stub linking to the new file when a note is
+	// renamed for a changed bookmark title
+	RenameStubs bool
+	// MinYear and MaxYear bound plausible bookmark dates, dates outside
+	// are corrected and flagged with date_suspect (0 = defaults)
+	MinYear int
+	MaxYear int
+	// Now is the start of the run, which the default MaxYear is relative
+	// to, zero is the current time
+	Now time.Time
+	// Triage marks new notes with status inbox
+	Triage bool
+	// ReadStats adds read_at columns and read counts to indexes
+	ReadStats bool
+	// ReadDates are read_at dates keyed by bookmark ID, written to notes
+	// regenerated from scratch
+	ReadDates map[string]string
+	// PinTag is a Firefox tag marking bookmarks as pinned, so they are listed
+	// first in indexes
+	PinTag string
+	// DryRun records planned changes instead of writing notes (plan, fetch),
+	// empty writes notes
+	DryRun string
+	// Plan restricts writes and pruning to the changes of a plan made by a
+	// dry run, nil allows all changes
+	Plan *Plan
+	// Related selects how LinkRelated relates notes, RelatedURLs or
+	// RelatedTitles
+	Related string
+	// TrackingParams are ignored when matching duplicate, refreshed and
+	// mentioned URLs, defaults to web.DefaultTrackingParams
+	TrackingParams []string
+	// Summarizer fills the description of new notes, nil leaves it empty
+	Summarizer Summarizer
+	// Tagger adds topical tags to new notes, nil adds none
+	Tagger Tagger
+	Hooks  NoteHooks
+	// Context stops processing between two notes when it is done, nil
+	// never stops
+	Context context.Context
 }
 
+// Summarizer writes short descriptions of note content
+type Summarizer interface {
+	SummarizeMarkdown(content string) (string, error)
+}
+
+// Tagger proposes topical tags for note content
+type Tagger interface {
+	SuggestTags(content string) ([]string, error)
+}
+
+// NoteHooks are notified about generated notes
+type NoteHooks interface {
+	PostCreate(path string, bookmark bookmarks.Bookmark) error
+}
+
+// Strategies for resolving a folder and a bookmark that share a name
+const (
+	CollisionSuffixBookmark = "bookmark"
+	CollisionSuffixFolder   = "folder"
+)
+
+// Orders in which new notes are created
+const (
+	OrderFolder = "folder"
+	OrderNewest = "newest"
+	OrderOldest = "oldest"
+)
+
+// generatedEndMarker separates generated note content from user additions
+const generatedEndMarker = "%% end of generated content %%"
+
+// InlineFields lists frontmatter fields that can be emitted as Dataview inline fields
+var InlineFields = []string{"url", "path", "created", "tags"}
+
 type Frontmatter struct {
 	CreatedAt   string   `yaml:"created_at"`
+	DateSuspect bool     `yaml:"date_suspect,omitempty"`
 	Path        string   `yaml:"path"`
+	Paths       []string `yaml:"paths,omitempty"`
 	URL         string   `yaml:"url"`
 	ID          string   `yaml:"id"`
 	Description string   `yaml:"description,omitempty"`
 	Title       string   `yaml:"title"`
-	Tags        []string `yaml:"tags,omitempty"`
+	Slug        string   `yaml:"slug,omitempty"`
+	Keyword     string   `yaml:"keyword,omitempty"`
+	Fragment    string   `yaml:"fragment,omitempty"`
+	Excerpt     string   `yaml:"excerpt,omitempty"`
+	License     string   `yaml:"license,omitempty"`
+	NoArchive   bool     `yaml:"noarchive,omitempty"`
+	HTTPStatus  int      `yaml:"http_status,omitempty"`
+	// VisualChange is high or low, by how much a screenshot taken again on
+	// VisualChangeAt differs from the previous one
+	VisualChange   string `yaml:"visual_change,omitempty"`
+	VisualChangeAt string `yaml:"visual_change_at,omitempty"`
+	Status         string `yaml:"status,omitempty"`
+	// PreviousTitles lists the earlier titles of the bookmark, oldest first
+	PreviousTitles []string `yaml:"previous_titles,omitempty"`
+	Pinned         bool     `yaml:"pinned,omitempty"`
+	ReadAt         string   `yaml:"read_at,omitempty"`
+	Tags           []string `yaml:"tags,omitempty"`
+	Aliases        []string `yaml:"aliases,omitempty"`
+	DuplicateOf    string   `yaml:"duplicate_of,omitempty"`
+}
+
+// MarshalYAML lists the set fields in the order they are read in, followed
+// by the cssclasses of the note
+func (f Frontmatter) MarshalYAML() (interface{}, error) {
+	fields := yaml.MapSlice{
+		{Key: "title", Value: f.Title},
+		{Key: "url", Value: f.URL},
+		{Key: "path", Value: f.Path},
+		{Key: "paths", Value: f.Paths},
+		{Key: "description", Value: f.Description},
+		{Key: "created_at", Value: f.CreatedAt},
+		{Key: "date_suspect", Value: f.DateSuspect},
+		{Key: "id", Value: f.ID},
+		{Key: "slug", Value: f.Slug},
+		{Key: "keyword", Value: f.Keyword},
+		{Key: "fragment", Value: f.Fragment},
+		{Key: "excerpt", Value: f.Excerpt},
+		{Key: "license", Value: f.License},
+		{Key: "noarchive", Value: f.NoArchive},
+		{Key: "http_status", Value: f.HTTPStatus},
+		{Key: "visual_change", Value: f.VisualChange},
+		{Key: "visual_change_at", Value: f.VisualChangeAt},
+		{Key: "duplicate_of", Value: f.DuplicateOf},
+		{Key: "status", Value: f.Status},
+		{Key: "previous_titles", Value: f.PreviousTitles},
+		{Key: "pinned", Value: f.Pinned},
+		{Key: "read_at", Value: f.ReadAt},
+		{Key: "aliases", Value: f.Aliases},
+		{Key: "cssclasses", Value: []string{"line3"}},
+		{Key: "tags", Value: f.Tags},
+	}
+
+	return slices.DeleteFunc(fields, func(field yaml.MapItem) bool {
+		switch value := field.Value.(type) {
+		case string:
+			return value == ""
+		case bool:
+			return !value
+		case int:
+			return value == 0
+		case []string:
+			return len(value) == 0
+		}
+		return false
+	}), nil
 }
 
-// Update String method to handle tags
+// String renders the frontmatter between --- fences
 func (f Frontmatter) String() string {
+	// Marshaling only fails for types that Frontmatter doesn't have
+	out, _ := yaml.Marshal(f)
+	return "---\n" + string(out) + "---"
+}
+
+// yamlScalar renders a value as a single line YAML scalar, quoted where needed
+func yamlScalar(value string) string {
+	if strings.ContainsAny(value, "\r\n") {
+		// The encoder would write a block scalar spanning several lines
+		return strconv.Quote(value)
+	}
+	out, _ := yaml.Marshal(value)
+	return strings.TrimSuffix(string(out), "\n")
+}
+
+// InlineString renders selected fields as a Dataview inline fields block
+func (f Frontmatter) InlineString(fields []string) string {
 	var sb strings.Builder
 
-	writeKV := func(key string, value string) {
+	writeField := func(key string, value string) {
 		if value != "" {
-			sb.WriteString(fmt.Sprintf("%s: %s\n", key, value))
+			sb.WriteString(fmt.Sprintf("%s:: %s\n", key, value))
 		}
 	}
 
-	writeList := func(key string, values []string) {
-		if len(values) > 0 {
-			sb.WriteString(fmt.Sprintf("%s: [\"%s\"]\n", key, strings.Join(values, ", ")))
+	for _, field := range fields {
+		switch field {
+		case "url":
+			writeField("url", f.URL)
+		case "path":
+			if len(f.Paths) > 0 {
+				writeField("path", strings.Join(f.Paths, ", "))
+			} else {
+				writeField("path", f.Path)
+			}
+		case "created":
+			writeField("created", f.CreatedAt)
+		case "tags":
+			var hashtags []string
+			for _, tag := range f.Tags {
+				hashtags = append(hashtags, "#"+tag)
+			}
+			writeField("tags", strings.Join(hashtags, " "))
 		}
 	}
 
-	sb.WriteString("---\n")
-	if strings.Contains(f.Title, "'") {
-		writeKV("title", "\""+f.Title+"\"")
-	} else {
-		writeKV("title", "'"+f.Title+"'")
-	}
-	writeKV("url", f.URL)
-	writeKV("path", f.Path)
-	writeKV("description", f.Description)
-	writeKV("created_at", f.CreatedAt)
-	writeKV("id", f.ID)
-	writeKV("cssclasses", "line3")
-	writeList("tags", f.Tags)
-	sb.WriteString("---")
+	return strings.TrimSuffix(sb.String(), "\n")
+}
 
-	return sb.String()
+// Summary counts the outcome of processing bookmarks
+type Summary struct {
+	Created  int
+	Failed   int
+	Deferred int
+	// Retitled counts existing notes updated for a renamed bookmark
+	Retitled int
+	// Pruned counts notes deleted or archived for removed bookmarks
+	Pruned int
+	// Refreshed counts notes whose content was refetched with RefreshNotes
+	Refreshed int
+	// UnreadReadingTime is the estimated time to read all notes without read_at
+	UnreadReadingTime time.Duration
+	// Problems lists the failed and deferred bookmarks
+	Problems []Problem
+	// VisualChanges lists the notes whose page looks very different from
+	// its previous screenshot
+	VisualChanges []VisualChange
+}
+
+// Problem is a bookmark whose note could not be created
+type Problem struct {
+	Bookmark bookmarks.Bookmark
+	Reason   string
+	Category web.Category
+	// Deferred is set for bookmarks left for the next run
+	Deferred bool
+}
+
+// FailureCategories counts the failed and deferred bookmarks by category
+func (s Summary) FailureCategories() map[web.Category]int {
+	counts := make(map[web.Category]int)
+	for _, problem := range s.Problems {
+		counts[problem.Category]++
+	}
+	return counts
 }
 
 // Processor handles markdown file generation
 type Processor struct {
 	outputDir         string
+	output            Output
 	ignoredFolders    []string
+	inlineFields      []string
+	nameCollision     string
+	linkSafeNames     bool
+	slugs             map[string]bool
+	includeDeleted    bool
+	overridesDir      string
+	maxPathLength     int
+	order             string
+	looseDir          string
+	fragmentMode      string
+	pages             map[string]CacheEntry
+	location          *time.Location
+	minDate           time.Time
+	maxDate           time.Time
+	excerpt           bool
+	dedupe            bool
+	dedupeStubs       bool
+	licenseMetadata   bool
+	expandLists       string
+	expandMax         int
+	plannedURLs       map[string]bool
+	folderIndexes     bool
+	folderIndexSort   string
+	indexedFolders    []indexedFolder
+	noteFiles         map[string]string
+	clippings         map[string]Clipping
+	rewriteClippings  bool
+	checkpointCache   x.Cache
+	checkpointKey     string
+	checkpointed      map[string]bool
+	screenshots       map[string]web.ScreenshotResult
+	readyScreenshots  bool
+	recaptureTimeout  time.Duration
+	now               time.Time
+	tagSynonyms       map[string]string
+	batchClean        bool
+	cleanConcurrency  int
+	paywallDomains    []string
+	renameOnTitle     bool
+	pinTag            string
+	readStats         bool
+	readDates         map[string]string
+	renameStubs       bool
+	triage            bool
+	dryRun            string
+	changes           Changes
+	plan              *Plan
+	summarizer        Summarizer
+	tagger            Tagger
+	related           string
+	trackingParams    []string
+	hooks             NoteHooks
+	ctx               context.Context
 	contentService    *web.ContentService
 	screenshotService *web.ScreenshotService
 	cache             Cache
+	summary           Summary
 }
 
 // NewProcessor creates a new markdown processor
 func NewProcessor(opts ProcessorOptions, contentService *web.ContentService, screenshotService *web.ScreenshotService, cache Cache) *Processor {
-	return &Processor{
+	output := opts.Output
+	if output == nil {
+		output = NewDirOutput(opts.OutputDir)
+	}
+
+	// Collect slugs already used in the vault
+	var slugs map[string]bool
+	if opts.Slugs {
+		slugs = make(map[string]bool)
+		for _, entry := range cache {
+			if entry.Slug != "" {
+				slugs[entry.Slug] = true
+			}
+		}
+	}
+
+	checkpointKey := checkpointKey(opts.OutputDir)
+
+	location := time.Local
+	if opts.Deterministic {
+		location = time.UTC
+	}
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	minDate, maxDate := dateRange(opts.MinYear, opts.MaxYear, now)
+
+	trackingParams := opts.TrackingParams
+	if trackingParams == nil {
+		trackingParams = web.DefaultTrackingParams
+	}
+
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	p := &Processor{
 		outputDir:         opts.OutputDir,
+		output:            output,
 		ignoredFolders:    opts.IgnoredFolders,
+		inlineFields:      opts.InlineFields,
+		nameCollision:     opts.NameCollision,
+		linkSafeNames:     opts.LinkSafeNames,
+		slugs:             slugs,
+		includeDeleted:    opts.IncludeDeleted,
+		overridesDir:      opts.OverridesDir,
+		maxPathLength:     opts.MaxPathLength,
+		order:             opts.Order,
+		looseDir:          opts.LooseDir,
+		fragmentMode:      opts.FragmentMode,
+		pages:             pageNotes(cache),
+		noteFiles:         make(map[string]string),
+		location:          location,
+		minDate:           minDate,
+		maxDate:           maxDate,
+		excerpt:           opts.Excerpt,
+		dedupe:            opts.Dedupe,
+		dedupeStubs:       opts.DedupeStubs,
+		licenseMetadata:   opts.LicenseMetadata,
+		expandLists:       opts.ExpandLists,
+		expandMax:         opts.ExpandMax,
+		folderIndexes:     opts.FolderIndexes,
+		folderIndexSort:   opts.FolderIndexSort,
+		clippings:         opts.Clippings,
+		rewriteClippings:  opts.RewriteClippings,
+		checkpointCache:   opts.Checkpoint,
+		checkpointKey:     checkpointKey,
+		checkpointed:      loadCheckpoint(opts.Checkpoint, checkpointKey),
+		screenshots:       opts.Screenshots,
+		readyScreenshots:  opts.ReadyScreenshotsOnly,
+		recaptureTimeout:  opts.RecaptureScreenshots,
+		now:               now,
+		tagSynonyms:       normalizeSynonyms(opts.TagSynonyms),
+		batchClean:        opts.BatchClean,
+		cleanConcurrency:  opts.CleanConcurrency,
+		paywallDomains:    opts.PaywallDomains,
+		triage:            opts.Triage,
+		pinTag:            strings.TrimSpace(opts.PinTag),
+		renameOnTitle:     opts.RenameOnTitleChange,
+		readStats:         opts.ReadStats,
+		readDates:         opts.ReadDates,
+		renameStubs:       opts.RenameStubs,
+		dryRun:            opts.DryRun,
+		plan:              opts.Plan,
+		summarizer:        opts.Summarizer,
+		tagger:            opts.Tagger,
+		related:           opts.Related,
+		trackingParams:    trackingParams,
+		hooks:             opts.Hooks,
+		ctx:               ctx,
 		contentService:    contentService,
 		screenshotService: screenshotService,
 		cache:             cache,
 	}
+	if opts.DryRun != "" {
+		p.output = &dryRunOutput{dir: opts.OutputDir, p: p}
+	} else if opts.Plan != nil {
+		p.output = &planOutput{Output: output, files: opts.Plan.files()}
+	}
+	return p
+}
+
+// plannedNote is a bookmark scheduled for note creation
+type plannedNote struct {
+	bookmark bookmarks.Bookmark
+	path     string
+	filename string
+	// paths lists all folders the URL is bookmarked in, when deduplicating
+	paths []string
+	// duplicates are other bookmarks of the URL sharing this note
+	duplicates []plannedNote
 }
 
 // ProcessBookmarks processes bookmarks recursively
 func (p *Processor) ProcessBookmarks(folder bookmarks.Bookmark, currentPath string) error {
+	var planned []plannedNote
+	if err := p.planFolder(folder, currentPath, &planned); err != nil {
+		return err
+	}
+
+	if p.dedupe {
+		planned = p.dedupePlanned(planned)
+	}
+
+	p.plannedURLs = make(map[string]bool, len(planned))
+	for _, note := range planned {
+		p.plannedURLs[note.bookmark.URI] = true
+	}
+
+	switch p.order {
+	case OrderNewest:
+		slices.SortStableFunc(planned, func(a, b plannedNote) int {
+			return cmp.Compare(b.bookmark.AddedUnix, a.bookmark.AddedUnix)
+		})
+	case OrderOldest:
+		slices.SortStableFunc(planned, func(a, b plannedNote) int {
+			return cmp.Compare(a.bookmark.AddedUnix, b.bookmark.AddedUnix)
+		})
+	}
+
+	if p.fragmentMode != "" && p.fragmentMode != FragmentFull {
+		// Create page notes before the fragment notes linking to them
+		var pages, fragments []plannedNote
+		for _, note := range planned {
+			if _, fragment := splitFragment(note.bookmark.URI); fragment != "" {
+				fragments = append(fragments, note)
+			} else {
+				pages = append(pages, note)
+			}
+		}
+		planned = append(pages, fragments...)
+	}
+
+	if p.batchClean && p.dryRun != DryRunPlan {
+		p.contentService.CleanBatch(p.ctx, p.batchURLs(planned), p.cleanConcurrency)
+	}
+
+	for _, note := range planned {
+		// Keep the checkpoint of an interrupted run for the next one
+		if err := p.ctx.Err(); err != nil {
+			p.saveCheckpoint()
+			return err
+		}
+		p.processNote(note)
+	}
+
+	p.clearCheckpoint()
+	return nil
+}
+
+// batchURLs returns the URLs of planned notes whose content is fetched,
+// leaving out notes with a content override and fragment notes
+func (p *Processor) batchURLs(planned []plannedNote) []string {
+	var urls []string
+	for _, note := range planned {
+		if _, fragment := splitFragment(note.bookmark.URI); fragment != "" && p.fragmentMode != "" && p.fragmentMode != FragmentFull {
+			continue
+		}
+		if _, ok, _ := p.readOverride(note.bookmark); ok {
+			continue
+		}
+		urls = append(urls, note.bookmark.URI)
+	}
+	return urls
+}
+
+// planFolder creates output folders and collects bookmarks that need a note
+func (p *Processor) planFolder(folder bookmarks.Bookmark, currentPath string, planned *[]plannedNote) error {
 	// Create folder path for non-root folders
 	if currentPath != "" {
-		folderPath := filepath.Join(p.outputDir, currentPath)
-		if err := os.MkdirAll(folderPath, 0755); err != nil {
-			return fmt.Errorf("failed to create directory %s: %w", folderPath, err)
+		if err := p.output.MkdirAll(currentPath); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", currentPath, err)
+		}
+
+		if p.folderIndexes {
+			p.indexedFolders = append(p.indexedFolders, indexedFolder{path: currentPath, folder: folder})
 		}
 	}
 
-	for _, bookmark := range folder.Children {
-		if bookmark.Type == "bookmark" && !bookmark.Deleted {
-			// Check if bookmark exists in cache
-			if _, exists := p.cache[bookmark.ID]; !exists {
-				if err := p.createBookmarkFile(bookmark, currentPath); err != nil {
-					slog.Error("failed to create bookmark file",
-						"title", bookmark.Title,
-						"error", err)
+	names := p.resolveNames(folder.Children)
+
+	for i, bookmark := range folder.Children {
+		switch {
+		case bookmark.Type == bookmarks.TypeSeparator:
+			// Separators only order bookmarks in the Firefox UI
+			continue
+		case bookmark.IsQuery():
+			// Queries only list other bookmarks, there is no page to fetch
+			slog.Debug("skipping bookmark query", "id", bookmark.ID, "uri", bookmark.URI)
+			continue
+		case bookmark.Type != bookmarks.TypeBookmark && bookmark.Type != bookmarks.TypeFolder:
+			slog.Warn("skipping bookmark of unsupported type", "id", bookmark.ID, "type", bookmark.Type, "title", bookmark.Title)
+			continue
+		}
+
+		if bookmark.Type == bookmarks.TypeBookmark && (!bookmark.Deleted || p.includeDeleted) {
+			// Check if bookmark exists in cache or was processed by an interrupted run
+			entry, exists := p.cache[bookmark.ID]
+			if exists && entry.File != "" && entry.Title != bookmark.Title {
+				planned := len(p.changes)
+				if err := p.retitleNote(entry, bookmark, names[i]); err != nil {
+					slog.Warn("failed to update note title", "file", entry.File, "error", err)
+				}
+				p.describeChanges(planned, bookmark, ReasonRetitled)
+			}
+			if exists && entry.File != "" && p.pinTag != "" {
+				planned := len(p.changes)
+				if err := p.updatePin(p.cache[bookmark.ID], p.isPinned(bookmark.Tags)); err != nil {
+					slog.Warn("failed to update note pin", "file", entry.File, "error", err)
+				}
+				p.describeChanges(planned, bookmark, ReasonPin)
+			}
+			if !exists && !p.checkpointed[bookmark.ID] {
+				// Pages clipped with the Web Clipper are not fetched again
+				if p.adoptClipping(bookmark) {
 					continue
 				}
-				p.cache[bookmark.ID] = bookmark
+
+				notePath, err := p.notePath(currentPath)
+				if err != nil {
+					return err
+				}
+				*planned = append(*planned, plannedNote{
+					bookmark: bookmark,
+					path:     notePath,
+					filename: p.fitFileName(notePath, names[i]),
+				})
 			}
-		} else if bookmark.Type == "folder" {
+		} else if bookmark.Type == bookmarks.TypeFolder {
 			// Skip ignored folders
 			if p.shouldIgnoreFolder(bookmark.Title) {
 				slog.Info("skipping ignored folder", "folder", bookmark.Title)
@@ -113,11 +651,11 @@ func (p *Processor) ProcessBookmarks(folder bookmarks.Bookmark, currentPath stri
 			}
 
 			// Process nested folders
-			newPath := bookmark.Title
+			newPath := p.fitFolderName(currentPath, names[i])
 			if currentPath != "" {
-				newPath = filepath.Join(currentPath, bookmark.Title)
+				newPath = filepath.Join(currentPath, newPath)
 			}
-			if err := p.ProcessBookmarks(bookmark, newPath); err != nil {
+			if err := p.planFolder(bookmark, newPath, planned); err != nil {
 				return fmt.Errorf("failed to process folder %s: %w", newPath, err)
 			}
 		}
@@ -126,54 +664,376 @@ func (p *Processor) ProcessBookmarks(folder bookmarks.Bookmark, currentPath stri
 	return nil
 }
 
-// createBookmarkFile creates a markdown file for a bookmark
-func (p *Processor) createBookmarkFile(bookmark bookmarks.Bookmark, currentPath string) error {
+// notePath returns the folder for notes of bookmarks in currentPath, moving
+// bookmarks directly in the sync root into the loose bookmarks folder
+func (p *Processor) notePath(currentPath string) (string, error) {
+	if currentPath != "" || p.looseDir == "" {
+		return currentPath, nil
+	}
+
+	if err := p.output.MkdirAll(p.looseDir); err != nil {
+		return "", fmt.Errorf("failed to create directory %s: %w", p.looseDir, err)
+	}
+	return p.looseDir, nil
+}
+
+// processNote creates the note for a planned bookmark
+func (p *Processor) processNote(note plannedNote) {
+	bookmark := note.bookmark
+	note.filename = p.noteFileName(bookmark, note.path, note.filename)
+
+	var filePath string
+	var err error
+	planned := len(p.changes)
+	page, isFragment := p.fragmentPage(bookmark)
+	if isFragment {
+		filePath, err = p.createFragmentFile(bookmark, page, note.path, note.filename)
+	} else {
+		filePath, err = p.createBookmarkFile(bookmark, note.path, note.filename, note.paths, note.aliases())
+	}
+	if p.dryRun != "" && len(p.changes) > planned {
+		p.describeChanges(planned, bookmark, ReasonNew)
+		if _, ok, _ := p.readOverride(bookmark); !isFragment && !ok {
+			p.planFetch(&p.changes[planned], false)
+		}
+	}
+	category := web.CategoryOf(err)
+	if err != nil && category.Transient() {
+		// Leave the bookmark for the next run
+		slog.Warn("deferring bookmark",
+			"title", bookmark.Title,
+			"category", category,
+			"error", err)
+		p.summary.Deferred++
+		p.summary.Problems = append(p.summary.Problems, Problem{Bookmark: bookmark, Reason: err.Error(), Category: category, Deferred: true})
+		return
+	}
+	if err != nil {
+		slog.Error("failed to create bookmark file",
+			"title", bookmark.Title,
+			"category", category,
+			"error", err)
+		p.summary.Failed++
+		p.summary.Problems = append(p.summary.Problems, Problem{Bookmark: bookmark, Reason: err.Error(), Category: category})
+		return
+	}
+	entry := CacheEntry{
+		Bookmark:      bookmark,
+		File:          filePath,
+		HasScreenshot: !isFragment && p.embedsScreenshot(bookmark.URI),
+	}
+	p.cache[bookmark.ID] = entry
+	p.noteFiles[strings.ToLower(filePath)] = bookmark.ID
+	for _, duplicate := range note.duplicates {
+		if p.dedupeStubs {
+			p.createDuplicateStub(duplicate.bookmark, duplicate.path, duplicate.filename, entry)
+		} else {
+			p.cache[duplicate.bookmark.ID] = CacheEntry{Bookmark: duplicate.bookmark, File: filePath}
+		}
+	}
+	addPageNote(p.pages, entry)
+	p.summary.Created++
+	p.checkpoint(bookmark.ID)
+
+	if p.hooks != nil {
+		if err := p.hooks.PostCreate(filePath, bookmark); err != nil {
+			slog.Error("post-create hook failed",
+				"title", bookmark.Title,
+				"error", err)
+		}
+	}
+}
+
+// resolveNames assigns file names to bookmarks and directory names to folders
+// sharing a parent, so that a folder and a bookmark never end up with the same name
+func (p *Processor) resolveNames(children []bookmarks.Bookmark) []string {
+	names := make([]string, len(children))
+	folders := make(map[string]bool)
+	files := make(map[string]bool)
+
+	for i, child := range children {
+		switch child.Type {
+		case bookmarks.TypeBookmark:
+			names[i] = sanitizeFilename(child.Title, child.URI, p.linkSafeNames)
+			files[collisionKey(child, names[i])] = true
+		case bookmarks.TypeFolder:
+			names[i] = child.Title
+			if p.linkSafeNames {
+				names[i] = replaceReserved(names[i])
+			}
+			folders[collisionKey(child, names[i])] = true
+		}
+	}
+
+	for i, child := range children {
+		name := collisionKey(child, names[i])
+		if !folders[name] || !files[name] {
+			continue
+		}
+
+		switch {
+		case child.Type == bookmarks.TypeBookmark && p.nameCollision != CollisionSuffixFolder:
+			names[i] = strings.TrimSuffix(names[i], ".md") + " (bookmark).md"
+		case child.Type == bookmarks.TypeFolder && p.nameCollision == CollisionSuffixFolder:
+			names[i] = names[i] + " (folder)"
+		default:
+			continue
+		}
+
+		slog.Warn("folder and bookmark share a name",
+			"name", child.Title,
+			"renamed", names[i])
+	}
+
+	return names
+}
+
+// collisionKey returns the name a folder or bookmark file is shown with,
+// which is the file name without its extension for notes
+func collisionKey(child bookmarks.Bookmark, name string) string {
+	if child.Type == bookmarks.TypeBookmark {
+		name = strings.TrimSuffix(name, ".md")
+	}
+	return strings.ToLower(name)
+}
+
+// Summary returns counts of processed bookmarks
+func (p *Processor) Summary() Summary {
+	s := p.summary
+	s.UnreadReadingTime += p.cache.UnreadReadingTime()
+	return s
+}
+
+// createBookmarkFile creates a markdown file for a bookmark and returns its path.
+// paths lists all folders and aliases the titles of duplicates of a
+// deduplicated bookmark.
+func (p *Processor) createBookmarkFile(bookmark bookmarks.Bookmark, currentPath string, filename string, paths []string, aliases []string) (string, error) {
 	slog.Info("creating markdown file",
 		"title", bookmark.Title,
 		"url", bookmark.URI,
 		"path", currentPath)
 
-	// Get content
-	content, err := p.contentService.FetchContent(bookmark.URI)
+	tags := []string{"bookmark"}
+	if bookmark.Deleted {
+		tags = append(tags, "deleted")
+	}
+
+	// Get content, preferring a hand-authored override
+	content, ok, err := p.readOverride(bookmark)
 	if err != nil {
-		return fmt.Errorf("failed to fetch content: %w", err)
+		return "", err
+	}
+	if ok {
+		slog.Info("using content override", "url", bookmark.URI)
+	} else if p.dryRun != DryRunPlan {
+		content, err = p.contentService.FetchContent(bookmark.URI)
+	}
+
+	if errors.Is(err, web.ErrBinaryContent) {
+		// Write a link-only note instead of binary garbage
+		slog.Warn("binary content, writing link-only note", "url", bookmark.URI)
+		content = fmt.Sprintf("[%s](%s)", bookmark.Title, bookmark.URI)
+		tags = append(tags, "binary")
+	} else if errors.Is(err, web.ErrContentTooLarge) {
+		slog.Warn("content too large, writing link-only note", "url", bookmark.URI, "error", err)
+		content = fmt.Sprintf("[%s](%s)", bookmark.Title, bookmark.URI)
+		tags = append(tags, "oversized")
+	} else if err != nil {
+		return "", fmt.Errorf("failed to fetch content: %w", err)
+	} else if !ok && isPaywalled(bookmark.URI, content, p.paywallDomains) {
+		slog.Warn("content looks paywalled", "url", bookmark.URI)
+		tags = append(tags, "paywalled")
+	} else if p.expandLists != ExpandOff {
+		if links := readingListLinks(content, bookmark.URI); links != nil {
+			content += "\n" + p.expandReadingList(bookmark, currentPath, filename, links)
+		}
 	}
 
 	// Generate frontmatter
+	added, suspect := p.addedTime(bookmark)
+	if suspect {
+		slog.Warn("implausible bookmark date, corrected", "title", bookmark.Title, "added_unix", bookmark.AddedUnix, "date", added.Format("2006-01-02"))
+	}
 	frontmatter := Frontmatter{
-		CreatedAt: time.Unix(bookmark.AddedUnix, 0).Format("2006-01-02"),
-		Path:      currentPath,
-		URL:       bookmark.URI,
-		ID:        bookmark.ID,
-		Title:     bookmark.Title,
-		Tags:      []string{"bookmark"},
+		CreatedAt:   added.Format("2006-01-02"),
+		DateSuspect: suspect,
+		Path:        currentPath,
+		URL:         bookmark.URI,
+		ID:          bookmark.ID,
+		Title:       bookmark.Title,
+		Keyword:     bookmark.Keyword,
+		Status:      p.newNoteStatus(),
+		Pinned:      p.isPinned(bookmark.Tags),
+		ReadAt:      p.readDates[bookmark.ID],
+		Tags:        p.mergeTags(tags, bookmark.Tags),
+	}
+	if len(paths) > 1 {
+		frontmatter.Paths = paths
+	}
+	if !p.dedupeStubs {
+		frontmatter.Aliases = aliases
+	}
+	if p.slugs != nil {
+		frontmatter.Slug = uniqueSlug(bookmark.Title, p.slugs)
+	}
+	if p.excerpt && !slices.Contains(tags, "binary") {
+		frontmatter.Excerpt = excerpt(content)
+	}
+	if p.summarizer != nil && !slices.Contains(tags, "binary") && !slices.Contains(tags, "oversized") &&
+		web.SourceOf(bookmark.URI) != web.SourceYouTube && p.dryRun != DryRunPlan {
+		// YouTube notes only embed the player, there is nothing to summarize
+		description, err := p.summarizer.SummarizeMarkdown(content)
+		if err != nil {
+			slog.Warn("failed to summarize content", "url", bookmark.URI, "category", web.CategoryOf(err), "error", err)
+		}
+		frontmatter.Description = description
+	}
+	if p.tagger != nil && !slices.Contains(tags, "binary") && !slices.Contains(tags, "oversized") &&
+		web.SourceOf(bookmark.URI) != web.SourceYouTube && p.dryRun != DryRunPlan {
+		suggested, err := p.tagger.SuggestTags(content)
+		if err != nil {
+			slog.Warn("failed to suggest tags", "url", bookmark.URI, "category", web.CategoryOf(err), "error", err)
+		}
+		frontmatter.Tags = append(frontmatter.Tags, topicTags(suggested, frontmatter.Tags)...)
+	}
+	if p.licenseMetadata && !slices.Contains(tags, "binary") && p.dryRun != DryRunPlan {
+		metadata, err := p.contentService.FetchMetadata(bookmark.URI)
+		if err != nil {
+			slog.Warn("failed to fetch license metadata", "url", bookmark.URI, "error", err)
+		}
+		frontmatter.License = metadata.License
+		frontmatter.NoArchive = metadata.NoArchive
+	}
+	if result, ok := p.screenshotResult(bookmark.URI); ok {
+		frontmatter.HTTPStatus = result.ResponseCode
+		frontmatter.Tags = append(frontmatter.Tags, techTags(result.Technologies)...)
 	}
 
-	markdownContent := fmt.Sprintf("%s\n%s\n", frontmatter.String(), content)
-	if p.screenshotService != nil {
-		// Get screenshot URL
-		screenshotURL := p.screenshotService.GetScreenshotURL(bookmark.URI)
+	markdownContent := frontmatter.String() + "\n" + p.renderBody(frontmatter, content)
 
-		// Create markdown content
-		markdownContent = fmt.Sprintf("%s\n![Screenshot](%s)\n%s\n",
-			frontmatter.String(),
-			screenshotURL,
-			content)
+	// Write file, unless another machine syncing the vault created it meanwhile
+	filePath := filepath.Join(currentPath, filename)
+	if id, ok := p.existingNoteID(filePath); ok && id == bookmark.ID {
+		slog.Info("note already created elsewhere", "file", filePath)
+		return filePath, nil
+	}
+	if err := p.output.WriteFile(filePath, []byte(markdownContent)); err != nil {
+		return "", fmt.Errorf("%w: failed to write file: %w", web.CategoryWriteFailed, err)
+	}
+	if frontmatter.ReadAt == "" {
+		p.summary.UnreadReadingTime += readingTime(content)
 	}
 
-	// Write file
-	filename := sanitizeFilename(bookmark.Title, bookmark.URI)
-	filePath := filepath.Join(p.outputDir, currentPath, filename)
-	if err := os.WriteFile(filePath, []byte(markdownContent), 0644); err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
+	return filePath, nil
+}
+
+// normalizeSynonyms slugifies aliases and canonical tags, so they match
+// slugified bookmark tags
+func normalizeSynonyms(synonyms map[string]string) map[string]string {
+	normalized := make(map[string]string, len(synonyms))
+	for alias, canonical := range synonyms {
+		normalized[slugify(alias)] = slugify(canonical)
 	}
+	return normalized
+}
 
-	return nil
+// mergeTags adds Firefox bookmark tags to tags, slugified so Obsidian accepts
+// them and with aliases replaced by their canonical tag
+func (p *Processor) mergeTags(tags []string, bookmarkTags []string) []string {
+	for _, tag := range bookmarkTags {
+		tag = slugify(tag)
+		if canonical, ok := p.tagSynonyms[tag]; ok {
+			tag = canonical
+		}
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// isGeneratedTag reports whether a tag is added by the processor rather than
+// taken from the bookmark
+func isGeneratedTag(tag string) bool {
+	return slices.Contains([]string{"bookmark", "deleted", "binary", "oversized", "paywalled", "duplicate", duplicateTag}, tag) ||
+		strings.HasPrefix(tag, "tech/") || strings.HasPrefix(tag, topicTagPrefix)
+}
+
+// topicTagPrefix sets tags suggested by the LLM apart from bookmark tags
+const topicTagPrefix = "topic/"
+
+// topicTags converts tags suggested by the LLM into topic/ tags, leaving out
+// generated tags and topics already among tags
+func topicTags(suggested []string, tags []string) []string {
+	var topics []string
+	for _, tag := range suggested {
+		if tag == "" || isGeneratedTag(tag) || slices.Contains(tags, tag) {
+			continue
+		}
+		if topic := topicTagPrefix + tag; !slices.Contains(topics, topic) {
+			topics = append(topics, topic)
+		}
+	}
+	return topics
+}
+
+// techTags converts technologies detected by the screenshot service into tech/ tags
+func techTags(technologies []string) []string {
+	var tags []string
+	for _, tech := range technologies {
+		if strings.TrimSpace(tech) == "" {
+			continue
+		}
+		tags = append(tags, "tech/"+slugify(tech))
+	}
+	return tags
+}
+
+// renderBody renders the generated part of a note below the frontmatter
+func (p *Processor) renderBody(frontmatter Frontmatter, content string) string {
+	var sb strings.Builder
+	if len(p.inlineFields) > 0 {
+		sb.WriteString(frontmatter.InlineString(p.inlineFields) + "\n")
+	}
+	if frontmatter.Fragment == "" && frontmatter.DuplicateOf == "" && p.embedsScreenshot(frontmatter.URL) {
+		// Get screenshot URL
+		screenshotURL := p.screenshotService.GetScreenshotURL(frontmatter.URL)
+		sb.WriteString(fmt.Sprintf("![Screenshot](%s)\n", screenshotURL))
+	}
+	sb.WriteString(content + "\n")
+	sb.WriteString(generatedEndMarker + "\n")
+	return sb.String()
+}
+
+// embedsScreenshot checks whether notes of a URL embed a screenshot
+func (p *Processor) embedsScreenshot(url string) bool {
+	if p.screenshotService == nil {
+		return false
+	}
+	if p.readyScreenshots {
+		_, ok := p.screenshotResult(url)
+		return ok
+	}
+	return true
+}
+
+// screenshotResult returns the screenshot taken of a URL
+func (p *Processor) screenshotResult(url string) (web.ScreenshotResult, bool) {
+	if p.screenshotService != nil {
+		url = p.screenshotService.Normalize(url)
+	}
+	result, ok := p.screenshots[url]
+	return result, ok
 }
 
 // shouldIgnoreFolder checks if a folder should be ignored
 func (p *Processor) shouldIgnoreFolder(name string) bool {
-	for _, ignored := range p.ignoredFolders {
+	return isIgnoredFolder(name, p.ignoredFolders)
+}
+
+// isIgnoredFolder checks if a folder name is in the ignore list
+func isIgnoredFolder(name string, ignoredFolders []string) bool {
+	for _, ignored := range ignoredFolders {
 		if strings.TrimSpace(ignored) == name {
 			return true
 		}
@@ -181,8 +1041,30 @@ func (p *Processor) shouldIgnoreFolder(name string) bool {
 	return false
 }
 
-// sanitizeFilename creates a safe filename from bookmark title and URL
-func sanitizeFilename(title string, url string) string {
+// IsIgnoredPath checks if a bookmark path, as yielded by Bookmark.All, lies in
+// an ignored folder. The first path element is the sync target and the last is
+// the bookmark itself, so only the folders in between are checked: an explicitly
+// requested target folder is always processed, even if its name is ignored.
+func IsIgnoredPath(path string, ignoredFolders []string) bool {
+	parts := strings.Split(path, "/")
+	if len(parts) <= 2 {
+		return false
+	}
+
+	for _, folder := range parts[1 : len(parts)-1] {
+		if isIgnoredFolder(folder, ignoredFolders) {
+			return true
+		}
+	}
+	return false
+}
+
+// obsidianReservedChars break Obsidian wikilinks and embeds when used in filenames
+var obsidianReservedChars = []string{"[", "]", "#", "^", "|"}
+
+// sanitizeFilename creates a safe filename from bookmark title and URL.
+// With linkSafe set, characters reserved by Obsidian links are replaced too.
+func sanitizeFilename(title string, url string, linkSafe bool) string {
 	// Extract domain from URL
 	domain := extractDomain(url)
 
@@ -191,6 +1073,9 @@ func sanitizeFilename(title string, url string) string {
 	for _, char := range invalid {
 		title = strings.ReplaceAll(title, char, " ")
 	}
+	if linkSafe {
+		title = replaceReserved(title)
+	}
 
 	// Clean up spaces
 	title = strings.Join(strings.Fields(title), " ")
@@ -202,6 +1087,39 @@ func sanitizeFilename(title string, url string) string {
 	return title + ".md"
 }
 
+// replaceReserved replaces characters reserved by Obsidian links with spaces
+func replaceReserved(name string) string {
+	for _, char := range obsidianReservedChars {
+		name = strings.ReplaceAll(name, char, " ")
+	}
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// wikilink creates an Obsidian wikilink to a note file, escaping the alias
+func wikilink(file string, alias string) string {
+	target := filepath.ToSlash(strings.TrimSuffix(file, ".md"))
+	if alias == "" {
+		return fmt.Sprintf("[[%s]]", target)
+	}
+
+	alias = strings.NewReplacer("|", "\\|", "[", "(", "]", ")").Replace(alias)
+	return fmt.Sprintf("[[%s|%s]]", target, alias)
+}
+
+// markdownLink returns a markdown link, escaping brackets in the text and
+// characters in the URL that would end the link
+func markdownLink(text string, url string) string {
+	text = strings.NewReplacer("\\", "\\\\", "[", "\\[", "]", "\\]").Replace(singleLine(text))
+	url = strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29", "<", "%3C", ">", "%3E").Replace(singleLine(url))
+	return fmt.Sprintf("[%s](%s)", text, url)
+}
+
+// singleLine joins the lines of s and collapses runs of white space, so s
+// fits into a list item or table cell
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
 // extractDomain extracts domain from URL
 func extractDomain(url string) string {
 	url = strings.TrimPrefix(strings.TrimPrefix(url, "https://"), "http://")
@@ -217,30 +1135,45 @@ func extractDomain(url string) string {
 func (p *Processor) CreateYearIndexes(bookmarks iter.Seq[*bookmarks.Bookmark]) error {
 	slog.Info("creating year indexes")
 
-	// Collect years from bookmarks
-	years := make(map[string]bool)
+	// Collect bookmark IDs by year, grouping corrected dates as unknown
+	years := make(map[string][]string)
 	for bookmark := range bookmarks {
-		year := time.Unix(bookmark.AddedUnix, 0).Format("2006")
-		years[year] = true
+		added, suspect := p.addedTime(*bookmark)
+		year := added.Format("2006")
+		if suspect {
+			year = unknownYearIndex
+		}
+		years[year] = append(years[year], bookmark.ID)
 	}
 
 	// Create index for each year
-	for year := range years {
+	for _, year := range slices.Sorted(maps.Keys(years)) {
 		mdStart := "```dataview"
 		mdEnd := "```"
+		where := fmt.Sprintf(`dateformat(created_at, "yyyy") = "%s" AND !date_suspect`, year)
+		if year == unknownYearIndex {
+			where = "date_suspect"
+		}
+		columns := `path, url, dateformat(created_at, "dd.MM") as "date"`
+		stats := ""
+		if p.readStats {
+			columns += `, read_at as "read"`
+			read, total := p.readCounts(years[year])
+			stats = fmt.Sprintf("Read: %d of %d\n\n", read, total)
+		}
 		content := fmt.Sprintf(`---
 cssclasses: ["line3"]
 ---
-%s
-TABLE path, url, dateformat(created_at, "dd.MM") as "date"
+%s%s
+TABLE %s
 FROM #bookmark
-WHERE dateformat(created_at, "yyyy") = "%s"
-SORT created_at DESC
+WHERE %s
+SORT pinned DESC, created_at DESC
 %s
-`, mdStart, year, mdEnd)
+`, stats, mdStart, columns, where, mdEnd)
 
-		indexPath := filepath.Join(p.outputDir, fmt.Sprintf("%s.md", year))
-		if err := os.WriteFile(indexPath, []byte(content), 0644); err != nil {
+		indexPath := fmt.Sprintf("%s.md", year)
+		if err := p.output.WriteFile(indexPath, []byte(content)); err != nil {
 			return fmt.Errorf("failed to write year index %s: %w", year, err)
 		}
 		slog.Debug("wrote year index", "year", year)
@@ -0,0 +1,98 @@
+package markdown
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/web"
+)
+
+func TestDeferWhileConverterUnavailable(t *testing.T) {
+	breaker := web.NewCircuitBreaker("converter", 1, time.Minute, time.Hour)
+	breaker.Failure()
+
+	dir := t.TempDir()
+	cache, err := BuildCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewProcessor(ProcessorOptions{OutputDir: dir}, newTestContentServiceWith(t, web.FetchOptions{Breaker: breaker}), nil, cache)
+
+	tree := testFolder("toolbar",
+		testBookmark("a", "First", "https://example.com/first"),
+		testBookmark("b", "Second", "https://example.com/second"),
+	)
+	if err := p.ProcessBookmarks(tree, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	got := p.Summary()
+	if got.Created != 0 || got.Failed != 0 || got.Deferred != 2 {
+		t.Errorf("got summary %+v, want both bookmarks deferred", got)
+	}
+	for _, problem := range got.Problems {
+		if !problem.Deferred {
+			t.Errorf("got problem %+v, want it deferred", problem)
+		}
+	}
+	if exists(dir, "example.com - First.md") {
+		t.Error("note of a deferred bookmark was written")
+	}
+}
+
+func TestIncludeDeleted(t *testing.T) {
+	deleted := testBookmark("b", "Deleted", "https://example.com/deleted")
+	deleted.Deleted = true
+	tree := testFolder("toolbar", testBookmark("a", "Kept", "https://example.com/kept"), deleted)
+
+	dir := t.TempDir()
+	p := newTestProcessor(t, dir, ProcessorOptions{})
+	if err := p.ProcessBookmarks(tree, ""); err != nil {
+		t.Fatal(err)
+	}
+	if exists(dir, "example.com - Deleted.md") {
+		t.Error("note was written for a deleted bookmark")
+	}
+	if note := readFile(t, dir, "example.com - Kept.md"); strings.Contains(note, "deleted") {
+		t.Errorf("kept bookmark is tagged deleted:\n%s", note)
+	}
+
+	p = newTestProcessor(t, dir, ProcessorOptions{IncludeDeleted: true})
+	if err := p.ProcessBookmarks(tree, ""); err != nil {
+		t.Fatal(err)
+	}
+	if tags := parseNote(t, dir, "example.com - Deleted.md").Tags; !slices.Equal(tags, []string{"bookmark", "deleted"}) {
+		t.Errorf("got tags %q for a deleted bookmark", tags)
+	}
+}
+
+func TestLooseDir(t *testing.T) {
+	tree := testFolder("toolbar",
+		testBookmark("a", "Loose", "https://example.com/loose"),
+		testFolder("Reading", testBookmark("b", "Filed", "https://example.com/filed")),
+	)
+
+	for looseDir, want := range map[string]string{
+		"_inbox": "_inbox/example.com - Loose.md",
+		"":       "example.com - Loose.md",
+	} {
+		dir := t.TempDir()
+		p := newTestProcessor(t, dir, ProcessorOptions{LooseDir: looseDir})
+		if err := p.ProcessBookmarks(tree, ""); err != nil {
+			t.Fatal(err)
+		}
+
+		if !exists(dir, want) {
+			t.Errorf("loose dir %q: %s was not written", looseDir, want)
+		}
+		if got := p.cache["a"].File; got != want {
+			t.Errorf("loose dir %q: got cached file %q, want %q", looseDir, got, want)
+		}
+		// Bookmarks in folders keep their place
+		if !exists(dir, "Reading/example.com - Filed.md") {
+			t.Errorf("loose dir %q: filed bookmark was moved", looseDir)
+		}
+	}
+}
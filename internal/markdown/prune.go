@@ -0,0 +1,153 @@
+package markdown
+
+import (
+	"fmt"
+	"iter"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/web"
+)
+
+// archiveDir is the folder below the output directory that notes of removed
+// bookmarks are moved into
+const archiveDir = "_archive"
+
+// Modes for notes of bookmarks removed from the browser
+const (
+	PruneDelete  = "delete"
+	PruneArchive = "archive"
+)
+
+// PruneNotes deletes notes whose bookmark is no longer among current, or
+// with PruneArchive moves them into the archive folder. current are the
+// bookmarks of the synced folders including ignored ones, so deleted
+// bookmarks are only pruned when they are left out of it. Only notes below
+// paths, the output paths of the synced folders, are considered, and notes
+// in ignored folders are kept. Notes without an id in frontmatter are never
+// touched, and notes with a remaining duplicate bookmark are handed over to
+// it instead. Dry runs only plan the changes. It returns the pruned files.
+func (p *Processor) PruneNotes(current iter.Seq[*bookmarks.Bookmark], paths []string, mode string) ([]string, error) {
+	present := make(map[string]bool)
+	for bookmark := range current {
+		present[bookmark.ID] = true
+	}
+	if len(present) == 0 {
+		// An empty tree is more likely a failed fetch than an empty browser
+		return nil, fmt.Errorf("refusing to prune notes, no bookmarks found")
+	}
+
+	var pruned []string
+	for _, entry := range p.cache.sorted() {
+		if entry.File == "" || entry.DerivedID || present[entry.ID] || present[listParentID(entry.ID)] {
+			continue
+		}
+		if !p.inSyncedTree(entry.File, paths) {
+			continue
+		}
+
+		if entry.DuplicateOf == "" {
+			promoted, err := p.promoteDuplicate(entry, present)
+			if err != nil {
+				return pruned, fmt.Errorf("failed to promote duplicate of %s: %w", entry.File, err)
+			}
+			if promoted {
+				continue
+			}
+		}
+
+		if p.plan != nil && !p.plan.removes(pruneAction(mode), entry.File) {
+			slog.Warn("keeping note, pruning it is not in the plan", "file", entry.File)
+			continue
+		}
+
+		if p.dryRun != "" {
+			p.planChange(noteChange(pruneAction(mode), entry, ReasonRemoved))
+			pruned = append(pruned, entry.File)
+			continue
+		}
+
+		if err := p.pruneNote(entry.File, mode); err != nil {
+			return pruned, fmt.Errorf("failed to prune %s: %w", entry.File, err)
+		}
+		slog.Info("pruned note", "file", entry.File, "id", entry.ID, "mode", mode)
+		pruned = append(pruned, entry.File)
+		delete(p.cache, entry.ID)
+	}
+
+	if p.dryRun == "" {
+		p.summary.Pruned = len(pruned)
+	}
+	return pruned, nil
+}
+
+// pruneAction returns the dry run change action of a prune mode
+func pruneAction(mode string) string {
+	if mode == PruneArchive {
+		return ChangeArchive
+	}
+	return ChangeDelete
+}
+
+// pruneNote deletes a note or moves it into the archive folder
+func (p *Processor) pruneNote(file string, mode string) error {
+	src := filepath.Join(p.outputDir, file)
+	if mode != PruneArchive {
+		return os.Remove(src)
+	}
+
+	dest := filepath.Join(p.outputDir, archiveDir, file)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	return os.Rename(src, dest)
+}
+
+// inSyncedTree checks whether a note lies below one of the output paths of
+// the synced folders and outside of ignored folders, which may still hold
+// notes from before they were ignored. An empty path is the output root.
+func (p *Processor) inSyncedTree(file string, paths []string) bool {
+	dir := filepath.ToSlash(filepath.Dir(file))
+	if dir == "." {
+		dir = ""
+	}
+
+	for _, path := range paths {
+		path = strings.Trim(filepath.ToSlash(path), "/")
+		if path != "" && dir != path && !strings.HasPrefix(dir, path+"/") {
+			continue
+		}
+
+		rel := strings.Trim(strings.TrimPrefix(dir, path), "/")
+		for _, folder := range strings.Split(rel, "/") {
+			if folder != "" && p.shouldIgnoreFolder(folder) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// listLinkKeyLength is the length of the URL key that link notes of reading
+// lists append to the ID of the list bookmark
+const listLinkKeyLength = 12
+
+// listLinkID returns the ID of the note for a link of a reading list
+func listLinkID(parentID string, url string) string {
+	return parentID + "-" + web.URLKey(url)[:listLinkKeyLength]
+}
+
+// listParentID returns the ID of the reading list bookmark a link note was
+// created for. Bookmark IDs and URL keys can both contain "-", so the key is
+// cut by its length.
+func listParentID(id string) string {
+	i := len(id) - listLinkKeyLength - 1
+	if i <= 0 || id[i] != '-' {
+		return ""
+	}
+	return id[:i]
+}
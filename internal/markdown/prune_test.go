@@ -0,0 +1,94 @@
+package markdown
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+)
+
+func bookmarkSeq(ids ...string) func(func(*bookmarks.Bookmark) bool) {
+	return func(yield func(*bookmarks.Bookmark) bool) {
+		for _, id := range ids {
+			if !yield(&bookmarks.Bookmark{ID: id, Type: bookmarks.TypeBookmark}) {
+				return
+			}
+		}
+	}
+}
+
+func TestPruneNotes(t *testing.T) {
+	dir := t.TempDir()
+	notes := map[string]string{
+		"work/Kept.md":              "kept-id",
+		"work/Removed.md":           "removed-id",
+		"work/old/Ignored.md":       "ignored-id",
+		"other/Outside.md":          "outside-id",
+		"work/List (links)/Link.md": listLinkID("abc-def-123", "https://example.com/link"),
+		"work/List (links)/Gone.md": listLinkID("gone-list-1", "https://example.com/gone"),
+		"work/Reading list.md":      "abc-def-123",
+	}
+	for file, id := range notes {
+		writeNote(t, dir, file, Frontmatter{Title: file, URL: "https://example.com/" + id, ID: id}, "content")
+	}
+
+	p := newTestProcessor(t, dir, ProcessorOptions{IgnoredFolders: []string{"old"}})
+	pruned, err := p.PruneNotes(bookmarkSeq("kept-id", "abc-def-123"), []string{"work"}, PruneDelete)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slices.Sort(pruned)
+	want := []string{"work/List (links)/Gone.md", "work/Removed.md"}
+	if !slices.Equal(pruned, want) {
+		t.Errorf("pruned %v, want %v", pruned, want)
+	}
+	for _, file := range want {
+		if exists(dir, file) {
+			t.Errorf("%s was not deleted", file)
+		}
+	}
+	for _, file := range []string{"work/Kept.md", "work/old/Ignored.md", "other/Outside.md", "work/List (links)/Link.md", "work/Reading list.md"} {
+		if !exists(dir, file) {
+			t.Errorf("%s was deleted", file)
+		}
+	}
+}
+
+func TestPruneNotesArchive(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "Removed.md", Frontmatter{Title: "Removed", URL: "https://example.com/", ID: "removed-id"}, "content")
+
+	p := newTestProcessor(t, dir, ProcessorOptions{})
+	if _, err := p.PruneNotes(bookmarkSeq("other-id"), []string{""}, PruneArchive); err != nil {
+		t.Fatal(err)
+	}
+	if exists(dir, "Removed.md") || !exists(dir, archiveDir+"/Removed.md") {
+		t.Error("note was not moved into the archive")
+	}
+}
+
+func TestPruneNotesRefusesEmptyTree(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "Note.md", Frontmatter{Title: "Note", URL: "https://example.com/", ID: "id"}, "content")
+
+	p := newTestProcessor(t, dir, ProcessorOptions{})
+	if _, err := p.PruneNotes(bookmarkSeq(), []string{""}, PruneDelete); err == nil {
+		t.Error("pruning an empty tree succeeded")
+	}
+	if !exists(dir, "Note.md") {
+		t.Error("note was deleted")
+	}
+}
+
+func TestListParentID(t *testing.T) {
+	for _, parent := range []string{"abc", "a-b-c", "toolbar_____", "url-0123456789ab"} {
+		id := listLinkID(parent, "https://example.com/"+parent)
+		if got := listParentID(id); got != parent {
+			t.Errorf("listParentID(%q) = %q, want %q", id, got, parent)
+		}
+	}
+	if got := listParentID("plain-guid-with-dashes"); got != "" {
+		t.Errorf("listParentID of a bookmark ID = %q", got)
+	}
+}
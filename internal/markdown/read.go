@@ -0,0 +1,98 @@
+package markdown
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// wordsPerMinute is the reading speed used to estimate reading time
+const wordsPerMinute = 200
+
+// readingTime estimates the time to read a note body, user additions
+// included
+func readingTime(body string) time.Duration {
+	return wordsReadingTime(len(strings.Fields(body)))
+}
+
+// wordsReadingTime estimates the time to read a number of words
+func wordsReadingTime(words int) time.Duration {
+	if words == 0 {
+		return 0
+	}
+	minutes := (words + wordsPerMinute - 1) / wordsPerMinute
+	return time.Duration(minutes) * time.Minute
+}
+
+// ReadDates returns the user-owned read_at dates of notes keyed by bookmark
+// ID, so notes regenerated from scratch keep them
+func (c Cache) ReadDates() map[string]string {
+	dates := make(map[string]string)
+	for id, entry := range c {
+		if entry.ReadAt != "" {
+			dates[id] = entry.ReadAt
+		}
+	}
+	return dates
+}
+
+// UnreadReadingTime sums the estimated reading time of notes without read_at
+func (c Cache) UnreadReadingTime() time.Duration {
+	var total time.Duration
+	for _, entry := range c {
+		if entry.ReadAt == "" {
+			total += entry.ReadingTime
+		}
+	}
+	return total
+}
+
+// Unread returns the notes without read_at of bookmarks added before
+// olderThan, oldest first
+func (c Cache) Unread(olderThan time.Time) UnreadList {
+	var entries UnreadList
+	for _, entry := range c.sorted() {
+		if entry.ReadAt == "" && entry.AddedUnix != 0 && entry.AddedUnix < olderThan.Unix() {
+			entries = append(entries, entry)
+		}
+	}
+	slices.SortStableFunc(entries, func(a, b CacheEntry) int {
+		return cmp.Compare(a.AddedUnix, b.AddedUnix)
+	})
+	return entries
+}
+
+// UnreadList are notes waiting to be read
+type UnreadList []CacheEntry
+
+// String renders the list as a table for terminal output
+func (l UnreadList) String() string {
+	var sb strings.Builder
+	var total time.Duration
+	for _, entry := range l {
+		total += entry.ReadingTime
+	}
+	sb.WriteString(fmt.Sprintf("Unread bookmarks: %d (reading time %s)\n", len(l), total))
+
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	for _, entry := range l {
+		added := time.Unix(entry.AddedUnix, 0).UTC().Format("2006-01-02")
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", added, entry.ReadingTime, entry.File, entry.URI)
+	}
+	w.Flush()
+
+	return sb.String()
+}
+
+// readCounts counts the read notes and all notes of the bookmarks with ids
+func (p *Processor) readCounts(ids []string) (read int, total int) {
+	for _, id := range ids {
+		if p.cache[id].ReadAt != "" {
+			read++
+		}
+	}
+	return read, len(ids)
+}
@@ -0,0 +1,96 @@
+package markdown
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/web"
+)
+
+// RefreshNotes refetches the content of notes whose bookmark ID or URL is in
+// refs, or of all notes with all set, bypassing the content and LLM caches.
+// The generated part of each note is replaced, frontmatter and anything added
+// below it are kept. With RecaptureScreenshots, their screenshots are taken
+// again and compared with the previous ones.
+func (p *Processor) RefreshNotes(refs []string, all bool) {
+	wanted := make(map[string]bool)
+	for _, ref := range refs {
+		wanted[p.refreshKey(ref)] = true
+	}
+
+	matched := make(map[string]bool)
+	var refreshed, failed int
+	var refreshedEntries []CacheEntry
+	for _, entry := range p.cache.sorted() {
+		if p.ctx.Err() != nil {
+			break
+		}
+		if entry.File == "" {
+			continue
+		}
+		if _, fragment := splitFragment(entry.URI); fragment != "" && p.fragmentMode != "" && p.fragmentMode != FragmentFull {
+			// Fragment notes are derived from their page note
+			continue
+		}
+
+		idKey, urlKey := p.refreshKey(entry.ID), p.refreshKey(entry.URI)
+		if !all && !wanted[idKey] && !wanted[urlKey] {
+			continue
+		}
+		matched[idKey], matched[urlKey] = true, true
+
+		if p.dryRun == DryRunPlan {
+			change := noteChange(ChangeUpdate, entry, ReasonRefresh)
+			p.planFetch(&change, true)
+			p.planChange(change)
+			continue
+		}
+
+		planned := len(p.changes)
+		ok, err := p.refetchNote(entry, p.contentService.RefreshContent)
+		p.describeChanges(planned, entry.Bookmark, ReasonRefresh)
+		if err != nil {
+			slog.Warn("failed to refresh note", "file", entry.File, "error", err)
+			failed++
+			continue
+		}
+		if !ok {
+			slog.Warn("refreshed content is empty, keeping note", "file", entry.File)
+			failed++
+			continue
+		}
+
+		entry.Degenerate = false
+		p.cache[entry.ID] = entry
+		refreshedEntries = append(refreshedEntries, entry)
+		refreshed++
+	}
+
+	for _, ref := range refs {
+		if !matched[p.refreshKey(ref)] {
+			slog.Warn("no note to refresh", "bookmark", ref)
+		}
+	}
+
+	p.summary.Refreshed = refreshed
+	slog.Info("refreshed notes", "refreshed", refreshed, "failed", failed)
+
+	// A dry run doesn't ask for new screenshots
+	if p.dryRun == "" && p.ctx.Err() == nil {
+		p.recaptureScreenshots(refreshedEntries)
+	}
+}
+
+// refreshKey normalizes a bookmark ID or URL for matching like
+// web.NormalizeURL, ignoring the scheme, which may be left out
+func (p *Processor) refreshKey(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if !strings.Contains(ref, "://") && strings.ContainsAny(ref, "./") {
+		ref = "https://" + ref
+	}
+	key := web.NormalizeURL(ref, p.trackingParams)
+	if _, rest, ok := strings.Cut(key, "://"); ok {
+		return rest
+	}
+	return key
+}
@@ -0,0 +1,20 @@
+package markdown
+
+import "testing"
+
+func TestRefreshKey(t *testing.T) {
+	p := newTestProcessor(t, t.TempDir(), ProcessorOptions{})
+	want := p.refreshKey("https://example.com/page")
+	for _, ref := range []string{
+		"example.com/page",
+		"http://EXAMPLE.com/page/",
+		"https://example.com/page?utm_source=feed",
+	} {
+		if got := p.refreshKey(ref); got != want {
+			t.Errorf("%s: got key %q, want %q", ref, got, want)
+		}
+	}
+	if got := p.refreshKey(" abc-def-123 "); got != "abc-def-123" {
+		t.Errorf("got key %q for a bookmark ID", got)
+	}
+}
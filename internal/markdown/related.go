@@ -0,0 +1,235 @@
+package markdown
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/web"
+)
+
+// Modes for relating notes to each other
+const (
+	// RelatedURLs relates notes whose content links to the URL of another note
+	RelatedURLs = "urls"
+	// RelatedTitles also relates the only two notes sharing a rare title word
+	RelatedTitles = "titles"
+)
+
+// relatedMarker starts the generated footer listing related notes
+const relatedMarker = "%% related notes %%"
+
+// minTitleTokenLength is the length of title words considered for relating
+// notes, shorter words are too common to mean anything
+const minTitleTokenLength = 5
+
+var contentURLRegex = regexp.MustCompile("https?://[^\\s<>\"'`()\\[\\]]+")
+
+// commonTitleWords are long title words that don't make two notes related
+var commonTitleWords = map[string]bool{
+	"about":         true,
+	"announcing":    true,
+	"introducing":   true,
+	"introduction":  true,
+	"github":        true,
+	"guide":         true,
+	"using":         true,
+	"where":         true,
+	"which":         true,
+	"would":         true,
+	"should":        true,
+	"there":         true,
+	"their":         true,
+	"these":         true,
+	"things":        true,
+	"without":       true,
+	"tutorial":      true,
+	"documentation": true,
+}
+
+// relatedNote is a note considered by LinkRelated
+type relatedNote struct {
+	entry     CacheEntry
+	rawMatter string
+	body      string
+}
+
+// LinkRelated links notes whose generated content mentions the URL of another
+// note, and with RelatedTitles notes that are the only two sharing a rare
+// title word. Relations are written both ways, as a footer at the end of the
+// generated content and as a related frontmatter list, and replace the ones
+// of the previous run.
+func (p *Processor) LinkRelated() error {
+	var notes []relatedNote
+	index := make(map[string]int)
+	for _, entry := range p.cache.sorted() {
+		if entry.File == "" || entry.DuplicateOf != "" {
+			continue
+		}
+		if _, fragment := splitFragment(entry.URI); fragment != "" {
+			continue
+		}
+
+		// Notes planned in a dry run don't exist yet
+		data, err := os.ReadFile(filepath.Join(p.outputDir, entry.File))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			slog.Warn("failed to read note for relating", "file", entry.File, "error", err)
+			continue
+		}
+		rawMatter, body, err := splitNote(string(data))
+		if err != nil || !strings.Contains(body, generatedEndMarker) {
+			continue
+		}
+
+		key := p.relationKey(entry.URI)
+		if _, ok := index[key]; !ok {
+			index[key] = len(notes)
+		}
+		notes = append(notes, relatedNote{entry: entry, rawMatter: rawMatter, body: body})
+	}
+
+	related := make([]map[int]bool, len(notes))
+	for i := range related {
+		related[i] = make(map[int]bool)
+	}
+	relate := func(a, b int) {
+		if a != b {
+			related[a][b] = true
+			related[b][a] = true
+		}
+	}
+
+	for i, note := range notes {
+		generated, _, _ := strings.Cut(note.body, generatedEndMarker)
+		generated, _, _ = strings.Cut(generated, relatedMarker)
+		for _, link := range contentURLRegex.FindAllString(generated, -1) {
+			link = strings.TrimRight(link, ".,;:!?")
+			if j, ok := index[p.relationKey(link)]; ok {
+				relate(i, j)
+			} else if j, ok := index[githubRepoKey(link)]; ok {
+				relate(i, j)
+			}
+		}
+	}
+
+	if p.related == RelatedTitles {
+		byToken := make(map[string][]int)
+		for i, note := range notes {
+			for _, token := range titleTokens(note.entry.Title) {
+				byToken[token] = append(byToken[token], i)
+			}
+		}
+		for _, ids := range byToken {
+			if len(ids) == 2 {
+				relate(ids[0], ids[1])
+			}
+		}
+	}
+
+	titles := make(map[string]string, len(notes))
+	for _, note := range notes {
+		titles[note.entry.File] = note.entry.Title
+	}
+
+	var updated int
+	for i, note := range notes {
+		var files []string
+		for j := range related[i] {
+			files = append(files, notes[j].entry.File)
+		}
+		slices.Sort(files)
+
+		content := relatedNoteContent(note, files, titles)
+		if content == note.rawMatter+"\n"+note.body {
+			continue
+		}
+		if err := p.output.WriteFile(note.entry.File, []byte(content)); err != nil {
+			return fmt.Errorf("failed to write note: %w", err)
+		}
+		updated++
+	}
+
+	slog.Info("linked related notes", "updated", updated)
+	return nil
+}
+
+// relatedNoteContent renders a note with its related notes replaced by the
+// ones in files, linked with their titles
+func relatedNoteContent(note relatedNote, files []string, titles map[string]string) string {
+	rawMatter := removeFrontmatterLine(note.rawMatter, "related")
+	generated, rest, _ := strings.Cut(note.body, generatedEndMarker)
+	generated, _, _ = strings.Cut(generated, relatedMarker)
+
+	if len(files) > 0 {
+		targets := make([]string, len(files))
+		links := make([]string, len(files))
+		for i, file := range files {
+			targets[i] = strconv.Quote(wikilink(file, ""))
+			links[i] = wikilink(file, titles[file])
+		}
+		rawMatter = setFrontmatterLine(note.rawMatter, "related", "["+strings.Join(targets, ", ")+"]")
+		generated += relatedMarker + "\nRelated: " + strings.Join(links, ", ") + "\n"
+	}
+
+	return rawMatter + "\n" + generated + generatedEndMarker + rest
+}
+
+// relationKey normalizes a URL for matching mentions of it. GitHub owners and
+// repositories are case-insensitive, so their paths are lowercased.
+func (p *Processor) relationKey(u string) string {
+	normalized := web.NormalizeURL(u, p.trackingParams)
+	parsed, err := url.Parse(normalized)
+	if err != nil || !isGitHubHost(parsed.Host) {
+		return normalized
+	}
+	return "https://github.com" + strings.TrimSuffix(strings.ToLower(parsed.Path), ".git")
+}
+
+// githubRepoKey returns the relation key of the repository a GitHub URL points
+// into, or an empty string for other URLs
+func githubRepoKey(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil || !isGitHubHost(parsed.Host) {
+		return ""
+	}
+	parts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return ""
+	}
+	repo := strings.TrimSuffix(strings.ToLower(parts[1]), ".git")
+	return "https://github.com/" + strings.ToLower(parts[0]) + "/" + repo
+}
+
+// isGitHubHost checks whether a host serves GitHub repositories
+func isGitHubHost(host string) bool {
+	host = strings.ToLower(host)
+	return host == "github.com" || host == "www.github.com"
+}
+
+// titleTokens returns the distinct long words of a title that may relate it
+// to another one
+func titleTokens(title string) []string {
+	var tokens []string
+	words := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		if utf8.RuneCountInString(word) < minTitleTokenLength || commonTitleWords[word] || slices.Contains(tokens, word) {
+			continue
+		}
+		tokens = append(tokens, word)
+	}
+	return tokens
+}
@@ -0,0 +1,93 @@
+package markdown
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/adrg/frontmatter"
+)
+
+// relatedOf parses the related frontmatter list of a note
+func relatedOf(t *testing.T, dir string, file string) []string {
+	t.Helper()
+	var matter struct {
+		Related []string `yaml:"related"`
+	}
+	if _, err := frontmatter.Parse(strings.NewReader(readFile(t, dir, file)), &matter); err != nil {
+		t.Fatalf("%s: %v", file, err)
+	}
+	return matter.Related
+}
+
+func TestLinkRelated(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "Go.md", Frontmatter{Title: "Go: the language", URL: "https://go.dev/", ID: "go"}, "See https://GitHub.com/golang/Go/issues/1 and https://example.com/tour/?utm_source=go.")
+	writeNote(t, dir, "Tour.md", Frontmatter{Title: `A "tour"`, URL: "https://example.com/tour", ID: "tour"}, "A tour of the language.")
+	writeNote(t, dir, "Repo.md", Frontmatter{Title: "golang/go", URL: "https://github.com/golang/go", ID: "repo"}, "The repository.")
+	writeNote(t, dir, "Other.md", Frontmatter{Title: "Other", URL: "https://example.org/", ID: "other"}, "Nothing related.")
+
+	p := newTestProcessor(t, dir, ProcessorOptions{Related: RelatedURLs})
+	if err := p.LinkRelated(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{
+		"Go.md":    {"[[Repo]]", "[[Tour]]"},
+		"Tour.md":  {"[[Go]]"},
+		"Repo.md":  {"[[Go]]"},
+		"Other.md": nil,
+	}
+	for file, related := range want {
+		if got := relatedOf(t, dir, file); !slices.Equal(got, related) {
+			t.Errorf("%s: got related %q, want %q", file, got, related)
+		}
+	}
+	if got := readFile(t, dir, "Tour.md"); !strings.Contains(got, relatedMarker+"\nRelated: [[Go|Go: the language]]\n"+generatedEndMarker) {
+		t.Errorf("footer missing:\n%s", got)
+	}
+	tour := readFile(t, dir, "Tour.md")
+	if matter := parseNote(t, dir, "Tour.md"); matter.Title != `A "tour"` || matter.ID != "tour" {
+		t.Errorf("frontmatter changed: %+v", matter)
+	}
+
+	// Linking again changes nothing
+	p = newTestProcessor(t, dir, ProcessorOptions{Related: RelatedURLs})
+	if err := p.LinkRelated(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, dir, "Tour.md"); got != tour {
+		t.Errorf("second run changed the note:\n%s", got)
+	}
+
+	// Relations that are gone are removed
+	writeNote(t, dir, "Go.md", Frontmatter{Title: "Go: the language", URL: "https://go.dev/", ID: "go"}, "No links anymore.")
+	p = newTestProcessor(t, dir, ProcessorOptions{Related: RelatedURLs})
+	if err := p.LinkRelated(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, dir, "Tour.md"); strings.Contains(got, "related") || strings.Contains(got, relatedMarker) {
+		t.Errorf("stale relation was kept:\n%s", got)
+	}
+}
+
+func TestLinkRelatedTitles(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "A.md", Frontmatter{Title: "Understanding Kubernetes operators", URL: "https://example.com/a", ID: "a"}, "Content")
+	writeNote(t, dir, "B.md", Frontmatter{Title: "Writing operators in Go", URL: "https://example.com/b", ID: "b"}, "Content")
+	writeNote(t, dir, "C.md", Frontmatter{Title: "Kubernetes networking guide", URL: "https://example.com/c", ID: "c"}, "Content")
+	writeNote(t, dir, "D.md", Frontmatter{Title: "Kubernetes storage", URL: "https://example.com/d", ID: "d"}, "Content")
+
+	p := newTestProcessor(t, dir, ProcessorOptions{Related: RelatedTitles})
+	if err := p.LinkRelated(); err != nil {
+		t.Fatal(err)
+	}
+
+	// "operators" is shared by two notes only, "kubernetes" by three
+	if got := relatedOf(t, dir, "A.md"); !slices.Equal(got, []string{"[[B]]"}) {
+		t.Errorf("got related %q, want [[B]]", got)
+	}
+	if got := relatedOf(t, dir, "C.md"); got != nil {
+		t.Errorf("got related %q for a common title word", got)
+	}
+}
@@ -0,0 +1,160 @@
+package markdown
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/adrg/frontmatter"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+	"gopkg.in/yaml.v2"
+)
+
+// retitleNote updates the title of an existing note whose bookmark was
+// renamed and appends the old title to previous_titles. Everything else is
+// kept, including frontmatter fields added by hand. With -rename-on-title-change
+// the note also moves to the file name derived from the new title within its
+// folder, unless the user renamed the file, like adopted clippings.
+func (p *Processor) retitleNote(entry CacheEntry, bookmark bookmarks.Bookmark, name string) error {
+	data, err := os.ReadFile(filepath.Join(p.outputDir, entry.File))
+	if err != nil {
+		return fmt.Errorf("failed to read note: %w", err)
+	}
+
+	rawMatter, body, err := splitNote(string(data))
+	if err != nil {
+		return err
+	}
+
+	var matter Frontmatter
+	if _, err := frontmatter.Parse(strings.NewReader(string(data)), &matter); err != nil {
+		return fmt.Errorf("failed to parse note: %w", err)
+	}
+	if entry.Title != "" && !slices.Contains(matter.PreviousTitles, entry.Title) {
+		rawMatter = setFrontmatterField(rawMatter, "previous_titles", append(matter.PreviousTitles, entry.Title))
+	}
+	note := setTitleLine(rawMatter, bookmark.Title) + "\n" + body
+
+	dir := filepath.Dir(entry.File)
+	if dir == "." {
+		dir = ""
+	}
+	file := entry.File
+	if p.renameOnTitle && p.hasGeneratedName(entry, dir) {
+		file = filepath.Join(dir, p.fitFileName(dir, name))
+	}
+	if file != entry.File && p.fileTaken(file, entry.ID) {
+		// Another file already has the name, so only the title changes
+		slog.Warn("note file name taken, keeping old name", "file", entry.File, "taken", file)
+		file = entry.File
+	}
+
+	if p.dryRun != "" && file != entry.File {
+		change := noteChange(ChangeRename, entry, ReasonRetitled)
+		change.To = file
+		p.planChange(change)
+	} else if err := p.output.WriteFile(file, []byte(note)); err != nil {
+		return fmt.Errorf("failed to write note: %w", err)
+	}
+
+	if file != entry.File && p.dryRun == "" {
+		if p.renameStubs {
+			stub := fmt.Sprintf("Renamed to %s\n", wikilink(file, bookmark.Title))
+			err = p.output.WriteFile(entry.File, []byte(stub))
+		} else {
+			err = p.output.Remove(entry.File)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to replace old note: %w", err)
+		}
+	}
+
+	slog.Info("updated note title", "title", bookmark.Title, "file", file, "previous", entry.File)
+
+	entry.Title = bookmark.Title
+	entry.File = file
+	p.cache[entry.ID] = entry
+	if page, _ := splitFragment(entry.URI); p.pages[page].ID == entry.ID {
+		p.pages[page] = entry
+	}
+	p.summary.Retitled++
+	return nil
+}
+
+// hasGeneratedName checks whether a note file is named after its title,
+// possibly with a suffix telling it apart from a folder or another note
+func (p *Processor) hasGeneratedName(entry CacheEntry, dir string) bool {
+	name := p.fitFileName(dir, sanitizeFilename(entry.Title, entry.URI, p.linkSafeNames))
+	stem := strings.TrimSuffix(name, ".md")
+	base := filepath.Base(entry.File)
+	if base == name || base == stem+" (bookmark).md" || base == stem+" ("+entry.ID+").md" {
+		return true
+	}
+	return len(entry.ID) > shortIDLength && base == stem+" ("+entry.ID[:shortIDLength]+").md"
+}
+
+// setTitleLine replaces the title line of raw frontmatter, adding it when
+// the note has none
+func setTitleLine(rawMatter string, title string) string {
+	return setFrontmatterLine(rawMatter, "title", yamlScalar(title))
+}
+
+// setFrontmatterLine replaces the lines of a field in raw frontmatter with a
+// single line, adding it when the note has none
+func setFrontmatterLine(rawMatter string, key string, value string) string {
+	return replaceField(rawMatter, key, key+": "+value)
+}
+
+// setFrontmatterField replaces the lines of a field in raw frontmatter with
+// the YAML encoding of value, which may span several lines
+func setFrontmatterField(rawMatter string, key string, value interface{}) string {
+	out, _ := yaml.Marshal(yaml.MapSlice{{Key: key, Value: value}})
+	return replaceField(rawMatter, key, strings.TrimSuffix(string(out), "\n"))
+}
+
+// replaceField replaces the lines of a field in raw frontmatter with field,
+// adding it when the note has none
+func replaceField(rawMatter string, key string, field string) string {
+	lines := strings.Split(rawMatter, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, key+":") {
+			end := fieldEnd(lines, i)
+			return strings.Join(slices.Replace(lines, i, end, field), "\n")
+		}
+	}
+
+	return "---\n" + field + "\n" + strings.TrimPrefix(rawMatter, "---\n")
+}
+
+// removeFrontmatterLine removes the lines of a field from raw frontmatter
+func removeFrontmatterLine(rawMatter string, key string) string {
+	lines := strings.Split(rawMatter, "\n")
+	for i := 0; i < len(lines); i++ {
+		if strings.HasPrefix(lines[i], key+":") {
+			lines = slices.Delete(lines, i, fieldEnd(lines, i))
+			i--
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// fieldEnd returns the index of the line after the field starting at line i,
+// skipping the items of block sequences and the lines of block scalars.
+// Blank lines belong to the field when more of its lines follow, like the
+// empty lines within a block scalar.
+func fieldEnd(lines []string, i int) int {
+	end := i + 1
+	for j := i + 1; j < len(lines); j++ {
+		if strings.TrimSpace(lines[j]) == "" {
+			continue
+		}
+		if !strings.HasPrefix(lines[j], " ") && !strings.HasPrefix(lines[j], "- ") {
+			break
+		}
+		end = j + 1
+	}
+	return end
+}
@@ -0,0 +1,128 @@
+package markdown
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/adrg/frontmatter"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/web"
+)
+
+// parseNote reads the frontmatter of a note
+func parseNote(t *testing.T, dir, file string) Frontmatter {
+	t.Helper()
+	var matter Frontmatter
+	if _, err := frontmatter.Parse(strings.NewReader(readFile(t, dir, file)), &matter); err != nil {
+		t.Fatal(err)
+	}
+	return matter
+}
+
+func TestRetitleKeepsFileName(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "example.com - First.md", Frontmatter{Title: "First", URL: "https://example.com/", ID: "id"}, "content")
+
+	for _, title := range []string{"Second", "Third: the end"} {
+		p := newTestProcessor(t, dir, ProcessorOptions{})
+		if err := p.ProcessBookmarks(testFolder("toolbar", testBookmark("id", title, "https://example.com/")), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if exists(dir, "example.com - Second.md") || exists(dir, "example.com - Third - the end.md") {
+		t.Error("note was renamed without -rename-on-title-change")
+	}
+	matter := parseNote(t, dir, "example.com - First.md")
+	if matter.Title != "Third: the end" {
+		t.Errorf("got title %q, want the new title", matter.Title)
+	}
+	if want := []string{"First", "Second"}; !slices.Equal(matter.PreviousTitles, want) {
+		t.Errorf("got previous titles %q, want %q", matter.PreviousTitles, want)
+	}
+	if !strings.Contains(readFile(t, dir, "example.com - First.md"), "content") {
+		t.Error("note content was not kept")
+	}
+}
+
+func TestRetitleRenamesFile(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "example.com - Old.md", Frontmatter{Title: "Old", URL: "https://example.com/", ID: "id"}, "content")
+	p := newTestProcessor(t, dir, ProcessorOptions{RenameOnTitleChange: true})
+
+	if err := p.ProcessBookmarks(testFolder("toolbar", testBookmark("id", "New", "https://example.com/")), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if exists(dir, "example.com - Old.md") {
+		t.Error("old note was kept")
+	}
+	matter := parseNote(t, dir, "example.com - New.md")
+	if matter.Title != "New" || !slices.Equal(matter.PreviousTitles, []string{"Old"}) {
+		t.Errorf("got title %q and previous titles %q", matter.Title, matter.PreviousTitles)
+	}
+}
+
+func TestRetitleKeepsUserFields(t *testing.T) {
+	dir := t.TempDir()
+	note := "---\ntitle: 'Old'\nurl: https://example.com/\nid: id\nrating: 5\ncssclasses: line3\n---\n\ncontent\n" + generatedEndMarker + "\nMy notes\n"
+	writeFile(t, dir, "example.com - Old.md", note)
+	p := newTestProcessor(t, dir, ProcessorOptions{})
+
+	if err := p.ProcessBookmarks(testFolder("toolbar", testBookmark("id", "It's new", "https://example.com/")), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "---\ntitle: It's new\nurl: https://example.com/\nid: id\nrating: 5\ncssclasses: line3\n---\n\ncontent\n" + generatedEndMarker + "\nMy notes\n"
+	want = strings.Replace(want, "---\ntitle:", "---\nprevious_titles:\n- Old\ntitle:", 1)
+	if got := readFile(t, dir, "example.com - Old.md"); got != want {
+		t.Errorf("got note\n%s\nwant\n%s", got, want)
+	}
+	if got := p.Summary().Retitled; got != 1 {
+		t.Errorf("got %d retitled notes, want 1", got)
+	}
+}
+
+func TestRetitleStub(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "Reading/example.com - Old.md", Frontmatter{Title: "Old", URL: "https://example.com/", Path: "Reading", ID: "id"}, "content")
+
+	// Retitling reads the note, so the converter is never asked for the page
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("content fetched for %s", r.URL.Query().Get("url"))
+	}))
+	defer server.Close()
+	service, err := web.NewContentService(server.Client(), web.FetchOptions{BaseURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, err := BuildCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewProcessor(ProcessorOptions{OutputDir: dir, RenameOnTitleChange: true, RenameStubs: true}, service, nil, cache)
+
+	tree := testFolder("toolbar", testFolder("Reading", testBookmark("id", "New", "https://example.com/")))
+	if err := p.ProcessBookmarks(tree, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readFile(t, dir, "Reading/example.com - Old.md"); got != "Renamed to [[Reading/example.com - New|New]]\n" {
+		t.Errorf("got old file %q, want a stub linking to the new note", got)
+	}
+	if matter := parseNote(t, dir, "Reading/example.com - New.md"); matter.Title != "New" || matter.ID != "id" {
+		t.Errorf("got renamed note %+v", matter)
+	}
+
+	// The stub is not taken for a note on the next run
+	cache, err = BuildCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry := cache["id"]; entry.File != filepath.Join("Reading", "example.com - New.md") {
+		t.Errorf("got cached file %q, want the renamed note", entry.File)
+	}
+}
@@ -0,0 +1,50 @@
+package markdown
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// slugify creates a lowercase, ASCII-only, dash separated slug from a title
+func slugify(title string) string {
+	// Fold accented characters to their ASCII base
+	folded, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), title)
+	if err != nil {
+		folded = title
+	}
+
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(folded) {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			sb.WriteRune(r)
+			dash = false
+		case !dash && sb.Len() > 0:
+			sb.WriteRune('-')
+			dash = true
+		}
+	}
+
+	slug := strings.TrimSuffix(sb.String(), "-")
+	if slug == "" {
+		slug = "bookmark"
+	}
+	return slug
+}
+
+// uniqueSlug returns a slug for title that is not in used, appending a counter on collision
+func uniqueSlug(title string, used map[string]bool) string {
+	base := slugify(title)
+	slug := base
+	for i := 2; used[slug]; i++ {
+		slug = fmt.Sprintf("%s-%d", base, i)
+	}
+	used[slug] = true
+	return slug
+}
@@ -0,0 +1,54 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	for title, want := range map[string]string{
+		"Hello, World!":              "hello-world",
+		"  Crème brûlée à la maison": "creme-brulee-a-la-maison",
+		"Go 1.23 -- release notes":   "go-1-23-release-notes",
+		"日本語":                        "bookmark",
+		"":                           "bookmark",
+	} {
+		if got := slugify(title); got != want {
+			t.Errorf("slugify(%q) = %q, want %q", title, got, want)
+		}
+	}
+}
+
+func TestUniqueSlugs(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "go.dev - Go.md", Frontmatter{Title: "Go", URL: "https://go.dev/", ID: "old", Slug: "go"}, "content")
+	p := newTestProcessor(t, dir, ProcessorOptions{Slugs: true})
+
+	tree := testFolder("toolbar",
+		testBookmark("a", "Go", "https://example.com/go"),
+		testBookmark("b", "Go!", "https://example.org/go"),
+	)
+	if err := p.ProcessBookmarks(tree, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	for file, slug := range map[string]string{
+		"example.com - Go.md":  "slug: go-2\n",
+		"example.org - Go!.md": "slug: go-3\n",
+	} {
+		if note := readFile(t, dir, file); !strings.Contains(note, slug) {
+			t.Errorf("%s is missing %q:\n%s", file, slug, note)
+		}
+	}
+}
+
+func TestNoSlugsByDefault(t *testing.T) {
+	dir := t.TempDir()
+	p := newTestProcessor(t, dir, ProcessorOptions{})
+	if err := p.ProcessBookmarks(testFolder("toolbar", testBookmark("a", "Go", "https://example.com/go")), ""); err != nil {
+		t.Fatal(err)
+	}
+	if note := readFile(t, dir, "example.com - Go.md"); strings.Contains(note, "slug:") {
+		t.Errorf("got a slug without -slugs:\n%s", note)
+	}
+}
@@ -0,0 +1,114 @@
+package markdown
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/web"
+)
+
+// statusNoteFile summarizes the last run in the vault
+const statusNoteFile = "_status.md"
+
+// CreateStatusNote writes a note summarizing the run finished at finished:
+// the counts, failed and deferred bookmarks with their reasons, quarantined
+// duplicates, pages whose screenshot changed significantly and notes without
+// content. A zero finished time leaves out the
+// time of the run, so deterministic output doesn't change between runs.
+func (p *Processor) CreateStatusNote(finished time.Time) error {
+	var sb strings.Builder
+	s := p.Summary()
+
+	sb.WriteString("# Sync status\n\n")
+	if !finished.IsZero() {
+		sb.WriteString(fmt.Sprintf("Last run: %s\n\n", finished.In(p.location).Format("2006-01-02 15:04")))
+	}
+	sb.WriteString("| Created | Retitled | Pruned | Failed | Deferred |\n")
+	sb.WriteString("| --- | --- | --- | --- | --- |\n")
+	sb.WriteString(fmt.Sprintf("| %d | %d | %d | %d | %d |\n", s.Created, s.Retitled, s.Pruned, s.Failed, s.Deferred))
+	sb.WriteString(fmt.Sprintf("\nUnread reading time: %s\n", s.UnreadReadingTime))
+
+	writeSection := func(title string, hint string, lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		sb.WriteString(fmt.Sprintf("\n## %s\n\n", title))
+		if hint != "" {
+			sb.WriteString(hint + "\n\n")
+		}
+		for _, line := range lines {
+			sb.WriteString("- " + line + "\n")
+		}
+	}
+
+	// Group failures by category, so e.g. pages that are gone stand out
+	// from ones that only timed out
+	failed := make(map[web.Category][]string)
+	var deferred []string
+	for _, problem := range s.Problems {
+		line := fmt.Sprintf("%s: %s", markdownLink(problem.Bookmark.Title, problem.Bookmark.URI), singleLine(problem.Reason))
+		if problem.Deferred {
+			deferred = append(deferred, line)
+		} else {
+			failed[problem.Category] = append(failed[problem.Category], line)
+		}
+	}
+	for _, category := range slices.Sorted(maps.Keys(failed)) {
+		writeSection(fmt.Sprintf("Failed bookmarks: %s", category), "", failed[category])
+	}
+	writeSection("Deferred bookmarks", "Retried on the next run.", deferred)
+
+	quarantined, err := p.quarantinedNotes()
+	if err != nil {
+		return err
+	}
+	writeSection("Quarantined notes", fmt.Sprintf("Duplicate notes moved to %s, see %s.", conflictsDir, wikilink(filepath.Join(conflictsDir, conflictsReport), "")), quarantined)
+
+	var changed []string
+	for _, change := range s.VisualChanges {
+		changed = append(changed, fmt.Sprintf("%s (%s), previous screenshot %s", wikilink(change.File, ""), singleLine(change.URL), wikilink(change.History, "")))
+	}
+	writeSection("Significantly changed pages", "Screenshots taken again that look very different from the previous ones, which may mean the content moved, went behind a paywall or the domain was parked.", changed)
+
+	var degenerate []string
+	for _, entry := range p.cache.Degenerate() {
+		degenerate = append(degenerate, fmt.Sprintf("%s (%s)", wikilink(entry.File, ""), singleLine(entry.URI)))
+	}
+	writeSection("Notes without content", "Refetched with -heal.", degenerate)
+
+	if err := p.output.WriteFile(statusNoteFile, []byte(sb.String())); err != nil {
+		return fmt.Errorf("failed to write status note: %w", err)
+	}
+	slog.Debug("wrote status note")
+	return nil
+}
+
+// quarantinedNotes returns the entries of the conflicts report, archives
+// have none since they are written from scratch
+func (p *Processor) quarantinedNotes() ([]string, error) {
+	if IsZipOutput(p.outputDir) {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(p.outputDir, conflictsDir, conflictsReport))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read conflicts report: %w", err)
+	}
+
+	var entries []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if entry, ok := strings.CutPrefix(line, "- "); ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
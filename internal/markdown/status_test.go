@@ -0,0 +1,72 @@
+package markdown
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/web"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/x/testutil"
+)
+
+// statusProblems are failures whose titles, URLs and reasons need escaping
+var statusProblems = []Problem{
+	{
+		Bookmark: bookmarks.Bookmark{Title: "Gone [archived]", URI: "https://example.com/wiki/Go_(language)"},
+		Reason:   "request failed with status: 404",
+		Category: web.CategoryNotFound,
+	},
+	{
+		Bookmark: bookmarks.Bookmark{Title: "Stack\ntrace", URI: "https://example.com/a b"},
+		Reason:   "parse failed:\n  line 1\n  line 2",
+		Category: web.CategoryOther,
+	},
+	{
+		Bookmark: bookmarks.Bookmark{Title: "Slow page", URI: "https://example.com/slow"},
+		Reason:   "context deadline exceeded",
+		Category: web.CategoryTimeout,
+		Deferred: true,
+	},
+}
+
+func TestCreateStatusNote(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "Empty.md", Frontmatter{ID: "empty-id", Title: "Empty", URL: "https://example.com/empty"}, "")
+
+	// Duplicates synced from other machines, dated by their modification time
+	var duplicates []duplicateNote
+	for i, file := range []string{"Reading/Go 1.md", "Reading/Go (laptop).md"} {
+		writeNote(t, dir, file, Frontmatter{ID: "go-id", Title: "Go", URL: "https://go.dev/"}, "")
+		modified := time.Date(2024, 2, 28+i, 9, 0, 0, 0, time.UTC)
+		if err := os.Chtimes(filepath.Join(dir, file), modified, modified); err != nil {
+			t.Fatal(err)
+		}
+		duplicates = append(duplicates, duplicateNote{id: "go-id", kept: "Reading/Go.md", file: file})
+	}
+	if err := quarantineDuplicates(dir, duplicates); err != nil {
+		t.Fatal(err)
+	}
+
+	p := newTestProcessor(t, dir, ProcessorOptions{Deterministic: true})
+	p.summary = Summary{Created: 3, Failed: 2, Deferred: 1, Problems: statusProblems}
+
+	if err := p.CreateStatusNote(time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	testutil.Golden(t, "status", []byte(readFile(t, dir, statusNoteFile)))
+}
+
+func TestCreateStatusNoteWithoutTime(t *testing.T) {
+	dir := t.TempDir()
+	p := newTestProcessor(t, dir, ProcessorOptions{Deterministic: true})
+
+	if err := p.CreateStatusNote(time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if note := readFile(t, dir, statusNoteFile); strings.Contains(note, "Last run") {
+		t.Errorf("status note without time has a run time:\n%s", note)
+	}
+}
@@ -0,0 +1,64 @@
+package markdown
+
+import (
+	"errors"
+	"testing"
+)
+
+// fakeSummarizer answers with a fixed summary or error, counting the calls
+type fakeSummarizer struct {
+	summary string
+	err     error
+	calls   int
+}
+
+func (s *fakeSummarizer) SummarizeMarkdown(content string) (string, error) {
+	s.calls++
+	return s.summary, s.err
+}
+
+func TestSummaryDescription(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		summarizer  *fakeSummarizer
+		dryRun      string
+		calls       int
+		description string
+	}{
+		{"summary", &fakeSummarizer{summary: `Explains "generics": with examples`}, "", 1, `Explains "generics": with examples`},
+		{"failed summary", &fakeSummarizer{err: errors.New("rate limited")}, "", 1, ""},
+		{"dry run", &fakeSummarizer{summary: "Summary"}, DryRunPlan, 0, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			p := newTestProcessor(t, dir, ProcessorOptions{Summarizer: tt.summarizer, DryRun: tt.dryRun})
+			if err := p.ProcessBookmarks(testFolder("toolbar", testBookmark("id", "Page", "https://example.com/page")), ""); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.summarizer.calls != tt.calls {
+				t.Errorf("got %d summaries, want %d", tt.summarizer.calls, tt.calls)
+			}
+			if tt.dryRun != "" {
+				return
+			}
+			// A failed summary leaves the description empty instead of failing the note
+			if got := parseNote(t, dir, "example.com - Page.md").Description; got != tt.description {
+				t.Errorf("got description %q, want %q", got, tt.description)
+			}
+		})
+	}
+}
+
+func TestSummarySkipsYouTube(t *testing.T) {
+	dir := t.TempDir()
+	summarizer := &fakeSummarizer{summary: "Summary"}
+	p := newTestProcessor(t, dir, ProcessorOptions{Summarizer: summarizer})
+	if err := p.ProcessBookmarks(testFolder("toolbar", testBookmark("id", "Video", "https://www.youtube.com/watch?v=abc")), ""); err != nil {
+		t.Fatal(err)
+	}
+	// YouTube notes only embed the player
+	if summarizer.calls != 0 {
+		t.Errorf("got %d summaries of a YouTube note, want none", summarizer.calls)
+	}
+}
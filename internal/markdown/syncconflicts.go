@@ -0,0 +1,133 @@
+package markdown
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/adrg/frontmatter"
+)
+
+// SyncConflictPatterns are file name patterns of conflict copies created by
+// file sync tools, matched case-insensitively against the base name
+var SyncConflictPatterns = []string{
+	// Dropbox and Nextcloud: "note (conflicted copy 2024-05-01).md"
+	"*conflicted copy*",
+	// Syncthing: "note.sync-conflict-20240501-120000-ABCDEFG.md"
+	"*.sync-conflict-*",
+}
+
+// isSyncConflict reports whether a file name matches a sync conflict pattern
+func isSyncConflict(name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range SyncConflictPatterns {
+		if ok, _ := filepath.Match(strings.ToLower(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// SyncConflict is a conflict copy of a note created by a file sync tool
+type SyncConflict struct {
+	// File is the conflict copy, relative to the output directory
+	File string
+	// Original is the note with the same bookmark ID, if any
+	Original string
+}
+
+// FindSyncConflicts lists conflict copies in the output directory, matched
+// to the notes in the cache by bookmark ID
+func FindSyncConflicts(outputDir string, cache Cache) ([]SyncConflict, error) {
+	var conflicts []SyncConflict
+	err := filepath.WalkDir(outputDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() && path == filepath.Join(outputDir, conflictsDir) {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".md") || !isSyncConflict(d.Name()) {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(outputDir, path)
+		if err != nil {
+			return nil
+		}
+
+		conflict := SyncConflict{File: relPath}
+		if data, err := os.ReadFile(path); err == nil {
+			var matter Frontmatter
+			if _, err := frontmatter.Parse(strings.NewReader(string(data)), &matter); err == nil {
+				if entry, ok := cache[matter.ID]; ok && matter.ID != "" {
+					conflict.Original = entry.File
+				}
+			}
+		}
+		conflicts = append(conflicts, conflict)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error finding sync conflicts: %w", err)
+	}
+
+	return conflicts, nil
+}
+
+// ResolveSyncConflicts keeps the newer of each conflict copy and its original
+// note at the original path, and moves the other one into the conflicts
+// folder. Conflict copies without an original are left alone.
+func ResolveSyncConflicts(outputDir string, conflicts []SyncConflict) (int, error) {
+	var report strings.Builder
+	resolved := 0
+	for _, conflict := range conflicts {
+		if conflict.Original == "" {
+			continue
+		}
+
+		copyInfo, err := os.Stat(filepath.Join(outputDir, conflict.File))
+		if err != nil {
+			return resolved, fmt.Errorf("failed to resolve %s: %w", conflict.File, err)
+		}
+		originalInfo, err := os.Stat(filepath.Join(outputDir, conflict.Original))
+		if err != nil {
+			return resolved, fmt.Errorf("failed to resolve %s: %w", conflict.File, err)
+		}
+
+		moved, newest := conflict.File, originalInfo.ModTime()
+		if copyInfo.ModTime().After(originalInfo.ModTime()) {
+			moved, newest = conflict.Original, copyInfo.ModTime()
+		}
+
+		dest := filepath.Join(outputDir, conflictsDir, moved)
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return resolved, fmt.Errorf("failed to create conflicts directory: %w", err)
+		}
+		if err := os.Rename(filepath.Join(outputDir, moved), dest); err != nil {
+			return resolved, fmt.Errorf("failed to resolve %s: %w", conflict.File, err)
+		}
+		if moved == conflict.Original {
+			// The newer copy takes the place of the original
+			if err := os.Rename(filepath.Join(outputDir, conflict.File), filepath.Join(outputDir, conflict.Original)); err != nil {
+				return resolved, fmt.Errorf("failed to resolve %s: %w", conflict.File, err)
+			}
+		}
+
+		slog.Info("resolved sync conflict", "file", conflict.File, "kept", conflict.Original, "moved", filepath.Join(conflictsDir, moved))
+		report.WriteString(fmt.Sprintf("- %s: sync conflict %s, kept newer as %s, moved %s\n",
+			newest.UTC().Format(time.RFC3339),
+			conflict.File, wikilink(conflict.Original, ""), wikilink(filepath.Join(conflictsDir, moved), "")))
+		resolved++
+	}
+
+	if resolved > 0 {
+		if err := appendConflictsReport(outputDir, report.String()); err != nil {
+			return resolved, err
+		}
+	}
+	return resolved, nil
+}
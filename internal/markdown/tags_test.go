@@ -0,0 +1,42 @@
+package markdown
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/llm"
+)
+
+func TestSuggestedTags(t *testing.T) {
+	dir := t.TempDir()
+	tagger := &llm.FakeClient{Tags: []string{"golang", "web-development", "deleted", "bookmark", "web-development"}}
+	cache, err := BuildCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewProcessor(ProcessorOptions{OutputDir: dir, Tagger: tagger}, newTestContentService(t), nil, cache)
+
+	bookmark := testBookmark("id", "Page", "https://example.com/page")
+	bookmark.Tags = []string{"Golang", "reading"}
+	if err := p.ProcessBookmarks(testFolder("toolbar", bookmark), ""); err != nil {
+		t.Fatal(err)
+	}
+	if tagger.Calls("SuggestTags") != 1 {
+		t.Fatalf("got %d tag suggestions, want 1", tagger.Calls("SuggestTags"))
+	}
+
+	matter := parseNote(t, dir, "example.com - Page.md")
+	want := []string{"bookmark", "golang", "reading", "topic/web-development"}
+	if !slices.Equal(matter.Tags, want) {
+		t.Errorf("got tags %q, want %q", matter.Tags, want)
+	}
+
+	// Suggested tags are not read back as bookmark tags
+	cache, err = BuildCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cache["id"].Tags; !slices.Equal(got, []string{"golang", "reading"}) {
+		t.Errorf("got bookmark tags %q from the note", got)
+	}
+}
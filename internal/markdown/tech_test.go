@@ -0,0 +1,41 @@
+package markdown
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/web"
+)
+
+func TestScreenshotTags(t *testing.T) {
+	dir := t.TempDir()
+	p := newTestProcessor(t, dir, ProcessorOptions{
+		Screenshots: map[string]web.ScreenshotResult{
+			"https://example.com/app": {
+				URL:          "https://example.com/app",
+				ResponseCode: 200,
+				Technologies: []string{"Next.js", "React", " "},
+			},
+		},
+	})
+
+	tree := testFolder("toolbar",
+		testBookmark("app", "App", "https://example.com/app"),
+		testBookmark("plain", "Plain", "https://example.com/plain"),
+	)
+	if err := p.ProcessBookmarks(tree, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	app := parseNote(t, dir, "example.com - App.md")
+	if app.HTTPStatus != 200 || !slices.Equal(app.Tags, []string{"bookmark", "tech/next-js", "tech/react"}) {
+		t.Errorf("got http status %d and tags %q", app.HTTPStatus, app.Tags)
+	}
+
+	// Notes without a screenshot result are left untagged
+	plain := readFile(t, dir, "example.com - Plain.md")
+	if strings.Contains(plain, "http_status") || strings.Contains(plain, "tech/") {
+		t.Errorf("got screenshot data for a page without a screenshot:\n%s", plain)
+	}
+}
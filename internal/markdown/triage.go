@@ -0,0 +1,45 @@
+package markdown
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// statusInbox marks new notes that have not been triaged yet. The status is
+// owned by the user once a note exists and is never written again.
+const statusInbox = "inbox"
+
+// inboxIndexFile lists all notes still waiting for triage
+const inboxIndexFile = "Inbox.md"
+
+// newNoteStatus returns the status of newly created notes
+func (p *Processor) newNoteStatus() string {
+	if !p.triage {
+		return ""
+	}
+	return statusInbox
+}
+
+// CreateInboxIndex creates an index listing the notes still in the inbox
+func (p *Processor) CreateInboxIndex() error {
+	columns := "path, url, created_at"
+	if p.readStats {
+		columns += `, read_at as "read"`
+	}
+	content := fmt.Sprintf(`---
+cssclasses: ["line3"]
+---
+%s
+TABLE %s
+FROM #bookmark
+WHERE status = "%s"
+SORT pinned DESC, created_at DESC
+%s
+`, "```dataview", columns, statusInbox, "```")
+
+	if err := p.output.WriteFile(inboxIndexFile, []byte(content)); err != nil {
+		return fmt.Errorf("failed to write inbox index: %w", err)
+	}
+	slog.Debug("wrote inbox index")
+	return nil
+}
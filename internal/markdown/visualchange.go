@@ -0,0 +1,119 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/phash"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/web"
+)
+
+// screenshotHistoryDir keeps the previous screenshot of pages whose
+// appearance changed significantly
+const screenshotHistoryDir = "_assets/screenshots/history"
+
+// Visual changes recorded in the visual_change field
+const (
+	VisualChangeHigh = "high"
+	VisualChangeLow  = "low"
+)
+
+// VisualChange is a note whose page looks very different from its previous
+// screenshot, which often means the content moved, went behind a paywall or
+// the domain was parked
+type VisualChange struct {
+	File string
+	URL  string
+	// Distance is the perceptual hash distance of the two screenshots
+	Distance int
+	// History is the previous screenshot, kept in screenshotHistoryDir
+	History string
+}
+
+// recaptureScreenshots takes new screenshots of the pages of notes and
+// records how much their appearance changed
+func (p *Processor) recaptureScreenshots(entries []CacheEntry) {
+	if p.screenshotService == nil || p.recaptureTimeout <= 0 || len(entries) == 0 {
+		return
+	}
+
+	var urls []string
+	for _, entry := range entries {
+		urls = append(urls, entry.URI)
+	}
+	recaptures, err := p.screenshotService.Recapture(p.ctx, urls, p.recaptureTimeout)
+	if err != nil {
+		slog.Warn("failed to recapture screenshots", "error", err)
+		return
+	}
+
+	date := p.now.In(p.location).Format("2006-01-02")
+	for _, entry := range entries {
+		recapture, ok := recaptures[p.screenshotService.Normalize(entry.URI)]
+		if !ok {
+			continue
+		}
+		if err := p.recordVisualChange(entry, recapture, date); err != nil {
+			slog.Warn("failed to compare screenshots", "file", entry.File, "error", err)
+		}
+	}
+}
+
+// recordVisualChange compares the screenshots of a note's page, setting
+// visual_change and the date of the comparison in its frontmatter. The
+// previous screenshot of pages that changed significantly is kept.
+func (p *Processor) recordVisualChange(entry CacheEntry, recapture web.Recapture, date string) error {
+	previous, err := phash.Decode(bytes.NewReader(recapture.Previous))
+	if err != nil {
+		return fmt.Errorf("failed to decode previous screenshot: %w", err)
+	}
+	current, err := phash.Decode(bytes.NewReader(recapture.Current))
+	if err != nil {
+		return fmt.Errorf("failed to decode screenshot: %w", err)
+	}
+
+	distance := phash.Distance(previous, current)
+	change := VisualChangeLow
+	if distance >= phash.ChangedDistance {
+		change = VisualChangeHigh
+	}
+	slog.Info("compared screenshots", "file", entry.File, "distance", distance, "change", change)
+
+	data, err := os.ReadFile(filepath.Join(p.outputDir, entry.File))
+	if err != nil {
+		return fmt.Errorf("failed to read note: %w", err)
+	}
+	rawMatter, body, err := splitNote(string(data))
+	if err != nil {
+		return err
+	}
+	rawMatter = setFrontmatterLine(rawMatter, "visual_change", change)
+	rawMatter = setFrontmatterLine(rawMatter, "visual_change_at", date)
+	if err := p.output.WriteFile(entry.File, []byte(rawMatter+"\n"+body)); err != nil {
+		return fmt.Errorf("failed to write note: %w", err)
+	}
+
+	if change != VisualChangeHigh {
+		return nil
+	}
+	name := strings.TrimSuffix(filepath.Base(entry.File), ".md") + " " + date + path.Ext(recapture.FileName)
+	history := filepath.Join(screenshotHistoryDir, name)
+	if err := p.output.MkdirAll(screenshotHistoryDir); err != nil {
+		return fmt.Errorf("failed to create screenshot history directory: %w", err)
+	}
+	if err := p.output.WriteFile(history, recapture.Previous); err != nil {
+		return fmt.Errorf("failed to keep previous screenshot: %w", err)
+	}
+	p.summary.VisualChanges = append(p.summary.VisualChanges, VisualChange{
+		File:     entry.File,
+		URL:      entry.URI,
+		Distance: distance,
+		History:  history,
+	})
+	return nil
+}
@@ -0,0 +1,79 @@
+package markdown
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/web"
+)
+
+func readScreenshot(t *testing.T, name string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("..", "phash", "testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestRecordVisualChange(t *testing.T) {
+	tests := []struct {
+		name    string
+		current string
+		want    string
+	}{
+		{"parked", "parked.png", VisualChangeHigh},
+		{"edited", "page-edited.png", VisualChangeLow},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeNote(t, dir, "Reading/Page.md", Frontmatter{ID: "page-id", Title: "Page", URL: "https://example.com/page"}, "Content")
+			notes := readFile(t, dir, "Reading/Page.md") + "\nMy notes\n"
+			writeFile(t, dir, "Reading/Page.md", notes)
+
+			p := newTestProcessor(t, dir, ProcessorOptions{Deterministic: true})
+			entry := CacheEntry{Bookmark: bookmarks.Bookmark{ID: "page-id", URI: "https://example.com/page"}, File: "Reading/Page.md"}
+			previous := readScreenshot(t, "page.png")
+			recapture := web.Recapture{Previous: previous, Current: readScreenshot(t, test.current), FileName: "https---example.com-page.png"}
+			if err := p.recordVisualChange(entry, recapture, "2024-03-01"); err != nil {
+				t.Fatal(err)
+			}
+
+			note := readFile(t, dir, "Reading/Page.md")
+			for _, line := range []string{"visual_change: " + test.want + "\n", "visual_change_at: 2024-03-01\n", "Content\n", "\nMy notes\n"} {
+				if !strings.Contains(note, line) {
+					t.Errorf("note is missing %q:\n%s", line, note)
+				}
+			}
+
+			history := filepath.Join(screenshotHistoryDir, "Page 2024-03-01.png")
+			if test.want != VisualChangeHigh {
+				if exists(dir, history) || len(p.summary.VisualChanges) != 0 {
+					t.Errorf("kept the previous screenshot of a page that barely changed")
+				}
+				return
+			}
+			if got := readFile(t, dir, history); got != string(previous) {
+				t.Errorf("history holds %d bytes, want the previous screenshot", len(got))
+			}
+			if len(p.summary.VisualChanges) != 1 || p.summary.VisualChanges[0].History != history {
+				t.Fatalf("got visual changes %+v", p.summary.VisualChanges)
+			}
+
+			if err := p.CreateStatusNote(p.now); err != nil {
+				t.Fatal(err)
+			}
+			status := readFile(t, dir, statusNoteFile)
+			want := "[[Reading/Page]] (https://example.com/page), previous screenshot [[" + filepath.ToSlash(strings.TrimSuffix(history, ".md")) + "]]"
+			if !strings.Contains(status, "Significantly changed pages") || !strings.Contains(status, want) {
+				t.Errorf("status note does not list the changed page:\n%s", status)
+			}
+		})
+	}
+}
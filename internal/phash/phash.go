@@ -0,0 +1,134 @@
+// Package phash computes perceptual hashes of images, which stay close for
+// images that look alike and differ for images that don't, e.g. screenshots
+// of a page before and after it was replaced by a parked domain
+package phash
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+	"math/bits"
+	"slices"
+)
+
+// ChangedDistance is the distance from which two screenshots of a page count
+// as significantly changed
+const ChangedDistance = 12
+
+// sampleSize is the width and height images are scaled down to before the
+// DCT, hashSize the width and height of the low frequencies kept in the hash
+const (
+	sampleSize = 32
+	hashSize   = 8
+)
+
+// Hash is a 64 bit perceptual hash
+type Hash uint64
+
+func (h Hash) String() string {
+	return fmt.Sprintf("%016x", uint64(h))
+}
+
+// Distance returns the number of differing bits of two hashes, from 0 for
+// alike images to 64
+func Distance(a, b Hash) int {
+	return bits.OnesCount64(uint64(a ^ b))
+}
+
+// Decode reads a PNG or JPEG image and returns its hash
+func Decode(r io.Reader) (Hash, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return Compute(img), nil
+}
+
+// Compute returns the hash of an image: its low DCT frequencies of the
+// grayscale image scaled down to 32x32, one bit per frequency set if it is
+// above their median
+func Compute(img image.Image) Hash {
+	pixels := sample(img)
+
+	// Separable DCT-II, only for the low frequencies kept in the hash
+	var rows [sampleSize][hashSize]float64
+	for y := range sampleSize {
+		for u := range hashSize {
+			for x := range sampleSize {
+				rows[y][u] += pixels[y][x] * cosines[u][x]
+			}
+		}
+	}
+	coefficients := make([]float64, 0, hashSize*hashSize)
+	for v := range hashSize {
+		for u := range hashSize {
+			var sum float64
+			for y := range sampleSize {
+				sum += rows[y][u] * cosines[v][y]
+			}
+			coefficients = append(coefficients, sum)
+		}
+	}
+
+	sorted := slices.Clone(coefficients)
+	slices.Sort(sorted)
+	median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+
+	var hash Hash
+	for i, c := range coefficients {
+		if c > median {
+			hash |= 1 << i
+		}
+	}
+	return hash
+}
+
+// cosines holds the DCT-II basis cos((2x+1)uπ/2N) of the kept frequencies
+var cosines = func() (table [hashSize][sampleSize]float64) {
+	for u := range hashSize {
+		for x := range sampleSize {
+			table[u][x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * sampleSize))
+		}
+	}
+	return table
+}()
+
+// sample scales an image down to sampleSize x sampleSize luminance values by
+// averaging the pixels each value covers
+func sample(img image.Image) (pixels [sampleSize][sampleSize]float64) {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w == 0 || h == 0 {
+		return pixels
+	}
+
+	for sy := range sampleSize {
+		y0, y1 := span(sy, h)
+		for sx := range sampleSize {
+			x0, x1 := span(sx, w)
+			var sum float64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+				}
+			}
+			pixels[sy][sx] = sum / float64((x1-x0)*(y1-y0)) / 0xffff
+		}
+	}
+	return pixels
+}
+
+// span returns the pixel range of size pixels covered by sample i, at least
+// one pixel for images smaller than the sample
+func span(i, size int) (int, int) {
+	start := i * size / sampleSize
+	end := (i + 1) * size / sampleSize
+	if end <= start {
+		end = start + 1
+	}
+	return start, end
+}
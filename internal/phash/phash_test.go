@@ -0,0 +1,73 @@
+package phash
+
+import (
+	"bytes"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func decodeFixture(t *testing.T, name string) Hash {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	hash, err := Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestDistance(t *testing.T) {
+	page := decodeFixture(t, "page.png")
+
+	for _, tt := range []struct {
+		fixture string
+		changed bool
+	}{
+		{"page.png", false},
+		// Recompressed as JPEG
+		{"page.jpg", false},
+		// A few shorter text lines
+		{"page-edited.png", false},
+		// Replaced by a domain parking page
+		{"parked.png", true},
+	} {
+		distance := Distance(page, decodeFixture(t, tt.fixture))
+		if changed := distance >= ChangedDistance; changed != tt.changed {
+			t.Errorf("%s: got distance %d, want changed %v", tt.fixture, distance, tt.changed)
+		}
+	}
+
+	if distance := Distance(page, page); distance != 0 {
+		t.Errorf("got distance %d of the same hash, want 0", distance)
+	}
+}
+
+func TestComputeSmallImages(t *testing.T) {
+	for _, size := range []image.Rectangle{
+		image.Rect(0, 0, 0, 0),
+		image.Rect(0, 0, 1, 1),
+		image.Rect(5, 5, 12, 40),
+	} {
+		// Must not panic on images smaller than the sample
+		Compute(image.NewGray(size))
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	if _, err := Decode(bytes.NewReader([]byte("not an image"))); err == nil {
+		t.Error("got no error")
+	}
+}
+
+func TestHashString(t *testing.T) {
+	if got := Hash(0xab).String(); got != "00000000000000ab" {
+		t.Errorf("got %q, want zero padded hex", got)
+	}
+}
@@ -0,0 +1,123 @@
+package safari
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/firefox"
+)
+
+// Kind is the -source kind reading Safari bookmarks
+const Kind = "safari"
+
+// Titles of the Safari top level folders
+const (
+	titleBookmarksBar  = "BookmarksBar"
+	titleBookmarksMenu = "BookmarksMenu"
+	titleReadingList   = "com.apple.ReadingList"
+)
+
+// Fetcher reads bookmarks from a Safari Bookmarks.plist file
+type Fetcher struct {
+	Path string
+}
+
+// NewFetcher creates a fetcher reading the Safari Bookmarks.plist at path
+func NewFetcher(path string) *Fetcher {
+	return &Fetcher{Path: path}
+}
+
+// GetBookmarks reads all bookmarks from the plist. The favorites bar maps to
+// the toolbar root, the bookmarks menu to menu, the reading list to mobile
+// and any other top level entries to unfiled.
+func (f *Fetcher) GetBookmarks(ctx context.Context) (*firefox.BookmarksRoot, error) {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Safari bookmarks: %w", err)
+	}
+
+	top, err := decodeBinaryPlist(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse plist: %w", err)
+	}
+	dict, ok := top.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("failed to parse plist: top level is not a dictionary")
+	}
+
+	var root firefox.BookmarksRoot
+	root.Bookmarks.Toolbar = bookmarks.Bookmark{ID: "toolbar", Title: "toolbar", Type: bookmarks.TypeFolder}
+	root.Bookmarks.Menu = bookmarks.Bookmark{ID: "menu", Title: "menu", Type: bookmarks.TypeFolder}
+	root.Bookmarks.Mobile = bookmarks.Bookmark{ID: "mobile", Title: "mobile", Type: bookmarks.TypeFolder}
+	root.Bookmarks.Unfiled = bookmarks.Bookmark{ID: "unfiled", Title: "unfiled", Type: bookmarks.TypeFolder}
+
+	for _, child := range children(dict) {
+		bookmark := convert(child)
+		switch {
+		case bookmark.Type == bookmarks.TypeFolder && bookmark.Title == titleBookmarksBar:
+			root.Bookmarks.Toolbar.Children = append(root.Bookmarks.Toolbar.Children, bookmark.Children...)
+		case bookmark.Type == bookmarks.TypeFolder && bookmark.Title == titleBookmarksMenu:
+			root.Bookmarks.Menu.Children = append(root.Bookmarks.Menu.Children, bookmark.Children...)
+		case bookmark.Type == bookmarks.TypeFolder && bookmark.Title == titleReadingList:
+			root.Bookmarks.Mobile.Children = append(root.Bookmarks.Mobile.Children, bookmark.Children...)
+		case bookmark.Type != "":
+			root.Bookmarks.Unfiled.Children = append(root.Bookmarks.Unfiled.Children, bookmark)
+		}
+	}
+	return &root, nil
+}
+
+// children returns the child dictionaries of a plist entry
+func children(dict map[string]any) []map[string]any {
+	list, _ := dict["Children"].([]any)
+	var result []map[string]any
+	for _, item := range list {
+		if child, ok := item.(map[string]any); ok {
+			result = append(result, child)
+		}
+	}
+	return result
+}
+
+// convert converts a plist entry and its children. Proxies such as the
+// History entry have no bookmark type and are returned empty.
+func convert(dict map[string]any) bookmarks.Bookmark {
+	bookmark := bookmarks.Bookmark{}
+	bookmark.ID, _ = dict["WebBookmarkUUID"].(string)
+
+	switch dict["WebBookmarkType"] {
+	case "WebBookmarkTypeLeaf":
+		bookmark.Type = bookmarks.TypeBookmark
+		bookmark.URI, _ = dict["URLString"].(string)
+		if uri, ok := dict["URIDictionary"].(map[string]any); ok {
+			bookmark.Title, _ = uri["title"].(string)
+		}
+		if bookmark.Title == "" {
+			bookmark.Title = bookmark.URI
+		}
+	case "WebBookmarkTypeList":
+		bookmark.Type = bookmarks.TypeFolder
+		bookmark.Title, _ = dict["Title"].(string)
+		for _, child := range children(dict) {
+			if converted := convert(child); converted.Type != "" {
+				bookmark.Children = append(bookmark.Children, converted)
+			}
+		}
+	default:
+		return bookmarks.Bookmark{}
+	}
+
+	// Reading list items keep their date in the ReadingList dictionary
+	added, ok := dict["DateAdded"].(time.Time)
+	if readingList, isDict := dict["ReadingList"].(map[string]any); !ok && isDict {
+		added, ok = readingList["DateAdded"].(time.Time)
+	}
+	if ok {
+		bookmark.AddedUnix = added.Unix()
+		bookmark.Added = added.Format(time.RFC3339)
+	}
+	return bookmark
+}
@@ -0,0 +1,52 @@
+package safari
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+)
+
+func TestFetcher(t *testing.T) {
+	root, err := NewFetcher("testdata/Bookmarks.plist").GetBookmarks(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page := root.Path("toolbar/Reading/Go")
+	if page == nil {
+		t.Fatal("nested bookmark not found")
+	}
+	if page.ID != "go" || page.Type != bookmarks.TypeBookmark || page.URI != "https://go.dev/" || page.AddedUnix != 1709294400 {
+		t.Errorf("got bookmark %+v", *page)
+	}
+
+	// Untitled bookmarks are named by their URL
+	if untitled := root.Bookmarks.Toolbar.Children[1]; untitled.Title != "https://example.com/untitled" || untitled.AddedUnix != 0 {
+		t.Errorf("got untitled bookmark %+v", untitled)
+	}
+
+	if menu := root.Bookmarks.Menu.Children; len(menu) != 1 || menu[0].Title != "Café – menu" {
+		t.Errorf("got menu %+v", menu)
+	}
+
+	// The reading list keeps its dates in a nested dictionary
+	if later := root.Bookmarks.Mobile.Children; len(later) != 1 || later[0].URI != "https://example.com/later" || later[0].AddedUnix != 1709294400 {
+		t.Errorf("got reading list %+v", later)
+	}
+
+	// Proxies like History are left out and other folders are unfiled
+	if unfiled := root.Bookmarks.Unfiled.Children; len(unfiled) != 1 || unfiled[0].Title != "Loose folder" || unfiled[0].Type != bookmarks.TypeFolder {
+		t.Errorf("got unfiled %+v", unfiled)
+	}
+}
+
+func TestFetcherErrors(t *testing.T) {
+	if _, err := NewFetcher(filepath.Join(t.TempDir(), "Bookmarks.plist")).GetBookmarks(context.Background()); err == nil {
+		t.Error("got no error for a missing file")
+	}
+	if _, err := NewFetcher("fetcher_test.go").GetBookmarks(context.Background()); err == nil {
+		t.Error("got no error for a file that isn't a binary plist")
+	}
+}
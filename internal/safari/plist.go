@@ -0,0 +1,216 @@
+package safari
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+	"unicode/utf16"
+)
+
+// plistEpoch is the reference date of plist dates
+var plistEpoch = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// errInvalidPlist is returned for malformed binary property lists
+var errInvalidPlist = errors.New("invalid binary plist")
+
+// binaryPlist decodes the bplist00 format into maps, slices, strings,
+// int64, float64, bool, time.Time and []byte values
+type binaryPlist struct {
+	data          []byte
+	offsets       []uint64
+	objectRefSize int
+	// depth guards against reference cycles in malformed files
+	depth int
+}
+
+// decodeBinaryPlist decodes a binary property list
+func decodeBinaryPlist(data []byte) (any, error) {
+	if len(data) < 40 || !bytes.HasPrefix(data, []byte("bplist00")) {
+		return nil, fmt.Errorf("%w: missing header", errInvalidPlist)
+	}
+
+	trailer := data[len(data)-32:]
+	offsetSize := int(trailer[6])
+	objectRefSize := int(trailer[7])
+	numObjects := binary.BigEndian.Uint64(trailer[8:16])
+	topObject := binary.BigEndian.Uint64(trailer[16:24])
+	offsetTable := binary.BigEndian.Uint64(trailer[24:32])
+
+	if offsetSize < 1 || offsetSize > 8 || objectRefSize < 1 || objectRefSize > 8 ||
+		numObjects > uint64(len(data)) || offsetTable+numObjects*uint64(offsetSize) > uint64(len(data)) {
+		return nil, fmt.Errorf("%w: bad trailer", errInvalidPlist)
+	}
+
+	p := &binaryPlist{data: data, objectRefSize: objectRefSize}
+	p.offsets = make([]uint64, numObjects)
+	for i := range p.offsets {
+		start := offsetTable + uint64(i*offsetSize)
+		p.offsets[i] = readUint(data[start : start+uint64(offsetSize)])
+	}
+
+	return p.object(topObject)
+}
+
+// readUint reads a big endian unsigned integer of any size up to 8 bytes
+func readUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+// object decodes the object with the given index
+func (p *binaryPlist) object(ref uint64) (any, error) {
+	if ref >= uint64(len(p.offsets)) || p.offsets[ref] >= uint64(len(p.data)) {
+		return nil, fmt.Errorf("%w: object %d out of range", errInvalidPlist, ref)
+	}
+	if p.depth > 512 {
+		return nil, fmt.Errorf("%w: nested too deeply", errInvalidPlist)
+	}
+	p.depth++
+	defer func() { p.depth-- }()
+
+	offset := p.offsets[ref]
+	marker := p.data[offset]
+	kind, info := marker>>4, marker&0x0f
+
+	switch kind {
+	case 0x0:
+		switch info {
+		case 0x8:
+			return false, nil
+		case 0x9:
+			return true, nil
+		}
+		return nil, nil
+	case 0x1:
+		size := uint64(1) << info
+		b, err := p.bytes(offset+1, size)
+		if err != nil {
+			return nil, err
+		}
+		return int64(readUint(b)), nil
+	case 0x2:
+		size := uint64(1) << info
+		b, err := p.bytes(offset+1, size)
+		if err != nil {
+			return nil, err
+		}
+		if size == 4 {
+			return float64(math.Float32frombits(uint32(readUint(b)))), nil
+		}
+		return math.Float64frombits(readUint(b)), nil
+	case 0x3:
+		b, err := p.bytes(offset+1, 8)
+		if err != nil {
+			return nil, err
+		}
+		seconds := math.Float64frombits(readUint(b))
+		return plistEpoch.Add(time.Duration(seconds * float64(time.Second))), nil
+	}
+
+	count, start, err := p.count(offset, info)
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case 0x4:
+		return p.bytes(start, count)
+	case 0x5:
+		b, err := p.bytes(start, count)
+		return string(b), err
+	case 0x6:
+		b, err := p.bytes(start, count*2)
+		if err != nil {
+			return nil, err
+		}
+		units := make([]uint16, count)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(b[i*2:])
+		}
+		return string(utf16.Decode(units)), nil
+	case 0xA:
+		refs, err := p.refs(start, count)
+		if err != nil {
+			return nil, err
+		}
+		array := make([]any, len(refs))
+		for i, ref := range refs {
+			if array[i], err = p.object(ref); err != nil {
+				return nil, err
+			}
+		}
+		return array, nil
+	case 0xD:
+		refs, err := p.refs(start, count*2)
+		if err != nil {
+			return nil, err
+		}
+		dict := make(map[string]any, count)
+		for i := uint64(0); i < count; i++ {
+			key, err := p.object(refs[i])
+			if err != nil {
+				return nil, err
+			}
+			value, err := p.object(refs[count+i])
+			if err != nil {
+				return nil, err
+			}
+			if k, ok := key.(string); ok {
+				dict[k] = value
+			}
+		}
+		return dict, nil
+	}
+
+	// UIDs and sets are not used by bookmark files
+	return nil, nil
+}
+
+// count returns the length of a variable sized object and where its data starts
+func (p *binaryPlist) count(offset uint64, info byte) (uint64, uint64, error) {
+	if info != 0x0f {
+		return uint64(info), offset + 1, nil
+	}
+
+	// Longer lengths follow as an integer object
+	b, err := p.bytes(offset+1, 1)
+	if err != nil {
+		return 0, 0, err
+	}
+	if b[0]>>4 != 0x1 {
+		return 0, 0, fmt.Errorf("%w: bad length", errInvalidPlist)
+	}
+	size := uint64(1) << (b[0] & 0x0f)
+	n, err := p.bytes(offset+2, size)
+	if err != nil {
+		return 0, 0, err
+	}
+	return readUint(n), offset + 2 + size, nil
+}
+
+// refs reads count object references starting at offset
+func (p *binaryPlist) refs(offset uint64, count uint64) ([]uint64, error) {
+	b, err := p.bytes(offset, count*uint64(p.objectRefSize))
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]uint64, count)
+	for i := range refs {
+		refs[i] = readUint(b[i*p.objectRefSize : (i+1)*p.objectRefSize])
+	}
+	return refs, nil
+}
+
+// bytes returns size bytes starting at offset
+func (p *binaryPlist) bytes(offset uint64, size uint64) ([]byte, error) {
+	if offset+size > uint64(len(p.data)) || offset+size < offset {
+		return nil, fmt.Errorf("%w: object out of bounds", errInvalidPlist)
+	}
+	return p.data[offset : offset+size], nil
+}
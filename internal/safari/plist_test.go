@@ -0,0 +1,27 @@
+package safari
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestDecodeBinaryPlistMalformed(t *testing.T) {
+	data, err := os.ReadFile("testdata/Bookmarks.plist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string][]byte{
+		"empty":       nil,
+		"xml":         []byte(`<?xml version="1.0"?><plist><dict/></plist>`),
+		"no trailer":  data[:len(data)-32],
+		"truncated":   data[:len(data)/2],
+		"bad offsets": append(append([]byte{}, data[:8]...), make([]byte, len(data)-8)...),
+	}
+	for name, input := range tests {
+		if _, err := decodeBinaryPlist(input); !errors.Is(err, errInvalidPlist) {
+			t.Errorf("%s: got error %v, want errInvalidPlist", name, err)
+		}
+	}
+}
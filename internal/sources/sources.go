@@ -0,0 +1,74 @@
+// Bookmark sources selectable with -source
+// Contains: registry of fetchers reading bookmark files of browsers and services
+
+package sources
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/chrome"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/firefox"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/safari"
+)
+
+// Source kinds, as named by the packages of their fetchers
+const (
+	KindFFSClient = firefox.KindFFSClient
+	KindPlaces    = firefox.KindPlaces
+	KindHTML      = firefox.KindHTML
+	KindBackup    = firefox.KindBackup
+	KindChrome    = chrome.Kind
+	KindSafari    = safari.Kind
+	KindPocket    = firefox.ImportPocket
+	KindRaindrop  = firefox.ImportRaindrop
+)
+
+// Factory creates a fetcher reading bookmarks from the file at path
+type Factory func(path string) firefox.BookmarksFetcher
+
+// registry maps source kinds to their fetchers. Every fetcher returns the
+// bookmarks in the Firefox tree shape, so the rest of the sync is the same
+// for all of them.
+var registry = map[string]Factory{
+	KindFFSClient: func(path string) firefox.BookmarksFetcher {
+		return firefox.NewFirefoxFetcher(path, firefox.DefaultFFSyncTimeout)
+	},
+	KindPlaces: func(path string) firefox.BookmarksFetcher { return firefox.NewPlacesFetcher(path) },
+	KindHTML:   func(path string) firefox.BookmarksFetcher { return firefox.NewHTMLFetcher(path) },
+	KindBackup: func(path string) firefox.BookmarksFetcher { return firefox.NewBackupFetcher(path) },
+	KindChrome: func(path string) firefox.BookmarksFetcher { return chrome.NewFetcher(path) },
+	KindSafari: func(path string) firefox.BookmarksFetcher { return safari.NewFetcher(path) },
+	KindPocket: func(path string) firefox.BookmarksFetcher {
+		return firefox.NewImportFetcher(firefox.ImportPocket, path)
+	},
+	KindRaindrop: func(path string) firefox.BookmarksFetcher {
+		return firefox.NewImportFetcher(firefox.ImportRaindrop, path)
+	},
+}
+
+// optionalPath lists the source kinds that can be given without a path, the
+// path of ffsclient being the binary to run
+var optionalPath = map[string]bool{KindFFSClient: true}
+
+// Register adds a source kind, replacing an existing one of the same name
+func Register(kind string, factory Factory) {
+	registry[kind] = factory
+}
+
+// Kinds returns the registered source kinds, sorted
+func Kinds() []string {
+	return slices.Sorted(maps.Keys(registry))
+}
+
+// New creates the fetcher for a kind:path source
+func New(source string) (firefox.BookmarksFetcher, error) {
+	kind, path, _ := strings.Cut(source, ":")
+	factory, ok := registry[kind]
+	if !ok || (path == "" && !optionalPath[kind]) {
+		return nil, fmt.Errorf("unknown bookmarks source '%s', use one of %s followed by :path", source, strings.Join(Kinds(), ", "))
+	}
+	return factory(path), nil
+}
@@ -0,0 +1,140 @@
+package sources
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/bookmarks"
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/firefox"
+)
+
+// placesDB creates a places.sqlite database from testdata/places.sql
+func placesDB(t *testing.T) string {
+	t.Helper()
+
+	schema, err := os.ReadFile("testdata/places.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "places.sqlite")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNew(t *testing.T) {
+	t.Setenv("FFSCLIENT_ARGS", filepath.Join(t.TempDir(), "args"))
+	ffsclient, err := filepath.Abs("../firefox/testdata/ffsclient.sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		source string
+		want   string
+		// path of the https://go.dev/ bookmark
+		path string
+	}{
+		{"ffsclient:" + ffsclient, "*firefox.FirefoxFetcher", "toolbar/Reading/Go"},
+		{"places:" + placesDB(t), "*firefox.PlacesFetcher", "toolbar/Reading/Go"},
+		{"html:testdata/bookmarks.html", "*firefox.HTMLFetcher", "toolbar/Reading/Go"},
+		{"backup:../firefox/testdata/backup.json", "*firefox.BackupFetcher", "toolbar/Reading/Go"},
+		{"chrome:../chrome/testdata/Bookmarks", "*chrome.Fetcher", "toolbar/Reading/Go"},
+		{"safari:../safari/testdata/Bookmarks.plist", "*safari.Fetcher", "toolbar/Reading/Go"},
+		{"pocket:testdata/pocket.csv", "*firefox.ImportFetcher", "toolbar/Go"},
+		{"raindrop:testdata/raindrop.csv", "*firefox.ImportFetcher", "toolbar/Reading/Go"},
+	}
+
+	var kinds []string
+	for _, test := range tests {
+		kind, _, _ := strings.Cut(test.source, ":")
+		kinds = append(kinds, kind)
+		t.Run(kind, func(t *testing.T) {
+			fetcher, err := New(test.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprintf("%T", fetcher); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+
+			root, err := fetcher.GetBookmarks(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Every source has the roots of the Firefox tree
+			for i, folder := range root.Roots() {
+				if key := firefox.RootKeys[i]; folder.Title != "" && (folder.ID != key || folder.Type != bookmarks.TypeFolder) {
+					t.Errorf("got %s root %+v", key, folder)
+				}
+			}
+			page := root.Path(test.path)
+			if page == nil {
+				t.Fatalf("bookmark %s not found", test.path)
+			}
+			if page.Type != bookmarks.TypeBookmark || page.URI != "https://go.dev/" || page.ID == "" {
+				t.Errorf("got bookmark %+v", *page)
+			}
+		})
+	}
+
+	slices.Sort(kinds)
+	if !slices.Equal(kinds, Kinds()) {
+		t.Errorf("tested kinds %q, want every registered kind %q", kinds, Kinds())
+	}
+}
+
+func TestNewDefaultFFSClient(t *testing.T) {
+	fetcher, err := New(KindFFSClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fetcher.(*firefox.FirefoxFetcher).FFSyncCmd; got != firefox.DefaultFFSyncCmd {
+		t.Errorf("got command %q, want %q", got, firefox.DefaultFFSyncCmd)
+	}
+}
+
+func TestEveryKindIsSelectable(t *testing.T) {
+	for _, kind := range Kinds() {
+		if fetcher, err := New(kind + ":path"); err != nil || fetcher == nil {
+			t.Errorf("%s: got %v, %v", kind, fetcher, err)
+		}
+	}
+}
+
+func TestNewRejectsUnknownSources(t *testing.T) {
+	for _, source := range []string{"", "opera:Bookmarks", "places", "html:"} {
+		if _, err := New(source); err == nil {
+			t.Errorf("%q: got no error", source)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	defer func(factory Factory) { registry[KindFFSClient] = factory }(registry[KindFFSClient])
+
+	var got string
+	Register(KindFFSClient, func(path string) firefox.BookmarksFetcher {
+		got = path
+		return firefox.NewFirefoxFetcher(path, 0)
+	})
+	if _, err := New("ffsclient:/opt/ffsclient"); err != nil {
+		t.Fatal(err)
+	}
+	if got != "/opt/ffsclient" {
+		t.Errorf("registered factory got path %q", got)
+	}
+}
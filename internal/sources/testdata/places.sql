@@ -0,0 +1,11 @@
+CREATE TABLE moz_places (id INTEGER PRIMARY KEY, url TEXT);
+CREATE TABLE moz_bookmarks (id INTEGER PRIMARY KEY, type INTEGER, fk INTEGER, parent INTEGER, position INTEGER, title TEXT, dateAdded INTEGER, guid TEXT);
+INSERT INTO moz_places VALUES (1, 'https://go.dev/');
+INSERT INTO moz_bookmarks VALUES
+	(1, 2, NULL, 0, 0, '', 0, 'root________'),
+	(2, 2, NULL, 1, 0, 'menu', 0, 'menu________'),
+	(3, 2, NULL, 1, 1, 'toolbar', 0, 'toolbar_____'),
+	(4, 2, NULL, 1, 2, 'unfiled', 0, 'unfiled_____'),
+	(5, 2, NULL, 1, 3, 'mobile', 0, 'mobile______'),
+	(6, 2, NULL, 3, 0, 'Reading', 1709294400000000, 'readingfoldr'),
+	(7, 1, 1, 6, 0, 'Go', 1709294400000000, 'gobookmark00');
@@ -0,0 +1,132 @@
+package web
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// syncCache is an in-memory x.Cache safe for concurrent use
+type syncCache struct {
+	mu      sync.Mutex
+	entries map[string]string
+}
+
+func (c *syncCache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	content, ok := c.entries[key]
+	return content, ok
+}
+
+func (c *syncCache) Set(key string, content string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = content
+	return nil
+}
+
+// upperCleaner upper-cases content and counts its calls
+type upperCleaner struct {
+	calls atomic.Int32
+}
+
+func (c *upperCleaner) CleanMarkdown(content string, contentType string) (string, error) {
+	c.calls.Add(1)
+	return strings.ToUpper(content), nil
+}
+
+func TestCleanBatch(t *testing.T) {
+	var fetches atomic.Int32
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		fetches.Add(1)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader("# Page " + req.URL.Query().Get("url"))),
+			Request:    req,
+		}, nil
+	})}
+	cache := &syncCache{entries: make(map[string]string)}
+	cleaner := &upperCleaner{}
+	service, err := NewContentService(client, FetchOptions{
+		BaseURL:        "http://converter",
+		ContentCleaner: cleaner,
+		Cache:          cache,
+		BatchClean:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	urls := []string{"https://example.com/a", "https://example.com/b", "https://example.com/c"}
+
+	// Content fetched by an interrupted run is not fetched again
+	cache.Set(rawKey(urls[2]), "# Page https://example.com/c")
+
+	service.CleanBatch(context.Background(), urls, 2)
+	if got := fetches.Load(); got != 2 {
+		t.Errorf("got %d fetches, want 2", got)
+	}
+	if got := cleaner.calls.Load(); got != 3 {
+		t.Errorf("got %d cleaner calls, want 3", got)
+	}
+	for _, u := range urls {
+		if raw, _ := cache.Get(rawKey(u)); raw != "# Page "+u {
+			t.Errorf("got raw content %q for %s", raw, u)
+		}
+	}
+
+	// Notes are written from the cleaned cache
+	for _, u := range urls {
+		content, err := service.FetchContent(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := strings.ToUpper("# Page " + u); content != want {
+			t.Errorf("got %q for %s, want %q", content, u, want)
+		}
+	}
+	if fetches.Load() != 2 || cleaner.calls.Load() != 3 {
+		t.Errorf("got %d fetches and %d cleaner calls after the batch, want no more", fetches.Load(), cleaner.calls.Load())
+	}
+}
+
+func TestCacheKeysIncludeRaw(t *testing.T) {
+	u := "https://example.com/a"
+	if keys := CacheKeys(u, nil); !slices.Contains(keys, rawKey(u)) {
+		t.Errorf("got keys %q, want the raw content key %q among them", keys, rawKey(u))
+	}
+}
+
+// keyCleaner caches cleaned content under a key of the content
+type keyCleaner struct {
+	upperCleaner
+}
+
+func (c *keyCleaner) CacheKeys(content string, contentType string) []string {
+	return []string{"clean-" + contentType + "-" + content}
+}
+
+func TestContentServiceCacheKeys(t *testing.T) {
+	cache := &syncCache{entries: make(map[string]string)}
+	service, err := NewContentService(http.DefaultClient, FetchOptions{
+		BaseURL:        "http://converter",
+		ContentCleaner: &keyCleaner{},
+		Cache:          cache,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := "https://example.com/a"
+	cache.Set(rawKey(u), "# Page")
+	keys := service.CacheKeys(u)
+	if !slices.Contains(keys, "clean-article-# Page") || !slices.Contains(keys, URLKey(u)) {
+		t.Errorf("got keys %q, want the cleaned and content keys among them", keys)
+	}
+}
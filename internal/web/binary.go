@@ -0,0 +1,34 @@
+package web
+
+import (
+	"errors"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrBinaryContent is returned when fetched content does not look like text
+var ErrBinaryContent = errors.New("content is binary")
+
+// minPrintableRatio is the minimum share of printable runes in text content
+const minPrintableRatio = 0.95
+
+// isBinaryContent checks whether content looks like binary data rather than text
+func isBinaryContent(content string) bool {
+	if !utf8.ValidString(content) {
+		return true
+	}
+
+	var total, printable int
+	for _, r := range content {
+		total++
+		if unicode.IsPrint(r) || unicode.IsSpace(r) {
+			printable++
+		}
+	}
+
+	if total == 0 {
+		return false
+	}
+
+	return float64(printable)/float64(total) < minPrintableRatio
+}
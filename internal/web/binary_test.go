@@ -0,0 +1,87 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// recordingCleaner records the content it is asked to clean
+type recordingCleaner struct {
+	cleaned      []string
+	contentTypes []string
+}
+
+func (c *recordingCleaner) CleanMarkdown(content string, contentType string) (string, error) {
+	c.cleaned = append(c.cleaned, content)
+	c.contentTypes = append(c.contentTypes, contentType)
+	return content, nil
+}
+
+func TestFetchBinaryContent(t *testing.T) {
+	// The converter passes through what the page serves
+	pages := map[string]struct {
+		contentType string
+		body        string
+	}{
+		"/report.pdf":  {"application/pdf", "%PDF-1.7\n%\xe2\xe3\xcf\xd3\n1 0 obj\n<< /Type /Catalog >>\nstream\n\x00\x01\x02\xff\xfe\nendstream\n"},
+		"/archive.bin": {"application/octet-stream", "\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\x03\x01\x02\x03\x04"},
+		"/article":     {"text/plain", "# Article\n\nSome text"},
+	}
+	converter := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, err := url.Parse(r.URL.Query().Get("url"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		page := pages[u.Path]
+		w.Header().Set("Content-Type", page.contentType)
+		w.Write([]byte(page.body))
+	}))
+	defer converter.Close()
+
+	for _, path := range []string{"/report.pdf", "/archive.bin"} {
+		cleaner := &recordingCleaner{}
+		service, err := NewContentService(converter.Client(), FetchOptions{BaseURL: converter.URL, ContentCleaner: cleaner})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		content, err := service.FetchContent("https://example.com" + path)
+		if !errors.Is(err, ErrBinaryContent) {
+			t.Errorf("%s: got content %q and error %v, want ErrBinaryContent", path, content, err)
+		}
+		if len(cleaner.cleaned) != 0 {
+			t.Errorf("%s: binary content was sent to the cleaner", path)
+		}
+	}
+
+	cleaner := &recordingCleaner{}
+	service, err := NewContentService(converter.Client(), FetchOptions{BaseURL: converter.URL, ContentCleaner: cleaner})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := service.FetchContent("https://example.com/article")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "# Article\nSome text" || len(cleaner.cleaned) != 1 {
+		t.Errorf("got content %q cleaned %d times, want the converted article cleaned once", content, len(cleaner.cleaned))
+	}
+}
+
+func TestIsBinaryContent(t *testing.T) {
+	for content, want := range map[string]bool{
+		"":                          false,
+		"Plain text\nwith lines\t.": false,
+		"Ünïcödé text – ok":         false,
+		"\xff\xfe invalid utf-8":    true,
+		"text\x00\x00\x01\x02\x03":  true,
+	} {
+		if got := isBinaryContent(content); got != want {
+			t.Errorf("isBinaryContent(%q) = %v, want %v", content, got, want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// DefaultMaxContentSize is the default limit for fetched content in bytes
+const DefaultMaxContentSize = 5 << 20
+
+// ErrContentTooLarge is returned when fetched content exceeds the size limit
+var ErrContentTooLarge = errors.New("content is too large")
+
+// readBody reads at most limit bytes of a response body, returning
+// ErrContentTooLarge if the body is longer
+func readBody(resp *http.Response, limit int64) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrContentTooLarge, limit)
+	}
+	return body, nil
+}
+
+// maxDrainSize limits how much of an unread body is drained for connection reuse
+const maxDrainSize = 64 << 10
+
+// closeBody drains a small remainder of a response body, so the connection
+// can be reused, and closes it
+func closeBody(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainSize))
+	resp.Body.Close()
+}
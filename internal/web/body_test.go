@@ -0,0 +1,70 @@
+package web
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestReadBody(t *testing.T) {
+	for _, tt := range []struct {
+		body    string
+		limit   int64
+		wantErr bool
+	}{
+		{"12345", 5, false},
+		{"123456", 5, true},
+		{"", 5, false},
+	} {
+		resp := &http.Response{Body: io.NopCloser(strings.NewReader(tt.body))}
+		got, err := readBody(resp, tt.limit)
+		if tt.wantErr {
+			if !errors.Is(err, ErrContentTooLarge) {
+				t.Errorf("%q limited to %d: got error %v, want ErrContentTooLarge", tt.body, tt.limit, err)
+			}
+			continue
+		}
+		if err != nil || string(got) != tt.body {
+			t.Errorf("%q limited to %d: got %q, %v", tt.body, tt.limit, got, err)
+		}
+	}
+}
+
+func TestMaxContentSize(t *testing.T) {
+	var closed []string
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		status := http.StatusOK
+		if req.URL.Host == "raw.githubusercontent.com" && !strings.HasSuffix(req.URL.Path, "/README.org") {
+			status = http.StatusNotFound
+		}
+		body := &trackedBody{Reader: strings.NewReader(strings.Repeat("x", 100)), done: func() {
+			closed = append(closed, req.URL.String())
+		}}
+		return &http.Response{StatusCode: status, Body: body, Request: req}, nil
+	})}
+
+	service, err := NewContentService(client, FetchOptions{BaseURL: "http://converter", MaxContentSize: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []string{"https://example.com/large", "https://github.com/example/repo"} {
+		if _, err := service.FetchContent(u); !errors.Is(err, ErrContentTooLarge) {
+			t.Errorf("%s: got error %v, want ErrContentTooLarge", u, err)
+		}
+	}
+
+	// Every response is closed, including the two READMEs that were not found
+	if len(closed) != 4 {
+		t.Errorf("got %d closed responses, want 4: %q", len(closed), closed)
+	}
+
+	// Content within the limit is fetched
+	github := NewGitHubFetcher(client, 100, "")
+	u, _ := url.Parse("https://github.com/example/repo")
+	if content, err := github.Fetch(u); err != nil || len(content) != 100 {
+		t.Errorf("got %d bytes and error %v, want the whole README", len(content), err)
+	}
+}
@@ -0,0 +1,137 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned while a failing service is not being called
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// Circuit breaker states
+const (
+	circuitClosed   = "closed"
+	circuitOpen     = "open"
+	circuitHalfOpen = "half-open"
+)
+
+// CircuitBreaker stops calling a service after repeated failures. After
+// threshold consecutive failures within window the circuit opens for cooldown,
+// then a single probe request decides whether it closes again.
+type CircuitBreaker struct {
+	name      string
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu          sync.Mutex
+	state       string
+	failures    int
+	streakStart time.Time
+	openedAt    time.Time
+	probing     bool
+	trips       int
+}
+
+// NewCircuitBreaker creates a circuit breaker for the named service
+func NewCircuitBreaker(name string, threshold int, window, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		name:      name,
+		threshold: threshold,
+		window:    window,
+		cooldown:  cooldown,
+		now:       time.Now,
+		state:     circuitClosed,
+	}
+}
+
+// Allow checks whether a request may be made, returning ErrCircuitOpen if not
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.setState(circuitHalfOpen)
+		b.probing = true
+		return nil
+	case circuitHalfOpen:
+		// Only a single probe request is allowed
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+
+	return nil
+}
+
+// Success records a successful request
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.probing = false
+	if b.state != circuitClosed {
+		b.setState(circuitClosed)
+	}
+}
+
+// Failure records a failed request
+func (b *CircuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.probing = false
+
+	if b.state == circuitHalfOpen {
+		b.open(now)
+		return
+	}
+
+	if b.failures == 0 || now.Sub(b.streakStart) > b.window {
+		b.failures = 0
+		b.streakStart = now
+	}
+	b.failures++
+
+	if b.failures >= b.threshold {
+		b.open(now)
+	}
+}
+
+// Trips returns how many times the circuit has opened
+func (b *CircuitBreaker) Trips() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.trips
+}
+
+func (b *CircuitBreaker) open(now time.Time) {
+	b.openedAt = now
+	b.failures = 0
+	b.trips++
+	b.setState(circuitOpen)
+}
+
+func (b *CircuitBreaker) setState(state string) {
+	level := slog.LevelInfo
+	if state == circuitOpen {
+		level = slog.LevelWarn
+	}
+
+	slog.Log(context.Background(), level, "circuit breaker state changed",
+		"service", b.name,
+		"from", b.state,
+		"to", state)
+	b.state = state
+}
@@ -0,0 +1,173 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testBreaker returns a breaker with a clock advanced by the returned function
+func testBreaker(threshold int, window, cooldown time.Duration) (*CircuitBreaker, func(time.Duration)) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	b := NewCircuitBreaker("test", threshold, window, cooldown)
+	b.now = func() time.Time { return now }
+	return b, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	b, advance := testBreaker(3, time.Minute, 30*time.Second)
+
+	for range 2 {
+		if err := b.Allow(); err != nil {
+			t.Fatal(err)
+		}
+		b.Failure()
+	}
+	if err := b.Allow(); err != nil {
+		t.Fatalf("got %v before the threshold", err)
+	}
+	b.Failure()
+
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("got %v after 3 failures, want ErrCircuitOpen", err)
+	}
+	if b.Trips() != 1 {
+		t.Errorf("got %d trips, want 1", b.Trips())
+	}
+
+	// After the cooldown a single probe is let through
+	advance(31 * time.Second)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("got %v for the probe", err)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("got %v during the probe, want ErrCircuitOpen", err)
+	}
+
+	// A failed probe opens the circuit again
+	b.Failure()
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("got %v after a failed probe, want ErrCircuitOpen", err)
+	}
+	if b.Trips() != 2 {
+		t.Errorf("got %d trips, want 2", b.Trips())
+	}
+
+	// A successful probe closes it
+	advance(31 * time.Second)
+	if err := b.Allow(); err != nil {
+		t.Fatal(err)
+	}
+	b.Success()
+	for range 2 {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("got %v after a successful probe", err)
+		}
+	}
+}
+
+func TestCircuitBreakerWindow(t *testing.T) {
+	b, advance := testBreaker(3, time.Minute, 30*time.Second)
+
+	// Failures spread wider than the window don't open the circuit
+	for range 5 {
+		b.Failure()
+		b.Failure()
+		advance(2 * time.Minute)
+	}
+	if err := b.Allow(); err != nil {
+		t.Errorf("got %v for failures outside the window", err)
+	}
+
+	// A success resets the count
+	b.Failure()
+	b.Failure()
+	b.Success()
+	b.Failure()
+	if err := b.Allow(); err != nil {
+		t.Errorf("got %v after a success between failures", err)
+	}
+}
+
+func TestMarkdownFetcherBreaker(t *testing.T) {
+	var requests atomic.Int32
+	status := atomic.Int32{}
+	status.Store(http.StatusBadGateway)
+	converter := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(int(status.Load()))
+		w.Write([]byte("# Page"))
+	}))
+	defer converter.Close()
+
+	b, advance := testBreaker(2, time.Minute, time.Minute)
+	fetcher := NewMarkdownFetcher(converter.Client(), converter.URL, b, DefaultMaxContentSize)
+	page, _ := url.Parse("https://example.com/")
+
+	for range 2 {
+		if _, err := fetcher.Fetch(page); !errors.Is(err, CategoryConverterUnavailable) {
+			t.Fatalf("got %v, want the converter unavailable", err)
+		}
+	}
+	if _, err := fetcher.Fetch(page); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("got %v, want ErrCircuitOpen", err)
+	}
+	if requests.Load() != 2 {
+		t.Errorf("got %d requests, want none while the circuit is open", requests.Load())
+	}
+
+	// Pages the converter reports as missing don't count as failures
+	advance(2 * time.Minute)
+	status.Store(http.StatusNotFound)
+	for range 3 {
+		if _, err := fetcher.Fetch(page); err == nil || errors.Is(err, CategoryConverterUnavailable) || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("got %v, want a plain request failure", err)
+		}
+	}
+
+	status.Store(http.StatusOK)
+	if content, err := fetcher.Fetch(page); err != nil || content != "# Page" {
+		t.Errorf("got %q, %v after the converter recovered", content, err)
+	}
+}
+
+func TestMarkdownFetcherBreakerServerErrors(t *testing.T) {
+	var requests atomic.Int32
+	status := atomic.Int32{}
+	status.Store(http.StatusNotImplemented)
+	converter := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer converter.Close()
+
+	b, _ := testBreaker(2, time.Minute, time.Minute)
+	fetcher := NewMarkdownFetcher(converter.Client(), converter.URL, b, DefaultMaxContentSize)
+	page, _ := url.Parse("https://example.com/")
+
+	// Requests the converter doesn't support are not its failures
+	for range 3 {
+		if _, err := fetcher.Fetch(page); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("got %v, want a plain request failure", err)
+		}
+	}
+
+	// Internal errors keep their category but open the circuit
+	status.Store(http.StatusInternalServerError)
+	for range 2 {
+		_, err := fetcher.Fetch(page)
+		if got := CategoryOf(err); got != CategoryOther {
+			t.Fatalf("got %s for an internal error, want %s", got, CategoryOther)
+		}
+	}
+	if _, err := fetcher.Fetch(page); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("got %v, want ErrCircuitOpen", err)
+	}
+	if requests.Load() != 5 {
+		t.Errorf("got %d requests, want none while the circuit is open", requests.Load())
+	}
+}
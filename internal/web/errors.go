@@ -0,0 +1,90 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// Category classifies why a bookmark could not be turned into a note.
+// Errors wrap their category, so errors.Is tells categories apart.
+type Category string
+
+func (c Category) Error() string {
+	return string(c)
+}
+
+// Failure categories
+const (
+	CategoryNotFound             Category = "fetch-not-found"
+	CategoryTimeout              Category = "fetch-timeout"
+	CategoryBlocked              Category = "fetch-blocked"
+	CategoryConverterUnavailable Category = "converter-unavailable"
+	CategoryLLMRateLimited       Category = "llm-rate-limited"
+	CategoryLLMRefused           Category = "llm-refused"
+	CategoryWriteFailed          Category = "write-failed"
+	// CategoryOther is every failure without a known category
+	CategoryOther Category = "other"
+)
+
+// categories lists the known categories in the order they are matched
+var categories = []Category{
+	CategoryNotFound,
+	CategoryBlocked,
+	CategoryTimeout,
+	CategoryConverterUnavailable,
+	CategoryLLMRateLimited,
+	CategoryLLMRefused,
+	CategoryWriteFailed,
+}
+
+// CategoryOf returns the category of an error
+func CategoryOf(err error) Category {
+	switch {
+	case errors.Is(err, ErrCircuitOpen):
+		return CategoryConverterUnavailable
+	case errors.Is(err, ErrHostNotAllowed):
+		return CategoryBlocked
+	case isTimeout(err):
+		return CategoryTimeout
+	}
+
+	for _, category := range categories {
+		if errors.Is(err, category) {
+			return category
+		}
+	}
+	return CategoryOther
+}
+
+// Transient reports whether failures of a category are likely to go away on
+// a later run
+func (c Category) Transient() bool {
+	switch c {
+	case CategoryTimeout, CategoryConverterUnavailable, CategoryLLMRateLimited:
+		return true
+	}
+	return false
+}
+
+// isTimeout checks whether an error is a timed out request
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// statusError returns an error for an unexpected HTTP status of a fetched
+// page, wrapping the category of the status
+func statusError(what string, status int) error {
+	switch status {
+	case http.StatusNotFound, http.StatusGone:
+		return fmt.Errorf("%w: %s failed with status: %d", CategoryNotFound, what, status)
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests, http.StatusUnavailableForLegalReasons:
+		return fmt.Errorf("%w: %s failed with status: %d", CategoryBlocked, what, status)
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return fmt.Errorf("%w: %s failed with status: %d", CategoryTimeout, what, status)
+	}
+	return fmt.Errorf("%s failed with status: %d", what, status)
+}
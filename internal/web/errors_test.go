@@ -0,0 +1,143 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestCategoryOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Category
+	}{
+		{"not found", statusError("request", http.StatusNotFound), CategoryNotFound},
+		{"gone", statusError("request", http.StatusGone), CategoryNotFound},
+		{"forbidden", statusError("request", http.StatusForbidden), CategoryBlocked},
+		{"too many requests", statusError("request", http.StatusTooManyRequests), CategoryBlocked},
+		{"request timeout", statusError("request", http.StatusRequestTimeout), CategoryTimeout},
+		{"gateway timeout", statusError("request", http.StatusGatewayTimeout), CategoryTimeout},
+		{"teapot", statusError("request", http.StatusTeapot), CategoryOther},
+		{"deadline", fmt.Errorf("fetch: %w", context.DeadlineExceeded), CategoryTimeout},
+		{"circuit open", fmt.Errorf("fetch: %w", ErrCircuitOpen), CategoryConverterUnavailable},
+		{"host not allowed", fmt.Errorf("fetch: %w", ErrHostNotAllowed), CategoryBlocked},
+		{"rate limited", fmt.Errorf("%w: quota exhausted", CategoryLLMRateLimited), CategoryLLMRateLimited},
+		{"refused", fmt.Errorf("%w: refused", CategoryLLMRefused), CategoryLLMRefused},
+		{"write failed", fmt.Errorf("%w: disk full", CategoryWriteFailed), CategoryWriteFailed},
+		{"other", errors.New("something else"), CategoryOther},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := CategoryOf(test.err); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMarkdownFetcherCategories(t *testing.T) {
+	tests := []struct {
+		status int
+		want   Category
+	}{
+		{http.StatusNotFound, CategoryNotFound},
+		{http.StatusForbidden, CategoryBlocked},
+		{http.StatusInternalServerError, CategoryOther},
+		{http.StatusBadGateway, CategoryConverterUnavailable},
+		{http.StatusServiceUnavailable, CategoryConverterUnavailable},
+		{http.StatusGatewayTimeout, CategoryConverterUnavailable},
+	}
+
+	page, _ := url.Parse("https://example.com/page")
+	for _, test := range tests {
+		t.Run(http.StatusText(test.status), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+			}))
+			defer server.Close()
+
+			_, err := NewMarkdownFetcher(server.Client(), server.URL, nil, DefaultMaxContentSize).Fetch(page)
+			if got := CategoryOf(err); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+
+	t.Run("unreachable", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+
+		_, err := NewMarkdownFetcher(server.Client(), server.URL, nil, DefaultMaxContentSize).Fetch(page)
+		if got := CategoryOf(err); got != CategoryConverterUnavailable {
+			t.Errorf("got %s, want %s", got, CategoryConverterUnavailable)
+		}
+	})
+}
+
+// failingCleaner fails every request with err
+type failingCleaner struct {
+	err error
+}
+
+func (c failingCleaner) CleanMarkdown(content string, contentType string) (string, error) {
+	return "", c.err
+}
+
+func TestContentServiceCleanErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "Page content")
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		err     error
+		want    Category
+		content string
+	}{
+		{"rate limited", fmt.Errorf("%w: quota exhausted", CategoryLLMRateLimited), CategoryLLMRateLimited, ""},
+		{"refused", fmt.Errorf("%w: refused", CategoryLLMRefused), CategoryLLMRefused, ""},
+		{"other", errors.New("answer truncated"), "", "Page content"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service, err := NewContentService(server.Client(), FetchOptions{
+				BaseURL:        server.URL,
+				ContentCleaner: failingCleaner{err: test.err},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			content, err := service.FetchContent("https://example.com/page")
+			if test.want != "" {
+				if got := CategoryOf(err); got != test.want {
+					t.Errorf("got %s, want %s", got, test.want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if content != test.content {
+				t.Errorf("got content %q, want the original %q", content, test.content)
+			}
+		})
+	}
+}
+
+func TestCategoryTransient(t *testing.T) {
+	for _, category := range categories {
+		want := category == CategoryTimeout || category == CategoryConverterUnavailable || category == CategoryLLMRateLimited
+		if got := category.Transient(); got != want {
+			t.Errorf("%s: got transient %v, want %v", category, got, want)
+		}
+	}
+}
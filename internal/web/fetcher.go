@@ -4,17 +4,62 @@
 package web
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
 	"log/slog"
 	"net/url"
+	"slices"
+	"sync"
 
 	"github.com/xtruder/ffbookmarks-to-markdown/internal/x"
 )
 
 type ContentCleaner interface {
-	CleanMarkdown(content string) (string, error)
+	CleanMarkdown(content string, contentType string) (string, error)
+}
+
+// ContentRecleaner is a ContentCleaner that can clean content again instead
+// of reusing a cached result, for refreshed content
+type ContentRecleaner interface {
+	RecleanMarkdown(content string, contentType string) (string, error)
+}
+
+// CachingCleaner is a ContentCleaner that can list the keys it caches cleaned
+// content under, so they can be removed with the content of a URL
+type CachingCleaner interface {
+	CacheKeys(content string, contentType string) []string
+}
+
+// Content sources, named after the fetcher that produced the content
+const (
+	SourceGeneric = "generic"
+	SourceGitHub  = "github"
+	SourceYouTube = "youtube"
+)
+
+// Sources lists all content sources
+var Sources = []string{SourceGeneric, SourceGitHub, SourceYouTube}
+
+// Content types, selecting the prompt the ContentCleaner uses
+const (
+	ContentArticle    = "article"
+	ContentReadme     = "readme"
+	ContentDiscussion = "discussion"
+)
+
+// ContentTypes lists all content types
+var ContentTypes = []string{ContentArticle, ContentReadme, ContentDiscussion}
+
+// discussionHosts are sites whose pages are discussion threads
+var discussionHosts = []string{
+	"news.ycombinator.com",
+	"reddit.com",
+	"old.reddit.com",
+	"www.reddit.com",
+	"lobste.rs",
+	"tildes.net",
 }
 
 // FetchOptions contains configuration for content fetching
@@ -23,48 +68,258 @@ type FetchOptions struct {
 	ScreenshotURL  string
 	Cache          x.Cache
 	ContentCleaner ContentCleaner
+	// CleanSources lists the content sources cleaned by the ContentCleaner,
+	// defaults to generic pages only
+	CleanSources []string
+	// CleanMinLength is the content length below which content is not cleaned
+	CleanMinLength int
+	// BatchClean keeps fetched content uncleaned in the cache until
+	// CleanBatch cleans it, instead of cleaning each page right after fetching
+	BatchClean bool
+	// Breaker guards calls to the markdown converter service
+	Breaker *CircuitBreaker
+	// MaxContentSize limits fetched content in bytes, defaults to DefaultMaxContentSize
+	MaxContentSize int64
+	// GitHubToken authenticates README requests to GitHub
+	GitHubToken string
+	// TrackingParams are stripped from URLs for cache keys, defaults to
+	// DefaultTrackingParams
+	TrackingParams []string
 }
 
 // ContentService handles web content fetching
 type ContentService struct {
-	youtube  ContentFetcher
-	github   ContentFetcher
-	markdown ContentFetcher
-	cache    x.Cache
+	youtube        ContentFetcher
+	github         ContentFetcher
+	markdown       ContentFetcher
+	metadata       *MetadataFetcher
+	cache          x.Cache
+	cleaner        ContentCleaner
+	cleanSources   []string
+	cleanMin       int
+	batchClean     bool
+	trackingParams []string
 }
 
 // NewContentService creates a new content fetching service
-func NewContentService(client HTTPClient, opts FetchOptions) *ContentService {
-	return &ContentService{
-		youtube:  NewYouTubeFetcher(),
-		github:   NewGitHubFetcher(client),
-		markdown: NewMarkdownFetcher(client, opts.BaseURL, opts.ContentCleaner),
-		cache:    opts.Cache,
+func NewContentService(client HTTPClient, opts FetchOptions) (*ContentService, error) {
+	baseURL, err := x.NormalizeBaseURL(opts.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("markdown converter: %w", err)
+	}
+
+	maxSize := opts.MaxContentSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxContentSize
 	}
+
+	cleanSources := opts.CleanSources
+	if cleanSources == nil {
+		cleanSources = []string{SourceGeneric}
+	}
+
+	trackingParams := opts.TrackingParams
+	if trackingParams == nil {
+		trackingParams = DefaultTrackingParams
+	}
+
+	return &ContentService{
+		youtube:        NewYouTubeFetcher(),
+		github:         NewGitHubFetcher(client, maxSize, opts.GitHubToken),
+		markdown:       NewMarkdownFetcher(client, baseURL, opts.Breaker, maxSize),
+		metadata:       NewMetadataFetcher(client, opts.Cache, trackingParams),
+		cache:          opts.Cache,
+		cleaner:        opts.ContentCleaner,
+		cleanSources:   cleanSources,
+		cleanMin:       opts.CleanMinLength,
+		batchClean:     opts.BatchClean,
+		trackingParams: trackingParams,
+	}, nil
 }
 
 // FetchContent fetches content from a URL based on its type
 func (s *ContentService) FetchContent(u string) (string, error) {
+	return s.fetchContent(u, true, true)
+}
+
+// FetchMetadata fetches licensing hints of a URL
+func (s *ContentService) FetchMetadata(u string) (Metadata, error) {
+	return s.metadata.Fetch(u)
+}
+
+// RefreshContent fetches content from a URL bypassing the cache
+func (s *ContentService) RefreshContent(u string) (string, error) {
+	return s.fetchContent(u, false, false)
+}
+
+// RefetchContent fetches content from a URL bypassing the content cache, but
+// reuses the cached cleaning of content that did not change
+func (s *ContentService) RefetchContent(u string) (string, error) {
+	return s.fetchContent(u, false, true)
+}
+
+// fetchContent fetches content from a URL, useCache reusing cached content
+// and cleanCache cached cleaning
+func (s *ContentService) fetchContent(u string, useCache bool, cleanCache bool) (string, error) {
 	parsedURL, err := url.Parse(u)
 	if err != nil {
 		return "", fmt.Errorf("invalid URL: %w", err)
 	}
 
 	// Try cache first
-	if s.cache != nil {
-		if content, ok := s.cache.Get(getURLKey(u)); ok {
+	if s.cache != nil && useCache {
+		if content, ok := s.cached(u, URLKey); ok {
 			slog.Debug("using cached content", "url", u)
 			return content, nil
 		}
 	}
 
+	content, source, err := s.fetchRaw(u, parsedURL, useCache)
+	if err != nil {
+		return "", err
+	}
+
+	content, err = s.clean(source, contentType(source, parsedURL), content, cleanCache)
+	if err != nil {
+		return "", err
+	}
+
+	// Cache the content
+	if s.cache != nil {
+		if err := s.cache.Set(URLKey(s.normalize(u)), content); err != nil {
+			slog.Warn("failed to cache content", "error", err)
+		}
+	}
+
+	return content, nil
+}
+
+// FetchPlan describes what FetchContent does for a URL
+type FetchPlan struct {
+	// Cached is set if cleaned content of the URL is cached
+	Cached bool
+	// Fetch is set if neither cleaned nor uncleaned content is cached
+	Fetch bool
+	// Clean is set if content of the URL is cleaned by the LLM once fetched
+	Clean bool
+	// Length is the length of cached content, cleaned or not, 0 if unknown
+	Length int
+}
+
+// PlanFetch describes what fetching the content of a URL involves without
+// fetching it, from what is cached for it
+func (s *ContentService) PlanFetch(u string) FetchPlan {
+	plan := FetchPlan{
+		Fetch: true,
+		Clean: s.cleaner != nil && slices.Contains(s.cleanSources, SourceOf(u)),
+	}
+	if s.cache == nil {
+		return plan
+	}
+
+	if content, ok := s.cached(u, URLKey); ok {
+		plan.Cached, plan.Fetch, plan.Length = true, false, len(content)
+	} else if content, ok := s.cached(u, rawKey); ok {
+		plan.Fetch, plan.Length = false, len(content)
+	}
+	if plan.Length > 0 && plan.Length < s.cleanMin {
+		plan.Clean = false
+	}
+	return plan
+}
+
+// CleanBatch fetches all URLs that are not cached yet and then cleans the
+// fetched content with up to concurrency parallel cleaner calls, until ctx
+// is done. Fetch failures are left for FetchContent to report.
+func (s *ContentService) CleanBatch(ctx context.Context, urls []string, concurrency int) {
+	type fetched struct {
+		url     string
+		parsed  *url.URL
+		source  string
+		content string
+	}
+
+	var pending []fetched
+	for i, u := range urls {
+		if ctx.Err() != nil {
+			return
+		}
+		if s.cache != nil {
+			if _, ok := s.cached(u, URLKey); ok {
+				continue
+			}
+		}
+
+		parsedURL, err := url.Parse(u)
+		if err != nil {
+			continue
+		}
+
+		slog.Info("fetching content for batch cleaning", "url", u, "progress", fmt.Sprintf("%d/%d", i+1, len(urls)))
+		content, source, err := s.fetchRaw(u, parsedURL, true)
+		if err != nil {
+			slog.Warn("failed to fetch content for batch cleaning", "url", u, "error", err)
+			continue
+		}
+		pending = append(pending, fetched{url: u, parsed: parsedURL, source: source, content: content})
+	}
+
+	slog.Info("cleaning fetched content", "count", len(pending), "concurrency", concurrency)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	done := 0
+	sem := make(chan struct{}, max(concurrency, 1))
+	for _, page := range pending {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			content, err := s.clean(page.source, contentType(page.source, page.parsed), page.content, true)
+			if err != nil {
+				slog.Warn("failed to clean content for batch cleaning", "url", page.url, "category", CategoryOf(err), "error", err)
+				return
+			}
+			if s.cache != nil {
+				if err := s.cache.Set(URLKey(s.normalize(page.url)), content); err != nil {
+					slog.Warn("failed to cache content", "error", err)
+				}
+			}
+
+			mu.Lock()
+			done++
+			slog.Info("cleaned content", "url", page.url, "progress", fmt.Sprintf("%d/%d", done, len(pending)))
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+}
+
+// fetchRaw fetches uncleaned content from a URL. In batch mode the content
+// is cached until it is cleaned, so an interrupted cleaning phase does not
+// fetch it again.
+func (s *ContentService) fetchRaw(u string, parsedURL *url.URL, useCache bool) (string, string, error) {
+	source := contentSource(parsedURL)
+	if s.cache != nil && useCache {
+		if content, ok := s.cached(u, rawKey); ok {
+			slog.Debug("using cached raw content", "url", u)
+			return content, source, nil
+		}
+	}
+
 	// Fetch content based on URL type
 	var content string
-	switch parsedURL.Host {
-	case "youtube.com", "www.youtube.com", "youtu.be":
+	var err error
+	switch source {
+	case SourceYouTube:
 		slog.Info("generating YouTube embed", "url", u)
 		content, err = s.youtube.Fetch(parsedURL)
-	case "github.com", "www.github.com":
+	case SourceGitHub:
 		slog.Info("fetching GitHub README", "url", u)
 		content, err = s.github.Fetch(parsedURL)
 	default:
@@ -73,20 +328,144 @@ func (s *ContentService) FetchContent(u string) (string, error) {
 	}
 
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	// Cache the content
-	if s.cache != nil {
-		if err := s.cache.Set(getURLKey(u), content); err != nil {
-			slog.Warn("failed to cache content", "error", err)
+	// Don't send binary data to the cleaner
+	if isBinaryContent(content) {
+		return "", "", ErrBinaryContent
+	}
+
+	if s.cache != nil && s.batchClean {
+		if err := s.cache.Set(rawKey(s.normalize(u)), content); err != nil {
+			slog.Warn("failed to cache raw content", "error", err)
 		}
 	}
 
-	return content, nil
+	return content, source, nil
+}
+
+// SourceOf returns the content source of a URL
+func SourceOf(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return SourceGeneric
+	}
+	return contentSource(parsed)
+}
+
+// contentSource returns the fetcher used for a URL
+func contentSource(u *url.URL) string {
+	switch u.Host {
+	case "youtube.com", "www.youtube.com", "youtu.be":
+		return SourceYouTube
+	case "github.com", "www.github.com":
+		return SourceGitHub
+	default:
+		return SourceGeneric
+	}
 }
 
-func getURLKey(u string) string {
+// contentType returns the content type of a page fetched from a source
+func contentType(source string, u *url.URL) string {
+	switch {
+	case source == SourceGitHub:
+		return ContentReadme
+	case slices.Contains(discussionHosts, u.Host):
+		return ContentDiscussion
+	default:
+		return ContentArticle
+	}
+}
+
+// clean cleans content with the LLM if enabled for its source. Without
+// useCache, cleaners that can are asked to clean the content again. Rate
+// limited and refused requests fail, other cleaning errors fall back to the
+// original content.
+func (s *ContentService) clean(source string, contentType string, content string, useCache bool) (string, error) {
+	if s.cleaner == nil || !slices.Contains(s.cleanSources, source) {
+		return content, nil
+	}
+
+	// Short content rarely benefits from cleaning
+	if len(content) < s.cleanMin {
+		slog.Debug("content too short for LLM cleaning", "length", len(content))
+		return content, nil
+	}
+
+	clean := s.cleaner.CleanMarkdown
+	if recleaner, ok := s.cleaner.(ContentRecleaner); ok && !useCache {
+		clean = recleaner.RecleanMarkdown
+	}
+
+	cleaned, err := clean(content, contentType)
+	if category := CategoryOf(err); category == CategoryLLMRateLimited || category == CategoryLLMRefused {
+		return "", fmt.Errorf("LLM cleaning failed: %w", err)
+	}
+	if err != nil {
+		slog.Warn("LLM cleaning failed, using original content", "error", err)
+		return content, nil
+	}
+
+	return removeEmptyLines(cleaned), nil
+}
+
+// URLKey returns the cache key for content fetched from a URL
+func URLKey(u string) string {
 	hash := sha256.Sum256([]byte(u))
 	return base64.URLEncoding.EncodeToString(hash[:])
 }
+
+// rawKey returns the cache key for uncleaned content of a URL
+func rawKey(u string) string {
+	return "raw-" + URLKey(u)
+}
+
+// normalize returns the form of a URL its content is cached under
+func (s *ContentService) normalize(u string) string {
+	return NormalizeURL(u, s.trackingParams)
+}
+
+// cached looks up content of a URL cached under key of its normalized form,
+// falling back to key of the URL as given, which content cached before URLs
+// were normalized is stored under
+func (s *ContentService) cached(u string, key func(string) string) (string, bool) {
+	if content, ok := s.cache.Get(key(s.normalize(u))); ok {
+		return content, true
+	}
+	return s.cache.Get(key(u))
+}
+
+// CacheKeys returns the cache keys of everything cached for a URL, including
+// cleaned content cached by a CachingCleaner. Its keys depend on the
+// uncleaned content, which is fetched unless it is cached.
+func (s *ContentService) CacheKeys(u string) []string {
+	keys := CacheKeys(u, s.trackingParams)
+
+	cleaner, ok := s.cleaner.(CachingCleaner)
+	parsedURL, err := url.Parse(u)
+	if !ok || err != nil || !slices.Contains(s.cleanSources, contentSource(parsedURL)) {
+		return keys
+	}
+
+	content, source, err := s.fetchRaw(u, parsedURL, true)
+	if err != nil {
+		slog.Warn("failed to fetch content for its LLM cache keys", "url", u, "error", err)
+		return keys
+	}
+	if len(content) < s.cleanMin {
+		return keys
+	}
+	return append(keys, cleaner.CacheKeys(content, contentType(source, parsedURL))...)
+}
+
+// CacheKeys returns the cache keys of content, uncleaned content and metadata
+// cached for a URL, under its normalized form and the URL as given
+func CacheKeys(u string, trackingParams []string) []string {
+	normalized := NormalizeURL(u, trackingParams)
+	keys := []string{URLKey(normalized), rawKey(normalized), metadataKey(normalized)}
+	if normalized != u {
+		keys = append(keys, URLKey(u), rawKey(u), metadataKey(u))
+	}
+	return keys
+}
@@ -0,0 +1,123 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// roundTripFunc answers requests with a function
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// stubClient answers every request with a page naming the requested host
+func stubClient() *http.Client {
+	return &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader("# Page from " + req.URL.Host)),
+			Request:    req,
+		}, nil
+	})}
+}
+
+func TestCleanSources(t *testing.T) {
+	urls := map[string]string{
+		SourceGeneric: "https://example.com/article",
+		SourceGitHub:  "https://github.com/example/repo",
+		SourceYouTube: "https://www.youtube.com/watch?v=abc",
+	}
+
+	for _, tt := range []struct {
+		sources []string
+		cleaned []string
+	}{
+		{nil, []string{SourceGeneric}},
+		{[]string{SourceGitHub, SourceYouTube}, []string{SourceGitHub, SourceYouTube}},
+		{[]string{}, nil},
+	} {
+		for _, source := range Sources {
+			cleaner := &recordingCleaner{}
+			service, err := NewContentService(stubClient(), FetchOptions{
+				BaseURL:        "http://converter",
+				ContentCleaner: cleaner,
+				CleanSources:   tt.sources,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := service.FetchContent(urls[source]); err != nil {
+				t.Fatalf("%s: %v", source, err)
+			}
+			if want := slices.Contains(tt.cleaned, source); (len(cleaner.cleaned) == 1) != want {
+				t.Errorf("sources %q: got %s content cleaned %d times, want cleaned %v", tt.sources, source, len(cleaner.cleaned), want)
+			}
+		}
+	}
+}
+
+func TestContentType(t *testing.T) {
+	for _, tt := range []struct {
+		source string
+		url    string
+		want   string
+	}{
+		{SourceGitHub, "https://github.com/example/repo", ContentReadme},
+		{SourceGeneric, "https://news.ycombinator.com/item?id=1", ContentDiscussion},
+		{SourceGeneric, "https://old.reddit.com/r/golang/comments/x", ContentDiscussion},
+		{SourceGeneric, "https://example.com/post", ContentArticle},
+		{SourceYouTube, "https://www.youtube.com/watch?v=x", ContentArticle},
+	} {
+		u, _ := url.Parse(tt.url)
+		if got := contentType(tt.source, u); got != tt.want {
+			t.Errorf("contentType(%s, %s) = %s, want %s", tt.source, tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestCleanMinLength(t *testing.T) {
+	// The converted page "# Page from converter" is 21 bytes long
+	for minLength, want := range map[int]bool{0: true, 21: true, 22: false, 1000: false} {
+		cleaner := &recordingCleaner{}
+		service, err := NewContentService(stubClient(), FetchOptions{
+			BaseURL:        "http://converter",
+			ContentCleaner: cleaner,
+			CleanMinLength: minLength,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		content, err := service.FetchContent("https://example.com/article")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if content != "# Page from converter" {
+			t.Errorf("min length %d: got content %q", minLength, content)
+		}
+		if got := len(cleaner.cleaned) == 1; got != want {
+			t.Errorf("min length %d: got cleaned %v, want %v", minLength, got, want)
+		}
+	}
+}
+
+func TestSourceOf(t *testing.T) {
+	for u, want := range map[string]string{
+		"https://www.youtube.com/watch?v=abc": SourceYouTube,
+		"https://youtu.be/abc":                SourceYouTube,
+		"https://github.com/owner/repo":       SourceGitHub,
+		"https://example.com/page":            SourceGeneric,
+		"://invalid":                          SourceGeneric,
+	} {
+		if got := SourceOf(u); got != want {
+			t.Errorf("%s: got source %s, want %s", u, got, want)
+		}
+	}
+}
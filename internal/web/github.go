@@ -1,19 +1,26 @@
 package web
 
 import (
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
+	"path"
 	"strings"
 )
 
 type GitHubFetcher struct {
-	client HTTPClient
+	client  HTTPClient
+	maxSize int64
+	// token authenticates requests, which then go through the contents API
+	token string
 }
 
-func NewGitHubFetcher(client HTTPClient) *GitHubFetcher {
-	return &GitHubFetcher{client: client}
+// NewGitHubFetcher creates a fetcher for GitHub READMEs, rejecting files
+// longer than maxSize bytes. With a token, READMEs are fetched from the
+// GitHub API, which has higher rate limits and reaches private repositories.
+func NewGitHubFetcher(client HTTPClient, maxSize int64, token string) *GitHubFetcher {
+	return &GitHubFetcher{client: client, maxSize: maxSize, token: token}
 }
 
 func (f *GitHubFetcher) Fetch(u *url.URL) (string, error) {
@@ -22,9 +29,9 @@ func (f *GitHubFetcher) Fetch(u *url.URL) (string, error) {
 		return "", fmt.Errorf("invalid GitHub URL format")
 	}
 
+	// Links into a repository name a ref and a directory (tree) or file (blob)
 	repo := fmt.Sprintf("%s/%s", parts[0], parts[1])
-	baseURL := fmt.Sprintf("https://raw.githubusercontent.com/%s/HEAD/", repo)
-
+	ref, dir, file := "HEAD", "", ""
 	readmeFiles := []string{
 		"README.md",
 		"README.MD",
@@ -32,6 +39,32 @@ func (f *GitHubFetcher) Fetch(u *url.URL) (string, error) {
 		"Readme.md",
 		"readme.md",
 	}
+	if len(parts) >= 4 && (parts[2] == "tree" || parts[2] == "blob") {
+		ref = parts[3]
+		dir = strings.Join(parts[4:], "/")
+		if parts[2] == "blob" && dir != "" {
+			dir, file = path.Dir(dir), path.Base(dir)
+			readmeFiles = []string{file}
+		}
+		if dir == "." {
+			dir = ""
+		}
+	}
+	if dir != "" {
+		dir += "/"
+	}
+
+	rawRoot := fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/", repo, ref)
+	blobRoot := fmt.Sprintf("https://github.com/%s/blob/%s/", repo, ref)
+	baseURL := rawRoot + dir
+
+	if f.token != "" {
+		content, err := f.fetchAPI(repo, ref, dir, file)
+		if err != nil {
+			return "", err
+		}
+		return fixGitHubLinks(content, blobRoot, rawRoot, dir), nil
+	}
 
 	var lastErr error
 	for _, filename := range readmeFiles {
@@ -41,24 +74,94 @@ func (f *GitHubFetcher) Fetch(u *url.URL) (string, error) {
 			lastErr = fmt.Errorf("failed to fetch github readme: %w", err)
 			continue
 		}
-		defer resp.Body.Close()
 
 		if resp.StatusCode == http.StatusNotFound {
+			closeBody(resp)
+			lastErr = fmt.Errorf("%w: github file not found: %s", CategoryNotFound, rawURL)
 			continue
 		}
 		if resp.StatusCode != http.StatusOK {
-			lastErr = fmt.Errorf("failed to fetch github readme: %d", resp.StatusCode)
+			closeBody(resp)
+			lastErr = statusError("fetching github readme", resp.StatusCode)
 			continue
 		}
 
-		content, err := io.ReadAll(resp.Body)
+		content, err := readBody(resp, f.maxSize)
+		closeBody(resp)
+		if errors.Is(err, ErrContentTooLarge) {
+			return "", err
+		}
 		if err != nil {
 			lastErr = fmt.Errorf("failed to read github readme: %w", err)
 			continue
 		}
 
-		return string(content), nil
+		// Relative paths in a README point into the repository
+		return fixGitHubLinks(string(content), blobRoot, rawRoot, dir), nil
 	}
 
 	return "", fmt.Errorf("failed to fetch any readme file: %w", lastErr)
 }
+
+// fetchAPI fetches a file, or the README of a directory if file is empty,
+// through the authenticated GitHub contents API
+func (f *GitHubFetcher) fetchAPI(repo string, ref string, dir string, file string) (string, error) {
+	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/readme", repo)
+	if dir != "" {
+		apiURL += "/" + strings.TrimSuffix(dir, "/")
+	}
+	if file != "" {
+		apiURL = fmt.Sprintf("https://api.github.com/repos/%s/contents/%s%s", repo, dir, file)
+	}
+	if ref != "HEAD" {
+		apiURL += "?ref=" + url.QueryEscape(ref)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, apiURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create github request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+f.token)
+	req.Header.Set("Accept", "application/vnd.github.raw+json")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch github readme: %w", err)
+	}
+	defer closeBody(resp)
+
+	if resp.StatusCode != http.StatusOK {
+		return "", statusError("fetching github readme", resp.StatusCode)
+	}
+
+	content, err := readBody(resp, f.maxSize)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// fixGitHubLinks resolves relative README links against the repository blob
+// view and relative images against the raw file host, keeping anchors. Links
+// starting with a slash are relative to the repository root, like on GitHub.
+func fixGitHubLinks(content string, blobRoot string, rawRoot string, dir string) string {
+	blob, err := url.Parse(blobRoot)
+	if err != nil {
+		return content
+	}
+	raw, err := url.Parse(rawRoot)
+	if err != nil {
+		return content
+	}
+
+	return rewriteLinks(content, func(link string, isImage bool) string {
+		base := blob
+		if isImage {
+			base = raw
+		}
+		if strings.HasPrefix(link, "/") && !strings.HasPrefix(link, "//") {
+			return resolveLink(base, "."+link)
+		}
+		return resolveLink(base.ResolveReference(&url.URL{Path: dir}), link)
+	})
+}
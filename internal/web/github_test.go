@@ -0,0 +1,98 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestGitHubFetcher(t *testing.T) {
+	files := map[string]string{
+		"/example/repo/HEAD/README.md":             "# Repo\n\n[Docs](docs/intro.md)",
+		"/example/repo/v1.2/cmd/tool/README.md":    "# Tool\n\n![Logo](logo.png) [Usage](usage.md)",
+		"/example/repo/main/docs/GUIDE.md":         "# Guide\n\n[Next](next.md)",
+		"/example/repo/main/docs/nested/readme.md": "# Nested",
+		"/example/repo/main/README.md":             "# Main",
+	}
+	var requested []string
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requested = append(requested, req.URL.Path)
+		content, ok := files[req.URL.Path]
+		status := http.StatusOK
+		if !ok || req.URL.Host != "raw.githubusercontent.com" {
+			status = http.StatusNotFound
+		}
+		return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(content)), Request: req}, nil
+	})}
+	fetcher := NewGitHubFetcher(client, DefaultMaxContentSize, "")
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://github.com/example/repo", "# Repo\n\n[Docs](https://github.com/example/repo/blob/HEAD/docs/intro.md)"},
+		{"https://github.com/example/repo/tree/v1.2/cmd/tool",
+			"# Tool\n\n![Logo](https://raw.githubusercontent.com/example/repo/v1.2/cmd/tool/logo.png) [Usage](https://github.com/example/repo/blob/v1.2/cmd/tool/usage.md)"},
+		{"https://github.com/example/repo/blob/main/docs/GUIDE.md", "# Guide\n\n[Next](https://github.com/example/repo/blob/main/docs/next.md)"},
+		{"https://github.com/example/repo/tree/main/docs/nested", "# Nested"},
+		{"https://github.com/example/repo/tree/main", "# Main"},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		got, err := fetcher.Fetch(u)
+		if err != nil {
+			t.Errorf("%s: %v", tt.url, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.url, got, tt.want)
+		}
+	}
+
+	// A missing file names the last URL tried
+	requested = nil
+	u, _ := url.Parse("https://github.com/example/repo/blob/main/MISSING.md")
+	if _, err := fetcher.Fetch(u); err == nil || !strings.Contains(err.Error(), "raw.githubusercontent.com/example/repo/main/MISSING.md") {
+		t.Errorf("got error %v for a missing file", err)
+	}
+	if len(requested) != 1 {
+		t.Errorf("got requests %q for a blob link, want only the named file", requested)
+	}
+}
+
+func TestGitHubFetcherToken(t *testing.T) {
+	var requests []*http.Request
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requests = append(requests, req)
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("# Private\n\n[Docs](docs.md)")), Request: req}, nil
+	})}
+	fetcher := NewGitHubFetcher(client, DefaultMaxContentSize, "secret")
+
+	tests := []struct {
+		url    string
+		apiURL string
+	}{
+		{"https://github.com/example/private", "https://api.github.com/repos/example/private/readme"},
+		{"https://github.com/example/private/tree/v1/cmd", "https://api.github.com/repos/example/private/readme/cmd?ref=v1"},
+		{"https://github.com/example/private/blob/main/docs/GUIDE.md", "https://api.github.com/repos/example/private/contents/docs/GUIDE.md?ref=main"},
+	}
+	for _, tt := range tests {
+		requests = nil
+		u, _ := url.Parse(tt.url)
+		content, err := fetcher.Fetch(u)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.url, err)
+		}
+		if len(requests) != 1 || requests[0].URL.String() != tt.apiURL {
+			t.Fatalf("%s: got requests %v, want %s", tt.url, requests, tt.apiURL)
+		}
+		if got := requests[0].Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("%s: got Authorization %q", tt.url, got)
+		}
+		if !strings.Contains(content, "(https://github.com/example/private/blob/") {
+			t.Errorf("%s: relative link not resolved in %q", tt.url, content)
+		}
+	}
+}
@@ -1,40 +1,63 @@
 package web
 
 import (
+	"errors"
 	"fmt"
-	"io"
-	"log/slog"
 	"net/http"
 	"net/url"
-	"regexp"
 	"strings"
 )
 
 type MarkdownFetcher struct {
 	client  HTTPClient
 	baseURL string
-	cleaner ContentCleaner
+	breaker *CircuitBreaker
+	maxSize int64
 }
 
-func NewMarkdownFetcher(client HTTPClient, baseURL string, cleaner ContentCleaner) *MarkdownFetcher {
+// NewMarkdownFetcher creates a fetcher using the markdown converter service,
+// the optional breaker stops calling the converter while it keeps failing.
+// Responses longer than maxSize bytes are rejected.
+func NewMarkdownFetcher(client HTTPClient, baseURL string, breaker *CircuitBreaker, maxSize int64) *MarkdownFetcher {
 	return &MarkdownFetcher{
 		client:  client,
 		baseURL: baseURL,
-		cleaner: cleaner,
+		breaker: breaker,
+		maxSize: maxSize,
 	}
 }
 
 func (f *MarkdownFetcher) Fetch(u *url.URL) (string, error) {
-	content, err := f.fetchRaw(u)
+	if f.breaker != nil {
+		if err := f.breaker.Allow(); err != nil {
+			return "", err
+		}
+	}
+
+	content, status, err := f.fetchRaw(u)
+	if f.breaker != nil {
+		// Server errors other than an unsupported request mean the
+		// converter is struggling, even when the page is to blame
+		if errors.Is(err, CategoryConverterUnavailable) || (status >= 500 && status != http.StatusNotImplemented) {
+			f.breaker.Failure()
+		} else {
+			f.breaker.Success()
+		}
+	}
 	if err != nil {
 		return "", err
 	}
 
-	return f.clean(content, u)
+	if isBinaryContent(content) {
+		return "", ErrBinaryContent
+	}
+
+	return f.clean(content, u), nil
 }
 
-// fetchRaw gets the raw content from the markdown service
-func (f *MarkdownFetcher) fetchRaw(u *url.URL) (string, error) {
+// fetchRaw gets the raw content from the markdown service, with the status of
+// its response
+func (f *MarkdownFetcher) fetchRaw(u *url.URL) (string, int, error) {
 	// Fetch content
 	encodedURL := fmt.Sprintf("%s/?url=%s&enableDetailedResponse=true",
 		f.baseURL,
@@ -42,39 +65,39 @@ func (f *MarkdownFetcher) fetchRaw(u *url.URL) (string, error) {
 
 	resp, err := f.client.Get(encodedURL)
 	if err != nil {
-		return "", fmt.Errorf("error creating request: %w", err)
+		return "", 0, fmt.Errorf("%w: %w", CategoryConverterUnavailable, err)
 	}
-	defer resp.Body.Close()
+	defer closeBody(resp)
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("error reading response: %w", err)
+	// Only a converter that can't be reached counts as unavailable, other
+	// server errors are about the page it converts
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return "", resp.StatusCode, fmt.Errorf("%w: request failed with status: %d", CategoryConverterUnavailable, resp.StatusCode)
 	}
-
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("request failed with status: %d", resp.StatusCode)
+		return "", resp.StatusCode, statusError("request", resp.StatusCode)
+	}
+
+	body, err := readBody(resp, f.maxSize)
+	if err != nil {
+		return "", resp.StatusCode, err
 	}
 
-	return string(body), nil
+	return string(body), resp.StatusCode, nil
 }
 
 // clean processes the markdown content
-func (f *MarkdownFetcher) clean(content string, u *url.URL) (string, error) {
+func (f *MarkdownFetcher) clean(content string, u *url.URL) string {
 	// Fix relative links
 	baseURL := fmt.Sprintf("%s://%s%s", u.Scheme, u.Host, u.Path)
 	content = fixMarkdownLinks(content, baseURL)
 
-	if f.cleaner != nil {
-		// Clean with LLM if available
-		cleaned, err := f.cleaner.CleanMarkdown(content)
-		if err != nil {
-			slog.Warn("LLM cleaning failed, using original content", "error", err)
-		} else {
-			content = cleaned
-		}
-	}
+	return removeEmptyLines(content)
+}
 
-	// Remove empty lines
+// removeEmptyLines removes blank lines from markdown content
+func removeEmptyLines(content string) string {
 	lines := strings.Split(content, "\n")
 	var cleanLines []string
 	for _, line := range lines {
@@ -83,43 +106,150 @@ func (f *MarkdownFetcher) clean(content string, u *url.URL) (string, error) {
 		}
 	}
 
-	return strings.Join(cleanLines, "\n"), nil
+	return strings.Join(cleanLines, "\n")
 }
 
 // fixMarkdownLinks fixes relative links in markdown content
 func fixMarkdownLinks(content string, baseURL string) string {
-	// Match both markdown links and images, capturing the ! separately
-	re := regexp.MustCompile(`(!)?\[(.*?)\]\((.*?)\)`)
-	return re.ReplaceAllStringFunc(content, func(match string) string {
-		// Extract parts
-		parts := re.FindStringSubmatch(match)
-		if len(parts) != 4 {
-			return match
+	return fixRelativeLinks(content, baseURL, baseURL)
+}
+
+// fixRelativeLinks resolves relative links against linkBase and relative
+// images against imageBase, the way a browser showing the page would
+func fixRelativeLinks(content string, linkBase string, imageBase string) string {
+	links, err := url.Parse(linkBase)
+	if err != nil {
+		return content
+	}
+	images, err := url.Parse(imageBase)
+	if err != nil {
+		return content
+	}
+
+	return rewriteLinks(content, func(link string, isImage bool) string {
+		if isImage {
+			return resolveLink(images, link)
+		}
+		return resolveLink(links, link)
+	})
+}
+
+// resolveLink resolves a relative link against base, keeping anchors,
+// absolute URLs and links that don't parse
+func resolveLink(base *url.URL, link string) string {
+	ref, err := url.Parse(link)
+	if err != nil || link == "" || strings.HasPrefix(link, "#") || ref.Scheme != "" {
+		return link
+	}
+	return base.ResolveReference(ref).String()
+}
+
+// rewriteLinks replaces the target of every markdown link and image outside
+// code with the result of rewrite. Images nested in link text, as in badges,
+// are rewritten as well.
+func rewriteLinks(content string, rewrite func(link string, isImage bool) string) string {
+	lines := strings.Split(content, "\n")
+	fence := ""
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " \t")
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
 		}
+		if marker := codeFence(trimmed); marker != "" {
+			fence = marker
+			continue
+		}
+		lines[i] = rewriteInlineLinks(line, rewrite)
+	}
+	return strings.Join(lines, "\n")
+}
 
-		isImage := parts[1] == "!" // The ! prefix if present
-		text := parts[2]           // Link text
-		link := parts[3]           // The URL
+// codeFence returns the opening fence of a fenced code block line
+func codeFence(line string) string {
+	for _, marker := range []string{"```", "~~~"} {
+		if strings.HasPrefix(line, marker) {
+			return line[:len(line)-len(strings.TrimLeft(line, marker[:1]))]
+		}
+	}
+	return ""
+}
 
-		// Skip data URLs and absolute URLs
-		if strings.HasPrefix(link, "data:") ||
-			strings.HasPrefix(link, "http://") ||
-			strings.HasPrefix(link, "https://") {
-			return match
+func rewriteInlineLinks(text string, rewrite func(link string, isImage bool) string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		// Code spans are copied as they are
+		if text[i] == '`' {
+			ticks := len(text[i:]) - len(strings.TrimLeft(text[i:], "`"))
+			end := strings.Index(text[i+ticks:], text[i:i+ticks])
+			if end < 0 {
+				b.WriteString(text[i : i+ticks])
+				i += ticks
+				continue
+			}
+			end += i + 2*ticks
+			b.WriteString(text[i:end])
+			i = end
+			continue
 		}
 
-		// Reconstruct the link with proper image prefix
-		prefix := ""
+		isImage := strings.HasPrefix(text[i:], "![")
+		open := i
 		if isImage {
-			prefix = "!"
+			open++
+		}
+		if text[open] != '[' {
+			b.WriteByte(text[i])
+			i++
+			continue
 		}
 
-		baseURL = strings.TrimSuffix(baseURL, "/")
+		closeText := matchingBracket(text, open)
+		if closeText < 0 || closeText+1 >= len(text) || text[closeText+1] != '(' {
+			b.WriteByte(text[i])
+			i++
+			continue
+		}
+		closeLink := matchingBracket(text, closeText+1)
+		if closeLink < 0 {
+			b.WriteByte(text[i])
+			i++
+			continue
+		}
 
-		if !strings.HasPrefix(link, "/") {
-			link = "/" + link
+		// Keep a link title after the target
+		link, title, _ := strings.Cut(text[closeText+2:closeLink], " ")
+		if title != "" {
+			title = " " + title
 		}
 
-		return fmt.Sprintf("%s[%s](%s%s)", prefix, text, baseURL, link)
-	})
+		b.WriteString(text[i:open])
+		b.WriteString("[" + rewriteInlineLinks(text[open+1:closeText], rewrite) + "]")
+		b.WriteString("(" + rewrite(link, isImage) + title + ")")
+		i = closeLink + 1
+	}
+	return b.String()
+}
+
+// matchingBracket returns the index of the bracket closing the one at open,
+// or -1 if it is not closed
+func matchingBracket(text string, open int) int {
+	closing := map[byte]byte{'[': ']', '(': ')'}[text[open]]
+	depth := 0
+	for i := open; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case text[open]:
+			depth++
+		case closing:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
 }
@@ -0,0 +1,78 @@
+package web
+
+import (
+	"testing"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/x/testutil"
+)
+
+func TestFixMarkdownLinks(t *testing.T) {
+	tests := []struct {
+		fixture string
+		baseURL string
+	}{
+		{"github-readme", "https://github.com/example/fastjson"},
+		{"news-article", "https://news.example.com/local/2024/03/bike-lanes"},
+		{"recipe", "https://cooking.example.com/recipes/apple-pie"},
+		{"docs-page", "https://docs.example.com/guides/configuration"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.fixture, func(t *testing.T) {
+			content := testutil.Fixture(t, "markdown/"+test.fixture+".md")
+			got := fixMarkdownLinks(string(content), test.baseURL)
+			testutil.Golden(t, "fix-links/"+test.fixture, []byte(got))
+		})
+	}
+}
+
+func TestFixGitHubLinks(t *testing.T) {
+	content := testutil.Fixture(t, "markdown/github-readme.md")
+	got := fixGitHubLinks(string(content),
+		"https://github.com/example/fastjson/blob/HEAD/",
+		"https://raw.githubusercontent.com/example/fastjson/HEAD/", "")
+	testutil.Golden(t, "fix-links/github-readme-repo", []byte(got))
+}
+
+func TestRewriteLinks(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"[a](x.md)", "[a](/x.md)"},
+		{"![a](x.png)", "![a](/x.png!)"},
+		{`[a](x.md "Title")`, `[a](/x.md "Title")`},
+		{"[![a](x.png)](x.md)", "[![a](/x.png!)](/x.md)"},
+		{"[a [b]](x.md)", "[a [b]](/x.md)"},
+		{"`[a](x.md)` and [b](y.md)", "`[a](x.md)` and [b](/y.md)"},
+		{"~~~\n[a](x.md)\n~~~\n[b](y.md)", "~~~\n[a](x.md)\n~~~\n[b](/y.md)"},
+		{"[a] (x.md) [b](", "[a] (x.md) [b]("},
+	}
+
+	for _, test := range tests {
+		got := rewriteLinks(test.content, func(link string, isImage bool) string {
+			if isImage {
+				return "/" + link + "!"
+			}
+			return "/" + link
+		})
+		if got != test.want {
+			t.Errorf("rewriteLinks(%q) = %q, want %q", test.content, got, test.want)
+		}
+	}
+}
+
+func TestFixGitHubLinksSubdirectory(t *testing.T) {
+	content := "[Up](../README.md) [Here](guide.md) [Root](/LICENSE) ![Diagram](/docs/img/flow.png)"
+	got := fixGitHubLinks(content,
+		"https://github.com/example/fastjson/blob/main/",
+		"https://raw.githubusercontent.com/example/fastjson/main/", "docs/api/")
+
+	want := "[Up](https://github.com/example/fastjson/blob/main/docs/README.md) " +
+		"[Here](https://github.com/example/fastjson/blob/main/docs/api/guide.md) " +
+		"[Root](https://github.com/example/fastjson/blob/main/LICENSE) " +
+		"![Diagram](https://raw.githubusercontent.com/example/fastjson/main/docs/img/flow.png)"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
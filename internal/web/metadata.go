@@ -0,0 +1,199 @@
+package web
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/x"
+)
+
+// maxMetadataSize limits how much of a page is read to find its metadata
+const maxMetadataSize = 1 << 20
+
+var (
+	metadataTagRegex  = regexp.MustCompile(`(?is)<(meta|link|a)\s([^>]*)>`)
+	metadataAttrRegex = regexp.MustCompile(`(?s)([a-zA-Z:-]+)\s*=\s*("[^"]*"|'[^']*')`)
+	ccLicenseRegex    = regexp.MustCompile(`creativecommons\.org/(licenses|publicdomain)/([a-z-]+)/([\d.]+)`)
+)
+
+// Metadata holds licensing hints of a page, used to decide what may be republished
+type Metadata struct {
+	// License is an SPDX identifier or license URL
+	License string `json:"license,omitempty"`
+	// NoArchive is set when the page asks not to be archived or indexed
+	NoArchive bool `json:"noarchive,omitempty"`
+}
+
+// MetadataFetcher discovers licensing hints of pages
+type MetadataFetcher struct {
+	client         HTTPClient
+	cache          x.Cache
+	trackingParams []string
+}
+
+// NewMetadataFetcher creates a new metadata fetcher caching metadata under
+// URLs normalized with trackingParams
+func NewMetadataFetcher(client HTTPClient, cache x.Cache, trackingParams []string) *MetadataFetcher {
+	return &MetadataFetcher{client: client, cache: cache, trackingParams: trackingParams}
+}
+
+// metadataKey returns the cache key for metadata of a normalized URL
+func metadataKey(u string) string {
+	return "metadata-" + URLKey(u)
+}
+
+// Fetch returns licensing hints of the page at u
+func (f *MetadataFetcher) Fetch(u string) (Metadata, error) {
+	key := metadataKey(NormalizeURL(u, f.trackingParams))
+	if f.cache != nil {
+		if cached, ok := f.cache.Get(key); ok {
+			var metadata Metadata
+			if err := json.Unmarshal([]byte(cached), &metadata); err == nil {
+				return metadata, nil
+			}
+		}
+	}
+
+	parsedURL, err := url.Parse(u)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	var metadata Metadata
+	switch parsedURL.Host {
+	case "github.com", "www.github.com":
+		metadata, err = f.fetchGitHub(parsedURL)
+	default:
+		metadata, err = f.fetchPage(u)
+	}
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	if f.cache != nil {
+		data, _ := json.Marshal(metadata)
+		if err := f.cache.Set(key, string(data)); err != nil {
+			slog.Warn("failed to cache metadata", "error", err)
+		}
+	}
+	return metadata, nil
+}
+
+// fetchGitHub reads the license of a repository from the GitHub API
+func (f *MetadataFetcher) fetchGitHub(u *url.URL) (Metadata, error) {
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 {
+		return Metadata{}, nil
+	}
+
+	resp, err := f.client.Get(fmt.Sprintf("https://api.github.com/repos/%s/%s", parts[0], parts[1]))
+	if err != nil {
+		return Metadata{}, fmt.Errorf("failed to fetch repository: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Metadata{}, fmt.Errorf("failed to fetch repository: %d", resp.StatusCode)
+	}
+
+	var repo struct {
+		License *struct {
+			SPDXID string `json:"spdx_id"`
+		} `json:"license"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&repo); err != nil {
+		return Metadata{}, fmt.Errorf("failed to decode repository: %w", err)
+	}
+
+	// GitHub reports unrecognized licenses as NOASSERTION
+	if repo.License == nil || repo.License.SPDXID == "NOASSERTION" {
+		return Metadata{}, nil
+	}
+	return Metadata{License: repo.License.SPDXID}, nil
+}
+
+// fetchPage reads license and robots hints from the HTML of a page
+func (f *MetadataFetcher) fetchPage(u string) (Metadata, error) {
+	resp, err := f.client.Get(u)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("failed to fetch page: %w", err)
+	}
+	defer closeBody(resp)
+
+	if resp.StatusCode != http.StatusOK {
+		return Metadata{}, fmt.Errorf("failed to fetch page: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxMetadataSize))
+	if err != nil {
+		return Metadata{}, fmt.Errorf("failed to read page: %w", err)
+	}
+
+	metadata := parseMetadata(string(body))
+	if isNoArchive(resp.Header.Get("X-Robots-Tag")) {
+		metadata.NoArchive = true
+	}
+	return metadata, nil
+}
+
+// parseMetadata finds schema.org, Dublin Core and Creative Commons license
+// markup and robots directives in HTML
+func parseMetadata(html string) Metadata {
+	var metadata Metadata
+	for _, tag := range metadataTagRegex.FindAllStringSubmatch(html, -1) {
+		attrs := make(map[string]string)
+		for _, attr := range metadataAttrRegex.FindAllStringSubmatch(tag[2], -1) {
+			attrs[strings.ToLower(attr[1])] = strings.Trim(attr[2], `"'`)
+		}
+
+		name := strings.ToLower(attrs["name"])
+		rel := strings.Fields(strings.ToLower(attrs["rel"]))
+
+		switch {
+		case name == "robots" || name == "googlebot":
+			if isNoArchive(attrs["content"]) {
+				metadata.NoArchive = true
+			}
+		case metadata.License != "":
+			continue
+		case name == "license" || name == "dc.rights" || name == "dcterms.license":
+			metadata.License = normalizeLicense(attrs["content"])
+		case strings.EqualFold(attrs["itemprop"], "license"):
+			metadata.License = normalizeLicense(cmp.Or(attrs["content"], attrs["href"]))
+		case slices.Contains(rel, "license") || slices.Contains(rel, "cc:license"):
+			metadata.License = normalizeLicense(attrs["href"])
+		}
+	}
+	return metadata
+}
+
+// isNoArchive checks robots directives for noarchive or noindex
+func isNoArchive(directives string) bool {
+	directives = strings.ToLower(directives)
+	return strings.Contains(directives, "noarchive") || strings.Contains(directives, "noindex")
+}
+
+// normalizeLicense shortens Creative Commons license URLs to SPDX identifiers
+func normalizeLicense(license string) string {
+	license = strings.TrimSpace(license)
+	match := ccLicenseRegex.FindStringSubmatch(strings.ToLower(license))
+	if match == nil {
+		return license
+	}
+
+	if match[1] == "publicdomain" {
+		if match[2] == "zero" {
+			return "CC0-" + match[3]
+		}
+		return "CC-PDDC"
+	}
+	return "CC-" + strings.ToUpper(match[2]) + "-" + match[3]
+}
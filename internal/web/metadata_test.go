@@ -0,0 +1,84 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// mapCache is an in-memory x.Cache
+type mapCache map[string]string
+
+func (c mapCache) Get(key string) (string, bool) {
+	content, ok := c[key]
+	return content, ok
+}
+
+func (c mapCache) Set(key string, content string) error {
+	c[key] = content
+	return nil
+}
+
+func TestParseMetadata(t *testing.T) {
+	tests := []struct {
+		html string
+		want Metadata
+	}{
+		{`<link rel="license" href="https://creativecommons.org/licenses/by-sa/4.0/">`, Metadata{License: "CC-BY-SA-4.0"}},
+		{`<meta name="dcterms.license" content="MIT">`, Metadata{License: "MIT"}},
+		{`<a rel="cc:license" href="http://creativecommons.org/publicdomain/zero/1.0/">CC0</a>`, Metadata{License: "CC0-1.0"}},
+		{`<span itemprop="license" content="https://example.com/terms"></span><meta itemprop='license' content='https://example.com/terms'>`, Metadata{License: "https://example.com/terms"}},
+		{`<META NAME="robots" CONTENT="index, noarchive">`, Metadata{NoArchive: true}},
+		{`<meta name="robots" content="index, follow"><p>No license</p>`, Metadata{}},
+		// The first license wins, robots directives are read anywhere
+		{`<meta name="license" content="MIT"><link rel="license" href="https://creativecommons.org/licenses/by/4.0/"><meta name="googlebot" content="noindex">`, Metadata{License: "MIT", NoArchive: true}},
+	}
+
+	for _, tt := range tests {
+		if got := parseMetadata(tt.html); got != tt.want {
+			t.Errorf("parseMetadata(%q) = %+v, want %+v", tt.html, got, tt.want)
+		}
+	}
+}
+
+func TestMetadataFetcher(t *testing.T) {
+	var requests []string
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requests = append(requests, req.URL.String())
+		resp := &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Request: req}
+		switch req.URL.Host {
+		case "api.github.com":
+			resp.Body = io.NopCloser(strings.NewReader(`{"license": {"spdx_id": "Apache-2.0"}}`))
+		default:
+			resp.Header.Set("X-Robots-Tag", "noarchive")
+			resp.Body = io.NopCloser(strings.NewReader(`<html><head><meta name="license" content="MIT"></head></html>`))
+		}
+		return resp, nil
+	})}
+	fetcher := NewMetadataFetcher(client, mapCache{}, nil)
+
+	got, err := fetcher.Fetch("https://github.com/example/repo/tree/main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Metadata{License: "Apache-2.0"}); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	got, err = fetcher.Fetch("https://example.com/post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Metadata{License: "MIT", NoArchive: true}); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// Metadata is cached
+	if _, err := fetcher.Fetch("https://example.com/post"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"https://api.github.com/repos/example/repo", "https://example.com/post"}; strings.Join(requests, " ") != strings.Join(want, " ") {
+		t.Errorf("got requests %q, want %q", requests, want)
+	}
+}
@@ -0,0 +1,86 @@
+package web
+
+import (
+	"net/url"
+	"strings"
+)
+
+// DefaultTrackingParams are query parameters stripped by NormalizeURL. A
+// trailing * matches any parameter with that prefix.
+var DefaultTrackingParams = []string{
+	"utm_*",
+	"fbclid",
+	"gclid",
+	"dclid",
+	"msclkid",
+	"yclid",
+	"igshid",
+	"mc_cid",
+	"mc_eid",
+	"_hsenc",
+	"_hsmi",
+}
+
+// defaultPorts are the ports dropped from URLs of a scheme
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// NormalizeURL returns the form of a URL used for cache keys and screenshots:
+// lowercase scheme and host, no default port, no fragment, no trackingParams
+// query parameters and no trailing slash. Other query parameters keep their
+// order. URLs that don't parse or have no host are returned as they are.
+func NormalizeURL(u string, trackingParams []string) string {
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Host == "" {
+		return u
+	}
+
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	parsed.Host = strings.ToLower(parsed.Host)
+	if port := parsed.Port(); port != "" && port == defaultPorts[parsed.Scheme] {
+		parsed.Host = strings.TrimSuffix(parsed.Host, ":"+port)
+	}
+
+	parsed.Fragment = ""
+	parsed.RawFragment = ""
+
+	if parsed.RawQuery != "" {
+		var kept []string
+		for _, param := range strings.Split(parsed.RawQuery, "&") {
+			name, _, _ := strings.Cut(param, "=")
+			if param != "" && !isTrackingParam(name, trackingParams) {
+				kept = append(kept, param)
+			}
+		}
+		parsed.RawQuery = strings.Join(kept, "&")
+	}
+	parsed.ForceQuery = false
+
+	parsed.Path = strings.TrimRight(parsed.Path, "/")
+	parsed.RawPath = strings.TrimRight(parsed.RawPath, "/")
+
+	return parsed.String()
+}
+
+// isTrackingParam checks whether a query parameter name matches one of
+// trackingParams, ignoring case
+func isTrackingParam(name string, trackingParams []string) bool {
+	if unescaped, err := url.QueryUnescape(name); err == nil {
+		name = unescaped
+	}
+	name = strings.ToLower(name)
+
+	for _, param := range trackingParams {
+		param = strings.ToLower(param)
+		if prefix, ok := strings.CutSuffix(param, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == param {
+			return true
+		}
+	}
+	return false
+}
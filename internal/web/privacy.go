@@ -0,0 +1,111 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ErrHostNotAllowed is returned for requests to hosts outside the allowlist
+var ErrHostNotAllowed = errors.New("host not allowed")
+
+// HostPolicyTransport records every host contacted and blocks requests to
+// hosts outside an optional allowlist before anything is sent
+type HostPolicyTransport struct {
+	transport http.RoundTripper
+	allowed   []string
+
+	mu        sync.Mutex
+	purposes  map[string]string
+	contacted map[string]int
+}
+
+// NewHostPolicyTransport wraps a transport with host recording and an allowlist.
+// An empty allowlist allows all hosts. Allowed hosts also match their subdomains.
+func NewHostPolicyTransport(transport http.RoundTripper, allowed []string) *HostPolicyTransport {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return &HostPolicyTransport{
+		transport: transport,
+		allowed:   allowed,
+		purposes:  make(map[string]string),
+		contacted: make(map[string]int),
+	}
+}
+
+// SetPurpose records why the host of a service URL is contacted, for the report
+func (t *HostPolicyTransport) SetPurpose(serviceURL string, purpose string) {
+	u, err := url.Parse(serviceURL)
+	if err != nil || u.Hostname() == "" {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.purposes[strings.ToLower(u.Hostname())] = purpose
+}
+
+// RoundTrip records the request host and forwards allowed requests
+func (t *HostPolicyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := strings.ToLower(req.URL.Hostname())
+	if !t.isAllowed(host) {
+		return nil, fmt.Errorf("%w: %s", ErrHostNotAllowed, host)
+	}
+
+	t.mu.Lock()
+	t.contacted[host]++
+	t.mu.Unlock()
+
+	return t.transport.RoundTrip(req)
+}
+
+func (t *HostPolicyTransport) isAllowed(host string) bool {
+	if len(t.allowed) == 0 {
+		return true
+	}
+
+	return slices.ContainsFunc(t.allowed, func(allowed string) bool {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		return host == allowed || strings.HasSuffix(host, "."+allowed)
+	})
+}
+
+// Report summarizes contacted hosts grouped by purpose
+func (t *HostPolicyTransport) Report() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	groups := make(map[string][]string)
+	for host, count := range t.contacted {
+		purpose, ok := t.purposes[host]
+		if !ok {
+			purpose = "other"
+		}
+		groups[purpose] = append(groups[purpose], fmt.Sprintf("%s (%d requests)", host, count))
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Third parties contacted:\n")
+	purposes := make([]string, 0, len(groups))
+	for purpose := range groups {
+		purposes = append(purposes, purpose)
+	}
+	slices.Sort(purposes)
+
+	for _, purpose := range purposes {
+		hosts := groups[purpose]
+		slices.Sort(hosts)
+		sb.WriteString(fmt.Sprintf("  %s:\n", purpose))
+		for _, host := range hosts {
+			sb.WriteString(fmt.Sprintf("    %s\n", host))
+		}
+	}
+
+	return sb.String()
+}
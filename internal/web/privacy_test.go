@@ -0,0 +1,36 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHostPolicyTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "Page content")
+	}))
+	defer server.Close()
+
+	policy := NewHostPolicyTransport(server.Client().Transport, []string{"127.0.0.1"})
+	policy.SetPurpose(server.URL, "markdown converter")
+	client := &http.Client{Transport: policy}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if _, err := client.Get("http://blocked.example/"); !errors.Is(err, ErrHostNotAllowed) {
+		t.Errorf("got error %v, want %v", err, ErrHostNotAllowed)
+	}
+
+	report := policy.Report()
+	if !strings.Contains(report, "markdown converter:\n    127.0.0.1 (1 requests)") || strings.Contains(report, "blocked.example") {
+		t.Errorf("unexpected report:\n%s", report)
+	}
+}
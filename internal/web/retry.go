@@ -0,0 +1,44 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// RetryAfterBackoff returns a backoff that waits as long as the server asks via
+// the Retry-After header on 429 and 503 responses, up to maxWait. Other
+// responses use the default exponential backoff.
+func RetryAfterBackoff(maxWait time.Duration) retryablehttp.Backoff {
+	return func(minWait, maxBackoff time.Duration, attemptNum int, resp *http.Response) time.Duration {
+		if resp != nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+			if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				return min(wait, maxWait)
+			}
+		}
+
+		return retryablehttp.DefaultBackoff(minWait, maxBackoff, attemptNum, resp)
+	}
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,104 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+func TestRetryAfterBackoff(t *testing.T) {
+	retryAt := time.Now().Add(time.Minute).UTC()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := http.StatusTooManyRequests
+		switch r.URL.Path {
+		case "/seconds":
+			w.Header().Set("Retry-After", "7")
+		case "/date":
+			w.Header().Set("Retry-After", retryAt.Format(http.TimeFormat))
+		case "/past-date":
+			w.Header().Set("Retry-After", "Wed, 21 Oct 2015 07:28:00 GMT")
+		case "/long":
+			w.Header().Set("Retry-After", "3600")
+		case "/invalid":
+			w.Header().Set("Retry-After", "soon")
+		case "/unavailable":
+			status = http.StatusServiceUnavailable
+			w.Header().Set("Retry-After", "3")
+		case "/server-error":
+			status = http.StatusInternalServerError
+			w.Header().Set("Retry-After", "3")
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	const minWait, maxBackoff, maxWait = time.Second, 30 * time.Second, 10 * time.Minute
+	backoff := RetryAfterBackoff(maxWait)
+	wait := func(path string) (time.Duration, time.Duration) {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return backoff(minWait, maxBackoff, 2, resp), retryablehttp.DefaultBackoff(minWait, maxBackoff, 2, resp)
+	}
+
+	if got, _ := wait("/seconds"); got != 7*time.Second {
+		t.Errorf("got wait %s for Retry-After in seconds, want 7s", got)
+	}
+	// HTTP dates have a resolution of a second
+	if got, _ := wait("/date"); got < 58*time.Second || got > time.Minute {
+		t.Errorf("got wait %s for a Retry-After date a minute ahead", got)
+	}
+	if got, _ := wait("/past-date"); got != 0 {
+		t.Errorf("got wait %s for a Retry-After date in the past, want 0", got)
+	}
+	if got, _ := wait("/long"); got != maxWait {
+		t.Errorf("got wait %s for a long Retry-After, want it capped at %s", got, maxWait)
+	}
+	if got, _ := wait("/unavailable"); got != 3*time.Second {
+		t.Errorf("got wait %s for 503 with Retry-After, want 3s", got)
+	}
+
+	// Without a usable Retry-After the default exponential backoff applies
+	for _, path := range []string{"/missing", "/invalid", "/server-error"} {
+		if got, want := wait(path); got != want {
+			t.Errorf("%s: got wait %s, want the default backoff %s", path, got, want)
+		}
+	}
+}
+
+func TestRetryAfterClient(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := retryablehttp.NewClient()
+	client.Logger = nil
+	client.Backoff = RetryAfterBackoff(50 * time.Millisecond)
+
+	start := time.Now()
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || attempts.Load() != 2 {
+		t.Errorf("got status %d after %d attempts, want 200 after a retry", resp.StatusCode, attempts.Load())
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("retry took %s, want the wait capped", elapsed)
+	}
+}
@@ -4,31 +4,180 @@
 package web
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"path"
 	"strings"
+	"time"
+
+	"github.com/xtruder/ffbookmarks-to-markdown/internal/x"
+)
+
+// Supported gowitness API versions
+const (
+	ScreenshotAPIAuto = "auto"
+	ScreenshotAPIV2   = "v2"
+	ScreenshotAPIV3   = "v3"
 )
 
+// screenshotAPI abstracts differences between gowitness API versions
+type screenshotAPI interface {
+	// gallery returns all screenshot results known to the server
+	gallery() ([]ScreenshotResult, error)
+	// submit requests screenshots for the given URLs
+	submit(urls []string) error
+	// fileName returns the name the server stores the screenshot of a URL under
+	fileName(url string) string
+}
+
 // ScreenshotService handles website screenshots
 type ScreenshotService struct {
 	client  HTTPClient
 	baseURL string
+	api     screenshotAPI
+	// fileNames holds the file names reported by the gallery, keyed by
+	// normalized URL
+	fileNames      map[string]string
+	trackingParams []string
+}
+
+// ScreenshotOptions contains configuration for the screenshot service
+type ScreenshotOptions struct {
+	// Version is the gowitness API version, v2 or v3
+	Version string
+	// Width and Height set the browser viewport, zero uses the server default
+	Width  int
+	Height int
+	// FullPage captures the whole page instead of just the viewport
+	FullPage bool
+	// Auth holds credentials sent with every request to the API
+	Auth ScreenshotAuth
+	// TrackingParams are stripped from URLs before screenshots are taken,
+	// defaults to DefaultTrackingParams
+	TrackingParams []string
+}
+
+// ScreenshotAuth holds credentials for screenshot APIs behind authentication,
+// a bearer token or basic auth. The zero value sends no credentials.
+type ScreenshotAuth struct {
+	Token    string
+	Username string
+	Password string
+}
+
+// authClient sets screenshot API credentials on every request
+type authClient struct {
+	client HTTPClient
+	auth   ScreenshotAuth
+}
+
+// withAuth wraps client to send auth with every request, returning client
+// unchanged when there are no credentials
+func withAuth(client HTTPClient, auth ScreenshotAuth) HTTPClient {
+	if auth == (ScreenshotAuth{}) {
+		return client
+	}
+	return &authClient{client: client, auth: auth}
+}
+
+func (c *authClient) Get(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+func (c *authClient) Post(url, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return c.Do(req)
+}
+
+func (c *authClient) Do(req *http.Request) (*http.Response, error) {
+	if c.auth.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.auth.Token)
+	} else {
+		req.SetBasicAuth(c.auth.Username, c.auth.Password)
+	}
+	return c.client.Do(req)
 }
 
 // NewScreenshotService creates a new screenshot service
-func NewScreenshotService(client HTTPClient, baseURL string) *ScreenshotService {
-	return &ScreenshotService{
-		client:  client,
-		baseURL: baseURL,
+func NewScreenshotService(client HTTPClient, baseURL string, opts ScreenshotOptions) (*ScreenshotService, error) {
+	baseURL, err := x.NormalizeBaseURL(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("screenshot API: %w", err)
 	}
+
+	client = withAuth(client, opts.Auth)
+
+	var api screenshotAPI
+	switch opts.Version {
+	case ScreenshotAPIV2:
+		if opts.Width != 0 || opts.Height != 0 || opts.FullPage {
+			slog.Warn("screenshot dimensions are not supported by gowitness v2 API, ignoring")
+		}
+		api = &screenshotAPIV2{client: client, baseURL: baseURL}
+	case ScreenshotAPIV3:
+		api = &screenshotAPIV3{client: client, baseURL: baseURL, opts: opts}
+	default:
+		return nil, fmt.Errorf("unsupported screenshot API version: %s", opts.Version)
+	}
+
+	trackingParams := opts.TrackingParams
+	if trackingParams == nil {
+		trackingParams = DefaultTrackingParams
+	}
+
+	return &ScreenshotService{
+		client:         client,
+		baseURL:        baseURL,
+		api:            api,
+		trackingParams: trackingParams,
+	}, nil
 }
 
-// ScreenshotRequest represents a batch screenshot request
-type ScreenshotRequest struct {
-	URLs []string `json:"urls"`
+// DetectScreenshotAPIVersion probes the screenshot API to find out which gowitness version it runs
+func DetectScreenshotAPIVersion(client HTTPClient, baseURL string, auth ScreenshotAuth) (string, error) {
+	baseURL, err := x.NormalizeBaseURL(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("screenshot API: %w", err)
+	}
+
+	client = withAuth(client, auth)
+
+	probes := []struct {
+		version string
+		path    string
+	}{
+		{ScreenshotAPIV3, "/api/results/gallery?limit=1"},
+		{ScreenshotAPIV2, "/api/list"},
+	}
+
+	for _, probe := range probes {
+		resp, err := client.Get(baseURL + probe.path)
+		if err != nil {
+			return "", fmt.Errorf("error probing screenshot API: %w", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusOK {
+			slog.Debug("detected screenshot API version", "version", probe.version)
+			return probe.version, nil
+		}
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			return "", fmt.Errorf("screenshot API rejected the credentials with status %d, set -screenshot-token or -screenshot-basic-auth", resp.StatusCode)
+		}
+	}
+
+	return "", fmt.Errorf("could not detect screenshot API version at %s", baseURL)
 }
 
 // ScreenshotResult represents a single screenshot result
@@ -43,68 +192,267 @@ type ScreenshotResult struct {
 	Technologies []string `json:"technologies"`
 }
 
-// ScreenshotGallery represents the gallery response
-type ScreenshotGallery struct {
-	Results []ScreenshotResult `json:"results"`
-}
-
-// GetExistingScreenshots fetches the list of existing screenshots
-func (s *ScreenshotService) GetExistingScreenshots() (map[string]bool, error) {
+// GetScreenshotResults fetches successful screenshot results keyed by
+// normalized URL
+func (s *ScreenshotService) GetScreenshotResults() (map[string]ScreenshotResult, error) {
 	slog.Info("fetching existing screenshots")
 
-	resp, err := s.client.Get(s.baseURL + "/api/results/gallery?limit=10000")
+	results, err := s.api.gallery()
 	if err != nil {
-		return nil, fmt.Errorf("error fetching screenshot gallery: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	var gallery ScreenshotGallery
-	if err := json.NewDecoder(resp.Body).Decode(&gallery); err != nil {
-		return nil, fmt.Errorf("error decoding gallery response: %w", err)
-	}
+	screenshots := s.successful(results)
+	slog.Info("fetched existing screenshots", "count", len(screenshots))
+	return screenshots, nil
+}
 
-	// Create map of successful screenshots
-	screenshots := make(map[string]bool)
-	for _, result := range gallery.Results {
+// successful maps successful screenshot results by normalized URL and
+// remembers their file names. Screenshots taken of URLs before they were
+// normalized are found under the normalized URL too.
+func (s *ScreenshotService) successful(results []ScreenshotResult) map[string]ScreenshotResult {
+	screenshots := make(map[string]ScreenshotResult)
+	s.fileNames = make(map[string]string)
+	for _, result := range results {
 		if !result.Failed {
-			screenshots[result.URL] = true
+			u := s.Normalize(result.URL)
+			screenshots[u] = result
+			if result.FileName != "" {
+				s.fileNames[u] = result.FileName
+			}
 		}
 	}
+	return screenshots
+}
 
-	slog.Info("fetched existing screenshots", "count", len(screenshots))
-	return screenshots, nil
+// Normalize returns the URL screenshots of a URL are taken and looked up by
+func (s *ScreenshotService) Normalize(u string) string {
+	return NormalizeURL(u, s.trackingParams)
 }
 
-// SubmitScreenshots submits URLs for screenshots
-func (s *ScreenshotService) SubmitScreenshots(urls []string) error {
-	slog.Info("submitting screenshot request", "count", len(urls))
+// Polling intervals used while waiting for submitted screenshots
+const (
+	screenshotPollInitial = 2 * time.Second
+	screenshotPollMax     = 30 * time.Second
+)
+
+// WaitForScreenshots polls the gallery with backoff until every URL has a
+// result, successful or failed, or the timeout passes. The gallery is polled
+// once more at the timeout. It returns all successful results keyed by
+// normalized URL, like GetScreenshotResults; URLs still pending at the
+// timeout, or when ctx is canceled, are left out.
+func (s *ScreenshotService) WaitForScreenshots(ctx context.Context, urls []string, timeout time.Duration) (map[string]ScreenshotResult, error) {
+	return s.waitForResults(ctx, urls, timeout, func(ScreenshotResult) bool { return true })
+}
+
+// waitForResults waits like WaitForScreenshots for every URL to have a result
+// that done accepts
+func (s *ScreenshotService) waitForResults(ctx context.Context, urls []string, timeout time.Duration, done func(ScreenshotResult) bool) (map[string]ScreenshotResult, error) {
+	slog.Info("waiting for screenshots", "count", len(urls), "timeout", timeout)
 
-	request := ScreenshotRequest{
-		URLs: urls,
+	deadline := time.Now().Add(timeout)
+	delay := screenshotPollInitial
+	var screenshots map[string]ScreenshotResult
+	var lastErr error
+poll:
+	for {
+		results, err := s.api.gallery()
+		if err != nil {
+			slog.Warn("failed to poll screenshots", "error", err)
+			lastErr = err
+		} else {
+			lastErr = nil
+			screenshots = s.successful(results)
+
+			finished := make(map[string]bool, len(results))
+			for _, result := range results {
+				if done(result) {
+					finished[s.Normalize(result.URL)] = true
+				}
+			}
+			var pending int
+			for _, u := range urls {
+				if !finished[s.Normalize(u)] {
+					pending++
+				}
+			}
+			if pending == 0 {
+				slog.Info("screenshots ready", "count", len(urls))
+				return screenshots, nil
+			}
+			slog.Debug("screenshots pending", "pending", pending)
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			slog.Warn("timed out waiting for screenshots", "timeout", timeout)
+			break
+		}
+		select {
+		case <-ctx.Done():
+			slog.Warn("stopped waiting for screenshots", "error", ctx.Err())
+			break poll
+		case <-time.After(min(delay, remaining)):
+		}
+		delay = min(delay*2, screenshotPollMax)
 	}
 
-	jsonData, err := json.Marshal(request)
+	if screenshots == nil {
+		if lastErr == nil {
+			lastErr = ctx.Err()
+		}
+		return nil, fmt.Errorf("error waiting for screenshots: %w", lastErr)
+	}
+	return screenshots, nil
+}
+
+// Recapture is a screenshot taken again, with the image it replaced
+type Recapture struct {
+	// Previous and Current are the encoded images
+	Previous []byte
+	Current  []byte
+	// FileName is the name the server stores the screenshot under
+	FileName string
+}
+
+// Recapture takes new screenshots of URLs that already have one, waiting up
+// to timeout for them. The server replaces the image of a URL, so the previous
+// one is downloaded first. It returns the images of the URLs captured again,
+// keyed by normalized URL.
+func (s *ScreenshotService) Recapture(ctx context.Context, urls []string, timeout time.Duration) (map[string]Recapture, error) {
+	results, err := s.api.gallery()
 	if err != nil {
-		return fmt.Errorf("error marshaling request: %w", err)
+		return nil, err
+	}
+	previous := s.successful(results)
+
+	recaptures := make(map[string]Recapture)
+	var submitted []string
+	for _, u := range urls {
+		u = s.Normalize(u)
+		if _, ok := previous[u]; !ok {
+			continue
+		}
+		if _, ok := recaptures[u]; ok {
+			continue
+		}
+		image, err := s.fetchImage(u)
+		if err != nil {
+			slog.Warn("failed to download screenshot", "url", u, "error", err)
+			continue
+		}
+		recaptures[u] = Recapture{Previous: image}
+		submitted = append(submitted, u)
+	}
+	if len(submitted) == 0 {
+		return nil, nil
+	}
+
+	if err := s.SubmitScreenshots(submitted); err != nil {
+		return nil, err
 	}
 
-	resp, err := http.Post(s.baseURL+"/api/submit", "application/json", bytes.NewBuffer(jsonData))
+	// Results of the previous captures stay in the gallery, so new ones are
+	// told apart by their ID or probe time
+	current := make(map[string]ScreenshotResult)
+	if _, err := s.waitForResults(ctx, submitted, timeout, func(result ScreenshotResult) bool {
+		u := s.Normalize(result.URL)
+		old, ok := previous[u]
+		if !ok || (result.ID == old.ID && result.ProbedAt == old.ProbedAt) {
+			return false
+		}
+		if !result.Failed {
+			current[u] = result
+		}
+		return true
+	}); err != nil {
+		return nil, err
+	}
+
+	taken := make(map[string]Recapture, len(submitted))
+	for _, u := range submitted {
+		result, ok := current[u]
+		if !ok {
+			continue
+		}
+		if result.FileName != "" {
+			s.fileNames[u] = result.FileName
+		}
+		recapture := recaptures[u]
+		if recapture.Current, err = s.fetchImage(u); err != nil {
+			slog.Warn("failed to download screenshot", "url", u, "error", err)
+			continue
+		}
+		recapture.FileName = path.Base(s.GetScreenshotURL(u))
+		taken[u] = recapture
+	}
+	return taken, nil
+}
+
+// fetchImage downloads the screenshot of a normalized URL
+func (s *ScreenshotService) fetchImage(u string) ([]byte, error) {
+	resp, err := s.client.Get(s.GetScreenshotURL(u))
 	if err != nil {
-		return fmt.Errorf("error submitting screenshot request: %w", err)
+		return nil, fmt.Errorf("failed to fetch screenshot: %w", err)
 	}
-	defer resp.Body.Close()
+	defer closeBody(resp)
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("screenshot submission failed with status: %d", resp.StatusCode)
+		return nil, statusError("fetching screenshot", resp.StatusCode)
+	}
+	return readBody(resp, DefaultMaxContentSize)
+}
+
+// GetExistingScreenshots fetches the set of normalized URLs with a successful
+// screenshot
+func (s *ScreenshotService) GetExistingScreenshots() (map[string]bool, error) {
+	results, err := s.GetScreenshotResults()
+	if err != nil {
+		return nil, err
+	}
+
+	screenshots := make(map[string]bool, len(results))
+	for url := range results {
+		screenshots[url] = true
+	}
+	return screenshots, nil
+}
+
+// SubmitScreenshots submits the normalized form of URLs for screenshots
+func (s *ScreenshotService) SubmitScreenshots(urls []string) error {
+	slog.Info("submitting screenshot request", "count", len(urls))
+
+	var normalized []string
+	seen := make(map[string]bool, len(urls))
+	for _, u := range urls {
+		if u = s.Normalize(u); !seen[u] {
+			seen[u] = true
+			normalized = append(normalized, u)
+		}
+	}
+
+	if err := s.api.submit(normalized); err != nil {
+		return err
 	}
 
 	slog.Debug("screenshot request submitted successfully")
 	return nil
 }
 
-// GetScreenshotURL returns the URL for a screenshot
+// GetScreenshotURL returns the URL for a screenshot, using the file name
+// reported by the gallery and predicting it for URLs not captured yet
 func (s *ScreenshotService) GetScreenshotURL(url string) string {
-	screenshotPath := strings.NewReplacer(
+	url = s.Normalize(url)
+	fileName, ok := s.fileNames[url]
+	if !ok {
+		fileName = s.api.fileName(url)
+	}
+	return fmt.Sprintf("%s/screenshots/%s", s.baseURL, fileName)
+}
+
+// screenshotPath munges a URL the same way gowitness does when naming screenshot files
+func screenshotPath(url string) string {
+	return strings.NewReplacer(
 		"/", "-",
 		":", "-",
 		"?", "-",
@@ -113,5 +461,4 @@ func (s *ScreenshotService) GetScreenshotURL(url string) string {
 		"_", "-",
 		"#", "-",
 	).Replace(url)
-	return fmt.Sprintf("%s/screenshots/%s.jpeg", s.baseURL, screenshotPath)
 }
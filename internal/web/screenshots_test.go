@@ -0,0 +1,339 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// submitRecorder is a gowitness v3 stub recording submitted requests
+func submitRecorder(t *testing.T) (*httptest.Server, *[]map[string]any) {
+	t.Helper()
+
+	var requests []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		requests = append(requests, body)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestScreenshotOptionsV3(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ScreenshotOptions
+		want string
+	}{
+		{"defaults", ScreenshotOptions{}, `{"urls":["https://example.com"]}`},
+		{"viewport", ScreenshotOptions{Width: 1280, Height: 720}, `{"options":{"window_x":1280,"window_y":720},"urls":["https://example.com"]}`},
+		{"full page", ScreenshotOptions{FullPage: true}, `{"options":{"fullpage":true},"urls":["https://example.com"]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := submitRecorder(t)
+			tt.opts.Version = ScreenshotAPIV3
+			service, err := NewScreenshotService(server.Client(), server.URL, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := service.SubmitScreenshots([]string{"https://example.com"}); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(*requests) != 1 {
+				t.Fatalf("got %d submissions, want 1", len(*requests))
+			}
+			got, _ := json.Marshal((*requests)[0])
+			if string(got) != tt.want {
+				t.Errorf("got request %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubmitThroughClient(t *testing.T) {
+	for version, path := range map[string]string{ScreenshotAPIV2: "/api/screenshot", ScreenshotAPIV3: "/api/submit"} {
+		var submitted []string
+		client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			submitted = append(submitted, req.Method+" "+req.URL.String())
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}")), Request: req}, nil
+		})}
+
+		// The host doesn't resolve, so only the injected client reaches it
+		service, err := NewScreenshotService(client, "http://screenshots.invalid", ScreenshotOptions{Version: version})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := service.SubmitScreenshots([]string{"https://example.com"}); err != nil {
+			t.Fatalf("%s: %v", version, err)
+		}
+		if want := "POST http://screenshots.invalid" + path; len(submitted) != 1 || submitted[0] != want {
+			t.Errorf("%s: got requests %q, want %q", version, submitted, want)
+		}
+	}
+}
+
+func TestScreenshotUnsupportedVersion(t *testing.T) {
+	if _, err := NewScreenshotService(http.DefaultClient, "http://screenshots", ScreenshotOptions{Version: "v1"}); err == nil {
+		t.Error("got no error for an unsupported API version")
+	}
+}
+
+func TestGetScreenshotResults(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"results": [
+			{"url": "https://example.com/ok", "response_code": 200, "technologies": ["Go"]},
+			{"url": "https://example.com/broken", "failed": true}
+		]}`))
+	}))
+	defer server.Close()
+
+	service, err := NewScreenshotService(server.Client(), server.URL, ScreenshotOptions{Version: ScreenshotAPIV3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	screenshots, err := service.GetScreenshotResults()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(screenshots) != 1 {
+		t.Fatalf("got %d screenshots, want failed ones left out", len(screenshots))
+	}
+	if got := screenshots["https://example.com/ok"]; got.ResponseCode != 200 || len(got.Technologies) != 1 {
+		t.Errorf("got result %+v, want status and technologies kept", got)
+	}
+
+	// GetExistingScreenshots keeps returning just the set of URLs
+	existing, err := service.GetExistingScreenshots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(existing) != 1 || !existing["https://example.com/ok"] {
+		t.Errorf("got existing screenshots %v, want only the successful URL", existing)
+	}
+}
+
+func TestGetScreenshotURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"results": [
+			{"url": "https://example.com/page?q=1", "file_name": "custom-name.png"}
+		]}`))
+	}))
+	defer server.Close()
+
+	service, err := NewScreenshotService(server.Client(), server.URL, ScreenshotOptions{Version: ScreenshotAPIV3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Before the gallery is read, file names are predicted
+	if got, want := service.GetScreenshotURL("https://example.com/page?q=1"), server.URL+"/screenshots/https---example.com-page-q-1.jpeg"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if _, err := service.GetScreenshotResults(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := service.GetScreenshotURL("https://example.com/page?q=1"), server.URL+"/screenshots/custom-name.png"; got != want {
+		t.Errorf("got %q, want the gallery file name %q", got, want)
+	}
+	if got, want := service.GetScreenshotURL("https://example.com/new"), server.URL+"/screenshots/https---example.com-new.jpeg"; got != want {
+		t.Errorf("got %q, want predicted %q for a URL not in the gallery", got, want)
+	}
+}
+
+// galleryStub answers gallery polls with one result list per poll, repeating
+// the last one
+type galleryStub struct {
+	polls  [][]ScreenshotResult
+	calls  int
+	onPoll func()
+}
+
+func (g *galleryStub) gallery() ([]ScreenshotResult, error) {
+	results := g.polls[min(g.calls, len(g.polls)-1)]
+	g.calls++
+	if g.onPoll != nil {
+		g.onPoll()
+	}
+	return results, nil
+}
+
+func (g *galleryStub) submit(urls []string) error { return nil }
+
+func (g *galleryStub) fileName(url string) string { return "" }
+
+func newStubScreenshotService(api screenshotAPI) *ScreenshotService {
+	return &ScreenshotService{api: api, trackingParams: DefaultTrackingParams}
+}
+
+func TestWaitForScreenshotsPollsAtDeadline(t *testing.T) {
+	// The timeout is shorter than the first poll interval, so the result
+	// only shows up in the poll at the deadline
+	stub := &galleryStub{polls: [][]ScreenshotResult{
+		nil,
+		{{URL: "https://example.com/page", FileName: "page.png"}},
+	}}
+	service := newStubScreenshotService(stub)
+
+	start := time.Now()
+	screenshots, err := service.WaitForScreenshots(context.Background(), []string{"https://example.com/page"}, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stub.calls != 2 {
+		t.Errorf("got %d polls, want 2", stub.calls)
+	}
+	if elapsed := time.Since(start); elapsed >= screenshotPollInitial {
+		t.Errorf("waited %s, past the timeout", elapsed)
+	}
+	if _, ok := screenshots["https://example.com/page"]; !ok {
+		t.Errorf("got %v, want the screenshot of the final poll", screenshots)
+	}
+}
+
+func TestWaitForScreenshotsTimeout(t *testing.T) {
+	stub := &galleryStub{polls: [][]ScreenshotResult{
+		{{URL: "https://example.com/done"}},
+	}}
+	service := newStubScreenshotService(stub)
+
+	screenshots, err := service.WaitForScreenshots(context.Background(), []string{"https://example.com/done", "https://example.com/pending"}, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stub.calls != 2 {
+		t.Errorf("got %d polls, want 2", stub.calls)
+	}
+	if len(screenshots) != 1 {
+		t.Errorf("got %v, want only the finished screenshot", screenshots)
+	}
+}
+
+func TestWaitForScreenshotsCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stub := &galleryStub{polls: [][]ScreenshotResult{{}}, onPoll: cancel}
+	service := newStubScreenshotService(stub)
+
+	start := time.Now()
+	if _, err := service.WaitForScreenshots(ctx, []string{"https://example.com/page"}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if stub.calls != 1 || time.Since(start) >= screenshotPollInitial {
+		t.Errorf("kept waiting for %s after %d polls", time.Since(start), stub.calls)
+	}
+}
+
+func TestRecapture(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/screenshots/old.png":
+			fmt.Fprint(w, "previous")
+		case "/screenshots/new.png":
+			fmt.Fprint(w, "current")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	old := ScreenshotResult{ID: 1, URL: "https://example.com/page", FileName: "old.png"}
+	stub := &galleryStub{polls: [][]ScreenshotResult{
+		{old},
+		{old, {ID: 2, URL: "https://example.com/page", FileName: "new.png"}},
+	}}
+	service := newStubScreenshotService(stub)
+	service.client = server.Client()
+	service.baseURL = server.URL
+
+	// Pages without a previous screenshot have nothing to compare with
+	recaptures, err := service.Recapture(context.Background(), []string{"https://example.com/page?utm_source=feed", "https://example.com/new"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]Recapture{
+		"https://example.com/page": {Previous: []byte("previous"), Current: []byte("current"), FileName: "new.png"},
+	}
+	if !reflect.DeepEqual(recaptures, want) {
+		t.Errorf("got %+v, want %+v", recaptures, want)
+	}
+}
+
+// recordingClient records requests and answers them with an empty JSON object
+type recordingClient struct {
+	requests []*http.Request
+}
+
+func (c *recordingClient) Get(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+func (c *recordingClient) Post(url, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return c.Do(req)
+}
+
+func (c *recordingClient) Do(req *http.Request) (*http.Response, error) {
+	c.requests = append(c.requests, req)
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+}
+
+func TestScreenshotAuth(t *testing.T) {
+	tests := []struct {
+		name string
+		auth ScreenshotAuth
+		want string
+	}{
+		{"none", ScreenshotAuth{}, ""},
+		{"token", ScreenshotAuth{Token: "secret"}, "Bearer secret"},
+		{"basic", ScreenshotAuth{Username: "user", Password: "pass"}, "Basic dXNlcjpwYXNz"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := &recordingClient{}
+			service, err := NewScreenshotService(client, "http://gowitness.local", ScreenshotOptions{Version: ScreenshotAPIV3, Auth: test.auth})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := service.GetScreenshotResults(); err != nil {
+				t.Fatal(err)
+			}
+			if err := service.SubmitScreenshots([]string{"https://example.com/"}); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(client.requests) != 2 {
+				t.Fatalf("got %d requests, want 2", len(client.requests))
+			}
+			for _, req := range client.requests {
+				if got := req.Header.Get("Authorization"); got != test.want {
+					t.Errorf("%s %s: got Authorization %q, want %q", req.Method, req.URL.Path, got, test.want)
+				}
+			}
+		})
+	}
+}
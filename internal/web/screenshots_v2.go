@@ -0,0 +1,104 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// screenshotAPIV2 talks to the gowitness v2 REST API
+type screenshotAPIV2 struct {
+	client  HTTPClient
+	baseURL string
+}
+
+// screenshotRequestV2 represents a single URL screenshot request
+type screenshotRequestV2 struct {
+	URL     string `json:"url"`
+	Oneshot string `json:"oneshot"`
+}
+
+// screenshotURLV2 represents a URL entry returned by the v2 list endpoint
+type screenshotURLV2 struct {
+	ID           int    `json:"ID"`
+	CreatedAt    string `json:"CreatedAt"`
+	URL          string `json:"URL"`
+	ResponseCode int    `json:"ResponseCode"`
+	Title        string `json:"Title"`
+	Filename     string `json:"Filename"`
+	Technologies []struct {
+		Value string `json:"Value"`
+	} `json:"Technologies"`
+}
+
+func (a *screenshotAPIV2) gallery() ([]ScreenshotResult, error) {
+	resp, err := a.client.Get(a.baseURL + "/api/list")
+	if err != nil {
+		return nil, fmt.Errorf("error fetching screenshot list: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var list []screenshotURLV2
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("error decoding list response: %w", err)
+	}
+
+	results := make([]ScreenshotResult, 0, len(list))
+	for _, entry := range list {
+		result := ScreenshotResult{
+			ID:           entry.ID,
+			ProbedAt:     entry.CreatedAt,
+			URL:          entry.URL,
+			ResponseCode: entry.ResponseCode,
+			Title:        entry.Title,
+			FileName:     entry.Filename,
+		}
+		for _, tech := range entry.Technologies {
+			result.Technologies = append(result.Technologies, tech.Value)
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func (a *screenshotAPIV2) submit(urls []string) error {
+	// v2 has no batch endpoint, so submit URLs one by one
+	for _, u := range urls {
+		jsonData, err := json.Marshal(screenshotRequestV2{URL: u, Oneshot: "false"})
+		if err != nil {
+			return fmt.Errorf("error marshaling request: %w", err)
+		}
+
+		resp, err := a.client.Post(a.baseURL+"/api/screenshot", "application/json", bytes.NewBuffer(jsonData))
+		if err != nil {
+			return fmt.Errorf("error submitting screenshot request: %w", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+			return fmt.Errorf("screenshot submission failed with status: %d", resp.StatusCode)
+		}
+	}
+
+	return nil
+}
+
+var (
+	v2Separators = regexp.MustCompile(`[ &_=+:/]`)
+	v2Illegal    = regexp.MustCompile(`[^[:alnum:]-.]`)
+)
+
+// fileName mirrors gowitness v2 SafeFileName naming
+func (a *screenshotAPIV2) fileName(url string) string {
+	name := strings.TrimSpace(strings.ToLower(url))
+	name = v2Separators.ReplaceAllString(name, "-")
+	name = v2Illegal.ReplaceAllString(name, "")
+	for strings.Contains(name, "--") {
+		name = strings.ReplaceAll(name, "--", "-")
+	}
+	return name + ".png"
+}
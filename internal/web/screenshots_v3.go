@@ -0,0 +1,82 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// screenshotAPIV3 talks to the gowitness v3 REST API
+type screenshotAPIV3 struct {
+	client  HTTPClient
+	baseURL string
+	opts    ScreenshotOptions
+}
+
+// screenshotRequestV3 represents a batch screenshot request
+type screenshotRequestV3 struct {
+	URLs    []string                    `json:"urls"`
+	Options *screenshotRequestOptionsV3 `json:"options,omitempty"`
+}
+
+// screenshotRequestOptionsV3 contains optional capture settings
+type screenshotRequestOptionsV3 struct {
+	WindowX  int  `json:"window_x,omitempty"`
+	WindowY  int  `json:"window_y,omitempty"`
+	FullPage bool `json:"fullpage,omitempty"`
+}
+
+// screenshotGalleryV3 represents the gallery response
+type screenshotGalleryV3 struct {
+	Results []ScreenshotResult `json:"results"`
+}
+
+func (a *screenshotAPIV3) gallery() ([]ScreenshotResult, error) {
+	resp, err := a.client.Get(a.baseURL + "/api/results/gallery?limit=10000")
+	if err != nil {
+		return nil, fmt.Errorf("error fetching screenshot gallery: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var gallery screenshotGalleryV3
+	if err := json.NewDecoder(resp.Body).Decode(&gallery); err != nil {
+		return nil, fmt.Errorf("error decoding gallery response: %w", err)
+	}
+
+	return gallery.Results, nil
+}
+
+func (a *screenshotAPIV3) submit(urls []string) error {
+	request := screenshotRequestV3{
+		URLs: urls,
+	}
+	if a.opts.Width != 0 || a.opts.Height != 0 || a.opts.FullPage {
+		request.Options = &screenshotRequestOptionsV3{
+			WindowX:  a.opts.Width,
+			WindowY:  a.opts.Height,
+			FullPage: a.opts.FullPage,
+		}
+	}
+
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("error marshaling request: %w", err)
+	}
+
+	resp, err := a.client.Post(a.baseURL+"/api/submit", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("error submitting screenshot request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("screenshot submission failed with status: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (a *screenshotAPIV3) fileName(url string) string {
+	return screenshotPath(url) + ".jpeg"
+}
@@ -0,0 +1,78 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+// HostLimitTransport limits the number of simultaneous requests to a single host
+type HostLimitTransport struct {
+	transport http.RoundTripper
+	limit     int
+
+	mu   sync.Mutex
+	sems map[string]chan struct{}
+}
+
+// NewHostLimitTransport wraps a transport with a per-host concurrency limit
+func NewHostLimitTransport(transport http.RoundTripper, limit int) *HostLimitTransport {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return &HostLimitTransport{
+		transport: transport,
+		limit:     limit,
+		sems:      make(map[string]chan struct{}),
+	}
+}
+
+// RoundTrip executes a request once a slot for its host is available
+func (t *HostLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.limit <= 0 {
+		return t.transport.RoundTrip(req)
+	}
+
+	sem := t.semaphore(req.URL.Host)
+	select {
+	case sem <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil {
+		<-sem
+		return nil, err
+	}
+
+	// Keep the slot until the body has been consumed
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: func() { <-sem }}
+	return resp, nil
+}
+
+func (t *HostLimitTransport) semaphore(host string) chan struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	sem, ok := t.sems[host]
+	if !ok {
+		sem = make(chan struct{}, t.limit)
+		t.sems[host] = sem
+	}
+	return sem
+}
+
+// releaseBody releases a host slot when the response body is closed
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
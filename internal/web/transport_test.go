@@ -0,0 +1,149 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gatedTransport holds requests to gated hosts until release is closed and
+// tracks the requests per host whose body is not closed yet
+type gatedTransport struct {
+	gated   map[string]bool
+	release chan struct{}
+
+	mu          sync.Mutex
+	inFlight    map[string]int
+	maxInFlight map[string]int
+}
+
+func (t *gatedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	t.mu.Lock()
+	t.inFlight[host]++
+	t.maxInFlight[host] = max(t.maxInFlight[host], t.inFlight[host])
+	t.mu.Unlock()
+
+	if t.gated[host] {
+		<-t.release
+	}
+
+	body := &trackedBody{Reader: strings.NewReader("ok"), done: func() {
+		t.mu.Lock()
+		t.inFlight[host]--
+		t.mu.Unlock()
+	}}
+	return &http.Response{StatusCode: http.StatusOK, Body: body, Request: req}, nil
+}
+
+type trackedBody struct {
+	io.Reader
+	done func()
+}
+
+func (b *trackedBody) Close() error {
+	b.done()
+	return nil
+}
+
+func TestHostLimitTransport(t *testing.T) {
+	const limit, requests = 2, 10
+	gated := &gatedTransport{
+		gated:       map[string]bool{"slow.example.com": true},
+		release:     make(chan struct{}),
+		inFlight:    make(map[string]int),
+		maxInFlight: make(map[string]int),
+	}
+	client := &http.Client{Transport: NewHostLimitTransport(gated, limit)}
+
+	get := func(u string) error {
+		resp, err := client.Get(u)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		_, err = io.ReadAll(resp.Body)
+		return err
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, requests)
+	for range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- get("https://slow.example.com/page")
+		}()
+	}
+
+	// Wait for the slow host to fill its slots
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		gated.mu.Lock()
+		n := gated.inFlight["slow.example.com"]
+		gated.mu.Unlock()
+		if n == limit {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d requests in flight, want %d", n, limit)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Another host is not held up by the slow one
+	done := make(chan error, 1)
+	go func() { done <- get("https://fast.example.com/page") }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request to another host blocked by the slow host")
+	}
+
+	close(gated.release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := gated.maxInFlight["slow.example.com"]; got != limit {
+		t.Errorf("got at most %d requests in flight, want %d", got, limit)
+	}
+	if got := gated.inFlight["slow.example.com"]; got != 0 {
+		t.Errorf("got %d requests still in flight after all bodies were closed", got)
+	}
+}
+
+func TestHostLimitTransportUnlimited(t *testing.T) {
+	gated := &gatedTransport{
+		release:     make(chan struct{}),
+		inFlight:    make(map[string]int),
+		maxInFlight: make(map[string]int),
+	}
+	transport := NewHostLimitTransport(gated, 0)
+
+	var bodies []io.Closer
+	for range 5 {
+		req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bodies = append(bodies, resp.Body)
+	}
+	if got := gated.maxInFlight["example.com"]; got != 5 {
+		t.Errorf("got %d requests in flight without a limit, want 5", got)
+	}
+	for _, body := range bodies {
+		body.Close()
+	}
+}
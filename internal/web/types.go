@@ -1,6 +1,7 @@
 package web
 
 import (
+	"io"
 	"net/http"
 	"net/url"
 )
@@ -10,7 +11,10 @@ type ContentFetcher interface {
 	Fetch(url *url.URL) (string, error)
 }
 
-// HTTPClient defines the interface for making HTTP requests
+// HTTPClient defines the interface for making HTTP requests, Do sending
+// requests that need headers
 type HTTPClient interface {
 	Get(url string) (*http.Response, error)
+	Post(url, contentType string, body io.Reader) (*http.Response, error)
+	Do(req *http.Request) (*http.Response, error)
 }
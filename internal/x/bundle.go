@@ -0,0 +1,292 @@
+package x
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CacheKeyScheme versions how cache keys are derived. Bundles of newer
+// schemes can't be imported since their entries would not be found, entries
+// of older schemes are still looked up as a fallback.
+const CacheKeyScheme = 2
+
+// bundleFormat versions the layout of cache bundles
+const bundleFormat = 1
+
+// Names in cache bundles
+const (
+	bundleManifest = "manifest.json"
+	bundleCacheDir = "cache/"
+)
+
+// BundleManifest describes the entries of a cache bundle
+type BundleManifest struct {
+	Format      int           `json:"format"`
+	ToolVersion string        `json:"tool_version"`
+	KeyScheme   int           `json:"key_scheme"`
+	Created     time.Time     `json:"created"`
+	Entries     []BundleEntry `json:"entries"`
+}
+
+// BundleEntry is a cache entry in a bundle
+type BundleEntry struct {
+	Key     string    `json:"key"`
+	Size    int64     `json:"size"`
+	SHA256  string    `json:"sha256"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// Export writes all cache entries into a gzipped tar bundle at path, with a
+// manifest listing their checksums. It returns the number of entries.
+func (c *FileCache) Export(path string, toolVersion string) (int, error) {
+	dirEntries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	manifest := BundleManifest{
+		Format:      bundleFormat,
+		ToolVersion: toolVersion,
+		KeyScheme:   CacheKeyScheme,
+		Created:     c.now().UTC(),
+	}
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.Type().IsRegular() {
+			continue
+		}
+		entry, err := c.bundleEntry(dirEntry.Name())
+		if err != nil {
+			return 0, err
+		}
+		manifest.Entries = append(manifest.Entries, entry)
+	}
+
+	// Write to a temporary file first, so a failed export leaves no bundle
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create bundle: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := c.writeBundle(tmp, manifest); err != nil {
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, fmt.Errorf("failed to write bundle: %w", err)
+	}
+
+	return len(manifest.Entries), nil
+}
+
+// bundleEntry describes a cache file for the manifest
+func (c *FileCache) bundleEntry(key string) (BundleEntry, error) {
+	f, err := os.Open(filepath.Join(c.dir, key))
+	if err != nil {
+		return BundleEntry{}, fmt.Errorf("failed to read cache entry: %w", err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return BundleEntry{}, fmt.Errorf("failed to read cache entry: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return BundleEntry{}, fmt.Errorf("failed to read cache entry: %w", err)
+	}
+
+	return BundleEntry{
+		Key:     key,
+		Size:    size,
+		SHA256:  hex.EncodeToString(hash.Sum(nil)),
+		ModTime: info.ModTime().UTC(),
+	}, nil
+}
+
+// writeBundle writes the manifest followed by the listed cache files
+func (c *FileCache) writeBundle(w io.Writer, manifest BundleManifest) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := writeTarFile(tw, bundleManifest, manifest.Created, int64(len(data)), bytes.NewReader(data)); err != nil {
+		return err
+	}
+
+	for _, entry := range manifest.Entries {
+		f, err := os.Open(filepath.Join(c.dir, entry.Key))
+		if err != nil {
+			return fmt.Errorf("failed to read cache entry: %w", err)
+		}
+		err = writeTarFile(tw, bundleCacheDir+entry.Key, entry.ModTime, entry.Size, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return nil
+}
+
+// writeTarFile adds a file of size bytes read from r to the bundle
+func writeTarFile(tw *tar.Writer, name string, modTime time.Time, size int64, r io.Reader) error {
+	header := &tar.Header{Name: name, Mode: 0644, Size: size, ModTime: modTime, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if _, err := io.CopyN(tw, r, size); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return nil
+}
+
+// Import merges the entries of a bundle written by Export into the cache,
+// keeping existing entries that are newer than the bundled ones. The whole
+// bundle is unpacked and checked against the manifest before any entry is
+// merged. It returns the manifest and the number of imported entries.
+func (c *FileCache) Import(path string) (BundleManifest, int, error) {
+	staging, err := os.MkdirTemp(filepath.Dir(c.dir), ".import-*")
+	if err != nil {
+		return BundleManifest{}, 0, fmt.Errorf("failed to create import directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	manifest, err := unpackBundle(path, staging)
+	if err != nil {
+		return BundleManifest{}, 0, err
+	}
+	if err := verifyBundle(manifest, staging); err != nil {
+		return manifest, 0, err
+	}
+
+	imported := 0
+	for _, entry := range manifest.Entries {
+		target := filepath.Join(c.dir, entry.Key)
+		if info, err := os.Stat(target); err == nil && !info.ModTime().Before(entry.ModTime) {
+			continue
+		}
+
+		if err := os.Rename(filepath.Join(staging, entry.Key), target); err != nil {
+			return manifest, imported, fmt.Errorf("failed to import cache entry: %w", err)
+		}
+		if err := os.Chtimes(target, entry.ModTime, entry.ModTime); err != nil {
+			return manifest, imported, fmt.Errorf("failed to import cache entry: %w", err)
+		}
+		imported++
+	}
+
+	return manifest, imported, nil
+}
+
+// unpackBundle extracts the cache files of a bundle into dir and returns
+// its manifest
+func unpackBundle(path string, dir string) (BundleManifest, error) {
+	var manifest BundleManifest
+
+	f, err := os.Open(path)
+	if err != nil {
+		return manifest, fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return manifest, fmt.Errorf("corrupt bundle: %w", err)
+	}
+	tr := tar.NewReader(gz)
+
+	found := false
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return manifest, fmt.Errorf("corrupt bundle: %w", err)
+		}
+
+		if header.Name == bundleManifest {
+			if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+				return manifest, fmt.Errorf("corrupt bundle manifest: %w", err)
+			}
+			found = true
+			continue
+		}
+
+		key, ok := strings.CutPrefix(header.Name, bundleCacheDir)
+		if !ok || !validBundleKey(key) {
+			return manifest, fmt.Errorf("corrupt bundle: unexpected file %s", header.Name)
+		}
+		out, err := os.Create(filepath.Join(dir, key))
+		if err != nil {
+			return manifest, fmt.Errorf("failed to unpack bundle: %w", err)
+		}
+		_, err = io.Copy(out, tr)
+		out.Close()
+		if err != nil {
+			return manifest, fmt.Errorf("corrupt bundle: %w", err)
+		}
+	}
+
+	if !found {
+		return manifest, fmt.Errorf("corrupt bundle: no manifest")
+	}
+	return manifest, nil
+}
+
+// verifyBundle checks that the bundle is compatible and all unpacked entries
+// match their checksums
+func verifyBundle(manifest BundleManifest, dir string) error {
+	if manifest.Format != bundleFormat {
+		return fmt.Errorf("unsupported bundle format %d", manifest.Format)
+	}
+	if manifest.KeyScheme > CacheKeyScheme {
+		return fmt.Errorf("bundle uses cache key scheme %d, this version uses %d", manifest.KeyScheme, CacheKeyScheme)
+	}
+
+	for _, entry := range manifest.Entries {
+		if !validBundleKey(entry.Key) {
+			return fmt.Errorf("corrupt bundle: invalid entry key %q", entry.Key)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Key))
+		if err != nil {
+			return fmt.Errorf("corrupt bundle: missing entry %s", entry.Key)
+		}
+		sum := sha256.Sum256(data)
+		if int64(len(data)) != entry.Size || hex.EncodeToString(sum[:]) != entry.SHA256 {
+			return fmt.Errorf("corrupt bundle: checksum mismatch for entry %s", entry.Key)
+		}
+	}
+	return nil
+}
+
+// validBundleKey checks that a bundled cache key names a file directly in
+// the cache directory, so entries can't be written outside of it
+func validBundleKey(key string) bool {
+	return key != "" && key != "." && key != ".." && !strings.ContainsAny(key, `/\`) && key == filepath.Base(key)
+}
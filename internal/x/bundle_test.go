@@ -0,0 +1,141 @@
+package x
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestCache(t *testing.T, dir string) *FileCache {
+	t.Helper()
+	cache, err := NewFileCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cache
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+	source := newTestCache(t, filepath.Join(t.TempDir(), "cache"))
+	for key, content := range map[string]string{"page": "Page content", "raw-page": "Raw content", "kept": "Old content"} {
+		if err := source.Set(key, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bundle := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if n, err := source.Export(bundle, "test"); err != nil || n != 3 {
+		t.Fatalf("exported %d entries: %v", n, err)
+	}
+
+	// Entries newer on the target are kept
+	target := newTestCache(t, filepath.Join(t.TempDir(), "cache"))
+	if err := target.Set("kept", "New content"); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(target.dir, "kept"), future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, n, err := target.Import(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.ToolVersion != "test" || manifest.KeyScheme != CacheKeyScheme || n != 2 {
+		t.Errorf("got manifest %+v and %d imported entries", manifest, n)
+	}
+	if got, _ := target.Get("page"); got != "Page content" {
+		t.Errorf("got imported entry %q", got)
+	}
+	if got, _ := target.Get("kept"); got != "New content" {
+		t.Errorf("newer entry was replaced by %q", got)
+	}
+}
+
+// writeTestBundle writes a bundle with manifest and files named by their
+// path in the archive
+func writeTestBundle(t *testing.T, manifest BundleManifest, files map[string]string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files[bundleManifest] = string(data)
+	for name, content := range files {
+		if err := writeTarFile(tw, name, time.Now(), int64(len(content)), strings.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func bundleEntryOf(key string, content string) BundleEntry {
+	sum := sha256.Sum256([]byte(content))
+	return BundleEntry{Key: key, Size: int64(len(content)), SHA256: hex.EncodeToString(sum[:]), ModTime: time.Now()}
+}
+
+func TestImportRejectsPathTraversal(t *testing.T) {
+	root := t.TempDir()
+	cache := newTestCache(t, filepath.Join(root, "cache"))
+
+	// The manifest points at a file next to the staging directory, which
+	// the import must neither read nor move
+	outside := filepath.Join(root, "secret")
+	if err := os.WriteFile(outside, []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"../secret", "..", "", `..\secret`} {
+		manifest := BundleManifest{Format: bundleFormat, KeyScheme: CacheKeyScheme, Entries: []BundleEntry{bundleEntryOf(key, "secret")}}
+		bundle := writeTestBundle(t, manifest, map[string]string{})
+
+		if _, _, err := cache.Import(bundle); err == nil {
+			t.Errorf("%q: imported an entry outside of the cache", key)
+		}
+	}
+	if _, err := os.Stat(outside); err != nil {
+		t.Error("file outside of the cache was moved")
+	}
+
+	// Archive members outside of the cache directory are rejected too
+	bundle := writeTestBundle(t, BundleManifest{Format: bundleFormat, KeyScheme: CacheKeyScheme}, map[string]string{bundleCacheDir + "../escape": "x"})
+	if _, _, err := cache.Import(bundle); err == nil {
+		t.Error("unpacked a file outside of the staging directory")
+	}
+}
+
+func TestImportRejectsCorruptBundle(t *testing.T) {
+	cache := newTestCache(t, filepath.Join(t.TempDir(), "cache"))
+	entry := bundleEntryOf("page", "Page content")
+	manifest := BundleManifest{Format: bundleFormat, KeyScheme: CacheKeyScheme, Entries: []BundleEntry{entry}}
+	bundle := writeTestBundle(t, manifest, map[string]string{bundleCacheDir + "page": "Tampered content"})
+
+	if _, _, err := cache.Import(bundle); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("got error %v, want a checksum mismatch", err)
+	}
+	if _, ok := cache.Get("page"); ok {
+		t.Error("entry of a corrupt bundle was imported")
+	}
+}
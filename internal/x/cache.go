@@ -1,9 +1,11 @@
 package x
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 type Cache interface {
@@ -14,20 +16,36 @@ type Cache interface {
 // FileCache handles content caching
 type FileCache struct {
 	dir string
+	// ttl is the age after which entries are treated as missing, zero never expires
+	ttl time.Duration
+	now func() time.Time
 }
 
 // NewFileCache creates a new cache instance
 func NewFileCache(cacheDir string) (*FileCache, error) {
+	return NewFileCacheWithTTL(cacheDir, 0)
+}
+
+// NewFileCacheWithTTL creates a new cache instance whose entries expire
+// after ttl, using the modification time of the entry as its write time
+func NewFileCacheWithTTL(cacheDir string, ttl time.Duration) (*FileCache, error) {
 	if err := os.MkdirAll(cacheDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create cache directory: %w", err)
 	}
 
-	return &FileCache{dir: cacheDir}, nil
+	return &FileCache{dir: cacheDir, ttl: ttl, now: time.Now}, nil
 }
 
 // Get retrieves content from cache
 func (c *FileCache) Get(key string) (string, bool) {
 	path := filepath.Join(c.dir, key)
+	if c.ttl > 0 {
+		info, err := os.Stat(path)
+		if err != nil || c.now().Sub(info.ModTime()) > c.ttl {
+			return "", false
+		}
+	}
+
 	content, err := os.ReadFile(path)
 	if err != nil {
 		return "", false
@@ -41,6 +59,31 @@ func (c *FileCache) Set(key string, content string) error {
 	return os.WriteFile(path, []byte(content), 0644)
 }
 
-func (c *FileCache) Clear() error {
-	return os.RemoveAll(c.dir)
+// Delete removes entries from cache, returning the number of removed files
+func (c *FileCache) Delete(keys ...string) (int, error) {
+	removed := 0
+	for _, key := range keys {
+		err := os.Remove(filepath.Join(c.dir, key))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return removed, fmt.Errorf("failed to delete cache entry: %w", err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// Clear removes all entries from cache, returning the number of removed files
+func (c *FileCache) Clear() (int, error) {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 0, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	if err := os.RemoveAll(c.dir); err != nil {
+		return 0, fmt.Errorf("failed to clear cache: %w", err)
+	}
+	return len(entries), nil
 }
@@ -0,0 +1,53 @@
+package x
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFileCacheTTL(t *testing.T) {
+	cache, err := NewFileCacheWithTTL(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Set("page", "content"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, ok := cache.Get("page"); !ok || got != "content" {
+		t.Errorf("got %q, %v for a fresh entry, want a hit", got, ok)
+	}
+
+	now := time.Now()
+	cache.now = func() time.Time { return now.Add(2 * time.Hour) }
+	if got, ok := cache.Get("page"); ok {
+		t.Errorf("got %q for an expired entry, want a miss", got)
+	}
+
+	// Writing the entry again refreshes it
+	cache.now = time.Now
+	if err := cache.Set("page", "refetched"); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := cache.Get("page"); !ok || got != "refetched" {
+		t.Errorf("got %q, %v after refreshing, want a hit", got, ok)
+	}
+}
+
+func TestFileCacheNoTTL(t *testing.T) {
+	cache, err := NewFileCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Set("page", "content"); err != nil {
+		t.Fatal(err)
+	}
+
+	cache.now = func() time.Time { return time.Now().Add(24 * 365 * time.Hour) }
+	if _, ok := cache.Get("page"); !ok {
+		t.Error("got a miss, want entries to never expire without a TTL")
+	}
+	if _, ok := cache.Get("missing"); ok {
+		t.Error("got a hit for a missing entry")
+	}
+}
@@ -0,0 +1,70 @@
+// Package testutil holds helpers shared by the tests of all packages
+package testutil
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// update rewrites golden files with the current output. Only packages using
+// Golden know the flag, so pass it to those: go test ./internal/web -update
+var update = flag.Bool("update", false, "update golden files")
+
+// Golden compares got with testdata/<name>.golden of the package under test,
+// writing the file instead with -update
+func Golden(t testing.TB, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file, run with -update to create it: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s, run with -update to accept it\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// Fixture reads a file of the shared testdata corpus at the module root
+func Fixture(t testing.TB, name string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(moduleRoot(t), "testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// moduleRoot finds the directory of go.mod above the package under test
+func moduleRoot(t testing.TB) string {
+	t.Helper()
+
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			t.Fatal("go.mod not found")
+		}
+		dir = parent
+	}
+}
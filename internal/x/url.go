@@ -0,0 +1,32 @@
+package x
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// NormalizeBaseURL validates a service base URL and strips trailing slashes,
+// so paths can be appended with plain string concatenation
+func NormalizeBaseURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", fmt.Errorf("base URL is empty")
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL %q: %w", raw, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid base URL %q: scheme must be http or https", raw)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("invalid base URL %q: missing host", raw)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("invalid base URL %q: query and fragment are not allowed", raw)
+	}
+
+	return strings.TrimRight(u.String(), "/"), nil
+}
@@ -0,0 +1,22 @@
+package x
+
+import "testing"
+
+func TestNormalizeBaseURL(t *testing.T) {
+	for raw, want := range map[string]string{
+		"http://localhost:3000":       "http://localhost:3000",
+		" https://api.example.com/ ":  "https://api.example.com",
+		"https://example.com/v1beta/": "https://example.com/v1beta",
+	} {
+		got, err := NormalizeBaseURL(raw)
+		if err != nil || got != want {
+			t.Errorf("NormalizeBaseURL(%q) = %q, %v, want %q", raw, got, err, want)
+		}
+	}
+
+	for _, raw := range []string{"", "localhost:3000", "ftp://example.com", "http://", "http://example.com/?q=1", "http://example.com/#top"} {
+		if got, err := NormalizeBaseURL(raw); err == nil {
+			t.Errorf("NormalizeBaseURL(%q) = %q, want an error", raw, got)
+		}
+	}
+}